@@ -52,6 +52,8 @@ const (
 	_inline = "inline"
 	// dao primary key
 	_pk = "pk"
+	// tenant column, see lib/dao/tenant
+	_tenant = "tenant"
 )
 
 type Tag struct {
@@ -999,6 +1001,8 @@ func (g *dao) buildDaoTags(field *generator.FieldDescriptor, autoIncr bool) *Fie
 			if autoIncr {
 				fTag.Values = append(fTag.Values, "autoIncrement")
 			}
+		case _tenant:
+			fTag.Values = append(fTag.Values, "tenant")
 		}
 	}
 
@@ -36,9 +36,12 @@ import (
 
 	"github.com/lack-io/vine"
 	rrvine "github.com/lack-io/vine/cmd/vine/client/resolver/api"
+	"github.com/lack-io/vine/core/broker"
 	ahandler "github.com/lack-io/vine/lib/api/handler"
 	aapi "github.com/lack-io/vine/lib/api/handler/api"
 	"github.com/lack-io/vine/lib/api/handler/event"
+	agraphql "github.com/lack-io/vine/lib/api/handler/graphql"
+	agrpcweb "github.com/lack-io/vine/lib/api/handler/grpcweb"
 	ahttp "github.com/lack-io/vine/lib/api/handler/http"
 	"github.com/lack-io/vine/lib/api/handler/openapi"
 	arpc "github.com/lack-io/vine/lib/api/handler/rpc"
@@ -51,7 +54,9 @@ import (
 	regRouter "github.com/lack-io/vine/lib/api/router/registry"
 	"github.com/lack-io/vine/lib/api/server"
 	httpapi "github.com/lack-io/vine/lib/api/server/http"
+	cehttp "github.com/lack-io/vine/lib/event/http"
 	log "github.com/lack-io/vine/lib/logger"
+	"github.com/lack-io/vine/lib/traffic"
 	"github.com/lack-io/vine/util/helper"
 	"github.com/lack-io/vine/util/namespace"
 	"github.com/lack-io/vine/util/stats"
@@ -112,6 +117,14 @@ func Run(ctx *cli.Context, svcOpts ...vine.Option) {
 	// initialise service
 	svc := vine.NewService(svcOpts...)
 
+	// load the traffic policies of services, they're reloaded on change
+	tr, err := traffic.Load(svc.Options().Config)
+	if err != nil {
+		log.Errorf("loading traffic policies: %v", err)
+		return
+	}
+	defer tr.Stop()
+
 	// Init API
 	var opts []server.Option
 
@@ -197,6 +210,7 @@ func Run(ctx *cli.Context, svcOpts ...vine.Option) {
 			router.WithHandler(arpc.Handler),
 			router.WithResolver(rr),
 			router.WithRegistry(svc.Options().Registry),
+			router.WithTraffic(tr),
 		)
 		rp := arpc.NewHandler(
 			ahandler.WithNamespace(apiNamespace),
@@ -210,6 +224,7 @@ func Run(ctx *cli.Context, svcOpts ...vine.Option) {
 			router.WithHandler(aapi.Handler),
 			router.WithResolver(rr),
 			router.WithRegistry(svc.Options().Registry),
+			router.WithTraffic(tr),
 		)
 		ap := aapi.NewHandler(
 			ahandler.WithNamespace(apiNamespace),
@@ -223,6 +238,7 @@ func Run(ctx *cli.Context, svcOpts ...vine.Option) {
 			router.WithHandler(event.Handler),
 			router.WithResolver(rr),
 			router.WithRegistry(svc.Options().Registry),
+			router.WithTraffic(tr),
 		)
 		ev := event.NewHandler(
 			ahandler.WithNamespace(apiNamespace),
@@ -236,6 +252,7 @@ func Run(ctx *cli.Context, svcOpts ...vine.Option) {
 			router.WithHandler(ahttp.Handler),
 			router.WithResolver(rr),
 			router.WithRegistry(svc.Options().Registry),
+			router.WithTraffic(tr),
 		)
 		ht := ahttp.NewHandler(
 			ahandler.WithNamespace(apiNamespace),
@@ -243,12 +260,35 @@ func Run(ctx *cli.Context, svcOpts ...vine.Option) {
 			ahandler.WithClient(svc.Client()),
 		)
 		app.Group(ProxyPath, ht.Handle)
+	case "grpcweb":
+		log.Infof("Registering API gRPC-Web Handler at %s", APIPath)
+		rt := regRouter.NewRouter(
+			router.WithHandler(agrpcweb.Handler),
+			router.WithResolver(rr),
+			router.WithRegistry(svc.Options().Registry),
+			router.WithTraffic(tr),
+		)
+		gw := agrpcweb.NewHandler(
+			ahandler.WithNamespace(apiNamespace),
+			ahandler.WithRouter(rt),
+			ahandler.WithClient(svc.Client()),
+		)
+		app.Group(APIPath, gw.Handle)
+	case "graphql":
+		log.Infof("Registering API GraphQL Handler at %s", APIPath)
+		gq := agraphql.NewHandler(
+			ahandler.WithNamespace(apiNamespace),
+			ahandler.WithClient(svc.Client()),
+			ahandler.WithRegistry(svc.Options().Registry),
+		)
+		app.Group(APIPath, gq.Handle)
 	case "web":
 		log.Infof("Registering API Web Handler at %s", APIPath)
 		rt := regRouter.NewRouter(
 			router.WithHandler(aweb.Handler),
 			router.WithResolver(rr),
 			router.WithRegistry(svc.Options().Registry),
+			router.WithTraffic(tr),
 		)
 		w := aweb.NewHandler(
 			ahandler.WithNamespace(apiNamespace),
@@ -261,10 +301,29 @@ func Run(ctx *cli.Context, svcOpts ...vine.Option) {
 		rt := regRouter.NewRouter(
 			router.WithResolver(rr),
 			router.WithRegistry(svc.Options().Registry),
+			router.WithTraffic(tr),
 		)
 		app.Group(ProxyPath, handler.Meta(svc, rt, nsResolver.ResolveWithType).Handle)
 	}
 
+	// deliver the messages of topics to the http sinks as CloudEvents
+	sinks, err := eventSinks(ctx)
+	if err != nil {
+		log.Errorf(err.Error())
+		return
+	}
+	if len(sinks) > 0 {
+		svc.Init(vine.AfterStart(func() error {
+			for topic, sink := range sinks {
+				log.Infof("Delivering events of %s to %s", topic, sink)
+				if _, err := sink.Subscribe(svc.Options().Broker, topic, broker.Queue(Name)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}))
+	}
+
 	// create the auth wrapper and the server
 	// TODO: app middleware
 	api := httpapi.NewServer(Address)
@@ -288,6 +347,27 @@ func Run(ctx *cli.Context, svcOpts ...vine.Option) {
 	}
 }
 
+// eventSinks parses the sinks in format topic=url
+func eventSinks(ctx *cli.Context) (map[string]*cehttp.Sink, error) {
+	opts := []cehttp.SinkOption{
+		cehttp.Source(Name),
+		cehttp.Retries(ctx.Int("event-sink-retries")),
+	}
+	if ctx.Bool("event-sink-structured") {
+		opts = append(opts, cehttp.Structured())
+	}
+
+	sinks := make(map[string]*cehttp.Sink)
+	for _, v := range ctx.StringSlice("event-sink") {
+		parts := strings.SplitN(v, "=", 2)
+		if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+			return nil, fmt.Errorf("invalid event sink %s, expected topic=url", v)
+		}
+		sinks[parts[0]] = cehttp.NewSink(parts[1], opts...)
+	}
+	return sinks, nil
+}
+
 func Commands(options ...vine.Option) []*cli.Command {
 	command := &cli.Command{
 		Name:  "api",
@@ -304,7 +384,7 @@ func Commands(options ...vine.Option) []*cli.Command {
 			},
 			&cli.StringFlag{
 				Name:    "handler",
-				Usage:   "Specify the request handler to be used for mapping HTTP requests to services; {api, event, http, rpc}",
+				Usage:   "Specify the request handler to be used for mapping HTTP requests to services; {api, event, graphql, grpcweb, http, rpc}",
 				EnvVars: []string{"VINE_API_HANDLER"},
 			},
 			&cli.StringFlag{
@@ -338,6 +418,22 @@ func Commands(options ...vine.Option) []*cli.Command {
 				EnvVars: []string{"VINE_API_ENABLE_CORS"},
 				Value:   true,
 			},
+			&cli.StringSliceFlag{
+				Name:    "event-sink",
+				Usage:   "Deliver the messages of topic to the http sink as CloudEvents e.g. go.vine.api.order=http://example.com/hook",
+				EnvVars: []string{"VINE_API_EVENT_SINK"},
+			},
+			&cli.BoolFlag{
+				Name:    "event-sink-structured",
+				Usage:   "Deliver the events to sinks in structured mode instead of binary mode",
+				EnvVars: []string{"VINE_API_EVENT_SINK_STRUCTURED"},
+			},
+			&cli.IntFlag{
+				Name:    "event-sink-retries",
+				Usage:   "Set the number of retries of a failed delivery to sinks",
+				EnvVars: []string{"VINE_API_EVENT_SINK_RETRIES"},
+				Value:   3,
+			},
 		},
 	}
 
@@ -30,6 +30,7 @@ import (
 	"github.com/lack-io/vine/lib/api/handler"
 	aapi "github.com/lack-io/vine/lib/api/handler/api"
 	"github.com/lack-io/vine/lib/api/handler/event"
+	agrpcweb "github.com/lack-io/vine/lib/api/handler/grpcweb"
 	ahttp "github.com/lack-io/vine/lib/api/handler/http"
 	arpc "github.com/lack-io/vine/lib/api/handler/rpc"
 	aweb "github.com/lack-io/vine/lib/api/handler/web"
@@ -72,6 +73,9 @@ func (m *metaHandler) Handle(c *fiber.Ctx) error {
 			handler.WithClient(m.c),
 		)
 		return ev.Handle(c)
+	// grpc-web handler
+	case agrpcweb.Handler:
+		return agrpcweb.WithService(service, handler.WithClient(m.c)).Handle(c)
 	// api handler
 	case aapi.Handler:
 		return aapi.WithService(service, handler.WithClient(m.c)).Handle(c)
@@ -37,6 +37,8 @@ import (
 	cliBuild "github.com/lack-io/vine/cmd/vine/app/cli/build"
 	cliMg "github.com/lack-io/vine/cmd/vine/app/cli/mg"
 	cliRun "github.com/lack-io/vine/cmd/vine/app/cli/run"
+	"github.com/lack-io/vine/cmd/vine/app/debug"
+	"github.com/lack-io/vine/cmd/vine/app/job"
 	"github.com/lack-io/vine/lib/cmd"
 	"github.com/lack-io/vine/util/helper"
 )
@@ -305,7 +307,8 @@ func Setup(app *ccli.App, options ...vine.Option) {
 	//app.Commands = append(app.Commands, tunnel.Commands(options...)...)
 	//app.Commands = append(app.Commands, network.Commands(options...)...)
 	//app.Commands = append(app.Commands, registry.Commands(options...)...)
-	//app.Commands = append(app.Commands, debug.Commands(options...)...)
+	app.Commands = append(app.Commands, debug.Commands(options...)...)
+	app.Commands = append(app.Commands, job.Commands(options...)...)
 	//app.Commands = append(app.Commands, server.Commands(options...)...)
 	//app.Commands = append(app.Commands, Commands(options...)...)
 	//app.Commands = append(app.Commands, web.Commands(options...)...)
@@ -0,0 +1,98 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package debug implements the commands reading the debug service of services,
+// the services must be run with the vine.Debug option
+package debug
+
+import (
+	"github.com/lack-io/cli"
+
+	"github.com/lack-io/vine"
+)
+
+// Commands returns the debug commands
+func Commands(options ...vine.Option) []*cli.Command {
+	return []*cli.Command{
+		{
+			Name:      "debug",
+			Usage:     "Query the handlers, options and stats of a service across all of its nodes",
+			ArgsUsage: "<service>",
+			Action:    info,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "node",
+					Usage: "Query the node of the id only",
+				},
+				&cli.StringFlag{
+					Name:  "profile",
+					Usage: "Write the pprof profile of every node to <node>.<profile>.pprof e.g. heap, goroutine, cpu",
+				},
+				&cli.IntFlag{
+					Name:  "seconds",
+					Usage: "Duration of the cpu profile in seconds",
+					Value: 10,
+				},
+				&cli.StringFlag{
+					Name:  "output",
+					Usage: "Set the output format {text, json}",
+					Value: "text",
+				},
+			},
+		},
+		{
+			Name:      "logs",
+			Usage:     "Read the logs of a service across all of its nodes",
+			ArgsUsage: "<service>",
+			Action:    logs,
+			Flags: []cli.Flag{
+				&cli.IntFlag{
+					Name:  "count",
+					Usage: "Number of the recent records of every node, zero returns all",
+					Value: 100,
+				},
+				&cli.BoolFlag{
+					Name:    "follow",
+					Aliases: []string{"f"},
+					Usage:   "Stream the new records",
+				},
+				&cli.DurationFlag{
+					Name:  "since",
+					Usage: "Return the records newer than a relative duration e.g. 5m",
+				},
+				&cli.StringFlag{
+					Name:  "level",
+					Usage: "Return the records at or above the level e.g. warn",
+				},
+				&cli.StringSliceFlag{
+					Name:  "field",
+					Usage: "Return the records with the field value e.g. trace_id=abc",
+				},
+				&cli.StringFlag{
+					Name:  "output",
+					Usage: "Set the output format {text, json}",
+					Value: "text",
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,169 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package debug
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/lack-io/cli"
+
+	"github.com/lack-io/vine/core/client"
+	"github.com/lack-io/vine/lib/cmd"
+	pb "github.com/lack-io/vine/proto/services/debug"
+)
+
+func info(c *cli.Context) error {
+	name := c.Args().First()
+	if len(name) == 0 {
+		return errors.New("require service name")
+	}
+
+	services, err := (*cmd.DefaultOptions().Registry).GetService(name)
+	if err != nil {
+		return err
+	}
+
+	cc := *cmd.DefaultOptions().Client
+	profile := c.String("profile")
+	for _, service := range services {
+		for _, node := range service.Nodes {
+			if id := c.String("node"); len(id) > 0 && id != node.Id {
+				continue
+			}
+
+			if len(profile) > 0 {
+				if err := writeProfile(c, cc, name, node.Id, node.Address); err != nil {
+					fmt.Fprintf(os.Stderr, "error reading the profile of %s: %v\n", node.Id, err)
+				}
+				continue
+			}
+
+			rsp, err := pb.NewDebugService(name, cc).Info(context.Background(), &pb.InfoRequest{}, client.WithAddress(node.Address))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error querying %s: %v\n", node.Id, err)
+				continue
+			}
+			if c.String("output") == "json" {
+				b, _ := json.MarshalIndent(rsp, "", "  ")
+				fmt.Println(string(b))
+				continue
+			}
+			printInfo(rsp)
+		}
+	}
+
+	return nil
+}
+
+func writeProfile(c *cli.Context, cc client.Client, name, id, address string) error {
+	req := &pb.ProfileRequest{Name: c.String("profile"), Seconds: c.Int64("seconds")}
+	timeout := client.DefaultRequestTimeout
+	if req.Name == "cpu" {
+		timeout += time.Duration(req.Seconds) * time.Second
+	}
+
+	rsp, err := pb.NewDebugService(name, cc).Profile(context.Background(), req,
+		client.WithAddress(address), client.WithRequestTimeout(timeout))
+	if err != nil {
+		return err
+	}
+
+	file := fmt.Sprintf("%s.%s.pprof", id, req.Name)
+	if err := ioutil.WriteFile(file, rsp.Data, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("%s written\n", file)
+	return nil
+}
+
+func printInfo(rsp *pb.InfoResponse) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintf(w, "node\t%s\n", rsp.Id)
+	fmt.Fprintf(w, "version\t%s\n", rsp.Version)
+	fmt.Fprintf(w, "address\t%s\n", rsp.Address)
+	fmt.Fprintf(w, "registered\t%v\n", rsp.Registered)
+	if st := rsp.Stats; st != nil {
+		fmt.Fprintf(w, "uptime\t%v\n", time.Duration(st.Uptime)*time.Second)
+		fmt.Fprintf(w, "goroutines\t%d\n", st.Goroutines)
+		fmt.Fprintf(w, "threads\t%d\n", st.Threads)
+		fmt.Fprintf(w, "heap\t%d alloc, %d sys, %d objects\n", st.HeapAlloc, st.HeapSys, st.HeapObjects)
+		fmt.Fprintf(w, "gc\t%d, %v paused\n", st.Gc, time.Duration(st.GcPause))
+	}
+
+	fmt.Fprintln(w, "\nhandlers")
+	for _, h := range rsp.Handlers {
+		for _, e := range h.Endpoints {
+			fmt.Fprintf(w, "  %s\t%s\n", e.Name, internal(h.Internal))
+		}
+	}
+
+	if len(rsp.Subscribers) > 0 {
+		fmt.Fprintln(w, "\nsubscribers")
+		for _, s := range rsp.Subscribers {
+			for _, e := range s.Endpoints {
+				fmt.Fprintf(w, "  %s\t%s\t%s\n", s.Topic, e.Name, internal(s.Internal))
+			}
+		}
+	}
+
+	fmt.Fprintln(w, "\noptions")
+	keys := make([]string, 0, len(rsp.Options))
+	for k := range rsp.Options {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "  %s\t%s\n", k, rsp.Options[k])
+	}
+
+	if len(rsp.Nodes) > 0 {
+		fmt.Fprintln(w, "\nselector nodes")
+		for _, n := range rsp.Nodes {
+			var state []string
+			if n.Ejected {
+				state = append(state, "ejected")
+			}
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%d requests\t%d errors\t%d ejections\t%s\n",
+				n.Service, n.Id, n.Address, n.Requests, n.Errors, n.Ejections, strings.Join(state, ","))
+		}
+	}
+	fmt.Fprintln(w)
+}
+
+func internal(b bool) string {
+	if b {
+		return "internal"
+	}
+	return ""
+}
@@ -0,0 +1,158 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package debug
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lack-io/cli"
+
+	"github.com/lack-io/vine/core/client"
+	"github.com/lack-io/vine/lib/cmd"
+	pb "github.com/lack-io/vine/proto/services/debug"
+)
+
+// nodeRecord is a record of a node
+type nodeRecord struct {
+	Node string `json:"node"`
+	*pb.Record
+}
+
+func logs(c *cli.Context) error {
+	name := c.Args().First()
+	if len(name) == 0 {
+		return errors.New("require service name")
+	}
+
+	req := &pb.LogRequest{
+		Count:  c.Int64("count"),
+		Stream: c.Bool("follow"),
+		Level:  c.String("level"),
+		Fields: make(map[string]string),
+	}
+	if d := c.Duration("since"); d > 0 {
+		req.Since = time.Now().Add(-d).Unix()
+	}
+	for _, f := range c.StringSlice("field") {
+		parts := strings.SplitN(f, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid field %s, expected key=value", f)
+		}
+		req.Fields[parts[0]] = parts[1]
+	}
+
+	services, err := (*cmd.DefaultOptions().Registry).GetService(name)
+	if err != nil {
+		return err
+	}
+
+	cc := *cmd.DefaultOptions().Client
+	opts := []client.CallOption{}
+	if req.Stream {
+		opts = append(opts, client.WithStreamTimeout(0))
+	}
+
+	// fan out to every node and merge the streams
+	records := make(chan *nodeRecord, 128)
+	var wg sync.WaitGroup
+	for _, service := range services {
+		for _, node := range service.Nodes {
+			wg.Add(1)
+			go func(id, address string) {
+				defer wg.Done()
+
+				stream, err := pb.NewDebugService(name, cc).Logs(context.Background(), req, append(opts, client.WithAddress(address))...)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "error reading the logs of %s: %v\n", id, err)
+					return
+				}
+				defer stream.Close()
+
+				for {
+					r, err := stream.Recv()
+					if err != nil {
+						return
+					}
+					records <- &nodeRecord{Node: id, Record: r}
+				}
+			}(node.Id, node.Address)
+		}
+	}
+
+	go func() {
+		wg.Wait()
+		close(records)
+	}()
+
+	output := c.String("output")
+	if req.Stream {
+		for r := range records {
+			printRecord(output, r)
+		}
+		return nil
+	}
+
+	// sort the recent records of all the nodes by time
+	var list []*nodeRecord
+	for r := range records {
+		list = append(list, r)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Timestamp < list[j].Timestamp
+	})
+	for _, r := range list {
+		printRecord(output, r)
+	}
+	return nil
+}
+
+func printRecord(output string, r *nodeRecord) {
+	if output == "json" {
+		b, _ := json.Marshal(r)
+		fmt.Println(string(b))
+		return
+	}
+
+	keys := make([]string, 0, len(r.Metadata))
+	for k := range r.Metadata {
+		if k != "level" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	var metadata string
+	for _, k := range keys {
+		metadata += fmt.Sprintf(" %s=%s", k, r.Metadata[k])
+	}
+
+	t := time.Unix(0, r.Timestamp).Format("2006-01-02 15:04:05.000")
+	fmt.Printf("%s [%s] %s%s %s\n", t, r.Node, r.Metadata["level"], metadata, r.Message)
+}
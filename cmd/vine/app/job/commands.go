@@ -0,0 +1,128 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package job implements the commands managing the jobs of services
+package job
+
+import (
+	"github.com/lack-io/cli"
+
+	"github.com/lack-io/vine"
+)
+
+// Commands returns the job commands
+func Commands(options ...vine.Option) []*cli.Command {
+	return []*cli.Command{
+		{
+			Name:  "job",
+			Usage: "Manage the scheduled jobs of a service",
+			Subcommands: []*cli.Command{
+				{
+					Name:      "list",
+					Usage:     "List the jobs and their last runs",
+					ArgsUsage: "<service>",
+					Action:    list,
+					Flags:     []cli.Flag{outputFlag()},
+				},
+				{
+					Name:      "add",
+					Usage:     "Add a job running the registered handler",
+					ArgsUsage: "<service>",
+					Action:    add,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:     "name",
+							Usage:    "Name of the job",
+							Required: true,
+						},
+						&cli.StringFlag{
+							Name:     "handler",
+							Usage:    "Name of the handler registered by the service",
+							Required: true,
+						},
+						&cli.StringFlag{
+							Name:     "spec",
+							Usage:    "Cron spec with seconds e.g. \"0 */5 * * * *\", \"@every 1m\"",
+							Required: true,
+						},
+						&cli.StringFlag{
+							Name:  "args",
+							Usage: "Arguments passed to the handler",
+						},
+						&cli.BoolFlag{
+							Name:  "paused",
+							Usage: "Add the job paused",
+						},
+						outputFlag(),
+					},
+				},
+				{
+					Name:      "remove",
+					Usage:     "Remove a job",
+					ArgsUsage: "<service> <id>",
+					Action:    remove,
+				},
+				{
+					Name:      "pause",
+					Usage:     "Pause a job",
+					ArgsUsage: "<service> <id>",
+					Action:    pause,
+				},
+				{
+					Name:      "resume",
+					Usage:     "Resume a paused job",
+					ArgsUsage: "<service> <id>",
+					Action:    resume,
+				},
+				{
+					Name:      "trigger",
+					Usage:     "Run a job immediately",
+					ArgsUsage: "<service> <id>",
+					Action:    trigger,
+					Flags:     []cli.Flag{outputFlag()},
+				},
+				{
+					Name:      "history",
+					Usage:     "List the recent runs of a job",
+					ArgsUsage: "<service> <id>",
+					Action:    history,
+					Flags: []cli.Flag{
+						&cli.IntFlag{
+							Name:  "count",
+							Usage: "Number of the recent runs, zero returns all",
+							Value: 20,
+						},
+						outputFlag(),
+					},
+				},
+			},
+		},
+	}
+}
+
+func outputFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "output",
+		Usage: "Set the output format {text, json}",
+		Value: "text",
+	}
+}
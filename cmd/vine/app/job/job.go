@@ -0,0 +1,189 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/lack-io/cli"
+
+	"github.com/lack-io/vine/lib/cmd"
+	pb "github.com/lack-io/vine/proto/services/job"
+)
+
+func service(c *cli.Context) (pb.JobService, error) {
+	name := c.Args().First()
+	if len(name) == 0 {
+		return nil, errors.New("require service name")
+	}
+	return pb.NewJobService(name, *cmd.DefaultOptions().Client), nil
+}
+
+func serviceAndId(c *cli.Context) (pb.JobService, string, error) {
+	js, err := service(c)
+	if err != nil {
+		return nil, "", err
+	}
+	id := c.Args().Get(1)
+	if len(id) == 0 {
+		return nil, "", errors.New("require job id")
+	}
+	return js, id, nil
+}
+
+func printJSON(v interface{}) {
+	b, _ := json.MarshalIndent(v, "", "  ")
+	fmt.Println(string(b))
+}
+
+func timestamp(ns int64) string {
+	if ns == 0 {
+		return "-"
+	}
+	return time.Unix(0, ns).Format(time.RFC3339)
+}
+
+func list(c *cli.Context) error {
+	js, err := service(c)
+	if err != nil {
+		return err
+	}
+	rsp, err := js.List(context.Background(), &pb.ListRequest{})
+	if err != nil {
+		return err
+	}
+	if c.String("output") == "json" {
+		printJSON(rsp.Jobs)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	defer w.Flush()
+	fmt.Fprintln(w, "ID\tNAME\tHANDLER\tSPEC\tPAUSED\tLAST RUN\tRUNS\tFAILURES\tLAST ERROR")
+	for _, j := range rsp.Jobs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%v\t%s\t%d\t%d\t%s\n",
+			j.Id, j.Name, j.Handler, j.Spec, j.Paused, timestamp(j.LastRun), j.Runs, j.Failures, j.LastError)
+	}
+	return nil
+}
+
+func add(c *cli.Context) error {
+	js, err := service(c)
+	if err != nil {
+		return err
+	}
+	req := &pb.AddRequest{Job: &pb.Definition{
+		Name:    c.String("name"),
+		Handler: c.String("handler"),
+		Spec:    c.String("spec"),
+		Args:    []byte(c.String("args")),
+		Paused:  c.Bool("paused"),
+	}}
+	rsp, err := js.Add(context.Background(), req)
+	if err != nil {
+		return err
+	}
+	if c.String("output") == "json" {
+		printJSON(rsp.Job)
+		return nil
+	}
+	fmt.Println(rsp.Job.Id)
+	return nil
+}
+
+func remove(c *cli.Context) error {
+	js, id, err := serviceAndId(c)
+	if err != nil {
+		return err
+	}
+	_, err = js.Remove(context.Background(), &pb.RemoveRequest{Id: id})
+	return err
+}
+
+func pause(c *cli.Context) error {
+	js, id, err := serviceAndId(c)
+	if err != nil {
+		return err
+	}
+	_, err = js.Pause(context.Background(), &pb.PauseRequest{Id: id})
+	return err
+}
+
+func resume(c *cli.Context) error {
+	js, id, err := serviceAndId(c)
+	if err != nil {
+		return err
+	}
+	_, err = js.Resume(context.Background(), &pb.ResumeRequest{Id: id})
+	return err
+}
+
+func trigger(c *cli.Context) error {
+	js, id, err := serviceAndId(c)
+	if err != nil {
+		return err
+	}
+	rsp, err := js.Trigger(context.Background(), &pb.TriggerRequest{Id: id})
+	if err != nil {
+		return err
+	}
+	if c.String("output") == "json" {
+		printJSON(rsp.Run)
+		return nil
+	}
+	printRuns([]*pb.Run{rsp.Run})
+	return nil
+}
+
+func history(c *cli.Context) error {
+	js, id, err := serviceAndId(c)
+	if err != nil {
+		return err
+	}
+	rsp, err := js.History(context.Background(), &pb.HistoryRequest{Id: id, Limit: c.Int64("count")})
+	if err != nil {
+		return err
+	}
+	if c.String("output") == "json" {
+		printJSON(rsp.Runs)
+		return nil
+	}
+	printRuns(rsp.Runs)
+	return nil
+}
+
+func printRuns(runs []*pb.Run) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	defer w.Flush()
+	fmt.Fprintln(w, "STARTED\tDURATION\tNODE\tTRIGGER\tERROR")
+	for _, r := range runs {
+		fmt.Fprintf(w, "%s\t%v\t%s\t%v\t%s\n",
+			timestamp(r.Started), time.Duration(r.Finished-r.Started), r.Node, r.Trigger, r.Error)
+	}
+}
@@ -0,0 +1,87 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package broker
+
+import (
+	"strconv"
+
+	"github.com/lack-io/vine/util/compress"
+)
+
+const (
+	// CompressHeader marks the algorithm which the message body compressed by
+	CompressHeader = "Vine-Content-Encoding"
+	// CompressSizeHeader records the size of the uncompressed body
+	CompressSizeHeader = "Vine-Content-Length"
+)
+
+// CompressMessage returns a copy of message which body is compressed by the named
+// algorithm, the message is returned as is when name is empty or the body is
+// smaller than threshold.
+func CompressMessage(m *Message, name string, threshold int) (*Message, error) {
+	if m == nil || len(name) == 0 || len(m.Body) < threshold {
+		return m, nil
+	}
+	if _, ok := m.Header[CompressHeader]; ok {
+		return m, nil
+	}
+
+	body, err := compress.Compress(name, m.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &Message{
+		Header: make(map[string]string, len(m.Header)+2),
+		Body:   body,
+	}
+	for k, v := range m.Header {
+		msg.Header[k] = v
+	}
+	msg.Header[CompressHeader] = name
+	msg.Header[CompressSizeHeader] = strconv.Itoa(len(m.Body))
+
+	return msg, nil
+}
+
+// DecompressMessage decompresses the message body in place if it's marked as compressed,
+// the decompressed body is limited to compress.DefaultMaxSize
+func DecompressMessage(m *Message) error {
+	if m == nil || m.Header == nil {
+		return nil
+	}
+	name, ok := m.Header[CompressHeader]
+	if !ok {
+		return nil
+	}
+
+	body, err := compress.Decompress(name, m.Body)
+	if err != nil {
+		return err
+	}
+
+	m.Body = body
+	delete(m.Header, CompressHeader)
+	delete(m.Header, CompressSizeHeader)
+	return nil
+}
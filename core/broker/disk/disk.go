@@ -0,0 +1,521 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package disk is an embedded broker which persists the messages in
+// segmented append only logs, a topic is a log and the subscribers of
+// a queue are a consumer group which commits its offset.
+package disk
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/lack-io/vine/core/broker"
+	log "github.com/lack-io/vine/lib/logger"
+	"github.com/lack-io/vine/util/backoff"
+)
+
+type diskBroker struct {
+	opts broker.Options
+
+	sync.RWMutex
+	connected bool
+	dir       string
+	logs      map[string]*topicLog
+	groups    map[string]*group
+	exit      chan bool
+}
+
+func defaultDir() string {
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".vine", "broker")
+	}
+	return filepath.Join(os.TempDir(), "vine", "broker")
+}
+
+func (d *diskBroker) Options() broker.Options {
+	return d.opts
+}
+
+func (d *diskBroker) Address() string {
+	return d.dir
+}
+
+func (d *diskBroker) Connect() error {
+	d.Lock()
+	defer d.Unlock()
+
+	if d.connected {
+		return nil
+	}
+
+	d.dir = defaultDir()
+	if len(d.opts.Addrs) > 0 && len(d.opts.Addrs[0]) > 0 {
+		d.dir = d.opts.Addrs[0]
+	}
+	if v, ok := d.opts.Context.Value(dirKey{}).(string); ok && len(v) > 0 {
+		d.dir = v
+	}
+	if err := os.MkdirAll(d.dir, 0755); err != nil {
+		return err
+	}
+
+	d.exit = make(chan bool)
+	d.connected = true
+
+	go d.retain(d.exit)
+
+	return nil
+}
+
+func (d *diskBroker) Disconnect() error {
+	d.Lock()
+	if !d.connected {
+		d.Unlock()
+		return nil
+	}
+	d.connected = false
+	close(d.exit)
+	groups := d.groups
+	logs := d.logs
+	d.groups = make(map[string]*group)
+	d.logs = make(map[string]*topicLog)
+	d.Unlock()
+
+	for _, g := range groups {
+		g.stop()
+	}
+
+	var gerr error
+	for _, l := range logs {
+		if err := l.close(); err != nil {
+			gerr = err
+		}
+	}
+	return gerr
+}
+
+func (d *diskBroker) Init(opts ...broker.Option) error {
+	for _, o := range opts {
+		o(&d.opts)
+	}
+	return nil
+}
+
+// retain removes the expired segments periodically
+func (d *diskBroker) retain(exit chan bool) {
+	r := retention{age: DefaultRetentionAge}
+	if v, ok := d.opts.Context.Value(retentionKey{}).(retention); ok {
+		r = v
+	}
+
+	t := time.NewTicker(DefaultRetentionInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-exit:
+			return
+		case <-t.C:
+		}
+
+		d.RLock()
+		logs := make([]*topicLog, 0, len(d.logs))
+		for _, l := range d.logs {
+			logs = append(logs, l)
+		}
+		d.RUnlock()
+
+		for _, l := range logs {
+			if err := l.retain(r.size, r.age); err != nil {
+				log.Errorf("[disk]: failed to remove the segments of %s: %v", l.dir, err)
+			}
+		}
+	}
+}
+
+// topicLog opens the log of topic
+func (d *diskBroker) topicLog(topic string) (*topicLog, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	if !d.connected {
+		return nil, errors.New("not connected")
+	}
+
+	if l, ok := d.logs[topic]; ok {
+		return l, nil
+	}
+
+	size := DefaultSegmentSize
+	if v, ok := d.opts.Context.Value(segmentSizeKey{}).(int64); ok && v > 0 {
+		size = v
+	}
+	sync, _ := d.opts.Context.Value(syncKey{}).(bool)
+
+	l, err := openLog(filepath.Join(d.dir, escape(topic)), size, sync)
+	if err != nil {
+		return nil, err
+	}
+	d.logs[topic] = l
+	return l, nil
+}
+
+func (d *diskBroker) Publish(topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	var options broker.PublishOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	msg, err := broker.CompressMessage(msg, options.Compressor, options.CompressThreshold)
+	if err != nil {
+		return err
+	}
+
+	l, err := d.topicLog(topic)
+	if err != nil {
+		return err
+	}
+
+	_, err = l.append(&record{timestamp: time.Now().UnixNano(), message: msg})
+	return err
+}
+
+func (d *diskBroker) Subscribe(topic string, handler broker.Handler, opts ...broker.SubscribeOption) (broker.Subscriber, error) {
+	options := broker.NewSubscribeOptions(opts...)
+
+	l, err := d.topicLog(topic)
+	if err != nil {
+		return nil, err
+	}
+
+	sub := &diskSubscriber{
+		id:      uuid.New().String(),
+		topic:   topic,
+		handler: handler,
+		opts:    options,
+		b:       d,
+	}
+
+	// the subscriber without queue is a group of its own
+	key := topic + "/" + sub.id
+	if len(options.Queue) > 0 {
+		key = topic + "/" + options.Queue
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	if g, ok := d.groups[key]; ok {
+		g.add(sub)
+		sub.group = g
+		return sub, nil
+	}
+
+	g := &group{
+		key:     key,
+		name:    options.Queue,
+		log:     l,
+		b:       d,
+		exit:    make(chan bool),
+		done:    make(chan bool),
+		members: []*diskSubscriber{sub},
+	}
+	if err := g.seek(options); err != nil {
+		return nil, err
+	}
+	sub.group = g
+	d.groups[key] = g
+
+	go g.run()
+
+	return sub, nil
+}
+
+func (d *diskBroker) String() string {
+	return "disk"
+}
+
+// group delivers the records of log to one of its members in order
+type group struct {
+	key  string
+	name string
+	log  *topicLog
+	b    *diskBroker
+	c    *cursor
+
+	sync.Mutex
+	members []*diskSubscriber
+	rr      int
+	// committed is the offset of the next record to deliver
+	committed int64
+
+	once sync.Once
+	exit chan bool
+	done chan bool
+}
+
+// seek moves the cursor of group to the replay position, the committed
+// offset or the end of log
+func (g *group) seek(opts broker.SubscribeOptions) error {
+	g.c = &cursor{l: g.log}
+
+	if opts.Context != nil {
+		if v, ok := opts.Context.Value(offsetKey{}).(int64); ok {
+			return g.c.seek(v)
+		}
+		if v, ok := opts.Context.Value(sinceKey{}).(time.Time); ok {
+			return g.c.seekTime(v)
+		}
+	}
+
+	if len(g.name) > 0 {
+		if v, ok := g.log.offset(g.name); ok {
+			return g.c.seek(v)
+		}
+	}
+
+	_, next := g.log.bounds()
+	return g.c.seek(next)
+}
+
+func (g *group) add(sub *diskSubscriber) {
+	g.Lock()
+	g.members = append(g.members, sub)
+	g.Unlock()
+}
+
+// remove removes the member and returns whether the group is empty
+func (g *group) remove(sub *diskSubscriber) bool {
+	g.Lock()
+	defer g.Unlock()
+	members := g.members[:0]
+	for _, m := range g.members {
+		if m != sub {
+			members = append(members, m)
+		}
+	}
+	g.members = members
+	return len(g.members) == 0
+}
+
+// pick returns the next member by round robin
+func (g *group) pick() *diskSubscriber {
+	g.Lock()
+	defer g.Unlock()
+	if len(g.members) == 0 {
+		return nil
+	}
+	g.rr = (g.rr + 1) % len(g.members)
+	return g.members[g.rr]
+}
+
+func (g *group) run() {
+	defer close(g.done)
+
+	g.committed = g.c.offset
+	if len(g.name) > 0 {
+		go g.commitLoop()
+	}
+
+	for {
+		r, err := g.c.next(g.exit)
+		if err == errClosed {
+			return
+		} else if err != nil {
+			log.Errorf("[disk]: failed to read %s: %v", g.key, err)
+			select {
+			case <-g.exit:
+				return
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+
+		if !g.deliver(r) {
+			return
+		}
+
+		g.Lock()
+		g.committed = r.offset + 1
+		g.Unlock()
+	}
+}
+
+// deliver calls the handler of member until the record is acked, it
+// returns false when the group is stopped
+func (g *group) deliver(r *record) bool {
+	for i := 0; ; i++ {
+		sub := g.pick()
+		if sub == nil {
+			return false
+		}
+
+		// every delivery gets its own copy of message
+		msg := &broker.Message{Header: make(map[string]string, len(r.message.Header)), Body: r.message.Body}
+		for k, v := range r.message.Header {
+			msg.Header[k] = v
+		}
+		e := &diskEvent{topic: sub.topic, message: msg}
+
+		err := sub.handler(e)
+		if err == nil && (sub.opts.AutoAck || e.acked) {
+			return true
+		}
+		if err != nil {
+			e.err = err
+			if eh := g.b.opts.ErrorHandler; eh != nil {
+				eh(e)
+				return true
+			}
+		}
+
+		// redeliver the record after backoff
+		select {
+		case <-g.exit:
+			return false
+		case <-time.After(backoff.Do(i + 1)):
+		}
+	}
+}
+
+// commitLoop saves the offset of group periodically
+func (g *group) commitLoop() {
+	t := time.NewTicker(DefaultCommitInterval)
+	defer t.Stop()
+
+	var saved int64 = -1
+	for {
+		select {
+		case <-g.done:
+			return
+		case <-t.C:
+		}
+
+		g.Lock()
+		offset := g.committed
+		g.Unlock()
+
+		if offset == saved {
+			continue
+		}
+		if err := g.log.commit(g.name, offset); err != nil {
+			log.Errorf("[disk]: failed to commit %s: %v", g.key, err)
+			continue
+		}
+		saved = offset
+	}
+}
+
+// stop stops the delivery and saves the offset of group
+func (g *group) stop() {
+	g.once.Do(func() {
+		close(g.exit)
+	})
+	<-g.done
+
+	if len(g.name) > 0 {
+		g.Lock()
+		offset := g.committed
+		g.Unlock()
+		if err := g.log.commit(g.name, offset); err != nil {
+			log.Errorf("[disk]: failed to commit %s: %v", g.key, err)
+		}
+	}
+}
+
+type diskEvent struct {
+	topic   string
+	err     error
+	acked   bool
+	message *broker.Message
+}
+
+func (e *diskEvent) Topic() string {
+	return e.topic
+}
+
+func (e *diskEvent) Message() *broker.Message {
+	return e.message
+}
+
+func (e *diskEvent) Ack() error {
+	e.acked = true
+	return nil
+}
+
+func (e *diskEvent) Error() error {
+	return e.err
+}
+
+type diskSubscriber struct {
+	id      string
+	topic   string
+	handler broker.Handler
+	opts    broker.SubscribeOptions
+	b       *diskBroker
+	group   *group
+}
+
+func (s *diskSubscriber) Options() broker.SubscribeOptions {
+	return s.opts
+}
+
+func (s *diskSubscriber) Topic() string {
+	return s.topic
+}
+
+func (s *diskSubscriber) Unsubscribe() error {
+	g := s.group
+
+	s.b.Lock()
+	empty := g.remove(s)
+	if empty && s.b.groups[g.key] == g {
+		delete(s.b.groups, g.key)
+	}
+	s.b.Unlock()
+
+	if empty {
+		g.stop()
+	}
+	return nil
+}
+
+func NewBroker(opts ...broker.Option) broker.Broker {
+	options := broker.Options{Context: context.Background()}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return &diskBroker{
+		opts:   options,
+		logs:   make(map[string]*topicLog),
+		groups: make(map[string]*group),
+	}
+}
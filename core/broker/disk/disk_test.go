@@ -0,0 +1,280 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package disk
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lack-io/vine/core/broker"
+)
+
+// collect returns a handler which sends the ids of messages to the channel
+func collect(ch chan string) broker.Handler {
+	return func(e broker.Event) error {
+		ch <- e.Message().Header["id"]
+		return nil
+	}
+}
+
+func publish(t *testing.T, b broker.Broker, topic string, from, to int) {
+	for i := from; i < to; i++ {
+		msg := &broker.Message{
+			Header: map[string]string{"id": fmt.Sprintf("%d", i)},
+			Body:   []byte("hello world"),
+		}
+		if err := b.Publish(topic, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func expect(t *testing.T, ch chan string, from, to int) {
+	for i := from; i < to; i++ {
+		select {
+		case id := <-ch:
+			if id != fmt.Sprintf("%d", i) {
+				t.Fatalf("expected message %d, got %s", i, id)
+			}
+		case <-time.After(time.Second * 2):
+			t.Fatalf("timeout waiting for message %d", i)
+		}
+	}
+}
+
+func TestDiskBroker(t *testing.T) {
+	dir := t.TempDir()
+
+	b := NewBroker(Dir(dir))
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	publish(t, b, "test", 0, 10)
+
+	// the queue replays the topic from the first offset
+	ch := make(chan string, 20)
+	sub, err := b.Subscribe("test", collect(ch), broker.Queue("q"), Offset(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, ch, 0, 10)
+	if err := sub.Unsubscribe(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Disconnect(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the messages survive the restart and the queue resumes from the committed offset
+	b = NewBroker(Dir(dir))
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Disconnect()
+	publish(t, b, "test", 10, 15)
+
+	if _, err := b.Subscribe("test", collect(ch), broker.Queue("q")); err != nil {
+		t.Fatal(err)
+	}
+	expect(t, ch, 10, 15)
+
+	// the subscriber without queue starts at the end of topic
+	all := make(chan string, 20)
+	if _, err := b.Subscribe("test", collect(all)); err != nil {
+		t.Fatal(err)
+	}
+	publish(t, b, "test", 15, 20)
+	expect(t, ch, 15, 20)
+	expect(t, all, 15, 20)
+}
+
+func TestConsumerGroup(t *testing.T) {
+	b := NewBroker(Dir(t.TempDir()))
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Disconnect()
+
+	var mu sync.Mutex
+	count := map[string]int{}
+	ch := make(chan string, 20)
+	for i := 0; i < 2; i++ {
+		name := fmt.Sprintf("member-%d", i)
+		_, err := b.Subscribe("test", func(e broker.Event) error {
+			mu.Lock()
+			count[name]++
+			mu.Unlock()
+			ch <- e.Message().Header["id"]
+			return nil
+		}, broker.Queue("q"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	publish(t, b, "test", 0, 10)
+	expect(t, ch, 0, 10)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if count["member-0"]+count["member-1"] != 10 || count["member-0"] == 0 || count["member-1"] == 0 {
+		t.Fatalf("the messages are not shared by the group: %v", count)
+	}
+}
+
+func TestRedeliver(t *testing.T) {
+	b := NewBroker(Dir(t.TempDir()))
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Disconnect()
+
+	ch := make(chan string, 20)
+	attempts := 0
+	_, err := b.Subscribe("test", func(e broker.Event) error {
+		if attempts++; attempts == 1 {
+			return errors.New("unavailable")
+		}
+		ch <- e.Message().Header["id"]
+		return nil
+	}, broker.Queue("q"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publish(t, b, "test", 0, 2)
+	expect(t, ch, 0, 2)
+}
+
+func TestReplaySince(t *testing.T) {
+	b := NewBroker(Dir(t.TempDir()))
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Disconnect()
+
+	publish(t, b, "test", 0, 5)
+	time.Sleep(time.Millisecond * 10)
+	since := time.Now()
+	publish(t, b, "test", 5, 10)
+
+	ch := make(chan string, 20)
+	if _, err := b.Subscribe("test", collect(ch), Since(since)); err != nil {
+		t.Fatal(err)
+	}
+	expect(t, ch, 5, 10)
+}
+
+func TestRetention(t *testing.T) {
+	dir := t.TempDir()
+	l, err := openLog(dir, 256, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.close()
+
+	for i := 0; i < 50; i++ {
+		msg := &broker.Message{Header: map[string]string{"id": fmt.Sprintf("%d", i)}, Body: []byte("hello world")}
+		if _, err := l.append(&record{timestamp: time.Now().UnixNano(), message: msg}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(l.segments) < 3 {
+		t.Fatalf("expected the log rolled, got %d segments", len(l.segments))
+	}
+
+	// a cursor on the removed segment moves to the first record
+	c := &cursor{l: l}
+	if err := c.seek(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.retain(512, 0); err != nil {
+		t.Fatal(err)
+	}
+	first, next := l.bounds()
+	if first == 0 || next != 50 {
+		t.Fatalf("unexpected bounds %d-%d", first, next)
+	}
+	r, err := c.next(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.offset != first {
+		t.Fatalf("expected offset %d, got %d", first, r.offset)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	dir := t.TempDir()
+	l, err := openLog(dir, DefaultSegmentSize, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		msg := &broker.Message{Header: map[string]string{}, Body: []byte("hello world")}
+		if _, err := l.append(&record{timestamp: time.Now().UnixNano(), message: msg}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.close()
+
+	// a partial record is written before the crash
+	f, err := os.OpenFile(filepath.Join(dir, fmt.Sprintf("%020d%s", 0, segmentExt)), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{0, 0, 0, 0, 0, 0, 0, 3, 1, 2})
+	f.Close()
+
+	l, err = openLog(dir, DefaultSegmentSize, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.close()
+
+	msg := &broker.Message{Header: map[string]string{"id": "3"}, Body: []byte("hello world")}
+	offset, err := l.append(&record{timestamp: time.Now().UnixNano(), message: msg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset != 3 {
+		t.Fatalf("expected offset 3, got %d", offset)
+	}
+
+	c := &cursor{l: l}
+	if err := c.seek(3); err != nil {
+		t.Fatal(err)
+	}
+	r, err := c.next(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.message.Header["id"] != "3" {
+		t.Fatalf("unexpected message %v", r.message)
+	}
+}
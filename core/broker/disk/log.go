@@ -0,0 +1,451 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package disk
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	segmentExt = ".log"
+	groupsDir  = "groups"
+)
+
+var (
+	errClosed = errors.New("log closed")
+)
+
+// segment is an append only file of records, it's named by the offset of
+// its first record
+type segment struct {
+	base int64
+	// next is the offset of the next record
+	next int64
+	size int64
+	// timestamps of the first and the last record
+	first int64
+	last  int64
+	path  string
+	f     *os.File
+}
+
+// topicLog is the segmented log of topic, the last segment is active
+type topicLog struct {
+	sync.RWMutex
+	dir         string
+	segmentSize int64
+	sync        bool
+	segments    []*segment
+	// notify is closed when a record is appended
+	notify chan struct{}
+}
+
+// escape returns the file name of topic or group
+func escape(name string) string {
+	name = url.PathEscape(name)
+	if strings.HasPrefix(name, ".") {
+		name = "%2E" + name[1:]
+	}
+	return name
+}
+
+func segmentPath(dir string, base int64) string {
+	return filepath.Join(dir, fmt.Sprintf("%020d%s", base, segmentExt))
+}
+
+// openLog opens the log in dir, the tail of active segment is recovered
+func openLog(dir string, segmentSize int64, sync bool) (*topicLog, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &topicLog{
+		dir:         dir,
+		segmentSize: segmentSize,
+		sync:        sync,
+		notify:      make(chan struct{}),
+	}
+
+	for _, fi := range files {
+		if fi.IsDir() || !strings.HasSuffix(fi.Name(), segmentExt) {
+			continue
+		}
+		base, err := strconv.ParseInt(strings.TrimSuffix(fi.Name(), segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		s, err := openSegment(segmentPath(dir, base), base)
+		if err != nil {
+			l.close()
+			return nil, err
+		}
+		s.size = fi.Size()
+		s.last = fi.ModTime().UnixNano()
+		l.segments = append(l.segments, s)
+	}
+
+	sort.Slice(l.segments, func(i, j int) bool {
+		return l.segments[i].base < l.segments[j].base
+	})
+
+	if len(l.segments) == 0 {
+		s, err := openSegment(segmentPath(dir, 0), 0)
+		if err != nil {
+			return nil, err
+		}
+		l.segments = append(l.segments, s)
+		return l, nil
+	}
+
+	// the sealed segments end where the next ones begin
+	for i, s := range l.segments[:len(l.segments)-1] {
+		s.next = l.segments[i+1].base
+		if r, err := readRecord(s.f, 0); err == nil {
+			s.first = r.timestamp
+		}
+	}
+
+	if err := l.recover(l.segments[len(l.segments)-1]); err != nil {
+		l.close()
+		return nil, err
+	}
+
+	return l, nil
+}
+
+func openSegment(path string, base int64) (*segment, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &segment{base: base, next: base, path: path, f: f}, nil
+}
+
+// recover scans the active segment, the partial record which is written
+// before a crash is truncated
+func (l *topicLog) recover(s *segment) error {
+	var pos int64
+	s.next = s.base
+	for pos < s.size {
+		r, err := readRecord(s.f, pos)
+		if err == errCorrupt {
+			break
+		} else if err != nil {
+			return err
+		}
+		if s.first == 0 {
+			s.first = r.timestamp
+		}
+		s.last = r.timestamp
+		s.next = r.offset + 1
+		pos += r.size()
+	}
+
+	if pos < s.size {
+		if err := s.f.Truncate(pos); err != nil {
+			return err
+		}
+		s.size = pos
+	}
+	_, err := s.f.Seek(pos, 0)
+	return err
+}
+
+// append writes the record of message and returns its offset
+func (l *topicLog) append(r *record) (int64, error) {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.segments == nil {
+		return 0, errClosed
+	}
+
+	s := l.segments[len(l.segments)-1]
+	r.offset = s.next
+	b := r.encode()
+
+	// roll the segment when it's full
+	if s.size > 0 && s.size+int64(len(b)) > l.segmentSize {
+		if err := s.f.Sync(); err != nil {
+			return 0, err
+		}
+		ns, err := openSegment(segmentPath(l.dir, s.next), s.next)
+		if err != nil {
+			return 0, err
+		}
+		l.segments = append(l.segments, ns)
+		s = ns
+	}
+
+	if _, err := s.f.Write(b); err != nil {
+		// drop the partial record
+		_ = s.f.Truncate(s.size)
+		_, _ = s.f.Seek(s.size, 0)
+		return 0, err
+	}
+	if l.sync {
+		if err := s.f.Sync(); err != nil {
+			return 0, err
+		}
+	}
+
+	s.size += int64(len(b))
+	s.next = r.offset + 1
+	if s.first == 0 {
+		s.first = r.timestamp
+	}
+	s.last = r.timestamp
+
+	close(l.notify)
+	l.notify = make(chan struct{})
+
+	return r.offset, nil
+}
+
+// bounds returns the offsets of the first record and the next record
+func (l *topicLog) bounds() (int64, int64) {
+	l.RLock()
+	defer l.RUnlock()
+	if len(l.segments) == 0 {
+		return 0, 0
+	}
+	return l.segments[0].base, l.segments[len(l.segments)-1].next
+}
+
+// retain removes the oldest sealed segments while the log is larger than
+// size or the segments are older than age, zero disables the limit
+func (l *topicLog) retain(size int64, age time.Duration) error {
+	l.Lock()
+	defer l.Unlock()
+
+	var total int64
+	for _, s := range l.segments {
+		total += s.size
+	}
+
+	for len(l.segments) > 1 {
+		s := l.segments[0]
+		expired := age > 0 && time.Since(time.Unix(0, s.last)) > age
+		if !expired && (size <= 0 || total <= size) {
+			break
+		}
+		if err := s.f.Close(); err != nil {
+			return err
+		}
+		if err := os.Remove(s.path); err != nil {
+			return err
+		}
+		total -= s.size
+		l.segments = l.segments[1:]
+	}
+
+	return nil
+}
+
+func (l *topicLog) close() error {
+	l.Lock()
+	defer l.Unlock()
+
+	var gerr error
+	for _, s := range l.segments {
+		if err := s.f.Close(); err != nil {
+			gerr = err
+		}
+	}
+	l.segments = nil
+	return gerr
+}
+
+// offset returns the committed offset of group
+func (l *topicLog) offset(group string) (int64, bool) {
+	b, err := ioutil.ReadFile(filepath.Join(l.dir, groupsDir, escape(group)))
+	if err != nil {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// commit saves the offset of group, it's the next offset to deliver
+func (l *topicLog) commit(group string, offset int64) error {
+	dir := filepath.Join(l.dir, groupsDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, escape(group))
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(strconv.FormatInt(offset, 10)), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// cursor reads the records of log in order
+type cursor struct {
+	l      *topicLog
+	offset int64
+	seg    *segment
+	pos    int64
+}
+
+// find returns the segment of offset, the log must be locked
+func (l *topicLog) find(offset int64) *segment {
+	i := sort.Search(len(l.segments), func(i int) bool {
+		return l.segments[i].base > offset
+	})
+	if i == 0 {
+		return l.segments[0]
+	}
+	return l.segments[i-1]
+}
+
+// seek moves the cursor to the offset, it's limited to the bounds of log
+func (c *cursor) seek(offset int64) error {
+	c.l.RLock()
+	defer c.l.RUnlock()
+
+	if len(c.l.segments) == 0 {
+		return errClosed
+	}
+
+	first, next := c.l.segments[0].base, c.l.segments[len(c.l.segments)-1].next
+	if offset < first {
+		offset = first
+	}
+	if offset > next {
+		offset = next
+	}
+
+	c.seg = c.l.find(offset)
+	c.pos = 0
+	c.offset = c.seg.base
+	for c.offset < offset && c.pos < c.seg.size {
+		r, err := readRecord(c.seg.f, c.pos)
+		if err != nil {
+			return err
+		}
+		c.pos += r.size()
+		c.offset = r.offset + 1
+	}
+	return nil
+}
+
+// seekTime moves the cursor to the first record which is published at or
+// after the time
+func (c *cursor) seekTime(t time.Time) error {
+	ts := t.UnixNano()
+
+	c.l.RLock()
+	var offset int64
+	for i, s := range c.l.segments {
+		offset = s.next
+		if s.last < ts && i < len(c.l.segments)-1 {
+			continue
+		}
+		// scan the segment for the first record at or after the time
+		var pos int64
+		for pos < s.size {
+			r, err := readRecord(s.f, pos)
+			if err != nil {
+				c.l.RUnlock()
+				return err
+			}
+			if r.timestamp >= ts {
+				offset = r.offset
+				break
+			}
+			pos += r.size()
+		}
+		break
+	}
+	c.l.RUnlock()
+
+	return c.seek(offset)
+}
+
+// next returns the record at the cursor, it blocks until a record is
+// appended or exit is closed
+func (c *cursor) next(exit <-chan bool) (*record, error) {
+	for {
+		c.l.RLock()
+		if len(c.l.segments) == 0 {
+			c.l.RUnlock()
+			return nil, errClosed
+		}
+
+		// the segment is removed by the retention
+		if c.offset < c.l.segments[0].base {
+			c.l.RUnlock()
+			if err := c.seek(c.offset); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if c.pos < c.seg.size {
+			r, err := readRecord(c.seg.f, c.pos)
+			c.l.RUnlock()
+			if err != nil {
+				return nil, err
+			}
+			c.pos += r.size()
+			c.offset = r.offset + 1
+			return r, nil
+		}
+
+		// move to the next segment
+		if c.offset < c.l.segments[len(c.l.segments)-1].next {
+			c.seg = c.l.find(c.offset)
+			c.pos = 0
+			c.l.RUnlock()
+			continue
+		}
+
+		notify := c.l.notify
+		c.l.RUnlock()
+
+		select {
+		case <-notify:
+		case <-exit:
+			return nil, errClosed
+		}
+	}
+}
@@ -0,0 +1,107 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package disk
+
+import (
+	"context"
+	"time"
+
+	"github.com/lack-io/vine/core/broker"
+)
+
+var (
+	// DefaultSegmentSize is the maximum size of log segment (64 MB)
+	DefaultSegmentSize int64 = 64 * 1024 * 1024
+	// DefaultRetentionAge is the age which the segments are removed after (7 days)
+	DefaultRetentionAge = 7 * 24 * time.Hour
+	// DefaultRetentionInterval is the interval of checking the retention
+	DefaultRetentionInterval = time.Minute
+	// DefaultCommitInterval is the interval of saving the offsets of
+	// consumer groups, the messages after the last commit are delivered
+	// again after a crash
+	DefaultCommitInterval = time.Second
+)
+
+type dirKey struct{}
+type segmentSizeKey struct{}
+type retentionKey struct{}
+type syncKey struct{}
+type offsetKey struct{}
+type sinceKey struct{}
+
+type retention struct {
+	size int64
+	age  time.Duration
+}
+
+func setBrokerOption(k, v interface{}) broker.Option {
+	return func(o *broker.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, k, v)
+	}
+}
+
+func setSubscribeOption(k, v interface{}) broker.SubscribeOption {
+	return func(o *broker.SubscribeOptions) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, k, v)
+	}
+}
+
+// Dir sets the directory of the logs, it defaults to the address of
+// broker or ~/.vine/broker
+func Dir(dir string) broker.Option {
+	return setBrokerOption(dirKey{}, dir)
+}
+
+// SegmentSize sets the maximum size of log segment
+func SegmentSize(n int64) broker.Option {
+	return setBrokerOption(segmentSizeKey{}, n)
+}
+
+// Retention removes the oldest segments of topic when the log is larger
+// than size or the segments are older than age, zero disables the limit
+func Retention(size int64, age time.Duration) broker.Option {
+	return setBrokerOption(retentionKey{}, retention{size: size, age: age})
+}
+
+// SyncWrites flushes every message to the disk before Publish returns
+func SyncWrites() broker.Option {
+	return setBrokerOption(syncKey{}, true)
+}
+
+// Offset replays the topic from the offset instead of the committed one
+// of the queue, a subscriber without queue starts at the newest message
+// by default
+func Offset(n int64) broker.SubscribeOption {
+	return setSubscribeOption(offsetKey{}, n)
+}
+
+// Since replays the messages which are published at or after the time
+func Since(t time.Time) broker.SubscribeOption {
+	return setSubscribeOption(sinceKey{}, t)
+}
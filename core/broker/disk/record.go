@@ -0,0 +1,168 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package disk
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+
+	"github.com/lack-io/vine/core/broker"
+)
+
+const (
+	// headerSize is the size of record header: offset, timestamp,
+	// length and checksum of the payload
+	headerSize = 8 + 8 + 4 + 4
+)
+
+var (
+	// errCorrupt is returned when a record is truncated or its checksum
+	// doesn't match, it's the tail of segment after a crash
+	errCorrupt = errors.New("corrupt record")
+
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// record is a message in the log
+type record struct {
+	offset    int64
+	timestamp int64
+	message   *broker.Message
+}
+
+// size returns the size of record in the segment
+func (r *record) size() int64 {
+	return int64(headerSize + payloadSize(r.message))
+}
+
+func payloadSize(m *broker.Message) int {
+	n := 4
+	for k, v := range m.Header {
+		n += 4 + len(k) + 4 + len(v)
+	}
+	return n + 4 + len(m.Body)
+}
+
+// encode returns the record framed as header followed by payload
+func (r *record) encode() []byte {
+	m := r.message
+	b := make([]byte, headerSize, r.size())
+	binary.BigEndian.PutUint64(b[0:], uint64(r.offset))
+	binary.BigEndian.PutUint64(b[8:], uint64(r.timestamp))
+
+	b = appendUint32(b, uint32(len(m.Header)))
+	for k, v := range m.Header {
+		b = appendUint32(b, uint32(len(k)))
+		b = append(b, k...)
+		b = appendUint32(b, uint32(len(v)))
+		b = append(b, v...)
+	}
+	b = appendUint32(b, uint32(len(m.Body)))
+	b = append(b, m.Body...)
+
+	payload := b[headerSize:]
+	binary.BigEndian.PutUint32(b[16:], uint32(len(payload)))
+	binary.BigEndian.PutUint32(b[20:], crc32.Checksum(payload, crcTable))
+	return b
+}
+
+// readRecord reads the record at the position of segment
+func readRecord(r io.ReaderAt, pos int64) (*record, error) {
+	hdr := make([]byte, headerSize)
+	if _, err := r.ReadAt(hdr, pos); err != nil {
+		if err == io.EOF {
+			return nil, errCorrupt
+		}
+		return nil, err
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint32(hdr[16:]))
+	if _, err := r.ReadAt(payload, pos+headerSize); err != nil {
+		if err == io.EOF {
+			return nil, errCorrupt
+		}
+		return nil, err
+	}
+	if crc32.Checksum(payload, crcTable) != binary.BigEndian.Uint32(hdr[20:]) {
+		return nil, errCorrupt
+	}
+
+	m, err := decodeMessage(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &record{
+		offset:    int64(binary.BigEndian.Uint64(hdr[0:])),
+		timestamp: int64(binary.BigEndian.Uint64(hdr[8:])),
+		message:   m,
+	}, nil
+}
+
+func decodeMessage(b []byte) (*broker.Message, error) {
+	n, b, err := readUint32(b)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &broker.Message{Header: make(map[string]string, n)}
+	for i := uint32(0); i < n; i++ {
+		var k, v []byte
+		if k, b, err = readBytes(b); err != nil {
+			return nil, err
+		}
+		if v, b, err = readBytes(b); err != nil {
+			return nil, err
+		}
+		m.Header[string(k)] = string(v)
+	}
+
+	if m.Body, _, err = readBytes(b); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func readUint32(b []byte) (uint32, []byte, error) {
+	if len(b) < 4 {
+		return 0, nil, errCorrupt
+	}
+	return binary.BigEndian.Uint32(b), b[4:], nil
+}
+
+func readBytes(b []byte) ([]byte, []byte, error) {
+	n, b, err := readUint32(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	if uint32(len(b)) < n {
+		return nil, nil, errCorrupt
+	}
+	return b[:n], b[n:], nil
+}
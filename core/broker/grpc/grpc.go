@@ -66,9 +66,19 @@ func (b *gRPCBroker) Options() broker.Options {
 }
 
 func (b *gRPCBroker) Publish(topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	var options broker.PublishOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	msg, err := broker.CompressMessage(msg, options.Compressor, options.CompressThreshold)
+	if err != nil {
+		return err
+	}
+
 	logger.Debugf("Publishing to topic %s broker %v", topic, b.Addrs)
 
-	_, err := b.Client.Publish(context.TODO(), &pb.PublishRequest{
+	_, err = b.Client.Publish(context.TODO(), &pb.PublishRequest{
 		Topic: topic,
 		Message: &pb.Message{
 			Header: msg.Header,
@@ -535,6 +535,16 @@ func (h *httpBroker) Options() broker.Options {
 }
 
 func (h *httpBroker) Publish(topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	var options broker.PublishOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	msg, err := broker.CompressMessage(msg, options.Compressor, options.CompressThreshold)
+	if err != nil {
+		return err
+	}
+
 	// create the message first
 	m := &broker.Message{
 		Header: make(map[string]string),
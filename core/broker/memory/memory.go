@@ -97,6 +97,16 @@ func (m *memoryBroker) Init(opts ...broker.Option) error {
 }
 
 func (m *memoryBroker) Publish(topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	var options broker.PublishOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	msg, err := broker.CompressMessage(msg, options.Compressor, options.CompressThreshold)
+	if err != nil {
+		return err
+	}
+
 	m.RLock()
 	if !m.connected {
 		m.RUnlock()
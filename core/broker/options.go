@@ -48,6 +48,10 @@ type Options struct {
 }
 
 type PublishOptions struct {
+	// Compressor is the algorithm used to compress the message body
+	Compressor string
+	// CompressThreshold is the minimum body size to be compressed
+	CompressThreshold int
 	// Other options for implementations of the interface
 	// can be stored in a context
 	Context context.Context
@@ -79,6 +83,15 @@ func PublishContext(ctx context.Context) PublishOption {
 	}
 }
 
+// Compress compresses the message body by the named algorithm when the body
+// is larger than threshold, the algorithm is recorded in the message header.
+func Compress(name string, threshold int) PublishOption {
+	return func(o *PublishOptions) {
+		o.Compressor = name
+		o.CompressThreshold = threshold
+	}
+}
+
 type SubscribeOption func(*SubscribeOptions)
 
 func NewSubscribeOptions(opts ...SubscribeOption) SubscribeOptions {
@@ -0,0 +1,430 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package broker is the client.Client over broker.Broker, it calls the
+// services which can reach the broker only, e.g. the workers behind NAT.
+// The request is published to the topic of service with the inbox of
+// client and the correlation id, the response is received by the inbox.
+package broker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/lack-io/vine/core/broker"
+	"github.com/lack-io/vine/core/client"
+	"github.com/lack-io/vine/core/client/cache"
+	"github.com/lack-io/vine/core/client/rpc"
+	"github.com/lack-io/vine/core/client/selector"
+	"github.com/lack-io/vine/core/codec"
+	"github.com/lack-io/vine/proto/apis/errors"
+	regpb "github.com/lack-io/vine/proto/apis/registry"
+	"github.com/lack-io/vine/util/context/metadata"
+)
+
+var (
+	// DefaultPrefix is the prefix of the request topics, the topic of
+	// service is the prefix followed by the name of service
+	DefaultPrefix = "go.vine.rpc."
+)
+
+const (
+	// the headers of rpc, they're the same as the server
+	idKey       = "Vine-Correlation-Id"
+	replyToKey  = "Vine-Reply-To"
+	endpointKey = "Vine-Endpoint"
+	streamKey   = "Vine-Stream"
+	errorKey    = "Vine-Error"
+
+	// the operations of stream message
+	opOpen  = "open"
+	opData  = "data"
+	opClose = "close"
+	opEnd   = "end"
+)
+
+// waiter receives the responses of a request
+type waiter struct {
+	ch   chan *broker.Message
+	done chan struct{}
+}
+
+func (w *waiter) deliver(msg *broker.Message) {
+	select {
+	case w.ch <- msg:
+	case <-w.done:
+	}
+}
+
+type brokerClient struct {
+	opts   client.Options
+	once   atomic.Value
+	caller *client.Caller
+
+	sync.Mutex
+	// inbox is the topic of responses
+	inbox   string
+	sub     broker.Subscriber
+	waiters map[string]*waiter
+}
+
+func (b *brokerClient) prefix() string {
+	if b.opts.Context != nil {
+		if v, ok := b.opts.Context.Value(prefixKey{}).(string); ok && len(v) > 0 {
+			return v
+		}
+	}
+	return DefaultPrefix
+}
+
+// node returns the node of service, its address is the request topic
+func (b *brokerClient) node(service string) *regpb.Node {
+	topic := b.prefix() + service
+	return &regpb.Node{Id: topic, Address: topic}
+}
+
+// next returns the node of service, the broker routes the request to a
+// node of service
+func (b *brokerClient) next(req client.Request, opts client.CallOptions) (selector.Next, error) {
+	node := b.node(req.Service())
+	return func() (*regpb.Node, error) {
+		return node, nil
+	}, nil
+}
+
+func (b *brokerClient) connect() error {
+	if b.once.Load().(bool) {
+		return nil
+	}
+	if err := b.opts.Broker.Connect(); err != nil {
+		return err
+	}
+	b.once.Store(true)
+	return nil
+}
+
+// subscribe subscribes the inbox of client once
+func (b *brokerClient) subscribe() error {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.sub != nil {
+		return nil
+	}
+	if err := b.connect(); err != nil {
+		return err
+	}
+	sub, err := b.opts.Broker.Subscribe(b.inbox, b.serveReply)
+	if err != nil {
+		return err
+	}
+	b.sub = sub
+	return nil
+}
+
+// serveReply delivers the response to the waiter of request
+func (b *brokerClient) serveReply(p broker.Event) error {
+	msg := p.Message()
+	b.Lock()
+	w, ok := b.waiters[msg.Header[idKey]]
+	b.Unlock()
+	// the request is done
+	if !ok {
+		return nil
+	}
+	w.deliver(msg)
+	return nil
+}
+
+func (b *brokerClient) wait(id string, size int) *waiter {
+	w := &waiter{
+		ch:   make(chan *broker.Message, size),
+		done: make(chan struct{}),
+	}
+	b.Lock()
+	b.waiters[id] = w
+	b.Unlock()
+	return w
+}
+
+func (b *brokerClient) remove(id string) {
+	b.Lock()
+	if w, ok := b.waiters[id]; ok {
+		close(w.done)
+		delete(b.waiters, id)
+	}
+	b.Unlock()
+}
+
+// send publishes the message of stream
+func (b *brokerClient) send(topic, id, op, contentType string, body []byte) error {
+	return b.opts.Broker.Publish(topic, &broker.Message{
+		Header: map[string]string{
+			idKey:          id,
+			streamKey:      op,
+			"Content-Type": contentType,
+		},
+		Body: body,
+	})
+}
+
+// header returns the request headers of metadata
+func (b *brokerClient) header(ctx context.Context, id string, req client.Request) map[string]string {
+	hdr := make(map[string]string)
+	if md, ok := metadata.FromContext(ctx); ok {
+		for k, v := range md {
+			hdr[k] = v
+		}
+	}
+	// set the content type for the request
+	hdr["Content-Type"] = req.ContentType()
+	hdr[idKey] = id
+	hdr[replyToKey] = b.inbox
+	hdr[endpointKey] = req.Endpoint()
+	return hdr
+}
+
+func (b *brokerClient) call(ctx context.Context, node *regpb.Node, req client.Request, rsp interface{}, opts client.CallOptions) error {
+	id := uuid.New().String()
+	hdr := b.header(ctx, id, req)
+
+	// set timeout in nanoseconds, it's the remaining time before the deadline
+	// so that the timeout shrinks across the call chain
+	timeout := opts.RequestTimeout
+	if d, ok := ctx.Deadline(); ok {
+		timeout = time.Until(d)
+	}
+	if timeout <= 0 {
+		return errors.Timeout("go.vine.client", "%v", context.DeadlineExceeded)
+	}
+	hdr["Timeout"] = fmt.Sprintf("%d", timeout)
+
+	cf, err := b.newCodec(req.ContentType())
+	if err != nil {
+		return errors.InternalServerError("go.vine.client", "%v", err)
+	}
+
+	body, err := cf.Marshal(req.Body())
+	if err != nil {
+		return errors.InternalServerError("go.vine.client", "%v", err)
+	}
+
+	if err := b.subscribe(); err != nil {
+		return errors.InternalServerError("go.vine.client", "%v", err)
+	}
+
+	w := b.wait(id, 1)
+	defer b.remove(id)
+
+	if err := b.opts.Broker.Publish(node.Address, &broker.Message{Header: hdr, Body: body}); err != nil {
+		return errors.InternalServerError("go.vine.client", "Error sending request: %v", err)
+	}
+
+	var msg *broker.Message
+	select {
+	case <-ctx.Done():
+		return errors.Timeout("go.vine.client", "%v", ctx.Err())
+	case msg = <-w.ch:
+	}
+
+	if e := msg.Header[errorKey]; len(e) > 0 {
+		return vineError(e)
+	}
+
+	if err := cf.Unmarshal(msg.Body, rsp); err != nil {
+		return errors.InternalServerError("go.vine.client", "%v", err)
+	}
+	return nil
+}
+
+func (b *brokerClient) stream(ctx context.Context, node *regpb.Node, req client.Request, rsp interface{}, opts client.CallOptions) error {
+	stream, ok := rsp.(*brokerStream)
+	if !ok {
+		return errors.InternalServerError("go.vine.client", "invalid stream %T", rsp)
+	}
+
+	id := uuid.New().String()
+	hdr := b.header(ctx, id, req)
+	hdr[streamKey] = opOpen
+
+	// set timeout in nanoseconds
+	if opts.StreamTimeout > time.Duration(0) {
+		hdr["Timeout"] = fmt.Sprintf("%d", opts.StreamTimeout)
+	}
+
+	cf, err := b.newCodec(req.ContentType())
+	if err != nil {
+		return errors.InternalServerError("go.vine.client", "%v", err)
+	}
+
+	if err := b.subscribe(); err != nil {
+		return errors.InternalServerError("go.vine.client", "%v", err)
+	}
+
+	w := b.wait(id, 16)
+	if err := b.opts.Broker.Publish(node.Address, &broker.Message{Header: hdr}); err != nil {
+		b.remove(id)
+		return errors.InternalServerError("go.vine.client", "Error creating stream: %v", err)
+	}
+
+	// wait for the node which accepts the stream
+	var timer <-chan time.Time
+	if opts.DialTimeout > 0 {
+		t := time.NewTimer(opts.DialTimeout)
+		defer t.Stop()
+		timer = t.C
+	}
+
+	var msg *broker.Message
+	select {
+	case <-ctx.Done():
+		b.remove(id)
+		return errors.Timeout("go.vine.client", "%v", ctx.Err())
+	case <-timer:
+		b.remove(id)
+		return errors.Timeout("go.vine.client", "stream %s isn't accepted in %v", req.Endpoint(), opts.DialTimeout)
+	case msg = <-w.ch:
+	}
+
+	if msg.Header[streamKey] != opOpen {
+		b.remove(id)
+		if e := msg.Header[errorKey]; len(e) > 0 {
+			return vineError(e)
+		}
+		return errors.InternalServerError("go.vine.client", "Error creating stream: unexpected %s", msg.Header[streamKey])
+	}
+
+	// create a new cancelling context
+	newCtx, cancel := context.WithCancel(ctx)
+
+	stream.c = b
+	stream.id = id
+	stream.topic = msg.Header[replyToKey]
+	stream.contentType = req.ContentType()
+	stream.w = w
+	stream.codec = cf
+	stream.request = req
+	stream.ctx = ctx
+	stream.cancel = cancel
+
+	sc := rpc.NewStreamCodec(b.String(), stream)
+	stream.response = rpc.NewResponse(msg.Header, sc)
+
+	// set request codec
+	rpc.SetCodec(req, sc)
+
+	// close the stream when the context is done
+	go func() {
+		<-newCtx.Done()
+		_ = stream.Close()
+	}()
+
+	return nil
+}
+
+func (b *brokerClient) newCodec(contentType string) (codec.Marshaler, error) {
+	return rpc.NewCodec(b.opts, contentType)
+}
+
+func (b *brokerClient) Init(opts ...client.Option) error {
+	for _, o := range opts {
+		o(&b.opts)
+	}
+	return nil
+}
+
+func (b *brokerClient) Options() client.Options {
+	return b.opts
+}
+
+func (b *brokerClient) NewMessage(topic string, msg interface{}, opts ...client.MessageOption) client.Message {
+	return rpc.NewMessage(topic, msg, b.opts.ContentType, opts...)
+}
+
+func (b *brokerClient) NewRequest(service, method string, req interface{}, reqOpts ...client.RequestOption) client.Request {
+	return rpc.NewRequest(service, method, req, b.opts.ContentType, reqOpts...)
+}
+
+func (b *brokerClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	return b.caller.Call(ctx, req, rsp, opts...)
+}
+
+func (b *brokerClient) Stream(ctx context.Context, req client.Request, opts ...client.CallOption) (client.Stream, error) {
+	return b.caller.Stream(ctx, req, opts...)
+}
+
+func (b *brokerClient) Publish(ctx context.Context, p client.Message, opts ...client.PublishOption) error {
+	return b.caller.Publish(ctx, p, opts...)
+}
+
+func (b *brokerClient) String() string {
+	return "broker"
+}
+
+func newClient(opts ...client.Option) client.Client {
+	options := client.NewOptions()
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	if options.Cache == nil {
+		options.Cache = cache.NewLRU(cache.DefaultSize)
+	}
+
+	rc := &brokerClient{
+		opts:    options,
+		waiters: make(map[string]*waiter),
+	}
+	rc.inbox = rc.prefix() + "inbox." + uuid.New().String()
+	rc.once.Store(false)
+	rc.caller = &client.Caller{
+		Client:   rc,
+		Codec:    rc.newCodec,
+		Next:     rc.next,
+		Connect:  rc.connect,
+		CallNode: rc.call,
+		StreamNode: func(ctx context.Context, node *regpb.Node, req client.Request, opts client.CallOptions) (client.Stream, error) {
+			stream := &brokerStream{}
+			err := rc.stream(ctx, node, req, stream, opts)
+			return stream, err
+		},
+	}
+
+	c := client.Client(rc)
+
+	// wrap in reverse
+	for i := len(options.Wrappers); i > 0; i-- {
+		c = options.Wrappers[i-1](c)
+	}
+
+	return c
+}
+
+func NewClient(opts ...client.Option) client.Client {
+	return newClient(opts...)
+}
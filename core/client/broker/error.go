@@ -20,19 +20,17 @@
 // OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
 // SOFTWARE.
 
-package grpc
+package broker
 
 import (
-	"context"
-
-	"github.com/lack-io/vine/core/server"
+	"github.com/lack-io/vine/proto/apis/errors"
 )
 
-func setServerOption(k, v interface{}) server.Option {
-	return func(o *server.Options) {
-		if o.Context == nil {
-			o.Context = context.Background()
-		}
-		o.Context = context.WithValue(o.Context, k, v)
+// vineError parses the error of response, the server replies the vine
+// error as is
+func vineError(s string) error {
+	if e := errors.Parse(s); e.Code > 0 {
+		return e
 	}
+	return errors.InternalServerError("go.vine.client", s)
 }
@@ -0,0 +1,130 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package broker
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/lack-io/vine/core/broker"
+	"github.com/lack-io/vine/core/client"
+	"github.com/lack-io/vine/core/codec"
+)
+
+// Implements the streamer interface over broker, the messages are sent to
+// the inbox of server node and received by the inbox of client
+type brokerStream struct {
+	sync.RWMutex
+	closed bool
+	err    error
+
+	c           *brokerClient
+	id          string
+	topic       string
+	contentType string
+	w           *waiter
+	codec       codec.Marshaler
+	request     client.Request
+	response    client.Response
+	ctx         context.Context
+	cancel      func()
+}
+
+func (b *brokerStream) Context() context.Context {
+	return b.ctx
+}
+
+func (b *brokerStream) Request() client.Request {
+	return b.request
+}
+
+func (b *brokerStream) Response() client.Response {
+	return b.response
+}
+
+func (b *brokerStream) Send(msg interface{}) error {
+	data, err := b.codec.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	if err = b.c.send(b.topic, b.id, opData, b.contentType, data); err != nil {
+		b.setError(err)
+		return err
+	}
+	return nil
+}
+
+func (b *brokerStream) Recv(msg interface{}) (err error) {
+	defer func() {
+		b.setError(err)
+	}()
+
+	var m *broker.Message
+	select {
+	case m = <-b.w.ch:
+	case <-b.w.done:
+		return io.EOF
+	}
+
+	// the server ends the stream with the error of handler
+	if m.Header[streamKey] == opEnd {
+		err = io.EOF
+		if e := m.Header[errorKey]; len(e) > 0 {
+			err = vineError(e)
+		}
+		_ = b.Close()
+		return err
+	}
+
+	return b.codec.Unmarshal(m.Body, msg)
+}
+
+func (b *brokerStream) Error() error {
+	b.RLock()
+	defer b.RUnlock()
+	return b.err
+}
+
+func (b *brokerStream) setError(e error) {
+	b.Lock()
+	b.err = e
+	b.Unlock()
+}
+
+// Close the stream, the server reads the end of stream
+func (b *brokerStream) Close() error {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.closed {
+		return nil
+	}
+	// cancel the context
+	b.cancel()
+	b.closed = true
+
+	b.c.remove(b.id)
+	return b.c.send(b.topic, b.id, opClose, b.contentType, nil)
+}
@@ -0,0 +1,148 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package client
+
+import (
+	"sync"
+	"time"
+)
+
+const budgetBuckets = 10
+
+var (
+	// DefaultBudgetRatio is the default ratio of retries to requests
+	DefaultBudgetRatio = 0.2
+	// DefaultBudgetMinRetries is the default number of retries allowed in
+	// the window regardless of the ratio
+	DefaultBudgetMinRetries = 10
+	// DefaultBudgetWindow is the default window of recent requests
+	DefaultBudgetWindow = time.Second * 10
+)
+
+type bucket struct {
+	start    int64
+	requests int
+	retries  int
+}
+
+// window counts the requests and retries of a service in buckets
+type window struct {
+	buckets [budgetBuckets]bucket
+	// last is the time of the last request or retry
+	last int64
+}
+
+func (w *window) bucket(now, size int64) *bucket {
+	w.last = now
+	start := now - now%size
+	b := &w.buckets[(now/size)%budgetBuckets]
+	if b.start != start {
+		*b = bucket{start: start}
+	}
+	return b
+}
+
+func (w *window) sum(now, span int64) (requests, retries int) {
+	for _, b := range w.buckets {
+		if now-b.start < span {
+			requests += b.requests
+			retries += b.retries
+		}
+	}
+	return
+}
+
+// Budget limits the retries of every service to a ratio of its recent
+// requests, so that a failing service isn't overloaded by retry storms.
+type Budget struct {
+	sync.Mutex
+	ratio      float64
+	minRetries int
+	span       int64
+	services   map[string]*window
+	// swept is the time of the last eviction of the idle services
+	swept int64
+}
+
+// Request records a request to the service
+func (b *Budget) Request(service string) {
+	b.Lock()
+	defer b.Unlock()
+	now := time.Now().UnixNano()
+	b.window(service, now).bucket(now, b.span/budgetBuckets).requests++
+}
+
+// Retry checks and records a retry to the service, it returns false
+// when the budget of service runs out
+func (b *Budget) Retry(service string) bool {
+	b.Lock()
+	defer b.Unlock()
+	now := time.Now().UnixNano()
+	w := b.window(service, now)
+	requests, retries := w.sum(now, b.span)
+	if retries >= b.minRetries && float64(retries) >= float64(requests)*b.ratio {
+		return false
+	}
+	w.bucket(now, b.span/budgetBuckets).retries++
+	return true
+}
+
+// window returns the window of service, the windows idle for the span are
+// evicted once in a span so that the services called once don't pile up
+func (b *Budget) window(service string, now int64) *window {
+	if now-b.swept >= b.span {
+		for name, w := range b.services {
+			if now-w.last >= b.span {
+				delete(b.services, name)
+			}
+		}
+		b.swept = now
+	}
+
+	w, ok := b.services[service]
+	if !ok {
+		w = &window{}
+		b.services[service] = w
+	}
+	return w
+}
+
+// NewBudget returns a retry budget allowing the retries up to ratio of the
+// requests in the window, at least minRetries are allowed in the window.
+func NewBudget(ratio float64, minRetries int, span time.Duration) *Budget {
+	if ratio < 0 {
+		ratio = DefaultBudgetRatio
+	}
+	if minRetries < 0 {
+		minRetries = DefaultBudgetMinRetries
+	}
+	if span < budgetBuckets {
+		span = DefaultBudgetWindow
+	}
+	return &Budget{
+		ratio:      ratio,
+		minRetries: minRetries,
+		span:       int64(span),
+		services:   make(map[string]*window),
+	}
+}
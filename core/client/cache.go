@@ -0,0 +1,188 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lack-io/vine/core/codec"
+	"github.com/lack-io/vine/core/codec/bytes"
+	"github.com/lack-io/vine/proto/apis/errors"
+	"github.com/lack-io/vine/util/context/metadata"
+)
+
+const (
+	// CacheBypassKey is the metadata key which skips the response cache
+	CacheBypassKey = "Vine-Cache-Bypass"
+	// CacheRefreshKey is the metadata key which skips the cached response
+	// and caches the fresh one
+	CacheRefreshKey = "Vine-Cache-Refresh"
+)
+
+// Cache stores the encoded responses of calls, the call with
+// CallOptions.CacheExpiry is served from the cache
+type Cache interface {
+	// Get returns the cached response of the key
+	Get(key string) ([]byte, bool)
+	// Set caches the response for the duration
+	Set(key string, value []byte, expiry time.Duration)
+	// String returns the name of the implementation
+	String() string
+}
+
+// CacheKey returns the cache key of request, it's the hash of service, endpoint,
+// encoded request and the values of metadata keys.
+func CacheKey(ctx context.Context, req Request, body []byte, keys ...string) string {
+	h := sha256.New()
+	h.Write([]byte(req.Service()))
+	h.Write([]byte{0})
+	h.Write([]byte(req.Endpoint()))
+	h.Write([]byte{0})
+	h.Write(body)
+
+	if len(keys) > 0 {
+		// the keys of metadata are case insensitive
+		md, _ := metadata.FromContext(ctx)
+		sorted := make([]string, len(keys))
+		for i, k := range keys {
+			sorted[i] = strings.ToLower(k)
+		}
+		sort.Strings(sorted)
+		for _, k := range sorted {
+			v, _ := md.Get(k)
+			h.Write([]byte{0})
+			h.Write([]byte(k + "=" + v))
+		}
+	}
+
+	return req.Service() + ":" + req.Endpoint() + ":" + hex.EncodeToString(h.Sum(nil))
+}
+
+// CacheBypass checks if the call skips the cache
+func CacheBypass(ctx context.Context) bool {
+	v, _ := metadata.Get(ctx, CacheBypassKey)
+	return strings.EqualFold(v, "true")
+}
+
+// CacheRefresh checks if the call refreshes the cache
+func CacheRefresh(ctx context.Context) bool {
+	v, _ := metadata.Get(ctx, CacheRefreshKey)
+	return strings.EqualFold(v, "true")
+}
+
+// flight is an in-flight call of the cache key
+type flight struct {
+	// done is closed when the call returns
+	done chan struct{}
+	rsp  []byte
+	err  error
+}
+
+// CacheGroup serves the calls from the response cache, the concurrent
+// missed calls of the same cache key are collapsed into one call
+type CacheGroup struct {
+	sync.Mutex
+	calls map[string]*flight
+}
+
+// Call serves the call of c from the response cache, the missed call is made
+// with the raw frame and the encoded response is cached. cf encodes the request
+// and decodes the cached response.
+func (g *CacheGroup) Call(ctx context.Context, c Client, cf codec.Marshaler, req Request, rsp interface{}, callOpts CallOptions, opts ...CallOption) error {
+	body, err := cf.Marshal(req.Body())
+	if err != nil {
+		return errors.InternalServerError("go.vine.client", "%v", err)
+	}
+
+	options := c.Options()
+	cache := options.Cache
+	key := CacheKey(ctx, req, body, options.CacheMetadata...)
+
+	if !CacheRefresh(ctx) {
+		if b, ok := cache.Get(key); ok {
+			return cf.Unmarshal(b, rsp)
+		}
+	}
+
+	b, err := g.do(ctx, key, callOpts.RequestTimeout, func(ctx context.Context) ([]byte, error) {
+		frame := &bytes.Frame{}
+		if err := c.Call(ctx, req, frame, append(opts, WithCache(0))...); err != nil {
+			return nil, err
+		}
+		cache.Set(key, frame.Data, callOpts.CacheExpiry)
+		return frame.Data, nil
+	})
+	if err != nil {
+		return err
+	}
+	return cf.Unmarshal(b, rsp)
+}
+
+// detachedContext carries the values of the context without its deadline
+// and cancellation
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+// do runs fn once for the concurrent calls of the key. The call is shared by
+// the callers, so it's detached from the cancellation of the caller making it
+// and bounded by timeout, every caller stops waiting when its ctx is done.
+func (g *CacheGroup) do(ctx context.Context, key string, timeout time.Duration, fn func(context.Context) ([]byte, error)) ([]byte, error) {
+	g.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flight)
+	}
+	f, ok := g.calls[key]
+	if !ok {
+		f = &flight{done: make(chan struct{})}
+		g.calls[key] = f
+		go func() {
+			fctx, cancel := context.WithTimeout(detachedContext{ctx}, timeout)
+			defer cancel()
+			f.rsp, f.err = fn(fctx)
+
+			g.Lock()
+			delete(g.calls, key)
+			g.Unlock()
+			close(f.done)
+		}()
+	}
+	g.Unlock()
+
+	select {
+	case <-f.done:
+		return f.rsp, f.err
+	case <-ctx.Done():
+		return nil, errors.Timeout("go.vine.client", "%v", ctx.Err())
+	}
+}
@@ -0,0 +1,98 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lack-io/vine/core/client"
+	"github.com/lack-io/vine/lib/store/memory"
+	"github.com/lack-io/vine/util/context/metadata"
+)
+
+type testRequest struct {
+	client.Request
+}
+
+func (r *testRequest) Service() string  { return "go.vine.test" }
+func (r *testRequest) Endpoint() string { return "Test.Get" }
+
+func testCache(t *testing.T, c client.Cache) {
+	if _, ok := c.Get("a"); ok {
+		t.Fatalf("%s: unexpected hit", c)
+	}
+
+	c.Set("a", []byte("1"), time.Minute)
+	if v, ok := c.Get("a"); !ok || string(v) != "1" {
+		t.Fatalf("%s: expect 1, got %s", c, v)
+	}
+
+	c.Set("b", []byte("2"), time.Millisecond*10)
+	time.Sleep(time.Millisecond * 20)
+	if _, ok := c.Get("b"); ok {
+		t.Fatalf("%s: expect expired", c)
+	}
+}
+
+func TestLRU(t *testing.T) {
+	testCache(t, NewLRU(2))
+
+	c := NewLRU(2)
+	c.Set("a", []byte("1"), time.Minute)
+	c.Set("b", []byte("2"), time.Minute)
+	c.Get("a")
+	c.Set("c", []byte("3"), time.Minute)
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("expect b evicted")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("expect a cached")
+	}
+}
+
+func TestStore(t *testing.T) {
+	testCache(t, NewStore(memory.NewStore()))
+}
+
+func TestCacheKey(t *testing.T) {
+	req := &testRequest{}
+	// metadata.Set keeps the case of key, the keys of context are lower case
+	ctx := metadata.NewContext(context.TODO(), metadata.Metadata{"authorization": "a", "trace": "1"})
+
+	k1 := client.CacheKey(ctx, req, []byte("body"), "Authorization")
+	k2 := client.CacheKey(metadata.Set(ctx, "trace", "2"), req, []byte("body"), "Authorization")
+	if k1 != k2 {
+		t.Fatal("expect the metadata out of keys to be ignored")
+	}
+	if k := client.CacheKey(ctx, req, []byte("body"), "authorization"); k1 != k {
+		t.Fatal("expect the keys to be case insensitive")
+	}
+	if k3 := client.CacheKey(metadata.Set(ctx, "authorization", "b"), req, []byte("body"), "Authorization"); k1 == k3 {
+		t.Fatal("expect the metadata of keys to be part of key")
+	}
+	if k4 := client.CacheKey(ctx, req, []byte("other"), "Authorization"); k1 == k4 {
+		t.Fatal("expect the body to be part of key")
+	}
+}
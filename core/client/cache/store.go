@@ -0,0 +1,57 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cache
+
+import (
+	"time"
+
+	"github.com/lack-io/vine/core/client"
+	"github.com/lack-io/vine/lib/store"
+)
+
+type storeCache struct {
+	s      store.Store
+	prefix string
+}
+
+func (c *storeCache) Get(key string) ([]byte, bool) {
+	recs, err := c.s.Read(c.prefix + key)
+	if err != nil || len(recs) == 0 {
+		return nil, false
+	}
+	return recs[0].Value, true
+}
+
+func (c *storeCache) Set(key string, value []byte, expiry time.Duration) {
+	_ = c.s.Write(&store.Record{Key: c.prefix + key, Value: value, Expiry: expiry})
+}
+
+func (c *storeCache) String() string {
+	return "store"
+}
+
+// NewStore returns a cache backed by the store, the responses are
+// shared by the clients using the same store.
+func NewStore(s store.Store) client.Cache {
+	return &storeCache{s: s, prefix: "vine/cache/"}
+}
@@ -0,0 +1,93 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCacheGroup(t *testing.T) {
+	var g CacheGroup
+	release := make(chan struct{})
+	calls := 0
+	fn := func(ctx context.Context) ([]byte, error) {
+		calls++
+		select {
+		case <-release:
+			return []byte("ok"), nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	// the caller making the call gives up
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := g.do(ctx, "key", time.Second, fn)
+		first <- err
+	}()
+	time.Sleep(time.Millisecond * 10)
+
+	second := make(chan []byte, 1)
+	go func() {
+		b, err := g.do(context.Background(), "key", time.Second, fn)
+		if err != nil {
+			t.Error(err)
+		}
+		second <- b
+	}()
+	time.Sleep(time.Millisecond * 10)
+
+	cancel()
+	select {
+	case err := <-first:
+		if err == nil {
+			t.Fatal("expect the canceled caller to fail")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expect the canceled caller to return")
+	}
+
+	// the shared call isn't canceled with the first caller
+	close(release)
+	if b := <-second; string(b) != "ok" {
+		t.Fatalf("expect the shared response, got %q", b)
+	}
+	if calls != 1 {
+		t.Fatalf("expect the calls to be collapsed, got %d calls", calls)
+	}
+}
+
+func TestCacheGroupTimeout(t *testing.T) {
+	var g CacheGroup
+	_, err := g.do(context.Background(), "key", time.Millisecond*10, func(ctx context.Context) ([]byte, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expect the shared call bounded by the timeout, got %v", err)
+	}
+}
@@ -0,0 +1,450 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/lack-io/vine/core/broker"
+	"github.com/lack-io/vine/core/client/selector"
+	"github.com/lack-io/vine/core/codec"
+	"github.com/lack-io/vine/core/codec/bytes"
+	"github.com/lack-io/vine/proto/apis/errors"
+	regpb "github.com/lack-io/vine/proto/apis/registry"
+	"github.com/lack-io/vine/util/context/metadata"
+	mnet "github.com/lack-io/vine/util/net"
+)
+
+// StreamFunc opens the stream of request to the node
+type StreamFunc func(ctx context.Context, node *regpb.Node, req Request, opts CallOptions) (Stream, error)
+
+// Caller makes the calls, streams and publications of a client, the
+// transport supplies the per-node functions. The calls are retried with
+// the budget of client and the retry policy of endpoint.
+type Caller struct {
+	// Client is the client of transport, its options are used and the
+	// missed calls of the response cache are made by it
+	Client Client
+	// Codec returns the marshaler of content type
+	Codec func(contentType string) (codec.Marshaler, error)
+	// Next returns the nodes of request, the nodes of selector are used
+	// and marked by default
+	Next func(req Request, opts CallOptions) (selector.Next, error)
+	// Connect connects the broker before publishing
+	Connect func() error
+	// CallNode makes the call to the node
+	CallNode CallFunc
+	// StreamNode opens the stream to the node
+	StreamNode StreamFunc
+
+	flights CacheGroup
+
+	sync.Mutex
+	connected bool
+}
+
+// next returns the nodes of request from the selector, the address of
+// call options takes precedence
+func (c *Caller) next(opts Options, req Request, callOpts CallOptions) (selector.Next, error) {
+	if c.Next != nil {
+		return c.Next(req, callOpts)
+	}
+
+	service, address, _ := mnet.Proxy(req.Service(), callOpts.Address)
+
+	// return remote address
+	if len(address) > 0 {
+		return func() (*regpb.Node, error) {
+			return &regpb.Node{
+				Address: address[0],
+			}, nil
+		}, nil
+	}
+
+	// get next nodes from the selector
+	next, err := opts.Selector.Select(service, callOpts.SelectOptions...)
+	if err != nil {
+		return nil, selectError(service, err)
+	}
+
+	return next, nil
+}
+
+// selector returns the selector which marks the nodes, the nodes of
+// Next aren't marked
+func (c *Caller) selector(opts Options) selector.Selector {
+	if c.Next != nil {
+		return nil
+	}
+	return opts.Selector
+}
+
+// mark reports the result of call to the selector
+func (c *Caller) mark(opts Options, service string, node *regpb.Node, err error) {
+	if s := c.selector(opts); s != nil {
+		s.Mark(service, node, err)
+	}
+}
+
+func selectError(service string, err error) error {
+	if err == selector.ErrNotFound {
+		return errors.InternalServerError("go.vine.client", "service %s: %s", service, err.Error())
+	}
+	return errors.InternalServerError("go.vine.client", "error selecting %s node: %s", service, err.Error())
+}
+
+// retryPolicy returns the retry policy of endpoint from the services
+func retryPolicy(services []*regpb.Service, endpoint string) *RetryPolicy {
+	for _, service := range services {
+		for _, ep := range service.Endpoints {
+			if ep.Name == endpoint {
+				return ParseRetryPolicy(ep.Metadata)
+			}
+		}
+	}
+	return nil
+}
+
+// sleep sleeps the backoff before the attempt i of request
+func sleep(ctx context.Context, req Request, callOpts CallOptions, i int) error {
+	// call backoff first. Someone may want an initial start delay
+	t, err := callOpts.Backoff(ctx, req, i)
+	if err != nil {
+		return errors.InternalServerError("go.vine.client", "%v", err)
+	}
+
+	// only sleep if greater than 0
+	if t.Seconds() > 0 {
+		time.Sleep(t)
+	}
+	return nil
+}
+
+// Call makes the call of request, it's served from the response cache
+// and retried with the budget and the retry policy of endpoint
+func (c *Caller) Call(ctx context.Context, req Request, rsp interface{}, opts ...CallOption) error {
+	if req == nil {
+		return errors.InternalServerError("go.vine.client", "req is nil")
+	} else if rsp == nil {
+		return errors.InternalServerError("go.vine.client", "rsp is nil")
+	}
+
+	options := c.Client.Options()
+
+	// make a copy of call opts
+	callOpts := options.CallOptions
+	for _, opt := range opts {
+		opt(&callOpts)
+	}
+
+	// serve the call from the response cache
+	if callOpts.CacheExpiry > 0 && options.Cache != nil && !CacheBypass(ctx) {
+		cf, err := c.Codec(req.ContentType())
+		if err != nil {
+			return errors.InternalServerError("go.vine.client", "%v", err)
+		}
+		return c.flights.Call(ctx, c.Client, cf, req, rsp, callOpts, opts...)
+	}
+
+	// capture the retry policy which the endpoint publishes
+	var policy *RetryPolicy
+	callOpts.SelectOptions = append([]selector.SelectOption{selector.WithFilter(func(services []*regpb.Service) []*regpb.Service {
+		policy = retryPolicy(services, req.Endpoint())
+		return services
+	})}, callOpts.SelectOptions...)
+
+	next, err := c.next(options, req, callOpts)
+	if err != nil {
+		return err
+	}
+
+	if policy != nil {
+		if policy.Attempts > 0 {
+			callOpts.Retries = policy.Attempts - 1
+		}
+		if !policy.Idempotent {
+			callOpts.HedgeDelay = 0
+		}
+	}
+
+	budget := options.Budget
+	if budget != nil {
+		budget.Request(req.Service())
+	}
+
+	// check if we already have a deadline
+	d, ok := ctx.Deadline()
+	if !ok {
+		// no deadline so we create a new one
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, callOpts.RequestTimeout)
+		defer cancel()
+	} else {
+		// got a deadline so no need to setup context
+		// but we need to set the timeout we pass along
+		opt := WithRequestTimeout(time.Until(d))
+		opt(&callOpts)
+	}
+
+	// should we noop right here?
+	select {
+	case <-ctx.Done():
+		return errors.Timeout("go.vine.client", "%v", ctx.Err())
+	default:
+	}
+
+	// make copy of call method
+	ncall := c.CallNode
+
+	// wrap the call in reverse
+	for i := len(callOpts.CallWrappers); i > 0; i-- {
+		ncall = callOpts.CallWrappers[i-1](ncall)
+	}
+
+	call := func(i int) error {
+		if err := sleep(ctx, req, callOpts, i); err != nil {
+			return err
+		}
+
+		// select next node
+		node, err := next()
+		service := req.Service()
+		if err != nil {
+			return selectError(service, err)
+		}
+
+		// make the call
+		if callOpts.HedgeDelay > 0 && callOpts.Hedges > 0 {
+			err = Hedge(ctx, c.selector(options), next, node, req, rsp, callOpts, ncall)
+		} else {
+			err = ncall(ctx, node, req, rsp, callOpts)
+			c.mark(options, service, node, err)
+		}
+		if verr, ok := err.(*errors.Error); ok {
+			return verr
+		}
+
+		return err
+	}
+
+	ch := make(chan error, callOpts.Retries+1)
+	var gerr error
+
+	for i := 0; i <= callOpts.Retries; i++ {
+		go func(i int) {
+			ch <- call(i)
+		}(i)
+
+		select {
+		case <-ctx.Done():
+			return errors.Timeout("go.vine.client", "%v", ctx.Err())
+		case err := <-ch:
+			// if the call succeeded lets bail early
+			if err == nil {
+				return nil
+			}
+
+			// the policy of endpoint takes precedence
+			var retry bool
+			if policy != nil {
+				retry = policy.Retry(err)
+			} else {
+				var rerr error
+				retry, rerr = callOpts.Retry(ctx, req, i, err)
+				if rerr != nil {
+					return rerr
+				}
+			}
+
+			if !retry || i == callOpts.Retries {
+				return err
+			}
+
+			// stop retrying when the budget of service runs out
+			if budget != nil && !budget.Retry(req.Service()) {
+				return err
+			}
+
+			gerr = err
+		}
+	}
+
+	return gerr
+}
+
+// Stream opens the stream of request, the failed attempts are retried
+func (c *Caller) Stream(ctx context.Context, req Request, opts ...CallOption) (Stream, error) {
+	options := c.Client.Options()
+
+	// make a copy of call opts
+	callOpts := options.CallOptions
+	for _, opt := range opts {
+		opt(&callOpts)
+	}
+
+	next, err := c.next(options, req, callOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	// #200 - streams shouldn't have a request timeout set on the context
+
+	// should we noop right here?
+	select {
+	case <-ctx.Done():
+		return nil, errors.Timeout("go.vine.client", "%v", ctx.Err())
+	default:
+	}
+
+	call := func(i int) (Stream, error) {
+		if err := sleep(ctx, req, callOpts, i); err != nil {
+			return nil, err
+		}
+
+		node, err := next()
+		service := req.Service()
+		if err != nil {
+			return nil, selectError(service, err)
+		}
+
+		// make the call
+		stream, err := c.StreamNode(ctx, node, req, callOpts)
+
+		c.mark(options, service, node, err)
+		return stream, err
+	}
+
+	type response struct {
+		stream Stream
+		err    error
+	}
+
+	ch := make(chan response, callOpts.Retries+1)
+	var grr error
+
+	for i := 0; i <= callOpts.Retries; i++ {
+		go func(i int) {
+			s, err := call(i)
+			ch <- response{s, err}
+		}(i)
+
+		select {
+		case <-ctx.Done():
+			return nil, errors.Timeout("go.vine.client", "%v", ctx.Err())
+		case rsp := <-ch:
+			// if the call succeeded lets bail early
+			if rsp.err == nil {
+				return rsp.stream, nil
+			}
+
+			retry, rerr := callOpts.Retry(ctx, req, i, rsp.err)
+			if rerr != nil {
+				return nil, rerr
+			}
+
+			if !retry {
+				return nil, rsp.err
+			}
+
+			grr = rsp.err
+		}
+	}
+
+	return nil, grr
+}
+
+// connect connects the broker once
+func (c *Caller) connect(opts Options) error {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.connected {
+		return nil
+	}
+	connect := opts.Broker.Connect
+	if c.Connect != nil {
+		connect = c.Connect
+	}
+	if err := connect(); err != nil {
+		return err
+	}
+	c.connected = true
+	return nil
+}
+
+// Publish encodes the message and publishes it to the broker of client
+func (c *Caller) Publish(ctx context.Context, p Message, opts ...PublishOption) error {
+	var options PublishOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	md, ok := metadata.FromContext(ctx)
+	if !ok {
+		md = make(map[string]string)
+	}
+	md["Content-Type"] = p.ContentType()
+	md["Vine-Topic"] = p.Topic()
+
+	cf, err := c.Codec(p.ContentType())
+	if err != nil {
+		return errors.InternalServerError("go.vine.client", "%v", err)
+	}
+
+	var body []byte
+
+	// passed in raw data
+	if d, ok := p.Payload().(*bytes.Frame); ok {
+		body = d.Data
+	} else {
+		// set the body
+		b, err := cf.Marshal(p.Payload())
+		if err != nil {
+			return errors.InternalServerError("go.vine.client", "%v", err)
+		}
+		body = b
+	}
+
+	copts := c.Client.Options()
+	if err = c.connect(copts); err != nil {
+		return errors.InternalServerError("go.vine.client", "%v", err)
+	}
+
+	topic := p.Topic()
+
+	// get the exchange
+	if len(options.Exchange) > 0 {
+		topic = options.Exchange
+	}
+
+	msg := &broker.Message{
+		Header: md,
+		Body:   body,
+	}
+	return copts.Broker.Publish(topic, msg,
+		broker.PublishContext(options.Context),
+		broker.Compress(options.Compressor, options.CompressThreshold),
+	)
+}
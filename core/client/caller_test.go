@@ -0,0 +1,103 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lack-io/vine/core/client/selector"
+	"github.com/lack-io/vine/core/codec/bytes"
+	"github.com/lack-io/vine/proto/apis/errors"
+	regpb "github.com/lack-io/vine/proto/apis/registry"
+)
+
+type testClient struct {
+	Client
+	opts Options
+}
+
+func (c *testClient) Options() Options {
+	return c.opts
+}
+
+func TestCallerRetry(t *testing.T) {
+	opts := NewOptions(Retries(2), Backoff(func(context.Context, Request, int) (time.Duration, error) {
+		return 0, nil
+	}))
+
+	attempts := 0
+	c := &Caller{
+		Client: &testClient{opts: opts},
+		Next: func(req Request, opts CallOptions) (selector.Next, error) {
+			return func() (*regpb.Node, error) {
+				return &regpb.Node{Id: "test"}, nil
+			}, nil
+		},
+		CallNode: func(ctx context.Context, node *regpb.Node, req Request, rsp interface{}, opts CallOptions) error {
+			attempts++
+			if attempts < 2 {
+				return errors.New("test", "failed", 503)
+			}
+			rsp.(*bytes.Frame).Data = []byte(node.Id)
+			return nil
+		},
+	}
+
+	rsp := &bytes.Frame{}
+	if err := c.Call(context.TODO(), &testRequest{}, rsp); err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 2 || string(rsp.Data) != "test" {
+		t.Fatalf("expect the second attempt to succeed, got %d attempts", attempts)
+	}
+}
+
+func TestCallerBudget(t *testing.T) {
+	opts := NewOptions(Retries(5), Backoff(func(context.Context, Request, int) (time.Duration, error) {
+		return 0, nil
+	}))
+	opts.Budget = NewBudget(0, 1, time.Minute)
+
+	attempts := 0
+	c := &Caller{
+		Client: &testClient{opts: opts},
+		Next: func(req Request, opts CallOptions) (selector.Next, error) {
+			return func() (*regpb.Node, error) {
+				return &regpb.Node{Id: "test"}, nil
+			}, nil
+		},
+		CallNode: func(ctx context.Context, node *regpb.Node, req Request, rsp interface{}, opts CallOptions) error {
+			attempts++
+			return errors.New("test", "failed", 503)
+		},
+	}
+
+	if err := c.Call(context.TODO(), &testRequest{}, &bytes.Frame{}); err == nil {
+		t.Fatal("expect error")
+	}
+	if attempts != 2 {
+		t.Fatalf("expect 1 retry within the budget, got %d attempts", attempts)
+	}
+}
@@ -35,12 +35,16 @@ import (
 
 	"github.com/lack-io/vine/core/codec"
 	"github.com/lack-io/vine/core/codec/bytes"
+	"github.com/lack-io/vine/core/codec/cbor"
+	"github.com/lack-io/vine/core/codec/msgpack"
 	"github.com/lack-io/vine/util/jsonpb"
 )
 
 type jsonCodec struct{}
 type protoCodec struct{}
 type bytesCodec struct{}
+type msgpackCodec struct{ msgpack.Marshaler }
+type cborCodec struct{ cbor.Marshaler }
 type wrapCodec struct{ encoding.Codec }
 
 var jsonpbMarshaler = &jsonpb.Marshaler{}
@@ -59,6 +63,11 @@ var (
 		"application/grpc+json":    jsonCodec{},
 		"application/grpc+proto":   protoCodec{},
 		"application/grpc+bytes":   bytesCodec{},
+		"application/msgpack":      msgpackCodec{},
+		"application/x-msgpack":    msgpackCodec{},
+		"application/grpc+msgpack": msgpackCodec{},
+		"application/cbor":         cborCodec{},
+		"application/grpc+cbor":    cborCodec{},
 	}
 )
 
@@ -171,6 +180,50 @@ func (jsonCodec) Name() string {
 	return "json"
 }
 
+func (c msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	if b, ok := v.(*bytes.Frame); ok {
+		return b.Data, nil
+	}
+	return c.Marshaler.Marshal(v)
+}
+
+func (c msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	if len(data) == 0 {
+		return nil
+	}
+	if b, ok := v.(*bytes.Frame); ok {
+		b.Data = data
+		return nil
+	}
+	return c.Marshaler.Unmarshal(data, v)
+}
+
+func (msgpackCodec) Name() string {
+	return "msgpack"
+}
+
+func (c cborCodec) Marshal(v interface{}) ([]byte, error) {
+	if b, ok := v.(*bytes.Frame); ok {
+		return b.Data, nil
+	}
+	return c.Marshaler.Marshal(v)
+}
+
+func (c cborCodec) Unmarshal(data []byte, v interface{}) error {
+	if len(data) == 0 {
+		return nil
+	}
+	if b, ok := v.(*bytes.Frame); ok {
+		b.Data = data
+		return nil
+	}
+	return c.Marshaler.Unmarshal(data, v)
+}
+
+func (cborCodec) Name() string {
+	return "cbor"
+}
+
 type grpcCodec struct {
 	// headers
 	id       string
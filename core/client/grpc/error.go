@@ -23,6 +23,7 @@
 package grpc
 
 import (
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
 	"github.com/lack-io/vine/proto/apis/errors"
@@ -55,6 +56,11 @@ func vineError(err error) error {
 		return e // actually a vine error
 	}
 
+	// deadline exceeded is always a timeout
+	if s.Code() == codes.DeadlineExceeded {
+		return errors.Timeout("go.vine.client", s.Message())
+	}
+
 	// fallback
 	return errors.InternalServerError("go.vine.client", s.Message())
 }
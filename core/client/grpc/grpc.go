@@ -29,40 +29,50 @@ import (
 	"net"
 	"reflect"
 	"strings"
-	"sync/atomic"
 	"time"
 
+	"github.com/gogo/protobuf/proto"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/encoding"
 	gmetadata "google.golang.org/grpc/metadata"
 
-	"github.com/lack-io/vine/core/broker"
 	"github.com/lack-io/vine/core/client"
-	"github.com/lack-io/vine/core/client/selector"
+	"github.com/lack-io/vine/core/client/cache"
+	"github.com/lack-io/vine/core/codec"
 	"github.com/lack-io/vine/core/codec/bytes"
 	"github.com/lack-io/vine/proto/apis/errors"
 	regpb "github.com/lack-io/vine/proto/apis/registry"
+	"github.com/lack-io/vine/util/compress"
 	"github.com/lack-io/vine/util/context/metadata"
-	mnet "github.com/lack-io/vine/util/net"
 )
 
 type grpcClient struct {
-	opts client.Options
-	pool *pool
-	once atomic.Value
+	opts   client.Options
+	pool   *pool
+	caller *client.Caller
 }
 
 func init() {
 	encoding.RegisterCodec(wrapCodec{jsonCodec{}})
 	encoding.RegisterCodec(wrapCodec{protoCodec{}})
 	encoding.RegisterCodec(wrapCodec{bytesCodec{}})
+	encoding.RegisterCodec(wrapCodec{msgpackCodec{}})
+	encoding.RegisterCodec(wrapCodec{cborCodec{}})
+
+	for _, name := range compress.Names() {
+		c, _ := compress.Get(name)
+		encoding.RegisterCompressor(grpcCompressor{c})
+	}
 }
 
 // secure returns the dial option for whether its a secure or insecure connection
-func (g *grpcClient) secure(addr string) grpc.DialOption {
+func (g *grpcClient) secure(service, addr string) grpc.DialOption {
 	// first we check if there's tls config
 	if g.opts.Context != nil {
+		if fn, ok := g.opts.Context.Value(tlsAuthFunc{}).(func(string) *tls.Config); ok && fn != nil {
+			return grpc.WithTransportCredentials(credentials.NewTLS(fn(service)))
+		}
 		if v := g.opts.Context.Value(tlsAuth{}); v != nil {
 			tlsCfg := v.(*tls.Config)
 			creds := credentials.NewTLS(tlsCfg)
@@ -93,30 +103,6 @@ func (g *grpcClient) secure(addr string) grpc.DialOption {
 	return grpc.WithInsecure()
 }
 
-func (g *grpcClient) next(request client.Request, opts client.CallOptions) (selector.Next, error) {
-	service, address, _ := mnet.Proxy(request.Service(), opts.Address)
-
-	// return remote address
-	if len(address) > 0 {
-		return func() (*regpb.Node, error) {
-			return &regpb.Node{
-				Address: address[0],
-			}, nil
-		}, nil
-	}
-
-	// get next nodes from the selector
-	next, err := g.opts.Selector.Select(service, opts.SelectOptions...)
-	if err != nil {
-		if err == selector.ErrNotFound {
-			return nil, errors.InternalServerError("go.vine.client", "service %s: %s", service, err.Error())
-		}
-		return nil, errors.InternalServerError("go.vine.client", "error selecting %s node: %s", service, err.Error())
-	}
-
-	return next, nil
-}
-
 func (g *grpcClient) call(ctx context.Context, node *regpb.Node, req client.Request, rsp interface{}, opts client.CallOptions) error {
 	var header map[string]string
 
@@ -132,8 +118,16 @@ func (g *grpcClient) call(ctx context.Context, node *regpb.Node, req client.Requ
 		header = make(map[string]string)
 	}
 
-	// set timeout in nanoseconds
-	header["timeout"] = fmt.Sprintf("%d", opts.RequestTimeout)
+	// set timeout in nanoseconds, it's the remaining time before the deadline
+	// so that the timeout shrinks across the call chain
+	timeout := opts.RequestTimeout
+	if d, ok := ctx.Deadline(); ok {
+		timeout = time.Until(d)
+	}
+	if timeout <= 0 {
+		return errors.Timeout("go.vine.client", "%v", context.DeadlineExceeded)
+	}
+	header["timeout"] = fmt.Sprintf("%d", timeout)
 	// set the content type for the request
 	header["x-content-type"] = req.ContentType()
 
@@ -152,7 +146,7 @@ func (g *grpcClient) call(ctx context.Context, node *regpb.Node, req client.Requ
 
 	grpcDialOptions := []grpc.DialOption{
 		grpc.WithTimeout(opts.DialTimeout),
-		g.secure(address),
+		g.secure(req.Service(), address),
 		grpc.WithDefaultCallOptions(
 			grpc.MaxCallRecvMsgSize(maxRecvMsgSize),
 			grpc.MaxCallSendMsgSize(maxSendMsgSize),
@@ -180,6 +174,9 @@ func (g *grpcClient) call(ctx context.Context, node *regpb.Node, req client.Requ
 		if opts := g.getGrpcCallOptions(); opts != nil {
 			grpcCallOptions = append(grpcCallOptions, opts...)
 		}
+		if name := g.compressor(node, req.Body(), cf, opts); len(name) > 0 {
+			grpcCallOptions = append(grpcCallOptions, grpc.UseCompressor(name))
+		}
 
 		err := cc.Invoke(ctx, methodToGRPC(req.Service(), req.Endpoint()), req.Body(), rsp, grpcCallOptions...)
 		ch <- vineError(err)
@@ -237,7 +234,7 @@ func (g *grpcClient) stream(ctx context.Context, node *regpb.Node, req client.Re
 
 	grpcDialOptions := []grpc.DialOption{
 		grpc.WithTimeout(opts.DialTimeout),
-		g.secure(address),
+		g.secure(req.Service(), address),
 	}
 
 	if opts := g.getGrpcDialOptions(); opts != nil {
@@ -262,6 +259,10 @@ func (g *grpcClient) stream(ctx context.Context, node *regpb.Node, req client.Re
 	if opts := g.getGrpcCallOptions(); opts != nil {
 		grpcCallOptions = append(grpcCallOptions, opts...)
 	}
+	// the size of stream messages is unknown, ignore the threshold
+	if name := g.compressor(node, nil, cf, opts); len(name) > 0 {
+		grpcCallOptions = append(grpcCallOptions, grpc.UseCompressor(name))
+	}
 
 	// create a new cancelling context
 	newCtx, cancel := context.WithCancel(ctx)
@@ -368,6 +369,50 @@ func (g *grpcClient) newGRPCCodec(contentType string) (encoding.Codec, error) {
 	return nil, fmt.Errorf("unsupported Content-Type: %s", contentType)
 }
 
+// newCodec returns the marshaler of content type, it's used by the caller
+func (g *grpcClient) newCodec(contentType string) (codec.Marshaler, error) {
+	c, err := g.newGRPCCodec(contentType)
+	if err != nil {
+		return nil, err
+	}
+	return wrapCodec{c}, nil
+}
+
+// compressor returns the compressor used by the call, the compressor must be
+// advertised by the node and the request must be larger than the threshold.
+func (g *grpcClient) compressor(node *regpb.Node, body interface{}, cf encoding.Codec, opts client.CallOptions) string {
+	if len(opts.Compressor) == 0 {
+		return ""
+	}
+	if _, ok := compress.Get(opts.Compressor); !ok {
+		return ""
+	}
+	if node.Metadata == nil || !compress.Supports(node.Metadata["compressors"], opts.Compressor) {
+		return ""
+	}
+
+	if opts.CompressThreshold > 0 && body != nil {
+		var size int
+		switch v := body.(type) {
+		case *bytes.Frame:
+			size = len(v.Data)
+		case proto.Message:
+			size = proto.Size(v)
+		default:
+			b, err := cf.Marshal(v)
+			if err != nil {
+				return ""
+			}
+			size = len(b)
+		}
+		if size < opts.CompressThreshold {
+			return ""
+		}
+	}
+
+	return opts.Compressor
+}
+
 func (g *grpcClient) Init(opts ...client.Option) error {
 	size := g.opts.PoolSize
 	ttl := g.opts.PoolTTL
@@ -400,266 +445,15 @@ func (g *grpcClient) NewRequest(service, method string, req interface{}, reqOpts
 }
 
 func (g *grpcClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
-	if req == nil {
-		return errors.InternalServerError("go.vine.client", "req is nil")
-	} else if rsp == nil {
-		return errors.InternalServerError("go.vine.client", "rsp is nil")
-	}
-
-	// make a copy of call opts
-	callOpts := g.opts.CallOptions
-	for _, opt := range opts {
-		opt(&callOpts)
-	}
-
-	next, err := g.next(req, callOpts)
-	if err != nil {
-		return err
-	}
-
-	// check if we already have a deadline
-	d, ok := ctx.Deadline()
-	if !ok {
-		// no deadline so we create a new one
-		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ctx, callOpts.RequestTimeout)
-		defer cancel()
-	} else {
-		// got a deadline so no need to setup context
-		// but we need to set the timeout we pass along
-		opt := client.WithRequestTimeout(time.Until(d))
-		opt(&callOpts)
-	}
-
-	// should we noop right here?
-	select {
-	case <-ctx.Done():
-		return errors.Timeout("go.vine.client", "%v", ctx.Err())
-	default:
-	}
-
-	// make copy of call method
-	gcall := g.call
-
-	// wrap the call in reverse
-	for i := len(callOpts.CallWrappers); i > 0; i-- {
-		gcall = callOpts.CallWrappers[i-1](gcall)
-	}
-
-	// return errors.Timeout("go.vine.client", "%v", ctx.Err())
-	call := func(i int) error {
-		// call backoff first. Someone may want an initial start delay
-		t, err := callOpts.Backoff(ctx, req, i)
-		if err != nil {
-			return errors.InternalServerError("go.vine.client", err.Error())
-		}
-
-		// only sleep if greater than 0
-		if t.Seconds() > 0 {
-			time.Sleep(t)
-		}
-
-		// select next node
-		node, err := next()
-		service := req.Service()
-		if err != nil {
-			if err == selector.ErrNotFound {
-				return errors.InternalServerError("go.vine.client", "service %s: %s", service, err.Error())
-			}
-			return errors.InternalServerError("go.vine.client", "error selecting %s node: %s", service, err.Error())
-		}
-
-		// make the call
-		err = gcall(ctx, node, req, rsp, callOpts)
-		g.opts.Selector.Mark(service, node, err)
-		if verr, ok := err.(*errors.Error); ok {
-			return verr
-		}
-
-		return err
-	}
-
-	ch := make(chan error, callOpts.Retries+1)
-	var gerr error
-
-	for i := 0; i <= callOpts.Retries; i++ {
-		go func(i int) {
-			ch <- call(i)
-		}(i)
-
-		select {
-		case <-ctx.Done():
-			return errors.Timeout("go.vine.client", "%v", ctx.Err())
-		case err := <-ch:
-			// if the call succeeded lets bail early
-			if err == nil {
-				return nil
-			}
-
-			retry, rerr := callOpts.Retry(ctx, req, i, err)
-			if rerr != nil {
-				return rerr
-			}
-
-			if !retry {
-				return err
-			}
-
-			gerr = err
-		}
-	}
-
-	return gerr
+	return g.caller.Call(ctx, req, rsp, opts...)
 }
 
 func (g *grpcClient) Stream(ctx context.Context, req client.Request, opts ...client.CallOption) (client.Stream, error) {
-	// make a copy of call opts
-	callOpts := g.opts.CallOptions
-	for _, opt := range opts {
-		opt(&callOpts)
-	}
-
-	next, err := g.next(req, callOpts)
-	if err != nil {
-		return nil, err
-	}
-
-	// #200 - streams shouldn't have a request timeout set on the context
-
-	// should we noop right here?
-	select {
-	case <-ctx.Done():
-		return nil, errors.Timeout("go.vine.client", "%v", ctx.Err())
-	default:
-	}
-
-	// make a copy of stream
-	gstream := g.stream
-
-	// wrap the call in reverse
-	for i := len(callOpts.CallWrappers); i > 0; i-- {
-		gstream = callOpts.CallWrappers[i-1](gstream)
-	}
-
-	call := func(i int) (client.Stream, error) {
-		// call backoff first. Someone may want an initial start delay
-		t, err := callOpts.Backoff(ctx, req, i)
-		if err != nil {
-			return nil, errors.InternalServerError("go.vine.client", err.Error())
-		}
-
-		// only sleep if greater than 0
-		if t.Seconds() > 0 {
-			time.Sleep(t)
-		}
-
-		node, err := next()
-		service := req.Service()
-		if err != nil {
-			if err == selector.ErrNotFound {
-				return nil, errors.InternalServerError("go.vine.client", "service %s: %s", service, err.Error())
-			}
-			return nil, errors.InternalServerError("go.vine.client", "error selecting %s node: %s", service, err.Error())
-		}
-
-		// make the call
-		stream := &grpcStream{}
-		err = g.stream(ctx, node, req, stream, callOpts)
-
-		g.opts.Selector.Mark(service, node, err)
-		return stream, err
-	}
-
-	type response struct {
-		stream client.Stream
-		err    error
-	}
-
-	ch := make(chan response, callOpts.Retries+1)
-	var grr error
-
-	for i := 0; i <= callOpts.Retries; i++ {
-		go func(i int) {
-			s, err := call(i)
-			ch <- response{s, err}
-		}(i)
-
-		select {
-		case <-ctx.Done():
-			return nil, errors.Timeout("go.vine.client", "%v", ctx.Err())
-		case rsp := <-ch:
-			// if the call succeeded lets bail early
-			if rsp.err == nil {
-				return rsp.stream, nil
-			}
-
-			retry, rerr := callOpts.Retry(ctx, req, i, err)
-			if rerr != nil {
-				return nil, rerr
-			}
-
-			if !retry {
-				return nil, rsp.err
-			}
-
-			grr = rsp.err
-		}
-	}
-
-	return nil, grr
+	return g.caller.Stream(ctx, req, opts...)
 }
 
 func (g *grpcClient) Publish(ctx context.Context, p client.Message, opts ...client.PublishOption) error {
-	var options client.PublishOptions
-	for _, o := range opts {
-		o(&options)
-	}
-
-	md, ok := metadata.FromContext(ctx)
-	if !ok {
-		md = make(map[string]string)
-	}
-	md["Content-Type"] = p.ContentType()
-	md["Vine-Topic"] = p.Topic()
-
-	cf, err := g.newGRPCCodec(p.ContentType())
-	if err != nil {
-		return errors.InternalServerError("go.vine.client", err.Error())
-	}
-
-	var body []byte
-
-	// passed in raw data
-	if d, ok := p.Payload().(*bytes.Frame); ok {
-		body = d.Data
-	} else {
-		// set the body
-		b, err := cf.Marshal(p.Payload())
-		if err != nil {
-			return errors.InternalServerError("go.vine.client", err.Error())
-		}
-		body = b
-	}
-
-	if !g.once.Load().(bool) {
-		if err = g.opts.Broker.Connect(); err != nil {
-			return errors.InternalServerError("go.vine.client", err.Error())
-		}
-		g.once.Store(true)
-	}
-
-	topic := p.Topic()
-
-	// get the exchange
-	if len(options.Exchange) > 0 {
-		topic = options.Exchange
-	}
-
-	msg := &broker.Message{
-		Header: md,
-		Body:   body,
-	}
-	return g.opts.Broker.Publish(topic, msg, broker.PublishContext(options.Context))
+	return g.caller.Publish(ctx, p, opts...)
 }
 
 func (g *grpcClient) String() string {
@@ -709,10 +503,23 @@ func newClient(opts ...client.Option) client.Client {
 		o(&options)
 	}
 
+	if options.Cache == nil {
+		options.Cache = cache.NewLRU(cache.DefaultSize)
+	}
+
 	rc := &grpcClient{
 		opts: options,
 	}
-	rc.once.Store(false)
+	rc.caller = &client.Caller{
+		Client:   rc,
+		Codec:    rc.newCodec,
+		CallNode: rc.call,
+		StreamNode: func(ctx context.Context, node *regpb.Node, req client.Request, opts client.CallOptions) (client.Stream, error) {
+			stream := &grpcStream{}
+			err := rc.stream(ctx, node, req, stream, opts)
+			return stream, err
+		},
+	}
 
 	rc.pool = newPool(options.PoolSize, options.PoolTTL, rc.poolMaxIdle(), rc.poolMaxStreams())
 
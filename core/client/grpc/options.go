@@ -50,6 +50,7 @@ type poolMaxStreams struct{}
 type poolMaxIdle struct{}
 type codecsKey struct{}
 type tlsAuth struct{}
+type tlsAuthFunc struct{}
 type maxRecvMsgSizeKey struct{}
 type maxSendMsgSizeKey struct{}
 type grpcDialOptions struct{}
@@ -100,6 +101,17 @@ func AuthTLS(t *tls.Config) client.Option {
 	}
 }
 
+// AuthServiceTLS sets the tls config of every service, it's used to verify
+// the identity of the service, e.g. mtls.Provider.ClientConfig
+func AuthServiceTLS(fn func(service string) *tls.Config) client.Option {
+	return func(o *client.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, tlsAuthFunc{}, fn)
+	}
+}
+
 // MaxRecvMsgSize set the maximum size of message that client can receive
 func MaxRecvMsgSize(s int) client.Option {
 	return func(o *client.Options) {
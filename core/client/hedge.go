@@ -0,0 +1,144 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package client
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+
+	"github.com/lack-io/vine/core/client/selector"
+	"github.com/lack-io/vine/proto/apis/errors"
+	regpb "github.com/lack-io/vine/proto/apis/registry"
+)
+
+// Hedge makes the call to the node, and sends the hedged requests to other
+// nodes after every HedgeDelay without response. The first response wins
+// and the others are cancelled. The nodes are marked in the selector s
+// unless it's nil.
+func Hedge(ctx context.Context, s selector.Selector, next selector.Next, node *regpb.Node, req Request, rsp interface{}, opts CallOptions, call CallFunc) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		rsp interface{}
+		err error
+	}
+
+	ch := make(chan result, opts.Hedges+1)
+	used := map[string]bool{}
+	service := req.Service()
+
+	send := func(node *regpb.Node) error {
+		used[node.Id] = true
+		// every request decodes into its own response to avoid racing on rsp
+		r, err := newResponse(rsp)
+		if err != nil {
+			return err
+		}
+		go func() {
+			err := call(ctx, node, req, r, opts)
+			// don't penalize the node for the cancelled request
+			if s != nil && ctx.Err() == nil {
+				s.Mark(service, node, err)
+			}
+			ch <- result{rsp: r, err: err}
+		}()
+		return nil
+	}
+
+	if err := send(node); err != nil {
+		return err
+	}
+	pending, hedges := 1, 0
+
+	timer := time.NewTimer(opts.HedgeDelay)
+	defer timer.Stop()
+
+	var gerr error
+	for pending > 0 {
+		select {
+		case <-ctx.Done():
+			if ctx.Err() == context.Canceled {
+				return errors.Canceled("go.vine.client", "%v", ctx.Err())
+			}
+			return errors.Timeout("go.vine.client", "%v", ctx.Err())
+		case res := <-ch:
+			pending--
+			if res.err == nil {
+				setResponse(rsp, res.rsp)
+				return nil
+			}
+			gerr = res.err
+		case <-timer.C:
+			if hedges >= opts.Hedges {
+				continue
+			}
+			if n := pick(next, used); n != nil && send(n) == nil {
+				pending++
+			}
+			hedges++
+			timer.Reset(opts.HedgeDelay)
+		}
+	}
+
+	return gerr
+}
+
+// pick selects a node which isn't used by the call
+func pick(next selector.Next, used map[string]bool) *regpb.Node {
+	for i := 0; i < 3; i++ {
+		node, err := next()
+		if err != nil {
+			return nil
+		}
+		if !used[node.Id] {
+			return node
+		}
+	}
+	return nil
+}
+
+// setResponse sets rsp to the value of the winning response r
+func setResponse(rsp, r interface{}) {
+	// proto messages must not be copied by value
+	if m, ok := rsp.(proto.Message); ok {
+		if src, ok := r.(proto.Message); ok {
+			m.Reset()
+			proto.Merge(m, src)
+			return
+		}
+	}
+	reflect.ValueOf(rsp).Elem().Set(reflect.ValueOf(r).Elem())
+}
+
+// newResponse returns a new value of the response type, rsp must be a pointer
+func newResponse(rsp interface{}) (interface{}, error) {
+	t := reflect.TypeOf(rsp)
+	if t == nil || t.Kind() != reflect.Ptr {
+		return nil, errors.InternalServerError("go.vine.client", "rsp must be a pointer, got %T", rsp)
+	}
+	return reflect.New(t.Elem()).Interface(), nil
+}
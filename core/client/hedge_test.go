@@ -0,0 +1,157 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lack-io/vine/core/client/selector"
+	"github.com/lack-io/vine/core/codec/bytes"
+	"github.com/lack-io/vine/proto/apis/errors"
+	regpb "github.com/lack-io/vine/proto/apis/registry"
+)
+
+type testSelector struct {
+	selector.Selector
+}
+
+func (s *testSelector) Mark(string, *regpb.Node, error) {}
+
+type testRequest struct {
+	Request
+}
+
+func (r *testRequest) Service() string {
+	return "test"
+}
+
+func TestHedge(t *testing.T) {
+	nodes := []*regpb.Node{{Id: "slow"}, {Id: "fast"}}
+	i := 0
+	next := func() (*regpb.Node, error) {
+		n := nodes[i%len(nodes)]
+		i++
+		return n, nil
+	}
+
+	cancelled := make(chan struct{}, 1)
+	gcall := func(ctx context.Context, node *regpb.Node, req Request, rsp interface{}, opts CallOptions) error {
+		if node.Id == "slow" {
+			<-ctx.Done()
+			cancelled <- struct{}{}
+			return ctx.Err()
+		}
+		rsp.(*bytes.Frame).Data = []byte(node.Id)
+		return nil
+	}
+
+	opts := CallOptions{HedgeDelay: time.Millisecond * 10, Hedges: 1}
+	req, s := &testRequest{}, &testSelector{}
+	node, _ := next()
+
+	rsp := &bytes.Frame{}
+	if err := Hedge(context.TODO(), s, next, node, req, rsp, opts, gcall); err != nil {
+		t.Fatal(err)
+	}
+	if string(rsp.Data) != "fast" {
+		t.Fatalf("expect response of fast node, got %s", rsp.Data)
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("expect the slow request to be cancelled")
+	}
+}
+
+func TestHedgeProto(t *testing.T) {
+	next := func() (*regpb.Node, error) {
+		return &regpb.Node{Id: "fast"}, nil
+	}
+	gcall := func(ctx context.Context, node *regpb.Node, req Request, rsp interface{}, opts CallOptions) error {
+		rsp.(*regpb.Node).Id = node.Id
+		return nil
+	}
+
+	opts := CallOptions{HedgeDelay: time.Millisecond * 10, Hedges: 1}
+	req, s := &testRequest{}, &testSelector{}
+	node, _ := next()
+
+	rsp := &regpb.Node{Address: "stale"}
+	if err := Hedge(context.TODO(), s, next, node, req, rsp, opts, gcall); err != nil {
+		t.Fatal(err)
+	}
+	if rsp.Id != "fast" || len(rsp.Address) > 0 {
+		t.Fatalf("unexpected response %v", rsp)
+	}
+}
+
+func TestHedgeCanceled(t *testing.T) {
+	next := func() (*regpb.Node, error) {
+		return &regpb.Node{Id: "slow"}, nil
+	}
+	gcall := func(ctx context.Context, node *regpb.Node, req Request, rsp interface{}, opts CallOptions) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	opts := CallOptions{HedgeDelay: time.Second, Hedges: 1}
+	req, s := &testRequest{}, &testSelector{}
+	node, _ := next()
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	err := Hedge(ctx, s, next, node, req, &bytes.Frame{}, opts, gcall)
+	if verr := errors.FromErr(err); verr.Code != 499 {
+		t.Fatalf("expect canceled error, got %v", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.TODO(), time.Millisecond)
+	defer cancel()
+	err = Hedge(ctx, s, next, node, req, &bytes.Frame{}, opts, gcall)
+	if verr := errors.FromErr(err); verr.Code != 408 {
+		t.Fatalf("expect timeout error, got %v", err)
+	}
+}
+
+func TestHedgeResponse(t *testing.T) {
+	next := func() (*regpb.Node, error) {
+		return &regpb.Node{Id: "fast"}, nil
+	}
+	gcall := func(ctx context.Context, node *regpb.Node, req Request, rsp interface{}, opts CallOptions) error {
+		return nil
+	}
+
+	opts := CallOptions{HedgeDelay: time.Millisecond * 10, Hedges: 1}
+	req, s := &testRequest{}, &testSelector{}
+	node, _ := next()
+
+	for _, rsp := range []interface{}{bytes.Frame{}, nil} {
+		err := Hedge(context.TODO(), s, next, node, req, rsp, opts, gcall)
+		if verr := errors.FromErr(err); verr == nil || verr.Code != 500 {
+			t.Fatalf("expect internal server error of %T, got %v", rsp, err)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/fasthttp/websocket"
+
+	"github.com/lack-io/vine/proto/apis/errors"
+)
+
+const (
+	// closeErrorBase is added to the code of error by the server when the
+	// websocket is closed by an error
+	closeErrorBase = 4000
+)
+
+// vineError parses the error of response, the status is the code when the
+// body isn't a vine error, e.g. the response of load balancer
+func vineError(status int, body []byte) error {
+	if e := errors.Parse(string(body)); e.Code > 0 {
+		return e // actually a vine error
+	}
+
+	detail := strings.TrimSpace(string(body))
+	if len(detail) == 0 {
+		detail = http.StatusText(status)
+	}
+	return errors.New("go.vine.client", detail, int32(status))
+}
+
+// closeError converts the close frame of stream, the normal closure is the
+// end of stream
+func closeError(err *websocket.CloseError) error {
+	switch {
+	case err.Code == websocket.CloseNormalClosure:
+		return nil
+	case err.Code > closeErrorBase && err.Code < closeErrorBase+1000:
+		return vineError(err.Code-closeErrorBase, []byte(err.Text))
+	}
+	return errors.InternalServerError("go.vine.client", "%v", err)
+}
@@ -0,0 +1,402 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package http is the client.Client over plain HTTP/1.1 and HTTP/2, the
+// call is POST /Service.Endpoint and the streams are made over websocket.
+package http
+
+import (
+	b "bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fasthttp/websocket"
+
+	"github.com/lack-io/vine/core/client"
+	"github.com/lack-io/vine/core/client/cache"
+	"github.com/lack-io/vine/core/client/rpc"
+	"github.com/lack-io/vine/core/codec"
+	"github.com/lack-io/vine/proto/apis/errors"
+	regpb "github.com/lack-io/vine/proto/apis/registry"
+	"github.com/lack-io/vine/util/context/metadata"
+)
+
+type httpClient struct {
+	opts   client.Options
+	caller *client.Caller
+
+	sync.Mutex
+	// transports are the connection pools of the tls configs
+	transports map[string]*http.Transport
+}
+
+// secure returns the tls config for whether its a secure or insecure connection
+func (h *httpClient) secure(service, addr string) *tls.Config {
+	// first we check if there's tls config
+	if h.opts.Context != nil {
+		if fn, ok := h.opts.Context.Value(tlsAuthFunc{}).(func(string) *tls.Config); ok && fn != nil {
+			return fn(service)
+		}
+		if v, ok := h.opts.Context.Value(tlsAuth{}).(*tls.Config); ok && v != nil {
+			return v
+		}
+	}
+
+	// check if the address is prepended with https
+	if strings.HasPrefix(addr, "https://") {
+		return &tls.Config{}
+	}
+
+	// if no port is specified or port is 443 default to tls
+	_, port, err := net.SplitHostPort(addr)
+	// assuming with no port its going to be secured
+	if port == "443" {
+		return &tls.Config{}
+	} else if err != nil && strings.Contains(err.Error(), "missing port in address") {
+		return &tls.Config{}
+	}
+
+	// other fallback to insecure
+	return nil
+}
+
+// transport returns the connection pool of the service, the services which
+// verify the identity by the tls config have their own pools
+func (h *httpClient) transport(service string, tc *tls.Config) *http.Transport {
+	key := ""
+	if tc != nil {
+		key = "tls"
+		if h.opts.Context != nil && h.opts.Context.Value(tlsAuthFunc{}) != nil {
+			key = "tls:" + service
+		}
+	}
+
+	h.Lock()
+	defer h.Unlock()
+
+	if tr, ok := h.transports[key]; ok {
+		return tr
+	}
+
+	tr := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   h.opts.CallOptions.DialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:     tc,
+		ForceAttemptHTTP2:   true,
+		MaxIdleConnsPerHost: h.opts.PoolSize,
+		IdleConnTimeout:     h.opts.PoolTTL,
+	}
+	h.transports[key] = tr
+	return tr
+}
+
+// target returns the url of endpoint on the node
+func target(scheme, addr, endpoint string) string {
+	if i := strings.Index(addr, "://"); i >= 0 {
+		addr = addr[i+3:]
+	}
+	if !strings.HasPrefix(endpoint, "/") {
+		endpoint = "/" + endpoint
+	}
+	u := url.URL{Scheme: scheme, Host: addr, Path: endpoint}
+	return u.String()
+}
+
+// header returns the request headers of metadata
+func header(ctx context.Context, contentType string) http.Header {
+	hdr := http.Header{}
+	if md, ok := metadata.FromContext(ctx); ok {
+		for k, v := range md {
+			hdr.Set(k, v)
+		}
+	}
+	// set the content type for the request
+	hdr.Set("Content-Type", contentType)
+	return hdr
+}
+
+func (h *httpClient) call(ctx context.Context, node *regpb.Node, req client.Request, rsp interface{}, opts client.CallOptions) error {
+	hdr := header(ctx, req.ContentType())
+
+	// set timeout in nanoseconds, it's the remaining time before the deadline
+	// so that the timeout shrinks across the call chain
+	timeout := opts.RequestTimeout
+	if d, ok := ctx.Deadline(); ok {
+		timeout = time.Until(d)
+	}
+	if timeout <= 0 {
+		return errors.Timeout("go.vine.client", "%v", context.DeadlineExceeded)
+	}
+	hdr.Set("Timeout", fmt.Sprintf("%d", timeout))
+
+	cf, err := h.newCodec(req.ContentType())
+	if err != nil {
+		return errors.InternalServerError("go.vine.client", "%v", err)
+	}
+
+	body, err := cf.Marshal(req.Body())
+	if err != nil {
+		return errors.InternalServerError("go.vine.client", "%v", err)
+	}
+
+	tc := h.secure(req.Service(), node.Address)
+	scheme := "http"
+	if tc != nil {
+		scheme = "https"
+	}
+
+	hreq, err := http.NewRequestWithContext(ctx, http.MethodPost, target(scheme, node.Address, req.Endpoint()), b.NewReader(body))
+	if err != nil {
+		return errors.InternalServerError("go.vine.client", "%v", err)
+	}
+	hreq.Header = hdr
+
+	hc := &http.Client{Transport: h.transport(req.Service(), tc)}
+	hrsp, err := hc.Do(hreq)
+	if err != nil {
+		if ctx.Err() != nil {
+			return errors.Timeout("go.vine.client", "%v", ctx.Err())
+		}
+		return errors.InternalServerError("go.vine.client", "Error sending request: %v", err)
+	}
+	defer hrsp.Body.Close()
+
+	data, err := readAll(hrsp.Body, h.maxRecvMsgSizeValue())
+	if err != nil {
+		if ctx.Err() != nil {
+			return errors.Timeout("go.vine.client", "%v", ctx.Err())
+		}
+		return errors.InternalServerError("go.vine.client", "Error reading response: %v", err)
+	}
+
+	if hrsp.StatusCode != http.StatusOK {
+		return vineError(hrsp.StatusCode, data)
+	}
+
+	if err := cf.Unmarshal(data, rsp); err != nil {
+		return errors.InternalServerError("go.vine.client", "%v", err)
+	}
+	return nil
+}
+
+func (h *httpClient) stream(ctx context.Context, node *regpb.Node, req client.Request, rsp interface{}, opts client.CallOptions) error {
+	hdr := header(ctx, req.ContentType())
+
+	// set timeout in nanoseconds
+	if opts.StreamTimeout > time.Duration(0) {
+		hdr.Set("Timeout", fmt.Sprintf("%d", opts.StreamTimeout))
+	}
+
+	cf, err := h.newCodec(req.ContentType())
+	if err != nil {
+		return errors.InternalServerError("go.vine.client", "%v", err)
+	}
+
+	tc := h.secure(req.Service(), node.Address)
+	scheme := "ws"
+	if tc != nil {
+		scheme = "wss"
+	}
+
+	dialer := &websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: opts.DialTimeout,
+		TLSClientConfig:  tc,
+	}
+
+	conn, hrsp, err := dialer.DialContext(ctx, target(scheme, node.Address, req.Endpoint()), hdr)
+	if err != nil {
+		// the server replies the error instead of upgrading
+		if hrsp != nil && hrsp.StatusCode != http.StatusSwitchingProtocols {
+			data, _ := readAll(hrsp.Body, h.maxRecvMsgSizeValue())
+			hrsp.Body.Close()
+			return vineError(hrsp.StatusCode, data)
+		}
+		return errors.InternalServerError("go.vine.client", "Error creating stream: %v", err)
+	}
+	conn.SetReadLimit(int64(h.maxRecvMsgSizeValue()))
+
+	// create a new cancelling context
+	newCtx, cancel := context.WithCancel(ctx)
+
+	op := websocket.BinaryMessage
+	if req.ContentType() == "application/json" {
+		op = websocket.TextMessage
+	}
+
+	stream := &httpStream{
+		ctx:     ctx,
+		request: req,
+		conn:    conn,
+		op:      op,
+		codec:   cf,
+		cancel:  cancel,
+	}
+
+	sc := rpc.NewStreamCodec(h.String(), stream)
+
+	rh := make(map[string]string, len(hrsp.Header))
+	for k, v := range hrsp.Header {
+		rh[k] = strings.Join(v, ",")
+	}
+	stream.response = rpc.NewResponse(rh, sc)
+
+	// set request codec
+	rpc.SetCodec(req, sc)
+
+	// close the websocket when the context is done
+	go func() {
+		<-newCtx.Done()
+		_ = stream.Close()
+	}()
+
+	// set the stream as the response
+	val := reflect.ValueOf(rsp).Elem()
+	val.Set(reflect.ValueOf(stream).Elem())
+	return nil
+}
+
+func (h *httpClient) maxRecvMsgSizeValue() int {
+	if h.opts.Context == nil {
+		return DefaultMaxRecvMsgSize
+	}
+	v := h.opts.Context.Value(maxRecvMsgSizeKey{})
+	if v == nil {
+		return DefaultMaxRecvMsgSize
+	}
+	return v.(int)
+}
+
+func (h *httpClient) newCodec(contentType string) (codec.Marshaler, error) {
+	return rpc.NewCodec(h.opts, contentType)
+}
+
+func (h *httpClient) Init(opts ...client.Option) error {
+	for _, o := range opts {
+		o(&h.opts)
+	}
+
+	// the pools are created again with the new options
+	h.Lock()
+	for _, tr := range h.transports {
+		tr.CloseIdleConnections()
+	}
+	h.transports = make(map[string]*http.Transport)
+	h.Unlock()
+
+	return nil
+}
+
+func (h *httpClient) Options() client.Options {
+	return h.opts
+}
+
+func (h *httpClient) NewMessage(topic string, msg interface{}, opts ...client.MessageOption) client.Message {
+	return rpc.NewMessage(topic, msg, h.opts.ContentType, opts...)
+}
+
+func (h *httpClient) NewRequest(service, method string, req interface{}, reqOpts ...client.RequestOption) client.Request {
+	return rpc.NewRequest(service, method, req, h.opts.ContentType, reqOpts...)
+}
+
+func (h *httpClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	return h.caller.Call(ctx, req, rsp, opts...)
+}
+
+func (h *httpClient) Stream(ctx context.Context, req client.Request, opts ...client.CallOption) (client.Stream, error) {
+	return h.caller.Stream(ctx, req, opts...)
+}
+
+func (h *httpClient) Publish(ctx context.Context, p client.Message, opts ...client.PublishOption) error {
+	return h.caller.Publish(ctx, p, opts...)
+}
+
+func (h *httpClient) String() string {
+	return "http"
+}
+
+func newClient(opts ...client.Option) client.Client {
+	options := client.NewOptions()
+	// default content type for http
+	options.ContentType = "application/json"
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	if options.Cache == nil {
+		options.Cache = cache.NewLRU(cache.DefaultSize)
+	}
+
+	rc := &httpClient{
+		opts:       options,
+		transports: make(map[string]*http.Transport),
+	}
+	rc.caller = &client.Caller{
+		Client:   rc,
+		Codec:    rc.newCodec,
+		CallNode: rc.call,
+		StreamNode: func(ctx context.Context, node *regpb.Node, req client.Request, opts client.CallOptions) (client.Stream, error) {
+			stream := &httpStream{}
+			err := rc.stream(ctx, node, req, stream, opts)
+			return stream, err
+		},
+	}
+
+	c := client.Client(rc)
+
+	// wrap in reverse
+	for i := len(options.Wrappers); i > 0; i-- {
+		c = options.Wrappers[i-1](c)
+	}
+
+	return c
+}
+
+func NewClient(opts ...client.Option) client.Client {
+	return newClient(opts...)
+}
+
+// readAll reads the body up to the maximum size
+func readAll(r io.Reader, n int) ([]byte, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r, int64(n)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > n {
+		return nil, fmt.Errorf("message larger than max (%d vs. %d)", len(data), n)
+	}
+	return data, nil
+}
@@ -0,0 +1,77 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package http
+
+import (
+	"context"
+	"crypto/tls"
+
+	"github.com/lack-io/vine/core/client"
+	"github.com/lack-io/vine/core/client/rpc"
+	"github.com/lack-io/vine/core/codec"
+)
+
+var (
+	// DefaultMaxRecvMsgSize maximum message that client can receive (200 MB)
+	DefaultMaxRecvMsgSize = 1024 * 1024 * 200
+)
+
+type tlsAuth struct{}
+type tlsAuthFunc struct{}
+type maxRecvMsgSizeKey struct{}
+
+// Codec to be used to encode/decode requests for a given content type
+func Codec(contentType string, c codec.Marshaler) client.Option {
+	return rpc.Codec(contentType, c)
+}
+
+// AuthTLS should be used to setup a secure authentication using TLS
+func AuthTLS(t *tls.Config) client.Option {
+	return func(o *client.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, tlsAuth{}, t)
+	}
+}
+
+// AuthServiceTLS sets the tls config of every service, it's used to verify
+// the identity of the service, e.g. mtls.Provider.ClientConfig
+func AuthServiceTLS(fn func(service string) *tls.Config) client.Option {
+	return func(o *client.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, tlsAuthFunc{}, fn)
+	}
+}
+
+// MaxRecvMsgSize set the maximum size of message that client can receive
+func MaxRecvMsgSize(s int) client.Option {
+	return func(o *client.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, maxRecvMsgSizeKey{}, s)
+	}
+}
@@ -0,0 +1,128 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package http
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/fasthttp/websocket"
+
+	"github.com/lack-io/vine/core/client"
+	"github.com/lack-io/vine/core/codec"
+)
+
+// Implements the streamer interface over websocket
+type httpStream struct {
+	sync.RWMutex
+	// wmu serializes the writes of conn
+	wmu      sync.Mutex
+	closed   bool
+	err      error
+	conn     *websocket.Conn
+	op       int
+	codec    codec.Marshaler
+	request  client.Request
+	response client.Response
+	ctx      context.Context
+	cancel   func()
+}
+
+func (h *httpStream) Context() context.Context {
+	return h.ctx
+}
+
+func (h *httpStream) Request() client.Request {
+	return h.request
+}
+
+func (h *httpStream) Response() client.Response {
+	return h.response
+}
+
+func (h *httpStream) Send(msg interface{}) error {
+	b, err := h.codec.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	h.wmu.Lock()
+	err = h.conn.WriteMessage(h.op, b)
+	h.wmu.Unlock()
+	if err != nil {
+		h.setError(err)
+		return err
+	}
+	return nil
+}
+
+func (h *httpStream) Recv(msg interface{}) (err error) {
+	defer func() {
+		h.setError(err)
+	}()
+
+	_, b, err := h.conn.ReadMessage()
+	if err != nil {
+		// the server closes the stream with the error of handler
+		if cerr, ok := err.(*websocket.CloseError); ok {
+			if err = closeError(cerr); err == nil {
+				err = io.EOF
+			}
+		}
+		_ = h.Close()
+		return err
+	}
+
+	return h.codec.Unmarshal(b, msg)
+}
+
+func (h *httpStream) Error() error {
+	h.RLock()
+	defer h.RUnlock()
+	return h.err
+}
+
+func (h *httpStream) setError(e error) {
+	h.Lock()
+	h.err = e
+	h.Unlock()
+}
+
+// Close the websocket, the server reads the end of stream
+func (h *httpStream) Close() error {
+	h.Lock()
+	defer h.Unlock()
+
+	if h.closed {
+		return nil
+	}
+	// cancel the context
+	h.cancel()
+	h.closed = true
+
+	h.wmu.Lock()
+	_ = h.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	h.wmu.Unlock()
+	return h.conn.Close()
+}
@@ -45,6 +45,14 @@ type Options struct {
 	// Router sets the router
 	Router Router
 
+	// Cache stores the responses of calls with CacheExpiry
+	Cache Cache
+	// CacheMetadata is the metadata keys which are part of the cache key
+	CacheMetadata []string
+
+	// Budget limits the retries of every service, nil is unlimited
+	Budget *Budget
+
 	// Connection Pool
 	PoolSize int
 	PoolTTL  time.Duration
@@ -81,6 +89,16 @@ type CallOptions struct {
 	ServiceToken bool
 	// Duration to cache the response for
 	CacheExpiry time.Duration
+	// Compressor is the algorithm used to compress the request,
+	// it's used only when the remote node supports it
+	Compressor string
+	// CompressThreshold is the minimum request size to be compressed
+	CompressThreshold int
+	// HedgeDelay is the delay before sending the hedged request to
+	// another node, zero disables hedging
+	HedgeDelay time.Duration
+	// Hedges is the maximum number of hedged requests per attempt
+	Hedges int
 
 	// Middleware for low level call func
 	CallWrappers []CallWrapper
@@ -93,6 +111,10 @@ type CallOptions struct {
 type PublishOptions struct {
 	// Exchange is the routing exchange for the message
 	Exchange string
+	// Compressor is the algorithm used to compress the message body
+	Compressor string
+	// CompressThreshold is the minimum body size to be compressed
+	CompressThreshold int
 	// Other options for implementations of the interface
 	// can be stored in a context
 	Context context.Context
@@ -226,6 +248,23 @@ func Retry(fn RetryFunc) Option {
 	}
 }
 
+// Hedging sets the default hedging of calls, the call is sent to another node
+// when there is no response after delay, at most hedges times.
+func Hedging(delay time.Duration, hedges int) Option {
+	return func(o *Options) {
+		o.CallOptions.HedgeDelay = delay
+		o.CallOptions.Hedges = hedges
+	}
+}
+
+// RetryBudget limits the retries of every service to a ratio of its
+// recent requests
+func RetryBudget(b *Budget) Option {
+	return func(o *Options) {
+		o.Budget = b
+	}
+}
+
 // RequestTimeout the request timeout.
 // Should this be a Call Option?
 func RequestTimeout(d time.Duration) Option {
@@ -248,6 +287,14 @@ func DialTimeout(d time.Duration) Option {
 	}
 }
 
+// Compression sets the default compressor and the size threshold of calls
+func Compression(name string, threshold int) Option {
+	return func(o *Options) {
+		o.CallOptions.Compressor = name
+		o.CallOptions.CompressThreshold = threshold
+	}
+}
+
 // Call Options
 
 // WithExchange sets the exchange to route a message through
@@ -264,6 +311,15 @@ func PublishContext(ctx context.Context) PublishOption {
 	}
 }
 
+// PublishCompressor compresses the message body by the named algorithm
+// when the body is larger than threshold
+func PublishCompressor(name string, threshold int) PublishOption {
+	return func(o *PublishOptions) {
+		o.Compressor = name
+		o.CompressThreshold = threshold
+	}
+}
+
 // WithAddress sets the remote addresses to use rather than using service discovery
 func WithAddress(a ...string) CallOption {
 	return func(o *CallOptions) {
@@ -347,6 +403,25 @@ func WithCache(c time.Duration) CallOption {
 	}
 }
 
+// WithCompressor is a CallOption which compresses the request by the named
+// algorithm when the request is larger than threshold
+func WithCompressor(name string, threshold int) CallOption {
+	return func(o *CallOptions) {
+		o.Compressor = name
+		o.CompressThreshold = threshold
+	}
+}
+
+// WithHedging is a CallOption which sends the request to another node when
+// there is no response after delay, the first response wins and the
+// others are cancelled.
+func WithHedging(delay time.Duration, hedges int) CallOption {
+	return func(o *CallOptions) {
+		o.HedgeDelay = delay
+		o.Hedges = hedges
+	}
+}
+
 func WithMessageContentType(ct string) MessageOption {
 	return func(o *MessageOptions) {
 		o.ContentType = ct
@@ -367,6 +442,15 @@ func StreamingRequest() RequestOption {
 	}
 }
 
+// ResponseCache sets the cache of responses, the values of metadata keys
+// are part of the cache key, e.g. Authorization
+func ResponseCache(c Cache, keys ...string) Option {
+	return func(o *Options) {
+		o.Cache = c
+		o.CacheMetadata = keys
+	}
+}
+
 // WithRouter sets the client router
 func WithRouter(r Router) Option {
 	return func(o *Options) {
@@ -24,6 +24,8 @@ package client
 
 import (
 	"context"
+	"strconv"
+	"strings"
 
 	"github.com/lack-io/vine/proto/apis/errors"
 )
@@ -55,3 +57,94 @@ func RetryOnError(ctx context.Context, req Request, retryCount int, err error) (
 		return false, nil
 	}
 }
+
+const (
+	// RetryCodesKey is the endpoint metadata key of retryable error codes, e.g. "408,503"
+	RetryCodesKey = "retry_codes"
+	// RetryAttemptsKey is the endpoint metadata key of the maximum attempts of call
+	RetryAttemptsKey = "retry_attempts"
+	// IdempotentKey is the endpoint metadata key which marks the endpoint idempotent
+	IdempotentKey = "idempotent"
+)
+
+// RetryPolicy is the retry policy which services publish in endpoint metadata,
+// clients honour it instead of CallOptions.Retry and CallOptions.Retries.
+type RetryPolicy struct {
+	// Codes is the retryable error codes, defaults to 408 and 503
+	Codes []int32
+	// Attempts is the maximum attempts of call including the first one,
+	// zero keeps CallOptions.Retries
+	Attempts int
+	// Idempotent marks the endpoint safe to be called more than once, the
+	// request of non idempotent endpoint is only retried when it's rejected
+	// before processing (503) and never hedged.
+	Idempotent bool
+}
+
+// Metadata returns the endpoint metadata of policy, it's used by services
+// with server.EndpointMetadata
+func (p *RetryPolicy) Metadata() map[string]string {
+	md := map[string]string{
+		IdempotentKey: strconv.FormatBool(p.Idempotent),
+	}
+	if len(p.Codes) > 0 {
+		codes := make([]string, 0, len(p.Codes))
+		for _, c := range p.Codes {
+			codes = append(codes, strconv.Itoa(int(c)))
+		}
+		md[RetryCodesKey] = strings.Join(codes, ",")
+	}
+	if p.Attempts > 0 {
+		md[RetryAttemptsKey] = strconv.Itoa(p.Attempts)
+	}
+	return md
+}
+
+// Retry checks if the error is retryable by the policy
+func (p *RetryPolicy) Retry(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	code := errors.Parse(err.Error()).Code
+	if !p.Idempotent {
+		return code == 503
+	}
+
+	codes := p.Codes
+	if len(codes) == 0 {
+		codes = []int32{408, 503}
+	}
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseRetryPolicy returns the retry policy of endpoint metadata, nil if the
+// endpoint doesn't publish any
+func ParseRetryPolicy(md map[string]string) *RetryPolicy {
+	codes, cok := md[RetryCodesKey]
+	attempts, aok := md[RetryAttemptsKey]
+	idempotent, iok := md[IdempotentKey]
+	if !cok && !aok && !iok {
+		return nil
+	}
+
+	// the endpoint publishing retry codes is idempotent unless told otherwise
+	p := &RetryPolicy{Idempotent: !iok}
+	if iok {
+		p.Idempotent, _ = strconv.ParseBool(idempotent)
+	}
+	if aok {
+		p.Attempts, _ = strconv.Atoi(attempts)
+	}
+	for _, s := range strings.Split(codes, ",") {
+		if c, err := strconv.Atoi(strings.TrimSpace(s)); err == nil {
+			p.Codes = append(p.Codes, int32(c))
+		}
+	}
+	return p
+}
@@ -0,0 +1,97 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package client
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lack-io/vine/proto/apis/errors"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	if p := ParseRetryPolicy(map[string]string{"stream": "true"}); p != nil {
+		t.Fatalf("expect no policy, got %v", p)
+	}
+
+	p := ParseRetryPolicy((&RetryPolicy{Codes: []int32{500}, Attempts: 3, Idempotent: true}).Metadata())
+	if p == nil || p.Attempts != 3 || !p.Idempotent || len(p.Codes) != 1 || p.Codes[0] != 500 {
+		t.Fatalf("unexpected policy %v", p)
+	}
+	if !p.Retry(errors.InternalServerError("test", "")) {
+		t.Fatal("expect 500 to be retried")
+	}
+	if p.Retry(errors.Timeout("test", "")) {
+		t.Fatal("expect 408 not to be retried")
+	}
+
+	p = ParseRetryPolicy(map[string]string{IdempotentKey: "false"})
+	if p.Retry(errors.Timeout("test", "")) {
+		t.Fatal("expect 408 of non idempotent endpoint not to be retried")
+	}
+	if !p.Retry(errors.New("test", "", 503)) {
+		t.Fatal("expect 503 of non idempotent endpoint to be retried")
+	}
+}
+
+func TestBudget(t *testing.T) {
+	b := NewBudget(0.1, 2, time.Minute)
+
+	for i := 0; i < 10; i++ {
+		b.Request("a")
+	}
+	// min retries
+	if !b.Retry("a") || !b.Retry("a") {
+		t.Fatal("expect min retries to be allowed")
+	}
+	if b.Retry("a") {
+		t.Fatal("expect retry to be rejected")
+	}
+
+	for i := 0; i < 20; i++ {
+		b.Request("a")
+	}
+	if !b.Retry("a") {
+		t.Fatal("expect retry to be allowed by ratio")
+	}
+
+	// the budget is per service
+	if !b.Retry("b") {
+		t.Fatal("expect retry of other service to be allowed")
+	}
+}
+
+func TestBudgetEviction(t *testing.T) {
+	b := NewBudget(0.1, 1, time.Millisecond*10)
+	for i := 0; i < 100; i++ {
+		b.Request(fmt.Sprintf("s%d", i))
+	}
+	time.Sleep(time.Millisecond * 20)
+
+	// the idle services are evicted
+	b.Request("a")
+	if n := len(b.services); n != 1 {
+		t.Fatalf("expect the idle services to be evicted, got %d services", n)
+	}
+}
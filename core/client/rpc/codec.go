@@ -0,0 +1,103 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package rpc is the shared implementation of the clients over the
+// transports which exchange the encoded messages, e.g. http and broker.
+package rpc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lack-io/vine/core/client"
+	"github.com/lack-io/vine/core/codec"
+	"github.com/lack-io/vine/core/codec/bytes"
+	srpc "github.com/lack-io/vine/core/server/rpc"
+)
+
+type codecsKey struct{}
+
+// Codec sets the marshaler to encode/decode the requests of the content type
+func Codec(contentType string, c codec.Marshaler) client.Option {
+	return func(o *client.Options) {
+		codecs := make(map[string]codec.Marshaler)
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		if v, ok := o.Context.Value(codecsKey{}).(map[string]codec.Marshaler); ok && v != nil {
+			codecs = v
+		}
+		codecs[contentType] = c
+		o.Context = context.WithValue(o.Context, codecsKey{}, codecs)
+	}
+}
+
+// NewCodec returns the wrapped marshaler of the content type, the ones set
+// by Codec take precedence over the default codecs of server
+func NewCodec(opts client.Options, contentType string) (codec.Marshaler, error) {
+	if opts.Context != nil {
+		if v, ok := opts.Context.Value(codecsKey{}).(map[string]codec.Marshaler); ok {
+			if c, ok := v[contentType]; ok {
+				return srpc.Wrap(c), nil
+			}
+		}
+	}
+	if c, ok := srpc.DefaultCodecs[contentType]; ok {
+		return srpc.Wrap(c), nil
+	}
+	return nil, fmt.Errorf("unsupported Content-Type: %s", contentType)
+}
+
+// streamCodec is the codec.Codec of stream, the messages are read and
+// written by the stream
+type streamCodec struct {
+	name string
+	s    client.Stream
+}
+
+// NewStreamCodec returns the codec of stream s, name is the name of transport
+func NewStreamCodec(name string, s client.Stream) codec.Codec {
+	return &streamCodec{name: name, s: s}
+}
+
+func (c *streamCodec) ReadHeader(m *codec.Message, mt codec.MessageType) error {
+	return nil
+}
+
+func (c *streamCodec) ReadBody(v interface{}) error {
+	return c.s.Recv(v)
+}
+
+func (c *streamCodec) Write(m *codec.Message, v interface{}) error {
+	if v == nil {
+		v = &bytes.Frame{Data: m.Body}
+	}
+	return c.s.Send(v)
+}
+
+func (c *streamCodec) Close() error {
+	return c.s.Close()
+}
+
+func (c *streamCodec) String() string {
+	return c.name
+}
@@ -0,0 +1,58 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package rpc
+
+import (
+	"github.com/lack-io/vine/core/client"
+	"github.com/lack-io/vine/core/codec"
+	"github.com/lack-io/vine/core/codec/bytes"
+)
+
+type rpcResponse struct {
+	header map[string]string
+	codec  codec.Codec
+}
+
+// NewResponse returns the client.Response which is read by the codec
+func NewResponse(header map[string]string, c codec.Codec) client.Response {
+	return &rpcResponse{header: header, codec: c}
+}
+
+// Codec reads the response
+func (r *rpcResponse) Codec() codec.Reader {
+	return r.codec
+}
+
+// Header reads the header
+func (r *rpcResponse) Header() map[string]string {
+	return r.header
+}
+
+// Read the undecoded response
+func (r *rpcResponse) Read() ([]byte, error) {
+	f := &bytes.Frame{}
+	if err := r.codec.ReadBody(f); err != nil {
+		return nil, err
+	}
+	return f.Data, nil
+}
@@ -29,11 +29,13 @@ import (
 	"github.com/lack-io/vine/core/registry/cache"
 	"github.com/lack-io/vine/core/registry/mdns"
 	regpb "github.com/lack-io/vine/proto/apis/registry"
+	"github.com/lack-io/vine/util/locality"
 )
 
 type registrySelector struct {
 	so Options
 	rc cache.Cache
+	od *detector
 }
 
 func (c *registrySelector) newCache() cache.Cache {
@@ -53,6 +55,9 @@ func (c *registrySelector) Init(opts ...Option) error {
 
 	c.rc.Stop()
 	c.rc = c.newCache()
+	if c.so.Outlier != c.od.opts {
+		c.od = newDetector(c.so.Outlier)
+	}
 
 	return nil
 }
@@ -86,6 +91,12 @@ func (c *registrySelector) Select(service string, opts ...SelectOption) (Next, e
 		services = filter(services)
 	}
 
+	// avoid the nodes shutting down
+	services = skipDraining(services)
+
+	// remove the outlier nodes and prefer the nodes nearby
+	services = preferLocal(services, c.od.filter(service, services), c.so.Locality)
+
 	// if there's nothing left, return
 	if len(services) == 0 {
 		return nil, ErrNoneAvailable
@@ -94,9 +105,18 @@ func (c *registrySelector) Select(service string, opts ...SelectOption) (Next, e
 	return sopts.Strategy(services), nil
 }
 
-func (c *registrySelector) Mark(service string, node *regpb.Node, err error) {}
+func (c *registrySelector) Mark(service string, node *regpb.Node, err error) {
+	c.od.mark(service, node, err)
+}
+
+func (c *registrySelector) Reset(service string) {
+	c.od.reset(service)
+}
 
-func (c *registrySelector) Reset(service string) {}
+// Health returns the health of the nodes of service observed by outlier detection
+func (c *registrySelector) Health(service string) []*NodeHealth {
+	return c.od.health(service)
+}
 
 // Close stops the watcher and destroys the cache
 func (c *registrySelector) Close() error {
@@ -122,8 +142,17 @@ func NewSelector(opts ...Option) Selector {
 		sopts.Registry = mdns.NewRegistry()
 	}
 
+	if sopts.Locality == nil {
+		sopts.Locality = &LocalityOptions{
+			Region:    locality.Region(),
+			Zone:      locality.Zone(),
+			Threshold: DefaultLocalityThreshold,
+		}
+	}
+
 	s := &registrySelector{
 		so: sopts,
+		od: newDetector(sopts.Outlier),
 	}
 	s.rc = s.newCache()
 
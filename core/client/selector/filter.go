@@ -23,6 +23,7 @@
 package selector
 
 import (
+	"github.com/lack-io/vine/core/registry"
 	regpb "github.com/lack-io/vine/proto/apis/registry"
 )
 
@@ -93,3 +94,32 @@ func FilterVersion(version string) Filter {
 		return services
 	}
 }
+
+// skipDraining removes the draining nodes, they are kept when
+// there's no other node
+func skipDraining(old []*regpb.Service) []*regpb.Service {
+	var services []*regpb.Service
+	for _, service := range old {
+		var nodes []*regpb.Node
+		for _, node := range service.Nodes {
+			if node.Metadata[registry.DrainingKey] != "true" {
+				nodes = append(nodes, node)
+			}
+		}
+		if len(nodes) == 0 {
+			continue
+		}
+		if len(nodes) < len(service.Nodes) {
+			serv := new(regpb.Service)
+			*serv = *service
+			serv.Nodes = nodes
+			service = serv
+		}
+		services = append(services, service)
+	}
+
+	if len(services) == 0 {
+		return old
+	}
+	return services
+}
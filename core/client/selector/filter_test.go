@@ -0,0 +1,57 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package selector
+
+import (
+	"testing"
+
+	"github.com/lack-io/vine/core/registry"
+	regpb "github.com/lack-io/vine/proto/apis/registry"
+)
+
+func TestSkipDraining(t *testing.T) {
+	draining := map[string]string{registry.DrainingKey: "true"}
+	services := []*regpb.Service{
+		{
+			Name: "foo",
+			Nodes: []*regpb.Node{
+				{Id: "foo-1", Metadata: draining},
+				{Id: "foo-2"},
+			},
+		},
+	}
+
+	filtered := skipDraining(services)
+	if len(filtered) != 1 || len(filtered[0].Nodes) != 1 || filtered[0].Nodes[0].Id != "foo-2" {
+		t.Fatalf("unexpected nodes %v", filtered)
+	}
+	if len(services[0].Nodes) != 2 {
+		t.Fatal("the services are modified")
+	}
+
+	// the draining nodes are kept when there's no other node
+	services[0].Nodes[1].Metadata = draining
+	if filtered := skipDraining(services); len(filtered[0].Nodes) != 2 {
+		t.Fatalf("unexpected nodes %v", filtered)
+	}
+}
@@ -0,0 +1,110 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package selector
+
+import (
+	regpb "github.com/lack-io/vine/proto/apis/registry"
+	"github.com/lack-io/vine/util/locality"
+)
+
+// DefaultLocalityThreshold is the default healthy ratio of the nearer nodes
+// below which the farther nodes are selected
+var DefaultLocalityThreshold = 0.5
+
+// LocalityOptions is the locality of client
+type LocalityOptions struct {
+	Region string
+	Zone   string
+	// Threshold is the healthy ratio of the nearer nodes, the farther
+	// nodes are selected when the ratio drops below it
+	Threshold float64
+}
+
+func (l *LocalityOptions) enabled() bool {
+	return l != nil && (len(l.Region) > 0 || len(l.Zone) > 0)
+}
+
+// count returns the number of nodes within the distance
+func count(services []*regpb.Service, l *LocalityOptions, d locality.Distance) int {
+	n := 0
+	for _, svc := range services {
+		for _, node := range svc.Nodes {
+			if locality.Between(l.Region, l.Zone, node.Metadata) <= d {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// within returns the services with the nodes within the distance
+func within(services []*regpb.Service, l *LocalityOptions, d locality.Distance) []*regpb.Service {
+	var out []*regpb.Service
+	for _, svc := range services {
+		var nodes []*regpb.Node
+		for _, node := range svc.Nodes {
+			if locality.Between(l.Region, l.Zone, node.Metadata) <= d {
+				nodes = append(nodes, node)
+			}
+		}
+		if len(nodes) > 0 {
+			s := *svc
+			s.Nodes = nodes
+			out = append(out, &s)
+		}
+	}
+	return out
+}
+
+// preferLocal returns the healthy nodes in the zone, or in the region, when
+// enough of them are healthy, otherwise all the healthy nodes are returned.
+// The all are the services before removing the unhealthy nodes.
+func preferLocal(all, healthy []*regpb.Service, l *LocalityOptions) []*regpb.Service {
+	if !l.enabled() {
+		return healthy
+	}
+
+	for _, d := range []locality.Distance{locality.SameZone, locality.SameRegion} {
+		total := count(all, l, d)
+		if total == 0 {
+			continue
+		}
+		n := count(healthy, l, d)
+		if n > 0 && float64(n)/float64(total) >= l.Threshold {
+			return within(healthy, l, d)
+		}
+	}
+
+	return healthy
+}
+
+// FilterLocality is a locality based Select Filter which will only
+// return the nodes in the zone, or in the region when there is no
+// node in the zone. All the nodes are returned when there is no
+// node nearby.
+func FilterLocality(region, zone string) Filter {
+	l := &LocalityOptions{Region: region, Zone: zone}
+	return func(old []*regpb.Service) []*regpb.Service {
+		return preferLocal(old, old, l)
+	}
+}
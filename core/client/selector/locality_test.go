@@ -0,0 +1,90 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package selector
+
+import (
+	"testing"
+
+	regpb "github.com/lack-io/vine/proto/apis/registry"
+	"github.com/lack-io/vine/util/locality"
+)
+
+func localServices() []*regpb.Service {
+	node := func(id, region, zone string) *regpb.Node {
+		return &regpb.Node{Id: id, Metadata: map[string]string{locality.RegionKey: region, locality.ZoneKey: zone}}
+	}
+	return []*regpb.Service{{
+		Name: "test",
+		Nodes: []*regpb.Node{
+			node("a", "r1", "z1"),
+			node("b", "r1", "z1"),
+			node("c", "r1", "z2"),
+			node("d", "r2", "z3"),
+		},
+	}}
+}
+
+func without(services []*regpb.Service, ids ...string) []*regpb.Service {
+	excluded := map[string]bool{}
+	for _, id := range ids {
+		excluded[id] = true
+	}
+	s := *services[0]
+	s.Nodes = nil
+	for _, node := range services[0].Nodes {
+		if !excluded[node.Id] {
+			s.Nodes = append(s.Nodes, node)
+		}
+	}
+	return []*regpb.Service{&s}
+}
+
+func TestPreferLocal(t *testing.T) {
+	l := &LocalityOptions{Region: "r1", Zone: "z1", Threshold: 0.5}
+	all := localServices()
+
+	if ids := nodeIds(preferLocal(all, all, l)); len(ids) != 2 || !ids["a"] || !ids["b"] {
+		t.Fatalf("expect nodes of zone, got %v", ids)
+	}
+
+	// half of the zone is healthy
+	if ids := nodeIds(preferLocal(all, without(all, "a"), l)); len(ids) != 1 || !ids["b"] {
+		t.Fatalf("expect healthy node of zone, got %v", ids)
+	}
+
+	// fail over to the region
+	l.Threshold = 0.6
+	if ids := nodeIds(preferLocal(all, without(all, "a"), l)); len(ids) != 2 || !ids["b"] || !ids["c"] {
+		t.Fatalf("expect nodes of region, got %v", ids)
+	}
+
+	// fail over to all
+	if ids := nodeIds(preferLocal(all, without(all, "a", "b", "c"), l)); len(ids) != 1 || !ids["d"] {
+		t.Fatalf("expect remote node, got %v", ids)
+	}
+
+	// disabled
+	if ids := nodeIds(preferLocal(all, all, &LocalityOptions{})); len(ids) != 4 {
+		t.Fatalf("expect all nodes, got %v", ids)
+	}
+}
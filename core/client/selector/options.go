@@ -31,6 +31,11 @@ import (
 type Options struct {
 	Registry registry.Registry
 	Strategy Strategy
+	// Outlier is the outlier detection of nodes, nil disables it
+	Outlier *OutlierOptions
+	// Locality prefers the nodes near the client, nil uses the locality
+	// of environment
+	Locality *LocalityOptions
 
 	// Other options for implementations of the interface
 	// can be stored in a context
@@ -66,6 +71,27 @@ func SetStrategy(fn Strategy) Option {
 	}
 }
 
+// OutlierDetection enables the outlier detection which ejects the failing
+// nodes, e.g. OutlierDetection(*DefaultOutlierOptions()). It's disabled by
+// default and the zero value disables it.
+func OutlierDetection(o OutlierOptions) Option {
+	return func(opts *Options) {
+		opts.Outlier = &o
+	}
+}
+
+// Locality prefers the nodes in the zone, and then the region. The other nodes
+// are selected when the healthy ratio of the nearer nodes drops below threshold.
+func Locality(region, zone string, threshold float64) Option {
+	return func(o *Options) {
+		o.Locality = &LocalityOptions{
+			Region:    region,
+			Zone:      zone,
+			Threshold: threshold,
+		}
+	}
+}
+
 // WithFilter adds a filter function to the list of filters
 // used during the Select call.
 func WithFilter(fn ...Filter) SelectOption {
@@ -0,0 +1,311 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package selector
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lack-io/vine/proto/apis/errors"
+	regpb "github.com/lack-io/vine/proto/apis/registry"
+)
+
+// OutlierOptions configures the outlier detection which ejects the failing
+// nodes passively observed by Mark
+type OutlierOptions struct {
+	// ConsecutiveErrors ejects the node after the number of consecutive errors,
+	// zero disables it
+	ConsecutiveErrors int
+	// ErrorRate ejects the node when the error rate of interval exceeds it,
+	// zero disables it
+	ErrorRate float64
+	// MinRequests is the minimum requests of interval to check the error rate
+	MinRequests int
+	// Interval is the window of error rate, the ejections of a healthy node
+	// decrease every interval
+	Interval time.Duration
+	// BaseEjection is the ejection time, it grows exponentially with the
+	// ejections of node
+	BaseEjection time.Duration
+	// MaxEjection caps the ejection time
+	MaxEjection time.Duration
+	// MaxEjectionPercent is the maximum percentage of the nodes of a service
+	// to be ejected
+	MaxEjectionPercent int
+	// RampUp is the duration the re-admitted node takes to get full traffic,
+	// an error in this duration ejects the node again
+	RampUp time.Duration
+}
+
+// DefaultOutlierOptions returns the recommended outlier detection, it's
+// enabled by the OutlierDetection option
+func DefaultOutlierOptions() *OutlierOptions {
+	return &OutlierOptions{
+		ConsecutiveErrors:  5,
+		ErrorRate:          0.5,
+		MinRequests:        20,
+		Interval:           time.Second * 10,
+		BaseEjection:       time.Second * 30,
+		MaxEjection:        time.Minute * 5,
+		MaxEjectionPercent: 50,
+		RampUp:             time.Second * 10,
+	}
+}
+
+// NodeHealth is the passive health of node observed by outlier detection
+type NodeHealth struct {
+	Service           string
+	Id                string
+	Address           string
+	Ejected           bool
+	EjectedUntil      time.Time
+	Ejections         int
+	ConsecutiveErrors int
+	Requests          int
+	Errors            int
+}
+
+// HealthReporter is implemented by the selectors with outlier detection
+type HealthReporter interface {
+	// Health returns the health of the nodes of service,
+	// the nodes of all the services when it's empty
+	Health(service string) []*NodeHealth
+}
+
+type nodeState struct {
+	address     string
+	consecutive int
+	requests    int
+	errors      int
+	windowStart time.Time
+	ejections   int
+	until       time.Time
+	// healthy is the last time the ejections decreased
+	healthy time.Time
+}
+
+func (n *nodeState) ejected(now time.Time) bool {
+	return now.Before(n.until)
+}
+
+// ramping checks if the node is re-admitted in the ramp up duration
+func (n *nodeState) ramping(now time.Time, rampUp time.Duration) bool {
+	return !n.until.IsZero() && !now.Before(n.until) && now.Before(n.until.Add(rampUp))
+}
+
+// detector ejects the outlier nodes of services
+type detector struct {
+	sync.Mutex
+	opts     *OutlierOptions
+	services map[string]map[string]*nodeState
+}
+
+func newDetector(opts *OutlierOptions) *detector {
+	return &detector{
+		opts:     opts,
+		services: make(map[string]map[string]*nodeState),
+	}
+}
+
+func (d *detector) enabled() bool {
+	return d.opts != nil && (d.opts.ConsecutiveErrors > 0 || d.opts.ErrorRate > 0)
+}
+
+// failure checks if the error is caused by the node, the client errors are not
+func failure(err error) bool {
+	if err == nil {
+		return false
+	}
+	code := errors.Parse(err.Error()).Code
+	return code == 0 || code == 408 || code >= 500
+}
+
+func (d *detector) mark(service string, node *regpb.Node, err error) {
+	if !d.enabled() || node == nil || len(node.Id) == 0 {
+		return
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	nodes, ok := d.services[service]
+	if !ok {
+		nodes = make(map[string]*nodeState)
+		d.services[service] = nodes
+	}
+	n, ok := nodes[node.Id]
+	if !ok {
+		n = &nodeState{}
+		nodes[node.Id] = n
+	}
+	n.address = node.Address
+
+	now := time.Now()
+	if now.Sub(n.windowStart) > d.opts.Interval {
+		n.windowStart = now
+		n.requests = 0
+		n.errors = 0
+	}
+	// the healthy node is forgiven gradually
+	if n.ejections > 0 && !n.ejected(now) && now.Sub(n.healthy) > d.opts.Interval && now.Sub(n.until) > d.opts.Interval {
+		n.ejections--
+		n.healthy = now
+	}
+
+	n.requests++
+	if !failure(err) {
+		n.consecutive = 0
+		return
+	}
+	n.errors++
+	n.consecutive++
+
+	if n.ejected(now) {
+		return
+	}
+
+	switch {
+	case n.ramping(now, d.opts.RampUp):
+	case d.opts.ConsecutiveErrors > 0 && n.consecutive >= d.opts.ConsecutiveErrors:
+	case d.opts.ErrorRate > 0 && n.requests >= d.opts.MinRequests && float64(n.errors)/float64(n.requests) >= d.opts.ErrorRate:
+	default:
+		return
+	}
+
+	// eject the node for an exponentially growing interval
+	ejection := d.opts.BaseEjection << uint(n.ejections)
+	if ejection <= 0 || ejection > d.opts.MaxEjection {
+		ejection = d.opts.MaxEjection
+	}
+	n.ejections++
+	n.until = now.Add(ejection)
+	n.healthy = n.until
+	n.consecutive = 0
+}
+
+// filter removes the ejected nodes from services, no more than MaxEjectionPercent
+// of the nodes are removed
+func (d *detector) filter(service string, services []*regpb.Service) []*regpb.Service {
+	if !d.enabled() {
+		return services
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	nodes, ok := d.services[service]
+	if !ok || len(nodes) == 0 {
+		return services
+	}
+
+	now := time.Now()
+	total := 0
+	var ejected []string
+	excluded := map[string]bool{}
+	for _, svc := range services {
+		for _, node := range svc.Nodes {
+			total++
+			n, ok := nodes[node.Id]
+			if !ok {
+				continue
+			}
+			if n.ejected(now) {
+				ejected = append(ejected, node.Id)
+			} else if n.ramping(now, d.opts.RampUp) {
+				// admit the node with the probability growing over ramp up
+				if rand.Float64() > float64(now.Sub(n.until))/float64(d.opts.RampUp) {
+					excluded[node.Id] = true
+				}
+			}
+		}
+	}
+
+	// eject the nodes with the longest ejection first
+	sort.Slice(ejected, func(i, j int) bool {
+		return nodes[ejected[i]].until.After(nodes[ejected[j]].until)
+	})
+	allowed := total * d.opts.MaxEjectionPercent / 100
+	for i := 0; i < len(ejected) && i < allowed; i++ {
+		excluded[ejected[i]] = true
+	}
+
+	if len(excluded) == 0 || len(excluded) >= total {
+		return services
+	}
+
+	out := make([]*regpb.Service, 0, len(services))
+	for _, svc := range services {
+		s := *svc
+		s.Nodes = make([]*regpb.Node, 0, len(svc.Nodes))
+		for _, node := range svc.Nodes {
+			if !excluded[node.Id] {
+				s.Nodes = append(s.Nodes, node)
+			}
+		}
+		if len(s.Nodes) > 0 {
+			out = append(out, &s)
+		}
+	}
+	return out
+}
+
+func (d *detector) reset(service string) {
+	d.Lock()
+	defer d.Unlock()
+	delete(d.services, service)
+}
+
+func (d *detector) health(service string) []*NodeHealth {
+	d.Lock()
+	defer d.Unlock()
+
+	now := time.Now()
+	var out []*NodeHealth
+	for name, nodes := range d.services {
+		if len(service) > 0 && name != service {
+			continue
+		}
+		for id, n := range nodes {
+			out = append(out, &NodeHealth{
+				Service:           name,
+				Id:                id,
+				Address:           n.address,
+				Ejected:           n.ejected(now),
+				EjectedUntil:      n.until,
+				Ejections:         n.ejections,
+				ConsecutiveErrors: n.consecutive,
+				Requests:          n.requests,
+				Errors:            n.errors,
+			})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Service != out[j].Service {
+			return out[i].Service < out[j].Service
+		}
+		return out[i].Id < out[j].Id
+	})
+	return out
+}
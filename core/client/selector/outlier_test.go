@@ -0,0 +1,152 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package selector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lack-io/vine/core/registry/memory"
+	"github.com/lack-io/vine/proto/apis/errors"
+	regpb "github.com/lack-io/vine/proto/apis/registry"
+)
+
+func testServices(n int) []*regpb.Service {
+	svc := &regpb.Service{Name: "test"}
+	for i := 0; i < n; i++ {
+		svc.Nodes = append(svc.Nodes, &regpb.Node{Id: string(rune('a' + i)), Address: "127.0.0.1"})
+	}
+	return []*regpb.Service{svc}
+}
+
+func nodeIds(services []*regpb.Service) map[string]bool {
+	ids := map[string]bool{}
+	for _, svc := range services {
+		for _, node := range svc.Nodes {
+			ids[node.Id] = true
+		}
+	}
+	return ids
+}
+
+func TestOutlierOptIn(t *testing.T) {
+	r := memory.NewRegistry()
+	s := NewSelector(Registry(r)).(*registrySelector)
+	defer s.Close()
+	if s.od.enabled() {
+		t.Fatal("expect the outlier detection to be disabled by default")
+	}
+
+	s2 := NewSelector(Registry(r), OutlierDetection(*DefaultOutlierOptions())).(*registrySelector)
+	defer s2.Close()
+	if !s2.od.enabled() {
+		t.Fatal("expect the outlier detection to be enabled")
+	}
+}
+
+func TestOutlierConsecutiveErrors(t *testing.T) {
+	d := newDetector(&OutlierOptions{
+		ConsecutiveErrors:  3,
+		Interval:           time.Minute,
+		BaseEjection:       time.Minute,
+		MaxEjection:        time.Hour,
+		MaxEjectionPercent: 50,
+	})
+	services := testServices(4)
+	bad := services[0].Nodes[0]
+
+	for i := 0; i < 2; i++ {
+		d.mark("test", bad, errors.InternalServerError("test", "boom"))
+	}
+	// the client error doesn't count
+	d.mark("test", bad, errors.NotFound("test", "missing"))
+	d.mark("test", bad, errors.InternalServerError("test", "boom"))
+	if ids := nodeIds(d.filter("test", services)); !ids["a"] {
+		t.Fatal("expect node a not to be ejected yet")
+	}
+
+	for i := 0; i < 3; i++ {
+		d.mark("test", bad, errors.InternalServerError("test", "boom"))
+	}
+	if ids := nodeIds(d.filter("test", services)); ids["a"] || len(ids) != 3 {
+		t.Fatalf("expect node a to be ejected, got %v", ids)
+	}
+	if len(services[0].Nodes) != 4 {
+		t.Fatal("expect services not to be modified")
+	}
+
+	h := d.health("test")
+	if len(h) != 1 || !h[0].Ejected || h[0].Ejections != 1 {
+		t.Fatalf("unexpected health %v", h[0])
+	}
+
+	d.reset("test")
+	if ids := nodeIds(d.filter("test", services)); !ids["a"] {
+		t.Fatal("expect node a to be admitted after reset")
+	}
+}
+
+func TestOutlierMaxEjectionPercent(t *testing.T) {
+	d := newDetector(&OutlierOptions{
+		ErrorRate:          0.5,
+		MinRequests:        2,
+		Interval:           time.Minute,
+		BaseEjection:       time.Minute,
+		MaxEjection:        time.Hour,
+		MaxEjectionPercent: 50,
+	})
+	services := testServices(4)
+	for _, node := range services[0].Nodes {
+		d.mark("test", node, errors.InternalServerError("test", "boom"))
+		d.mark("test", node, errors.InternalServerError("test", "boom"))
+	}
+	if ids := nodeIds(d.filter("test", services)); len(ids) != 2 {
+		t.Fatalf("expect half of nodes to be ejected, got %v", ids)
+	}
+}
+
+func TestOutlierEjectionGrows(t *testing.T) {
+	d := newDetector(&OutlierOptions{
+		ConsecutiveErrors:  1,
+		Interval:           time.Minute,
+		BaseEjection:       time.Millisecond * 10,
+		MaxEjection:        time.Hour,
+		MaxEjectionPercent: 100,
+		RampUp:             time.Minute,
+	})
+	node := &regpb.Node{Id: "a"}
+
+	d.mark("test", node, errors.InternalServerError("test", "boom"))
+	first := d.health("test")[0]
+	time.Sleep(time.Millisecond * 20)
+
+	// an error in ramp up ejects the node again for longer
+	d.mark("test", node, errors.InternalServerError("test", "boom"))
+	second := d.health("test")[0]
+	if !second.Ejected || second.Ejections != 2 {
+		t.Fatalf("expect node to be ejected again, got %v", second)
+	}
+	if second.EjectedUntil.Sub(first.EjectedUntil) < time.Millisecond*20 {
+		t.Fatal("expect ejection to grow")
+	}
+}
@@ -0,0 +1,90 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cbor
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/gogo/protobuf/proto"
+
+	mjsonpb "github.com/lack-io/vine/util/jsonpb"
+)
+
+var (
+	jsonpbMarshaler = &mjsonpb.Marshaler{}
+
+	// decMode decodes cbor maps into map[string]interface{} so that they can be
+	// encoded as json again
+	decMode, _ = cbor.DecOptions{
+		DefaultMapType: reflect.TypeOf(map[string]interface{}(nil)),
+	}.DecMode()
+)
+
+type Marshaler struct{}
+
+func (Marshaler) Marshal(v interface{}) ([]byte, error) {
+	if pb, ok := v.(proto.Message); ok {
+		return marshalProto(pb)
+	}
+	return cbor.Marshal(v)
+}
+
+func (Marshaler) Unmarshal(d []byte, v interface{}) error {
+	if pb, ok := v.(proto.Message); ok {
+		return unmarshalProto(d, pb)
+	}
+	return decMode.Unmarshal(d, v)
+}
+
+func (Marshaler) String() string {
+	return "cbor"
+}
+
+// marshalProto encodes proto message with the same field names as jsonpb,
+// so the cbor document is interchangeable with the json one.
+func marshalProto(pb proto.Message) ([]byte, error) {
+	s, err := jsonpbMarshaler.MarshalToString(pb)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	if err = json.Unmarshal([]byte(s), &out); err != nil {
+		return nil, err
+	}
+	return cbor.Marshal(out)
+}
+
+func unmarshalProto(d []byte, pb proto.Message) error {
+	var in interface{}
+	if err := decMode.Unmarshal(d, &in); err != nil {
+		return err
+	}
+	b, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return mjsonpb.Unmarshal(bytes.NewReader(b), pb)
+}
@@ -0,0 +1,81 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package msgpack
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/vmihailenco/msgpack/v5"
+
+	mjsonpb "github.com/lack-io/vine/util/jsonpb"
+)
+
+var jsonpbMarshaler = &mjsonpb.Marshaler{}
+
+type Marshaler struct{}
+
+func (Marshaler) Marshal(v interface{}) ([]byte, error) {
+	if pb, ok := v.(proto.Message); ok {
+		return marshalProto(pb)
+	}
+	return msgpack.Marshal(v)
+}
+
+func (Marshaler) Unmarshal(d []byte, v interface{}) error {
+	if pb, ok := v.(proto.Message); ok {
+		return unmarshalProto(d, pb)
+	}
+	return msgpack.Unmarshal(d, v)
+}
+
+func (Marshaler) String() string {
+	return "msgpack"
+}
+
+// marshalProto encodes proto message with the same field names as jsonpb,
+// so the msgpack document is interchangeable with the json one.
+func marshalProto(pb proto.Message) ([]byte, error) {
+	s, err := jsonpbMarshaler.MarshalToString(pb)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	if err = json.Unmarshal([]byte(s), &out); err != nil {
+		return nil, err
+	}
+	return msgpack.Marshal(out)
+}
+
+func unmarshalProto(d []byte, pb proto.Message) error {
+	var in interface{}
+	if err := msgpack.Unmarshal(d, &in); err != nil {
+		return err
+	}
+	b, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return mjsonpb.Unmarshal(bytes.NewReader(b), pb)
+}
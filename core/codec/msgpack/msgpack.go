@@ -0,0 +1,88 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package msgpack provides a msgpack codec
+package msgpack
+
+import (
+	"io"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/lack-io/vine/core/codec"
+)
+
+type Codec struct {
+	Conn    io.ReadWriteCloser
+	Encoder *msgpack.Encoder
+	Decoder *msgpack.Decoder
+}
+
+func (c *Codec) ReadHeader(m *codec.Message, t codec.MessageType) error {
+	return nil
+}
+
+func (c *Codec) ReadBody(b interface{}) error {
+	if b == nil {
+		return nil
+	}
+	if pb, ok := b.(proto.Message); ok {
+		var raw msgpack.RawMessage
+		if err := c.Decoder.Decode(&raw); err != nil {
+			return err
+		}
+		return unmarshalProto(raw, pb)
+	}
+	return c.Decoder.Decode(b)
+}
+
+func (c *Codec) Write(m *codec.Message, b interface{}) error {
+	if b == nil {
+		return nil
+	}
+	if pb, ok := b.(proto.Message); ok {
+		data, err := marshalProto(pb)
+		if err != nil {
+			return err
+		}
+		_, err = c.Conn.Write(data)
+		return err
+	}
+	return c.Encoder.Encode(b)
+}
+
+func (c *Codec) Close() error {
+	return c.Conn.Close()
+}
+
+func (c *Codec) String() string {
+	return "msgpack"
+}
+
+func NewCodec(c io.ReadWriteCloser) codec.Codec {
+	return &Codec{
+		Conn:    c,
+		Decoder: msgpack.NewDecoder(c),
+		Encoder: msgpack.NewEncoder(c),
+	}
+}
@@ -215,16 +215,43 @@ func (m *Registry) Register(s *regpb.Service, opts ...registry.RegisterOption) e
 		return nil
 	}
 
-	// refresh TTL and timestamp
+	// refresh TTL and timestamp, the metadata of node may change too,
+	// e.g. the node is draining
+	updatedNodes := false
 	for _, n := range s.Nodes {
 		logger.Debugf("Updated registration for service: %s, version: %s", s.Name, s.Version)
-		m.records[s.Name][s.Version].Nodes[n.Id].TTL = options.TTL
-		m.records[s.Name][s.Version].Nodes[n.Id].LastSeen = time.Now()
+		rn := m.records[s.Name][s.Version].Nodes[n.Id]
+		rn.TTL = options.TTL
+		rn.LastSeen = time.Now()
+		if !equalMetadata(rn.Metadata, n.Metadata) {
+			metadata := make(map[string]string)
+			for k, v := range n.Metadata {
+				metadata[k] = v
+			}
+			rn.Node = &regpb.Node{Id: n.Id, Address: n.Address, Metadata: metadata}
+			updatedNodes = true
+		}
+	}
+
+	if updatedNodes {
+		go m.sendEvent(&regpb.Result{Action: "update", Service: s})
 	}
 
 	return nil
 }
 
+func equalMetadata(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
+
 func (m *Registry) Deregister(s *regpb.Service, opts ...registry.DeregisterOption) error {
 	m.Lock()
 	defer m.Unlock()
@@ -37,6 +37,10 @@ var (
 	ErrWatcherStopped = errors.New("watcher stopped")
 )
 
+// DrainingKey is the node metadata key which marks the node draining,
+// the selectors avoid the node while there are others
+const DrainingKey = "draining"
+
 // Registry the registry provides an interface for service discovery
 // and an abstraction over varying implementations
 // {consul, etcd, zookeeper, ...}
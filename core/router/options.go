@@ -26,6 +26,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/lack-io/vine/core/client"
 	"github.com/lack-io/vine/core/registry"
+	"github.com/lack-io/vine/util/locality"
 )
 
 // Options are router options
@@ -38,6 +39,10 @@ type Options struct {
 	Gateway string
 	// Network is network address
 	Network string
+	// Region is the region of router
+	Region string
+	// Zone is the zone of router
+	Zone string
 	// Registry is the local registry
 	Registry registry.Registry
 	// Advertise is the advertising strategy
@@ -81,6 +86,15 @@ func Network(n string) Option {
 	}
 }
 
+// Locality sets the region and zone of router, the routes to the
+// other zones and regions get higher metric
+func Locality(region, zone string) Option {
+	return func(o *Options) {
+		o.Region = region
+		o.Zone = zone
+	}
+}
+
 // Registry sets the local registry
 func Registry(r registry.Registry) Option {
 	return func(o *Options) {
@@ -101,6 +115,8 @@ func DefaultOptions() Options {
 		Id:        uuid.New().String(),
 		Address:   DefaultAddress,
 		Network:   DefaultNetwork,
+		Region:    locality.Region(),
+		Zone:      locality.Zone(),
 		Registry:  registry.DefaultRegistry,
 		Advertise: AdvertiseLocal,
 	}
@@ -140,7 +140,7 @@ func (r *router) manageRoutes(service *regpb.Service, action string) error {
 			Network: r.options.Network,
 			Router:  r.options.Id,
 			Link:    rr.DefaultLink,
-			Metric:  rr.DefaultLocalMetric,
+			Metric:  rr.LocalityMetric(r.options.Region, r.options.Zone, node.Metadata),
 		}
 
 		if err := r.manageRoute(route, action); err != nil {
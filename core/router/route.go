@@ -22,15 +22,39 @@
 
 package router
 
-import "hash/fnv"
+import (
+	"hash/fnv"
+
+	"github.com/lack-io/vine/util/locality"
+)
 
 var (
 	// DefaultLink is default network link
 	DefaultLink = "local"
 	// DefaultLocalMetric is default route cost for a local route
 	DefaultLocalMetric int64 = 1
+	// DefaultZoneMetric is the extra cost of a route to another zone
+	DefaultZoneMetric int64 = 10
+	// DefaultRegionMetric is the extra cost of a route to another region
+	DefaultRegionMetric int64 = 100
 )
 
+// LocalityMetric returns the cost of the route to the node, the routes
+// across zones and regions cost more
+func LocalityMetric(region, zone string, md map[string]string) int64 {
+	if len(region) == 0 && len(zone) == 0 {
+		return DefaultLocalMetric
+	}
+	switch locality.Between(region, zone, md) {
+	case locality.SameZone:
+		return DefaultLocalMetric
+	case locality.SameRegion:
+		return DefaultLocalMetric + DefaultZoneMetric
+	default:
+		return DefaultLocalMetric + DefaultRegionMetric
+	}
+}
+
 // Route is network route
 type Route struct {
 	// Service is destination service name
@@ -0,0 +1,852 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package broker is the server.Server over broker.Broker, it serves the
+// services which can reach the broker only, e.g. the workers behind NAT.
+// The requests are published to the topic of service with the inbox of
+// client and the correlation id, the responses are published back to the
+// inbox. The messages of stream are exchanged with the inbox of the server
+// node which accepts the stream.
+package broker
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lack-io/vine/core/broker"
+	"github.com/lack-io/vine/core/codec"
+	"github.com/lack-io/vine/core/registry"
+	"github.com/lack-io/vine/core/server"
+	"github.com/lack-io/vine/core/server/rpc"
+	log "github.com/lack-io/vine/lib/logger"
+	"github.com/lack-io/vine/proto/apis/errors"
+	openapipb "github.com/lack-io/vine/proto/apis/openapi"
+	regpb "github.com/lack-io/vine/proto/apis/registry"
+	"github.com/lack-io/vine/util/backoff"
+	meta "github.com/lack-io/vine/util/context/metadata"
+	"github.com/lack-io/vine/util/locality"
+)
+
+var (
+	// DefaultPrefix is the prefix of the request topics, the topic of
+	// service is the prefix followed by the name of service
+	DefaultPrefix = "go.vine.rpc."
+)
+
+const (
+	defaultContentType = "application/protobuf"
+
+	// the headers of rpc, they're the same as the client
+	idKey       = "Vine-Correlation-Id"
+	replyToKey  = "Vine-Reply-To"
+	endpointKey = "Vine-Endpoint"
+	streamKey   = "Vine-Stream"
+	errorKey    = "Vine-Error"
+
+	// the operations of stream message
+	opOpen  = "open"
+	opData  = "data"
+	opClose = "close"
+	opEnd   = "end"
+)
+
+var (
+	// skipHeaders are the headers of rpc which are not passed to the
+	// handler as metadata
+	skipHeaders = map[string]bool{
+		idKey:       true,
+		replyToKey:  true,
+		endpointKey: true,
+		streamKey:   true,
+	}
+)
+
+type brokerServer struct {
+	router *rpc.Router
+	exit   chan chan error
+	wg     *sync.WaitGroup
+
+	sync.RWMutex
+	opts        server.Options
+	handlers    map[string]server.Handler
+	subscribers map[*rpc.Subscriber][]broker.Subscriber
+	// subs are the subscriptions of the service topic and the node inbox
+	subs []broker.Subscriber
+	// marks the serve as started
+	started bool
+	// used for first registration
+	registered bool
+	// draining is advertised in lame duck, the new requests are
+	// refused once refusing is set
+	draining bool
+	refusing bool
+	// inflight tracks the requests and messages being processed
+	inflight sync.WaitGroup
+
+	// streams are the open streams by correlation id
+	smu     sync.Mutex
+	streams map[string]*streamTransport
+
+	// registry service instance
+	rsvc *regpb.Service
+}
+
+func newBrokerServer(opts ...server.Option) server.Server {
+	options := server.NewOptions(opts...)
+
+	return &brokerServer{
+		opts:        options,
+		router:      rpc.NewRouter(),
+		handlers:    make(map[string]server.Handler),
+		subscribers: make(map[*rpc.Subscriber][]broker.Subscriber),
+		streams:     make(map[string]*streamTransport),
+		exit:        make(chan chan error),
+		wg:          rpc.Wait(options.Context),
+	}
+}
+
+func (s *brokerServer) configure(opts ...server.Option) {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, o := range opts {
+		o(&s.opts)
+	}
+
+	s.rsvc = nil
+}
+
+func (s *brokerServer) prefix() string {
+	if s.opts.Context != nil {
+		if v, ok := s.opts.Context.Value(prefixKey{}).(string); ok && len(v) > 0 {
+			return v
+		}
+	}
+	return DefaultPrefix
+}
+
+// topic returns the request topic of service
+func (s *brokerServer) topic() string {
+	return s.prefix() + s.opts.Name
+}
+
+// inbox returns the topic of server node, the messages of the streams
+// accepted by the node are published to it
+func (s *brokerServer) inbox() string {
+	return s.prefix() + "inbox." + s.opts.Name + "-" + s.opts.Id
+}
+
+func (s *brokerServer) newCodec(contentType string) (codec.Marshaler, error) {
+	return rpc.NewCodec(s.opts, contentType)
+}
+
+// reply publishes the response of request to the inbox of client
+func (s *brokerServer) reply(topic, id, op string, header map[string]string, body []byte) error {
+	if header == nil {
+		header = make(map[string]string)
+	}
+	header[idKey] = id
+	if len(op) > 0 {
+		header[streamKey] = op
+	}
+	return s.opts.Broker.Publish(topic, &broker.Message{Header: header, Body: body})
+}
+
+// replyError publishes the error of request, the vine error is kept as is
+func (s *brokerServer) replyError(topic, id, op string, err error) error {
+	return s.reply(topic, id, op, map[string]string{errorKey: vineError(err).Error()}, nil)
+}
+
+// serveMessage is the handler of the service topic and the node inbox, the
+// requests are served in the background so that the broker isn't blocked
+func (s *brokerServer) serveMessage(p broker.Event) error {
+	msg := p.Message()
+	id, replyTo := msg.Header[idKey], msg.Header[replyToKey]
+	op := msg.Header[streamKey]
+
+	switch op {
+	case opData, opClose:
+		s.smu.Lock()
+		t, ok := s.streams[id]
+		s.smu.Unlock()
+		// the stream is done
+		if !ok {
+			return nil
+		}
+		if op == opData {
+			t.push(msg.Body)
+		} else {
+			t.closeSend()
+		}
+		return nil
+	}
+
+	if len(id) == 0 || len(replyTo) == 0 {
+		log.Debugf("Server [broker] Dropping the request without %s", replyToKey)
+		return nil
+	}
+
+	stream := op == opOpen
+
+	// refuse the new requests in lame duck, the clients retry on 503
+	if !s.acquire() {
+		if stream {
+			op = opEnd
+		}
+		_ = s.replyError(replyTo, id, op, errors.ServiceUnavailable(server.DefaultName, "server is draining"))
+		return nil
+	}
+
+	if s.wg != nil {
+		s.wg.Add(1)
+	}
+
+	go func() {
+		defer s.inflight.Done()
+		if s.wg != nil {
+			defer s.wg.Done()
+		}
+		s.serveRequest(msg, id, replyTo, stream)
+	}()
+
+	return nil
+}
+
+func (s *brokerServer) serveRequest(msg *broker.Message, id, replyTo string, stream bool) {
+	op := ""
+	if stream {
+		op = opEnd
+	}
+	fail := func(err error) {
+		if err := s.replyError(replyTo, id, op, err); err != nil {
+			log.Debugf("Server [broker] write response error: %v", err)
+		}
+	}
+
+	serviceName, methodName, err := rpc.ServiceMethod(msg.Header[endpointKey])
+	if err != nil {
+		fail(errors.BadRequest(server.DefaultName, "%v", err))
+		return
+	}
+
+	// copy the headers to vine.metadata
+	md := meta.Metadata{}
+	for k, v := range msg.Header {
+		if skipHeaders[k] {
+			continue
+		}
+		md.Set(k, v)
+	}
+
+	// timeout for server deadline
+	to, _ := md.Get("timeout")
+	md.Delete("timeout")
+
+	// get content type
+	ct := defaultContentType
+	if v := msg.Header["Content-Type"]; len(v) > 0 {
+		ct = v
+	}
+
+	cc, err := s.newCodec(ct)
+	if err != nil {
+		fail(errors.New(server.DefaultName, err.Error(), 415))
+		return
+	}
+
+	// create new context
+	ctx, cancel := context.WithCancel(meta.NewContext(context.Background(), md))
+	defer cancel()
+
+	// set the timeout if we have it, the inbound timeout is the remaining
+	// time of caller so the deadline shrinks across the call chain
+	if len(to) > 0 {
+		if n, err := strconv.ParseInt(to, 10, 64); err == nil {
+			if n <= 0 {
+				fail(errors.Timeout(server.DefaultName, "deadline exceeded"))
+				return
+			}
+			ctx, cancel = context.WithTimeout(ctx, time.Duration(n))
+			defer cancel()
+		}
+	}
+
+	// the stream of unknown method isn't accepted
+	if stream && s.opts.Router == nil {
+		if _, err := s.endpoint(serviceName, methodName); err != nil {
+			fail(err)
+			return
+		}
+	}
+
+	var t transport
+	if stream {
+		st := newStreamTransport(s, ctx, id, replyTo, ct)
+		s.smu.Lock()
+		s.streams[id] = st
+		s.smu.Unlock()
+
+		// the client sends the messages of stream to the inbox of node
+		if err := s.reply(replyTo, id, opOpen, map[string]string{replyToKey: s.inbox()}, nil); err != nil {
+			_ = st.Close(err)
+			return
+		}
+		t = st
+	} else {
+		t = &unaryTransport{s: s, id: id, replyTo: replyTo, contentType: ct, body: msg.Body}
+	}
+
+	if err := t.Close(s.serve(ctx, t, serviceName, methodName, ct, cc, md, stream)); err != nil {
+		log.Debugf("Server [broker] write response error: %v", err)
+	}
+}
+
+// endpoint returns the handler of method
+func (s *brokerServer) endpoint(serviceName, methodName string) (*rpc.Endpoint, error) {
+	ep, err := s.router.Endpoint(serviceName, methodName)
+	if err != nil {
+		return nil, errors.NotFound(server.DefaultName, "%v", err)
+	}
+	return ep, nil
+}
+
+// serve dispatches the request to the router or the handler
+func (s *brokerServer) serve(ctx context.Context, t transport, serviceName, methodName, ct string, cc codec.Marshaler, md meta.Metadata, stream bool) error {
+	method := fmt.Sprintf("%s.%s", serviceName, methodName)
+	ms := rpc.NewMsgStream(ctx, t, cc)
+
+	// process via router
+	if s.opts.Router != nil {
+		codec := rpc.NewRouterCodec(s.String(), s.opts.Name, method, md, ms, cc)
+
+		// create a client.Request
+		request := rpc.NewRequest(s.opts.Name, method, ct, md, codec, stream)
+
+		// serve the actual request using the request router
+		return rpc.ServeRouter(ctx, s.opts, request, codec)
+	}
+
+	// process the standard request flow
+	ep, err := s.endpoint(serviceName, methodName)
+	if err != nil {
+		return err
+	}
+
+	// process unary, it's served over stream as well
+	if !ep.Stream() {
+		reply, err := ep.Call(ctx, s.opts, ct, md, ms.RecvMsg)
+		if err != nil {
+			return err
+		}
+		return ms.SendMsg(reply)
+	}
+
+	if !stream {
+		return errors.BadRequest(server.DefaultName, "%s is a stream", method)
+	}
+
+	// process stream
+	return ep.Serve(ctx, s.opts, ct, md, ms)
+}
+
+func (s *brokerServer) Options() server.Options {
+	s.RLock()
+	opts := s.opts
+	s.RUnlock()
+
+	return opts
+}
+
+func (s *brokerServer) Init(opts ...server.Option) error {
+	s.configure(opts...)
+	return nil
+}
+
+func (s *brokerServer) NewHandler(h interface{}, opts ...server.HandlerOption) server.Handler {
+	return rpc.NewHandler(h, opts...)
+}
+
+func (s *brokerServer) Handle(h server.Handler) error {
+	if err := s.router.Register(h.Handler()); err != nil {
+		return err
+	}
+
+	s.handlers[h.Name()] = h
+	return nil
+}
+
+func (s *brokerServer) NewSubscriber(topic string, sb interface{}, opts ...server.SubscriberOption) server.Subscriber {
+	return rpc.NewSubscriber(topic, sb, opts...)
+}
+
+func (s *brokerServer) Subscribe(sb server.Subscriber) error {
+	sub, ok := sb.(*rpc.Subscriber)
+	if !ok {
+		return fmt.Errorf("invalid subscriber: expected *rpc.Subscriber")
+	}
+
+	if err := rpc.Validate(sb); err != nil {
+		return err
+	}
+
+	s.Lock()
+	if _, ok = s.subscribers[sub]; ok {
+		s.Unlock()
+		return fmt.Errorf("subscriber %v already exists", sub)
+	}
+
+	s.subscribers[sub] = nil
+	s.Unlock()
+	return nil
+}
+
+// Handlers returns the registered handlers sorted by name
+func (s *brokerServer) Handlers() []server.Handler {
+	s.RLock()
+	defer s.RUnlock()
+	handlers := make([]server.Handler, 0, len(s.handlers))
+	for _, h := range s.handlers {
+		handlers = append(handlers, h)
+	}
+	sort.Slice(handlers, func(i, j int) bool {
+		return handlers[i].Name() < handlers[j].Name()
+	})
+	return handlers
+}
+
+// Subscribers returns the registered subscribers sorted by topic
+func (s *brokerServer) Subscribers() []server.Subscriber {
+	s.RLock()
+	defer s.RUnlock()
+	subscribers := make([]server.Subscriber, 0, len(s.subscribers))
+	for sb := range s.subscribers {
+		subscribers = append(subscribers, sb)
+	}
+	sort.Slice(subscribers, func(i, j int) bool {
+		return subscribers[i].Topic() < subscribers[j].Topic()
+	})
+	return subscribers
+}
+
+func (s *brokerServer) Register() error {
+	s.RLock()
+	rsvc := s.rsvc
+	config := s.opts
+	s.RUnlock()
+
+	regFunc := func(service *regpb.Service) error {
+		var regErr error
+
+		for i := 0; i < 3; i++ {
+			// set the ttl
+			rOpts := []registry.RegisterOption{registry.RegisterTTL(config.RegisterTTL)}
+			// attempt to register
+			if err := config.Registry.Register(service, rOpts...); err != nil {
+				// set the error
+				regErr = err
+				// backoff then retry
+				time.Sleep(backoff.Do(i + 1))
+				continue
+			}
+			// success so nil error
+			regErr = nil
+			break
+		}
+
+		return regErr
+	}
+
+	// if service already filled, reuse it and return early
+	if rsvc != nil {
+		return regFunc(rsvc)
+	}
+
+	// make copy of metadata
+	md := meta.Copy(config.Metadata)
+
+	// the address of node is its inbox, the node isn't reachable other
+	// than the broker
+	node := &regpb.Node{
+		Id:       config.Name + "-" + config.Id,
+		Address:  s.inbox(),
+		Metadata: md,
+	}
+
+	node.Metadata["broker"] = config.Broker.String()
+	node.Metadata["registry"] = config.Registry.String()
+	node.Metadata["server"] = s.String()
+	node.Metadata["transport"] = s.String()
+	node.Metadata["protocol"] = "broker"
+	s.RLock()
+	if s.draining {
+		node.Metadata[registry.DrainingKey] = "true"
+	}
+	s.RUnlock()
+	// region and zone of the node
+	locality.Populate(node.Metadata)
+
+	s.RLock()
+	// Maps are ordered randomly, sort the keys for consistency
+	var handlerList []string
+	for n, e := range s.handlers {
+		// Only advertise non internal handlers
+		if !e.Options().Internal {
+			handlerList = append(handlerList, n)
+		}
+	}
+	sort.Strings(handlerList)
+
+	var subscriberList []*rpc.Subscriber
+	for e := range s.subscribers {
+		// Only advertise non internal subscribers
+		if !e.Options().Internal {
+			subscriberList = append(subscriberList, e)
+		}
+	}
+	sort.Slice(subscriberList, func(i, j int) bool {
+		return subscriberList[i].Topic() > subscriberList[j].Topic()
+	})
+
+	endpoints := make([]*regpb.Endpoint, 0, len(handlerList)+len(subscriberList))
+	apis := make([]*openapipb.OpenAPI, 0, len(handlerList))
+	for _, n := range handlerList {
+		endpoints = append(endpoints, s.handlers[n].Endpoints()...)
+		apis = append(apis, s.handlers[n].Options().OpenAPI)
+	}
+	for _, e := range subscriberList {
+		endpoints = append(endpoints, e.Endpoints()...)
+	}
+	s.RUnlock()
+
+	service := &regpb.Service{
+		Name:      config.Name,
+		Version:   config.Version,
+		Nodes:     []*regpb.Node{node},
+		Endpoints: endpoints,
+		Apis:      apis,
+	}
+
+	s.RLock()
+	registered := s.registered
+	s.RUnlock()
+
+	if !registered {
+		log.Infof("Registry [%s] Registering node: %s", config.Registry.String(), node.Id)
+	}
+
+	// register the service
+	if err := regFunc(service); err != nil {
+		return err
+	}
+
+	// already registered? don't need to register subscribers
+	if registered {
+		return nil
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	d := &rpc.Dispatcher{
+		ContentType: defaultContentType,
+		Codec:       s.newCodec,
+		Acquire:     s.acquire,
+		Release:     s.inflight.Done,
+		Wait:        s.wg,
+	}
+	for sb := range s.subscribers {
+		handler := d.Handler(sb, s.opts)
+		var opts []broker.SubscribeOption
+		if queue := sb.Options().Queue; len(queue) > 0 {
+			opts = append(opts, broker.Queue(queue))
+		}
+
+		if cx := sb.Options().Context; cx != nil {
+			opts = append(opts, broker.SubscribeContext(cx))
+		}
+
+		if !sb.Options().AutoAck {
+			opts = append(opts, broker.DisableAutoAck())
+		}
+
+		log.Infof("Subscribing to topic: %s", sb.Topic())
+		sub, err := config.Broker.Subscribe(sb.Topic(), handler, opts...)
+		if err != nil {
+			return err
+		}
+		s.subscribers[sb] = []broker.Subscriber{sub}
+	}
+
+	s.registered = true
+	s.rsvc = service
+
+	return nil
+}
+
+func (s *brokerServer) Deregister() error {
+	s.RLock()
+	config := s.opts
+	s.RUnlock()
+
+	node := &regpb.Node{
+		Id:      config.Name + "-" + config.Id,
+		Address: s.inbox(),
+	}
+
+	service := &regpb.Service{
+		Name:    config.Name,
+		Version: config.Version,
+		Nodes:   []*regpb.Node{node},
+	}
+
+	log.Infof("Deregistering node: %s", node.Id)
+	if err := config.Registry.Deregister(service); err != nil {
+		return err
+	}
+
+	s.Lock()
+	s.rsvc = nil
+
+	if !s.registered {
+		s.Unlock()
+		return nil
+	}
+
+	s.registered = false
+	s.unsubscribe()
+
+	s.Unlock()
+	return nil
+}
+
+// unsubscribe unsubscribes the subscribers from the broker, it must be
+// called with the lock held
+func (s *brokerServer) unsubscribe() {
+	wg := sync.WaitGroup{}
+	for sb, subs := range s.subscribers {
+		for _, sub := range subs {
+			wg.Add(1)
+			go func(s broker.Subscriber) {
+				defer wg.Done()
+				log.Infof("unsubscribing from topic: %s", s.Topic())
+				s.Unsubscribe()
+			}(sub)
+		}
+		s.subscribers[sb] = nil
+	}
+	wg.Wait()
+}
+
+// acquire tracks a request or message in flight, it fails once the
+// server is refusing the new ones
+func (s *brokerServer) acquire() bool {
+	s.RLock()
+	defer s.RUnlock()
+	if s.refusing {
+		return false
+	}
+	s.inflight.Add(1)
+	return true
+}
+
+// drain is the lame duck phase of stop. The node is advertised draining so
+// that the clients stop picking it, after the drain delay the new requests
+// are refused with a retryable error and the ones in flight are waited for
+// up to the drain timeout, then the subscribers are unsubscribed.
+func (s *brokerServer) drain() {
+	s.Lock()
+	s.draining = true
+	s.rsvc = nil
+	config := s.opts
+	s.Unlock()
+
+	if config.DrainDelay > 0 {
+		if err := s.Register(); err != nil {
+			log.Errorf("Server register error: %v", err)
+		}
+		log.Infof("Server [broker] Draining, waiting %v for the clients", config.DrainDelay)
+		time.Sleep(config.DrainDelay)
+	}
+
+	s.Lock()
+	s.refusing = true
+	s.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(config.DrainTimeout):
+		log.Warnf("Server [broker] Drain timeout after %v, requests are still in flight", config.DrainTimeout)
+	}
+
+	s.Lock()
+	s.unsubscribe()
+	s.Unlock()
+}
+
+func (s *brokerServer) Start() error {
+	s.RLock()
+	if s.started {
+		s.RUnlock()
+		return nil
+	}
+	s.RUnlock()
+
+	config := s.Options()
+
+	// the broker is the transport of requests
+	if err := config.Broker.Connect(); err != nil {
+		log.Errorf("Broker [%s] connect error: %v", config.Broker.String(), err)
+		return err
+	}
+
+	log.Infof("Broker [%s] Connected to %s", config.Broker.String(), config.Broker.Address())
+
+	// the requests are shared by the nodes of service, the messages of
+	// streams are sent to the node which accepts the stream
+	topic, inbox := s.topic(), s.inbox()
+	sub, err := config.Broker.Subscribe(topic, s.serveMessage, broker.Queue(config.Name))
+	if err != nil {
+		return err
+	}
+	isub, err := config.Broker.Subscribe(inbox, s.serveMessage)
+	if err != nil {
+		_ = sub.Unsubscribe()
+		return err
+	}
+
+	log.Infof("Server [broker] Listening on %s", topic)
+
+	s.Lock()
+	s.subs = []broker.Subscriber{sub, isub}
+	s.Unlock()
+
+	// announce self to the world
+	if err := s.Register(); err != nil {
+		log.Errorf("Server register error: %v", err)
+	}
+
+	go func() {
+		t := new(time.Ticker)
+
+		// only process if it exists
+		if s.opts.RegisterInterval > time.Duration(0) {
+			// new ticker
+			t = time.NewTicker(s.opts.RegisterInterval)
+		}
+
+		// return error chan
+		var ch chan error
+
+	Loop:
+		for {
+			select {
+			case <-t.C:
+				if err := s.Register(); err != nil {
+					log.Errorf("Server register error: %v", err)
+				}
+			// wait for exit
+			case ch = <-s.exit:
+				break Loop
+			}
+		}
+
+		// finish the requests and messages in flight
+		s.drain()
+
+		// deregister self
+		if err := s.Deregister(); err != nil {
+			log.Errorf("Server deregister error: %v", err)
+		}
+
+		// wait for waitgroup
+		if s.wg != nil {
+			s.wg.Wait()
+		}
+
+		// stop receiving the requests
+		s.Lock()
+		for _, sub := range s.subs {
+			_ = sub.Unsubscribe()
+		}
+		s.subs = nil
+		s.Unlock()
+
+		// close transport
+		ch <- nil
+
+		log.Infof("Broker [%s] Disconnected from %s", config.Broker.String(), config.Broker.Address())
+		// disconnect broker
+		if err := config.Broker.Disconnect(); err != nil {
+			log.Errorf("Broker [%s] disconnect error: %v", config.Broker.String(), err)
+		}
+	}()
+
+	// mark the server as started
+	s.Lock()
+	s.started = true
+	s.draining = false
+	s.refusing = false
+	s.Unlock()
+
+	return nil
+}
+
+func (s *brokerServer) Stop() error {
+	s.RLock()
+	if !s.started {
+		s.RUnlock()
+		return nil
+	}
+	s.RUnlock()
+
+	ch := make(chan error)
+	s.exit <- ch
+
+	var err error
+	select {
+	case err = <-ch:
+		s.Lock()
+		s.rsvc = nil
+		s.started = false
+		s.Unlock()
+	}
+
+	return err
+}
+
+func (s *brokerServer) String() string {
+	return "broker"
+}
+
+func NewServer(opts ...server.Option) server.Server {
+	return newBrokerServer(opts...)
+}
@@ -0,0 +1,222 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package broker
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/lack-io/vine/core/broker/memory"
+	"github.com/lack-io/vine/core/client"
+	cbroker "github.com/lack-io/vine/core/client/broker"
+	rmemory "github.com/lack-io/vine/core/registry/memory"
+	"github.com/lack-io/vine/core/server"
+	"github.com/lack-io/vine/proto/apis/errors"
+	regpb "github.com/lack-io/vine/proto/apis/registry"
+	"github.com/lack-io/vine/util/context/metadata"
+)
+
+type Test struct{}
+
+func (t *Test) Echo(ctx context.Context, req *regpb.Value, rsp *regpb.Value) error {
+	switch req.Name {
+	case "fail":
+		return errors.Conflict("go.vine.test", "conflict of %s", req.Type)
+	case "slow":
+		<-ctx.Done()
+		return ctx.Err()
+	case "metadata":
+		rsp.Type, _ = metadata.Get(ctx, "Trace")
+	}
+	rsp.Name = req.Name
+	if len(rsp.Type) == 0 {
+		rsp.Type = req.Type
+	}
+	return nil
+}
+
+func (t *Test) Stream(ctx context.Context, stream server.Stream) error {
+	for {
+		v := &regpb.Value{}
+		if err := stream.Recv(v); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if v.Name == "fail" {
+			return errors.Forbidden("go.vine.test", "forbidden")
+		}
+		if err := stream.Send(v); err != nil {
+			return err
+		}
+	}
+}
+
+func newTestServer(t *testing.T) (server.Server, client.Client) {
+	r := rmemory.NewRegistry()
+	b := memory.NewBroker()
+	s := NewServer(
+		server.Name("go.vine.test"),
+		server.Registry(r),
+		server.Broker(b),
+	)
+	if err := s.Handle(s.NewHandler(&Test{})); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Stop() })
+
+	c := cbroker.NewClient(client.Registry(r), client.Broker(b), client.Retries(0))
+	return s, c
+}
+
+func TestCall(t *testing.T) {
+	_, c := newTestServer(t)
+
+	for _, ct := range []string{"application/json", "application/protobuf", "application/msgpack"} {
+		req := c.NewRequest("go.vine.test", "Test.Echo", &regpb.Value{Name: "vine", Type: "string"}, client.WithContentType(ct))
+		rsp := &regpb.Value{}
+		if err := c.Call(context.Background(), req, rsp); err != nil {
+			t.Fatalf("%s: %v", ct, err)
+		}
+		if rsp.Name != "vine" || rsp.Type != "string" {
+			t.Fatalf("%s: unexpected response %v", ct, rsp)
+		}
+	}
+
+	ctx := metadata.NewContext(context.Background(), metadata.Metadata{"Trace": "1"})
+	req := c.NewRequest("go.vine.test", "Test.Echo", &regpb.Value{Name: "metadata"})
+	rsp := &regpb.Value{}
+	if err := c.Call(ctx, req, rsp); err != nil {
+		t.Fatal(err)
+	}
+	if rsp.Type != "1" {
+		t.Fatalf("expected the metadata, got %v", rsp)
+	}
+
+	req = c.NewRequest("go.vine.test", "Test.Echo", &regpb.Value{Name: "fail", Type: "echo"})
+	err := c.Call(context.Background(), req, &regpb.Value{})
+	if e := errors.FromErr(err); e == nil || e.Code != 409 || e.Detail != "conflict of echo" {
+		t.Fatalf("expected the conflict error, got %v", err)
+	}
+
+	req = c.NewRequest("go.vine.test", "Test.Missing", &regpb.Value{})
+	err = c.Call(context.Background(), req, &regpb.Value{})
+	if e := errors.FromErr(err); e == nil || e.Code != 404 {
+		t.Fatalf("expected the not found error, got %v", err)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	_, c := newTestServer(t)
+
+	// the deadline is passed to the handler
+	req := c.NewRequest("go.vine.test", "Test.Echo", &regpb.Value{Name: "slow"})
+	err := c.Call(context.Background(), req, &regpb.Value{}, client.WithRequestTimeout(time.Millisecond*100))
+	if e := errors.FromErr(err); e == nil || e.Code != 408 {
+		t.Fatalf("expected the timeout error, got %v", err)
+	}
+
+	// nobody serves the service
+	req = c.NewRequest("go.vine.missing", "Test.Echo", &regpb.Value{})
+	err = c.Call(context.Background(), req, &regpb.Value{}, client.WithRequestTimeout(time.Millisecond*100))
+	if e := errors.FromErr(err); e == nil || e.Code != 408 {
+		t.Fatalf("expected the timeout error, got %v", err)
+	}
+}
+
+func TestStream(t *testing.T) {
+	_, c := newTestServer(t)
+
+	for _, ct := range []string{"application/json", "application/protobuf"} {
+		req := c.NewRequest("go.vine.test", "Test.Stream", &regpb.Value{}, client.WithContentType(ct))
+		stream, err := c.Stream(context.Background(), req)
+		if err != nil {
+			t.Fatalf("%s: %v", ct, err)
+		}
+
+		for _, name := range []string{"a", "b", "c"} {
+			if err := stream.Send(&regpb.Value{Name: name}); err != nil {
+				t.Fatal(err)
+			}
+			v := &regpb.Value{}
+			if err := stream.Recv(v); err != nil {
+				t.Fatal(err)
+			}
+			if v.Name != name {
+				t.Fatalf("%s: expected %s, got %s", ct, name, v.Name)
+			}
+		}
+
+		// the error of handler closes the stream
+		if err := stream.Send(&regpb.Value{Name: "fail"}); err != nil {
+			t.Fatal(err)
+		}
+		err = stream.Recv(&regpb.Value{})
+		if e := errors.FromErr(err); e == nil || e.Code != 403 || e.Detail != "forbidden" {
+			t.Fatalf("%s: expected the forbidden error, got %v", ct, err)
+		}
+	}
+
+	req := c.NewRequest("go.vine.test", "Test.Missing", &regpb.Value{})
+	_, err := c.Stream(context.Background(), req)
+	if e := errors.FromErr(err); e == nil || e.Code != 404 {
+		t.Fatalf("expected the not found error, got %v", err)
+	}
+}
+
+func TestStreamOverflow(t *testing.T) {
+	st := newStreamTransport(&brokerServer{}, context.TODO(), "1", "inbox", "application/json")
+
+	done := make(chan struct{})
+	go func() {
+		// the inbox isn't blocked by the stream which isn't read
+		for i := 0; i <= streamBuffer; i++ {
+			st.push([]byte("{}"))
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expect push not to block")
+	}
+
+	if !st.overflowed() {
+		t.Fatal("expect the stream to overflow")
+	}
+	for i := 0; i <= streamBuffer; i++ {
+		if _, err := st.Recv(); err != nil {
+			if verr := errors.FromErr(err); verr.Code != 429 {
+				t.Fatalf("expect overflow error, got %v", err)
+			}
+			return
+		}
+	}
+	t.Fatal("expect the stream to fail")
+}
@@ -20,42 +20,23 @@
 // OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
 // SOFTWARE.
 
-package grpc
+package broker
 
 import (
-	"context"
-
-	"google.golang.org/grpc"
-
+	"github.com/lack-io/vine/core/codec"
 	"github.com/lack-io/vine/core/server"
+	"github.com/lack-io/vine/core/server/rpc"
 )
 
-// rpcStream implements a server side Stream.
-type rpcStream struct {
-	s       grpc.ServerStream
-	request server.Request
-}
-
-func (r *rpcStream) Close() error {
-	return nil
-}
-
-func (r *rpcStream) Error() error {
-	return nil
-}
-
-func (r *rpcStream) Request() server.Request {
-	return r.request
-}
-
-func (r *rpcStream) Context() context.Context {
-	return r.s.Context()
-}
+type prefixKey struct{}
 
-func (r *rpcStream) Send(m interface{}) error {
-	return r.s.SendMsg(m)
+// Codec to be used to encode/decode requests for a given content type
+func Codec(contentType string, c codec.Marshaler) server.Option {
+	return rpc.Codec(contentType, c)
 }
 
-func (r *rpcStream) Recv(m interface{}) error {
-	return r.s.RecvMsg(m)
+// Prefix sets the prefix of the request topics, it must be the same as
+// the prefix of clients. Default prefix is go.vine.rpc.
+func Prefix(p string) server.Option {
+	return rpc.SetOption(prefixKey{}, p)
 }
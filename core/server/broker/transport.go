@@ -0,0 +1,214 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package broker
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/lack-io/vine/core/server"
+	"github.com/lack-io/vine/core/server/rpc"
+	"github.com/lack-io/vine/proto/apis/errors"
+)
+
+// transport reads and writes the encoded messages of a request
+type transport interface {
+	rpc.Transport
+	// Close finishes the request with the error of handler
+	Close(error) error
+}
+
+// unaryTransport reads the body of request and publishes the response to
+// the inbox of client
+type unaryTransport struct {
+	s           *brokerServer
+	id          string
+	replyTo     string
+	contentType string
+	body        []byte
+	read        bool
+	written     bool
+}
+
+func (u *unaryTransport) Recv() ([]byte, error) {
+	if u.read {
+		return nil, io.EOF
+	}
+	u.read = true
+	return u.body, nil
+}
+
+func (u *unaryTransport) Send(b []byte) error {
+	if u.written {
+		return errors.InternalServerError(server.DefaultName, "response is written")
+	}
+	u.written = true
+	return u.s.reply(u.replyTo, u.id, "", map[string]string{"Content-Type": u.contentType}, b)
+}
+
+func (u *unaryTransport) Close(err error) error {
+	if u.written {
+		return nil
+	}
+	if err == nil {
+		return u.Send(nil)
+	}
+	return u.s.replyError(u.replyTo, u.id, "", err)
+}
+
+// streamBuffer is the number of the messages of client which are buffered
+// for the handler of stream
+const streamBuffer = 16
+
+// streamTransport exchanges the messages of stream, the messages of client
+// are delivered to the inbox of server node and the stream ends with a
+// message carrying the error of handler
+type streamTransport struct {
+	s           *brokerServer
+	ctx         context.Context
+	cancel      context.CancelFunc
+	id          string
+	replyTo     string
+	contentType string
+
+	ch   chan []byte
+	once sync.Once
+	// eof is closed when the client closes the stream
+	eof chan struct{}
+	// done is closed when the handler returns
+	done chan struct{}
+	// overflow is closed when the handler falls behind the client
+	overflow     chan struct{}
+	overflowOnce sync.Once
+}
+
+func newStreamTransport(s *brokerServer, ctx context.Context, id, replyTo, ct string) *streamTransport {
+	ctx, cancel := context.WithCancel(ctx)
+	return &streamTransport{
+		s:           s,
+		ctx:         ctx,
+		cancel:      cancel,
+		id:          id,
+		replyTo:     replyTo,
+		contentType: ct,
+		ch:          make(chan []byte, streamBuffer),
+		eof:         make(chan struct{}),
+		done:        make(chan struct{}),
+		overflow:    make(chan struct{}),
+	}
+}
+
+// push delivers the message of client without blocking the inbox of node,
+// the stream fails when the handler falls behind by streamBuffer messages
+func (t *streamTransport) push(b []byte) {
+	select {
+	case t.ch <- b:
+	case <-t.done:
+	default:
+		t.overflowOnce.Do(func() {
+			close(t.overflow)
+			t.cancel()
+		})
+	}
+}
+
+// overflowed checks if the handler fell behind the client
+func (t *streamTransport) overflowed() bool {
+	select {
+	case <-t.overflow:
+		return true
+	default:
+		return false
+	}
+}
+
+func overflowError() error {
+	return errors.New(server.DefaultName, "stream buffer overflow", http.StatusTooManyRequests)
+}
+
+func (t *streamTransport) closeSend() {
+	t.once.Do(func() {
+		close(t.eof)
+	})
+}
+
+func (t *streamTransport) Recv() ([]byte, error) {
+	select {
+	case b := <-t.ch:
+		return b, nil
+	case <-t.eof:
+		// the messages sent before close are read first
+		select {
+		case b := <-t.ch:
+			return b, nil
+		default:
+			return nil, io.EOF
+		}
+	case <-t.ctx.Done():
+		if t.overflowed() {
+			return nil, overflowError()
+		}
+		return nil, t.ctx.Err()
+	}
+}
+
+func (t *streamTransport) Send(b []byte) error {
+	return t.s.reply(t.replyTo, t.id, opData, map[string]string{"Content-Type": t.contentType}, b)
+}
+
+func (t *streamTransport) Close(err error) error {
+	t.s.smu.Lock()
+	delete(t.s.streams, t.id)
+	t.s.smu.Unlock()
+	close(t.done)
+	t.cancel()
+
+	// the stream is failed by the client which sends too fast
+	if t.overflowed() {
+		err = overflowError()
+	}
+
+	if err == nil {
+		return t.s.reply(t.replyTo, t.id, opEnd, nil, nil)
+	}
+	return t.s.replyError(t.replyTo, t.id, opEnd, err)
+}
+
+// vineError converts the error of handler to the vine error
+func vineError(err error) *errors.Error {
+	verr, ok := err.(*errors.Error)
+	if !ok {
+		switch err {
+		case context.DeadlineExceeded:
+			verr = errors.Timeout(server.DefaultName, "%v", err)
+		default:
+			verr = errors.InternalServerError(server.DefaultName, "%v", err)
+		}
+	}
+	if verr.Code == 0 {
+		verr.Code = http.StatusInternalServerError
+	}
+	return verr
+}
@@ -31,8 +31,6 @@ import (
 	"net"
 	"net/http"
 	"net/http/pprof"
-	"reflect"
-	"runtime/debug"
 	"sort"
 	"strconv"
 	"strings"
@@ -51,15 +49,19 @@ import (
 	"google.golang.org/grpc/status"
 
 	"github.com/lack-io/vine/core/broker"
+	"github.com/lack-io/vine/core/codec"
 	"github.com/lack-io/vine/core/registry"
 	"github.com/lack-io/vine/core/server"
+	"github.com/lack-io/vine/core/server/rpc"
 	log "github.com/lack-io/vine/lib/logger"
 	"github.com/lack-io/vine/proto/apis/errors"
 	openapipb "github.com/lack-io/vine/proto/apis/openapi"
 	regpb "github.com/lack-io/vine/proto/apis/registry"
 	"github.com/lack-io/vine/util/addr"
 	"github.com/lack-io/vine/util/backoff"
+	"github.com/lack-io/vine/util/compress"
 	meta "github.com/lack-io/vine/util/context/metadata"
+	"github.com/lack-io/vine/util/locality"
 	mnet "github.com/lack-io/vine/util/net"
 )
 
@@ -74,28 +76,41 @@ const (
 )
 
 type grpcServer struct {
-	rpc  *rServer
-	svc  *grpc.Server
-	exit chan chan error
-	wg   *sync.WaitGroup
+	router *rpc.Router
+	svc    *grpc.Server
+	exit   chan chan error
+	wg     *sync.WaitGroup
 
 	sync.RWMutex
 	opts        server.Options
 	handlers    map[string]server.Handler
-	subscribers map[*subscriber][]broker.Subscriber
+	subscribers map[*rpc.Subscriber][]broker.Subscriber
 	// marks the serve as started
 	started bool
 	// used for first registration
 	registered bool
+	// draining is advertised in lame duck, the new requests are
+	// refused once refusing is set
+	draining bool
+	refusing bool
+	// inflight tracks the requests and messages being processed
+	inflight sync.WaitGroup
 
 	// registry service instance
 	rsvc *regpb.Service
 }
 
 func init() {
-	encoding.RegisterCodec(wrapCodec{jsonCodec{}})
-	encoding.RegisterCodec(wrapCodec{protoCodec{}})
-	encoding.RegisterCodec(wrapCodec{bytesCodec{}})
+	encoding.RegisterCodec(newCodec(rpc.JSON))
+	encoding.RegisterCodec(newCodec(rpc.Proto))
+	encoding.RegisterCodec(newCodec(rpc.Bytes))
+	encoding.RegisterCodec(newCodec(rpc.Msgpack))
+	encoding.RegisterCodec(newCodec(rpc.Cbor))
+
+	for _, name := range compress.Names() {
+		c, _ := compress.Get(name)
+		encoding.RegisterCompressor(grpcCompressor{c})
+	}
 }
 
 func newGRPCServer(opts ...server.Option) server.Server {
@@ -103,14 +118,12 @@ func newGRPCServer(opts ...server.Option) server.Server {
 
 	// create a grpc server
 	svc := &grpcServer{
-		opts: options,
-		rpc: &rServer{
-			serviceMap: make(map[string]*service),
-		},
+		opts:        options,
+		router:      rpc.NewRouter(),
 		handlers:    make(map[string]server.Handler),
-		subscribers: make(map[*subscriber][]broker.Subscriber),
+		subscribers: make(map[*rpc.Subscriber][]broker.Subscriber),
 		exit:        make(chan chan error),
-		wg:          wait(options.Context),
+		wg:          rpc.Wait(options.Context),
 	}
 
 	// configure the grpc server
@@ -119,19 +132,6 @@ func newGRPCServer(opts ...server.Option) server.Server {
 	return svc
 }
 
-type grpcRouter struct {
-	h func(context.Context, server.Request, interface{}) error
-	m func(context.Context, server.Message) error
-}
-
-func (r grpcRouter) ProcessMessage(ctx context.Context, msg server.Message) error {
-	return r.m(ctx, msg)
-}
-
-func (r grpcRouter) ServeRequest(ctx context.Context, req server.Request, rsp server.Response) error {
-	return r.h(ctx, req, rsp)
-}
-
 func (g *grpcServer) configure(opts ...server.Option) {
 	g.Lock()
 	defer g.Unlock()
@@ -234,6 +234,12 @@ func (g *grpcServer) getListener() net.Listener {
 }
 
 func (g *grpcServer) handler(svc interface{}, stream grpc.ServerStream) error {
+	// refuse the new requests in lame duck, the clients retry on 503
+	if !g.acquire() {
+		return status.New(codes.Unavailable, errors.ServiceUnavailable(server.DefaultName, "server is draining").Error()).Err()
+	}
+	defer g.inflight.Done()
+
 	if g.wg != nil {
 		g.wg.Add(1)
 		defer g.wg.Done()
@@ -244,7 +250,7 @@ func (g *grpcServer) handler(svc interface{}, stream grpc.ServerStream) error {
 		return status.Errorf(codes.Internal, "method does not exist in context")
 	}
 
-	serviceName, methodName, err := serverMethod(fullMethod)
+	serviceName, methodName, err := rpc.ServiceMethod(fullMethod)
 	if err != nil {
 		return status.New(codes.InvalidArgument, err.Error()).Err()
 	}
@@ -286,9 +292,13 @@ func (g *grpcServer) handler(svc interface{}, stream grpc.ServerStream) error {
 		ctx = peer.NewContext(ctx, p)
 	}
 
-	// set the timeout if we have it
+	// set the timeout if we have it, the inbound timeout is the remaining
+	// time of caller so the deadline shrinks across the call chain
 	if len(to) > 0 {
-		if n, err := strconv.ParseUint(to, 10, 64); err == nil {
+		if n, err := strconv.ParseInt(to, 10, 64); err == nil {
+			if n <= 0 {
+				return status.New(codes.DeadlineExceeded, errors.Timeout(server.DefaultName, "deadline exceeded").Error()).Err()
+			}
 			var cancel context.CancelFunc
 			ctx, cancel = context.WithTimeout(ctx, time.Duration(n))
 			defer cancel()
@@ -301,42 +311,14 @@ func (g *grpcServer) handler(svc interface{}, stream grpc.ServerStream) error {
 		if err != nil {
 			return errors.InternalServerError(server.DefaultName, err.Error())
 		}
-		codec := &grpcCodec{
-			method:   fmt.Sprintf("%s.%s", serviceName, methodName),
-			endpoint: fmt.Sprintf("%s.%s", serviceName, methodName),
-			target:   g.opts.Name,
-			s:        stream,
-			c:        cc,
-		}
+		method := fmt.Sprintf("%s.%s", serviceName, methodName)
+		codec := rpc.NewRouterCodec(g.String(), g.opts.Name, method, md, stream, marshaler{cc})
 
 		// create a client.Request
-		request := &rpcRequest{
-			service:     serviceFromMethod(fullMethod),
-			contentType: ct,
-			method:      fmt.Sprintf("%s.%s", serviceName, methodName),
-			codec:       codec,
-			stream:      true,
-		}
-
-		response := &rpcResponse{
-			header: make(map[string]string),
-			codec:  codec,
-		}
-
-		// create a wrapped function
-		handler := func(ctx context.Context, req server.Request, rsp interface{}) error {
-			return g.opts.Router.ServeRequest(ctx, req, rsp.(server.Response))
-		}
-
-		// execute the wrapper for it
-		for i := len(g.opts.HdlrWrappers); i > 0; i-- {
-			handler = g.opts.HdlrWrappers[i-1](handler)
-		}
-
-		r := grpcRouter{h: handler}
+		request := rpc.NewRequest(serviceFromMethod(fullMethod), method, ct, md, codec, true)
 
 		// serve the actual request using the request router
-		if err := r.ServeRequest(ctx, request, response); err != nil {
+		if err := rpc.ServeRouter(ctx, g.opts, request, codec); err != nil {
 			if _, ok := status.FromError(err); ok {
 				return err
 			}
@@ -347,215 +329,49 @@ func (g *grpcServer) handler(svc interface{}, stream grpc.ServerStream) error {
 	}
 
 	// process the standard request flow
-	g.rpc.mu.Lock()
-	service := g.rpc.serviceMap[serviceName]
-	g.rpc.mu.Unlock()
-
-	if service == nil {
-		return status.New(codes.Unimplemented, fmt.Sprintf("unknown service %s", serviceName)).Err()
-	}
-
-	mtype := service.method[methodName]
-	if mtype == nil {
-		return status.New(codes.Unimplemented, fmt.Sprintf("unknown service %s.%s", serviceName, methodName)).Err()
+	ep, err := g.router.Endpoint(serviceName, methodName)
+	if err != nil {
+		return status.New(codes.Unimplemented, err.Error()).Err()
 	}
 
 	// process unary
-	if !mtype.stream {
-		return g.processRequest(stream, service, mtype, ct, ctx)
+	if !ep.Stream() {
+		reply, err := ep.Call(ctx, g.opts, ct, md, stream.RecvMsg)
+		if err != nil {
+			return statusError(err)
+		}
+		return stream.SendMsg(reply)
 	}
 
 	// process stream
-	return g.processStream(stream, service, mtype, ct, ctx)
+	if err := ep.Serve(ctx, g.opts, ct, md, stream); err != nil {
+		return statusError(err)
+	}
+	return nil
 }
 
-func (g *grpcServer) processRequest(stream grpc.ServerStream, service *service, mtype *methodType, ct string, ctx context.Context) error {
-	for {
-		var argv, replyv reflect.Value
-
-		// Decode the argument value.
-		argIsValue := false // if true, need to indirect before calling.
-		if mtype.ArgType.Kind() == reflect.Ptr {
-			argv = reflect.New(mtype.ArgType.Elem())
-		} else {
-			argv = reflect.New(mtype.ArgType)
-			argIsValue = true
-		}
-
-		if argIsValue {
-			argv = argv.Elem()
-		}
-
-		var argvi interface{}
-		switch ct {
-		case "application/proto",
-			"application/protobuf",
-			"application/octet-stream",
-			"application/grpc",
-			"application/grpc+proto":
-			argvi = argv.Interface()
-		case "application/json", "application/grpc+json":
-			vv := argv.Interface()
-			argvi = &vv
-		}
-
-		// Unmarshal request
-		if err := stream.RecvMsg(argvi); err != nil {
-			return err
-		}
-
-		// reply value
-		replyv = reflect.New(mtype.ReplyType.Elem())
-
-		function := mtype.method.Func
-		var returnValues []reflect.Value
-
-		cc, err := g.newGRPCCodec(ct)
-		if err != nil {
-			return errors.InternalServerError(server.DefaultName, err.Error())
-		}
-		b, err := cc.Marshal(argvi)
+// statusError converts the error of handler to the grpc status
+func statusError(appErr error) error {
+	var err error
+	var errStatus *status.Status
+	switch verr := appErr.(type) {
+	case *errors.Error:
+		// vine.Error now proto based and we can attach it to grpc status
+		errStatus, err = status.New(vineError(verr), verr.Error()).WithDetails(verr)
 		if err != nil {
 			return err
 		}
-
-		// create a client.Request
-		r := &rpcRequest{
-			service:     g.opts.Name,
-			contentType: ct,
-			method:      fmt.Sprintf("%s.%s", service.name, mtype.method.Name),
-			body:        b,
-			payload:     argvi,
-		}
-
-		// define the handler func
-		fn := func(ctx context.Context, req server.Request, rsp interface{}) (err error) {
-			defer func() {
-				if r := recover(); r != nil {
-					log.Error("panic recovered: ", r)
-					log.Error(string(debug.Stack()))
-					err = errors.InternalServerError(server.DefaultName, "panic recovered: %v", r)
-				}
-			}()
-			returnValues = function.Call([]reflect.Value{service.rcvr, mtype.prepareContext(ctx), reflect.ValueOf(argv.Interface()), reflect.ValueOf(rsp)})
-
-			// The return value for the method is an error.
-			if rerr := returnValues[0].Interface(); rerr != nil {
-				err = rerr.(error)
-			}
-
-			return err
-		}
-
-		// wrap the handler func
-		for i := len(g.opts.HdlrWrappers); i > 0; i-- {
-			fn = g.opts.HdlrWrappers[i-1](fn)
-		}
-		statusCode := codes.OK
-		statusDesc := ""
-		// execute the handler
-		if appErr := fn(ctx, r, replyv.Interface()); appErr != nil {
-			var errStatus *status.Status
-			switch verr := appErr.(type) {
-			case *errors.Error:
-				// vine.Error new proto based and we can attach it to grpc status
-				statusCode = vineError(verr)
-				statusDesc = verr.Error()
-				errStatus, err = status.New(statusCode, statusDesc).WithDetails(verr)
-				if err != nil {
-					return err
-				}
-			case proto.Message:
-				// user defined error that proto based we can attach it to grpc status
-				statusCode = convertCode(appErr)
-				statusDesc = appErr.Error()
-				errStatus, err = status.New(statusCode, statusDesc).WithDetails(verr)
-				if err != nil {
-					return err
-				}
-			default:
-				// default case user pass own error type that not proto based
-				statusCode = convertCode(verr)
-				statusDesc = verr.Error()
-				errStatus = status.New(statusCode, statusDesc)
-			}
-
-			return errStatus.Err()
-		}
-
-		if err := stream.SendMsg(replyv.Interface()); err != nil {
+	case proto.Message:
+		// user defined error that proto based we can attach it to grpc status
+		errStatus, err = status.New(convertCode(appErr), appErr.Error()).WithDetails(verr)
+		if err != nil {
 			return err
 		}
-
-		return status.New(statusCode, statusDesc).Err()
-	}
-}
-
-func (g *grpcServer) processStream(stream grpc.ServerStream, service *service, mtype *methodType, ct string, ctx context.Context) error {
-	opts := g.opts
-
-	r := &rpcRequest{
-		service:     opts.Name,
-		contentType: ct,
-		method:      fmt.Sprintf("%s.%s", service.name, mtype.method.Name),
-		stream:      true,
-	}
-
-	ss := &rpcStream{
-		request: r,
-		s:       stream,
-	}
-
-	function := mtype.method.Func
-	var returnValues []reflect.Value
-
-	// Invoke the method, providing a new value for the reply.
-	fn := func(ctx context.Context, req server.Request, stream interface{}) error {
-		returnValues = function.Call([]reflect.Value{service.rcvr, mtype.prepareContext(ctx), reflect.ValueOf(stream)})
-		if err := returnValues[0].Interface(); err != nil {
-			return err.(error)
-		}
-
-		return nil
-	}
-
-	for i := len(opts.HdlrWrappers); i > 0; i-- {
-		fn = opts.HdlrWrappers[i-1](fn)
-	}
-
-	statusCode := codes.OK
-	statusDesc := ""
-
-	if appErr := fn(ctx, r, ss); appErr != nil {
-		var err error
-		var errStatus *status.Status
-		switch verr := appErr.(type) {
-		case *errors.Error:
-			// vine.Error now proto based and we can attach it to grpc status
-			statusCode = vineError(verr)
-			statusDesc = verr.Error()
-			errStatus, err = status.New(statusCode, statusDesc).WithDetails(verr)
-			if err != nil {
-				return err
-			}
-		case proto.Message:
-			// user defined error that proto based we can attach it to grpc status
-			statusCode = convertCode(appErr)
-			statusDesc = appErr.Error()
-			errStatus, err = status.New(statusCode, statusDesc).WithDetails(verr)
-			if err != nil {
-				return err
-			}
-		default:
-			// default case user pass own error type that not proto based
-			statusCode = convertCode(verr)
-			statusDesc = verr.Error()
-			errStatus = status.New(statusCode, statusDesc)
-		}
-		return errStatus.Err()
+	default:
+		// default case user pass own error type that not proto based
+		errStatus = status.New(convertCode(verr), verr.Error())
 	}
-
-	return status.New(statusCode, statusDesc).Err()
+	return errStatus.Err()
 }
 
 func (g *grpcServer) newGRPCCodec(contentType string) (encoding.Codec, error) {
@@ -574,6 +390,15 @@ func (g *grpcServer) newGRPCCodec(contentType string) (encoding.Codec, error) {
 	return nil, fmt.Errorf("unsupported Content-Type: %s", contentType)
 }
 
+// newCodec returns the marshaler of the content type, it decodes the messages
+func (g *grpcServer) newCodec(contentType string) (codec.Marshaler, error) {
+	c, err := g.newGRPCCodec(contentType)
+	if err != nil {
+		return nil, err
+	}
+	return marshaler{c}, nil
+}
+
 func (g *grpcServer) Options() server.Options {
 	g.RLock()
 	opts := g.opts
@@ -588,11 +413,11 @@ func (g *grpcServer) Init(opts ...server.Option) error {
 }
 
 func (g *grpcServer) NewHandler(h interface{}, opts ...server.HandlerOption) server.Handler {
-	return newRpcHandler(h, opts...)
+	return rpc.NewHandler(h, opts...)
 }
 
 func (g *grpcServer) Handle(h server.Handler) error {
-	if err := g.rpc.register(h.Handler()); err != nil {
+	if err := g.router.Register(h.Handler()); err != nil {
 		return err
 	}
 
@@ -601,19 +426,16 @@ func (g *grpcServer) Handle(h server.Handler) error {
 }
 
 func (g *grpcServer) NewSubscriber(topic string, sb interface{}, opts ...server.SubscriberOption) server.Subscriber {
-	return newSubscriber(topic, sb, opts...)
+	return rpc.NewSubscriber(topic, sb, opts...)
 }
 
 func (g *grpcServer) Subscribe(sb server.Subscriber) error {
-	sub, ok := sb.(*subscriber)
+	sub, ok := sb.(*rpc.Subscriber)
 	if !ok {
-		return fmt.Errorf("invalid subscriber: expected *subscriber")
-	}
-	if len(sub.handlers) == 0 {
-		return fmt.Errorf("invalid subscriber: no handler functions")
+		return fmt.Errorf("invalid subscriber: expected *rpc.Subscriber")
 	}
 
-	if err := validateSubscriber(sb); err != nil {
+	if err := rpc.Validate(sb); err != nil {
 		return err
 	}
 
@@ -628,6 +450,34 @@ func (g *grpcServer) Subscribe(sb server.Subscriber) error {
 	return nil
 }
 
+// Handlers returns the registered handlers sorted by name
+func (g *grpcServer) Handlers() []server.Handler {
+	g.RLock()
+	defer g.RUnlock()
+	handlers := make([]server.Handler, 0, len(g.handlers))
+	for _, h := range g.handlers {
+		handlers = append(handlers, h)
+	}
+	sort.Slice(handlers, func(i, j int) bool {
+		return handlers[i].Name() < handlers[j].Name()
+	})
+	return handlers
+}
+
+// Subscribers returns the registered subscribers sorted by topic
+func (g *grpcServer) Subscribers() []server.Subscriber {
+	g.RLock()
+	defer g.RUnlock()
+	subscribers := make([]server.Subscriber, 0, len(g.subscribers))
+	for sb := range g.subscribers {
+		subscribers = append(subscribers, sb)
+	}
+	sort.Slice(subscribers, func(i, j int) bool {
+		return subscribers[i].Topic() < subscribers[j].Topic()
+	})
+	return subscribers
+}
+
 func (g *grpcServer) Register() error {
 	g.RLock()
 	rsvc := g.rsvc
@@ -711,6 +561,14 @@ func (g *grpcServer) Register() error {
 	node.Metadata["server"] = g.String()
 	node.Metadata["transport"] = g.String()
 	node.Metadata["protocol"] = "grpc"
+	node.Metadata["compressors"] = strings.Join(compress.Names(), ",")
+	g.RLock()
+	if g.draining {
+		node.Metadata[registry.DrainingKey] = "true"
+	}
+	g.RUnlock()
+	// region and zone of the node
+	locality.Populate(node.Metadata)
 
 	g.RLock()
 	// Maps are ordered randomly, sort the keys for consistency
@@ -723,7 +581,7 @@ func (g *grpcServer) Register() error {
 	}
 	sort.Strings(handlerList)
 
-	var subscriberList []*subscriber
+	var subscriberList []*rpc.Subscriber
 	for e := range g.subscribers {
 		// Only advertise non internal subscribers
 		if !e.Options().Internal {
@@ -731,7 +589,7 @@ func (g *grpcServer) Register() error {
 		}
 	}
 	sort.Slice(subscriberList, func(i, j int) bool {
-		return subscriberList[i].topic > subscriberList[j].topic
+		return subscriberList[i].Topic() > subscriberList[j].Topic()
 	})
 
 	endpoints := make([]*regpb.Endpoint, 0, len(handlerList)+len(subscriberList))
@@ -774,8 +632,15 @@ func (g *grpcServer) Register() error {
 	g.Lock()
 	defer g.Unlock()
 
+	d := &rpc.Dispatcher{
+		ContentType: defaultContentType,
+		Codec:       g.newCodec,
+		Acquire:     g.acquire,
+		Release:     g.inflight.Done,
+		Wait:        g.wg,
+	}
 	for sb := range g.subscribers {
-		handler := g.createSubHandler(sb, g.opts)
+		handler := d.Handler(sb, g.opts)
 		var opts []broker.SubscribeOption
 		if queue := sb.Options().Queue; len(queue) > 0 {
 			opts = append(opts, broker.Queue(queue))
@@ -862,7 +727,15 @@ func (g *grpcServer) Deregister() error {
 	}
 
 	g.registered = false
+	g.unsubscribe()
+
+	g.Unlock()
+	return nil
+}
 
+// unsubscribe unsubscribes the subscribers from the broker, it must be
+// called with the lock held
+func (g *grpcServer) unsubscribe() {
 	wg := sync.WaitGroup{}
 	for sb, subs := range g.subscribers {
 		for _, sub := range subs {
@@ -876,9 +749,58 @@ func (g *grpcServer) Deregister() error {
 		g.subscribers[sb] = nil
 	}
 	wg.Wait()
+}
 
+// acquire tracks a request or message in flight, it fails once the
+// server is refusing the new ones
+func (g *grpcServer) acquire() bool {
+	g.RLock()
+	defer g.RUnlock()
+	if g.refusing {
+		return false
+	}
+	g.inflight.Add(1)
+	return true
+}
+
+// drain is the lame duck phase of stop. The node is advertised draining so
+// that the clients stop picking it, after the drain delay the new requests
+// are refused with a retryable error and the ones in flight are waited for
+// up to the drain timeout, then the subscribers are unsubscribed.
+func (g *grpcServer) drain() {
+	g.Lock()
+	g.draining = true
+	g.rsvc = nil
+	config := g.opts
+	g.Unlock()
+
+	if config.DrainDelay > 0 {
+		if err := g.Register(); err != nil {
+			log.Errorf("Server register error: %v", err)
+		}
+		log.Infof("Server [grpc] Draining, waiting %v for the clients", config.DrainDelay)
+		time.Sleep(config.DrainDelay)
+	}
+
+	g.Lock()
+	g.refusing = true
+	g.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		g.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(config.DrainTimeout):
+		log.Warnf("Server [grpc] Drain timeout after %v, requests are still in flight", config.DrainTimeout)
+	}
+
+	g.Lock()
+	g.unsubscribe()
 	g.Unlock()
-	return nil
 }
 
 func (g *grpcServer) Start() error {
@@ -963,7 +885,7 @@ func (g *grpcServer) Start() error {
 			})
 
 			s := http.Server{
-				Handler:           handler,
+				Handler: handler,
 			}
 
 			if err := s.ServeTLS(ts, gh.CertFile, gh.KeyFile); err != nil {
@@ -1002,6 +924,9 @@ func (g *grpcServer) Start() error {
 			}
 		}
 
+		// finish the requests and messages in flight
+		g.drain()
+
 		// deregister self
 		if err := g.Deregister(); err != nil {
 			log.Errorf("Server deregister error: %v", err)
@@ -1039,6 +964,8 @@ func (g *grpcServer) Start() error {
 	// mark the server as started
 	g.Lock()
 	g.started = true
+	g.draining = false
+	g.refusing = false
 	g.Unlock()
 
 	return nil
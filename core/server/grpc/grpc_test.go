@@ -0,0 +1,157 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lack-io/vine/core/broker/memory"
+	"github.com/lack-io/vine/core/client"
+	cgrpc "github.com/lack-io/vine/core/client/grpc"
+	"github.com/lack-io/vine/core/registry"
+	rmemory "github.com/lack-io/vine/core/registry/memory"
+	"github.com/lack-io/vine/core/server"
+	debug "github.com/lack-io/vine/lib/debug/handler"
+	"github.com/lack-io/vine/proto/apis/errors"
+	pb "github.com/lack-io/vine/proto/services/debug"
+)
+
+func TestDrain(t *testing.T) {
+	r := rmemory.NewRegistry()
+	b := memory.NewBroker()
+	s := NewServer(
+		server.Name("go.vine.test"),
+		server.Address("127.0.0.1:0"),
+		server.Registry(r),
+		server.Broker(b),
+		server.Drain(time.Millisecond*200, time.Second*5),
+	)
+	if err := pb.RegisterDebugHandler(s, debug.NewHandler(debug.Server(s))); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	address := s.Options().Address
+
+	c := cgrpc.NewClient(client.Registry(r), client.Broker(b), client.Retries(0))
+	svc := pb.NewDebugService("go.vine.test", c)
+
+	// the cpu profile is in flight during the stop
+	done := make(chan error, 1)
+	go func() {
+		_, err := svc.Profile(context.Background(), &pb.ProfileRequest{Name: "cpu", Seconds: 1},
+			client.WithAddress(address), client.WithRequestTimeout(time.Second*5))
+		done <- err
+	}()
+	time.Sleep(time.Millisecond * 100)
+
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- s.Stop()
+	}()
+
+	// the node is advertised draining first
+	time.Sleep(time.Millisecond * 100)
+	services, err := r.GetService("go.vine.test")
+	if err != nil || len(services) == 0 || len(services[0].Nodes) == 0 {
+		t.Fatalf("the node is deregistered before draining: %v", err)
+	}
+	if v := services[0].Nodes[0].Metadata[registry.DrainingKey]; v != "true" {
+		t.Fatalf("the node is not advertised draining: %q", v)
+	}
+
+	// the new requests are refused after the delay
+	time.Sleep(time.Millisecond * 200)
+	_, err = svc.Info(context.Background(), &pb.InfoRequest{}, client.WithAddress(address))
+	if e := errors.FromErr(err); e == nil || e.Code != 503 {
+		t.Fatalf("expected the request refused by 503, got %v", err)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("the request in flight failed: %v", err)
+	}
+	if err := <-stopped; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.GetService("go.vine.test"); err != registry.ErrNotFound {
+		t.Fatalf("the node is not deregistered: %v", err)
+	}
+}
+
+func TestDrainWithoutDelay(t *testing.T) {
+	r := rmemory.NewRegistry()
+	b := memory.NewBroker()
+	// the default drain delay is zero
+	s := NewServer(
+		server.Name("go.vine.test"),
+		server.Address("127.0.0.1:0"),
+		server.Registry(r),
+		server.Broker(b),
+	)
+	if err := pb.RegisterDebugHandler(s, debug.NewHandler(debug.Server(s))); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	address := s.Options().Address
+
+	c := cgrpc.NewClient(client.Registry(r), client.Broker(b), client.Retries(0))
+	svc := pb.NewDebugService("go.vine.test", c)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := svc.Profile(context.Background(), &pb.ProfileRequest{Name: "cpu", Seconds: 1},
+			client.WithAddress(address), client.WithRequestTimeout(time.Second*5))
+		done <- err
+	}()
+	time.Sleep(time.Millisecond * 100)
+
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- s.Stop()
+	}()
+	time.Sleep(time.Millisecond * 100)
+
+	// the node isn't advertised draining and the new requests are refused at once
+	if services, err := r.GetService("go.vine.test"); err == nil && len(services) > 0 && len(services[0].Nodes) > 0 {
+		if v := services[0].Nodes[0].Metadata[registry.DrainingKey]; v == "true" {
+			t.Fatal("the node is advertised draining without the delay")
+		}
+	}
+	_, err := svc.Info(context.Background(), &pb.InfoRequest{}, client.WithAddress(address))
+	if e := errors.FromErr(err); e == nil || e.Code != 503 {
+		t.Fatalf("expected the request refused by 503, got %v", err)
+	}
+
+	// the requests in flight are still waited for
+	if err := <-done; err != nil {
+		t.Fatalf("the request in flight failed: %v", err)
+	}
+	if err := <-stopped; err != nil {
+		t.Fatal(err)
+	}
+}
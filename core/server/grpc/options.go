@@ -31,6 +31,7 @@ import (
 	"google.golang.org/grpc/encoding"
 
 	"github.com/lack-io/vine/core/server"
+	"github.com/lack-io/vine/core/server/rpc"
 )
 
 type codecsKey struct{}
@@ -63,31 +64,31 @@ func Codec(contentType string, c encoding.Codec) server.Option {
 
 // AuthTLS should be used to setup a secure authentication using TLS
 func AuthTLS(t *tls.Config) server.Option {
-	return setServerOption(tlsAuth{}, t)
+	return rpc.SetOption(tlsAuth{}, t)
 }
 
 // GrpcToHttp specifies http and grpc service
 func GrpcToHttp(t *Grpc2Http) server.Option {
-	return setServerOption(Grpc2Http{}, t)
+	return rpc.SetOption(Grpc2Http{}, t)
 }
 
 // MaxConn specifies maximum number of max simultaneous connections to server
 func MaxConn(n int) server.Option {
-	return setServerOption(maxConnKey{}, n)
+	return rpc.SetOption(maxConnKey{}, n)
 }
 
 // Listener specifies the net.Listener to use instead of the default
 func Listener(l net.Listener) server.Option {
-	return setServerOption(netListener{}, l)
+	return rpc.SetOption(netListener{}, l)
 }
 
 // Options to be used to configure gRPC options
 func Options(opts ...grpc.ServerOption) server.Option {
-	return setServerOption(grpcOptions{}, opts)
+	return rpc.SetOption(grpcOptions{}, opts)
 }
 
 // MaxMsgSize set the maximum message in bytes the server can receive and
 // send. Default maximum message size is 4 MB
 func MaxMsgSize(s int) server.Option {
-	return setServerOption(maxMsgSizeKey{}, s)
+	return rpc.SetOption(maxMsgSizeKey{}, s)
 }
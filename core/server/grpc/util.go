@@ -24,11 +24,9 @@ package grpc
 
 import (
 	"context"
-	"fmt"
 	"io"
 	"os"
 	"strings"
-	"sync"
 
 	"google.golang.org/grpc/codes"
 )
@@ -61,51 +59,6 @@ func convertCode(err error) codes.Code {
 	return codes.Unknown
 }
 
-func wait(ctx context.Context) *sync.WaitGroup {
-	if ctx == nil {
-		return nil
-	}
-	wg, ok := ctx.Value("wait").(*sync.WaitGroup)
-	if !ok {
-		return nil
-	}
-	return wg
-}
-
-// serviceMethod converts a gRPC method to a Go method
-// Input:
-// Foo.Bat, /Foo/Bar, /Package.Foo/Bar, /a.package.Foo/Bar
-// Output:
-// 	[Foo, Bar]
-func serverMethod(m string) (string, string, error) {
-	if len(m) == 0 {
-		return "", "", fmt.Errorf("malformed method name: %q", m)
-	}
-
-	// grpc method
-	if m[0] == '/' {
-		// [ , Foo, Bar]
-		// [ , package.Foo, Bar]
-		// [ , a.package.Foo, Bar]
-		parts := strings.Split(m, "/")
-		if len(parts) != 3 || len(parts[1]) == 0 || len(parts[2]) == 0 {
-			return "", "", fmt.Errorf("malformed method name: %q", m)
-		}
-		service := strings.Split(parts[1], ".")
-		return service[len(service)-1], parts[2], nil
-	}
-
-	// non grpc method
-	parts := strings.Split(m, ".")
-
-	// expect [Foo, Bar]
-	if len(parts) != 2 {
-		return "", "", fmt.Errorf("malformed method name: %q", m)
-	}
-
-	return parts[0], parts[1], nil
-}
-
 // serviceFromMethod returns the service
 // /service.Foo/Bar => service
 func serviceFromMethod(m string) string {
@@ -0,0 +1,879 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package http is the server.Server over plain HTTP/1.1 and HTTP/2, the
+// request of POST /Service.Endpoint is dispatched to the handler and the
+// streams are served over websocket.
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"golang.org/x/net/netutil"
+
+	"github.com/lack-io/vine/core/broker"
+	"github.com/lack-io/vine/core/codec"
+	"github.com/lack-io/vine/core/registry"
+	"github.com/lack-io/vine/core/server"
+	"github.com/lack-io/vine/core/server/rpc"
+	log "github.com/lack-io/vine/lib/logger"
+	"github.com/lack-io/vine/proto/apis/errors"
+	openapipb "github.com/lack-io/vine/proto/apis/openapi"
+	regpb "github.com/lack-io/vine/proto/apis/registry"
+	"github.com/lack-io/vine/util/addr"
+	"github.com/lack-io/vine/util/backoff"
+	meta "github.com/lack-io/vine/util/context/metadata"
+	"github.com/lack-io/vine/util/locality"
+	mnet "github.com/lack-io/vine/util/net"
+)
+
+var (
+	// DefaultMaxMsgSize define maximum message size that server can
+	// receive. Default value is 100MB.
+	DefaultMaxMsgSize = 1024 * 1024 * 100
+)
+
+const (
+	defaultContentType = "application/json"
+)
+
+var (
+	// skipHeaders are the connection headers which are not passed
+	// to the handler as metadata
+	skipHeaders = map[string]bool{
+		"Connection":               true,
+		"Keep-Alive":               true,
+		"Proxy-Connection":         true,
+		"Te":                       true,
+		"Trailer":                  true,
+		"Transfer-Encoding":        true,
+		"Upgrade":                  true,
+		"Content-Length":           true,
+		"Sec-Websocket-Key":        true,
+		"Sec-Websocket-Version":    true,
+		"Sec-Websocket-Extensions": true,
+		"Sec-Websocket-Protocol":   true,
+	}
+)
+
+type httpServer struct {
+	router *rpc.Router
+	exit   chan chan error
+	wg     *sync.WaitGroup
+
+	sync.RWMutex
+	opts        server.Options
+	handlers    map[string]server.Handler
+	subscribers map[*rpc.Subscriber][]broker.Subscriber
+	// marks the serve as started
+	started bool
+	// used for first registration
+	registered bool
+	// draining is advertised in lame duck, the new requests are
+	// refused once refusing is set
+	draining bool
+	refusing bool
+	// inflight tracks the requests and messages being processed
+	inflight sync.WaitGroup
+
+	// registry service instance
+	rsvc *regpb.Service
+}
+
+func newHTTPServer(opts ...server.Option) server.Server {
+	options := server.NewOptions(opts...)
+
+	return &httpServer{
+		opts:        options,
+		router:      rpc.NewRouter(),
+		handlers:    make(map[string]server.Handler),
+		subscribers: make(map[*rpc.Subscriber][]broker.Subscriber),
+		exit:        make(chan chan error),
+		wg:          rpc.Wait(options.Context),
+	}
+}
+
+func (s *httpServer) configure(opts ...server.Option) {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, o := range opts {
+		o(&s.opts)
+	}
+
+	s.rsvc = nil
+}
+
+func (s *httpServer) getMaxMsgSize() int {
+	if s.opts.Context == nil {
+		return DefaultMaxMsgSize
+	}
+	v, ok := s.opts.Context.Value(maxMsgSizeKey{}).(int)
+	if !ok {
+		return DefaultMaxMsgSize
+	}
+	return v
+}
+
+func (s *httpServer) getListener() net.Listener {
+	if s.opts.Context == nil {
+		return nil
+	}
+
+	if l, ok := s.opts.Context.Value(netListener{}).(net.Listener); ok && l != nil {
+		return l
+	}
+	return nil
+}
+
+func (s *httpServer) newCodec(contentType string) (codec.Marshaler, error) {
+	return rpc.NewCodec(s.opts, contentType)
+}
+
+// ServeHTTP serves the request of POST /Service.Endpoint, the stream
+// endpoints are served over websocket on the same path.
+func (s *httpServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// refuse the new requests in lame duck, the clients retry on 503
+	if !s.acquire() {
+		writeError(w, errors.ServiceUnavailable(server.DefaultName, "server is draining"))
+		return
+	}
+	defer s.inflight.Done()
+
+	if s.wg != nil {
+		s.wg.Add(1)
+		defer s.wg.Done()
+	}
+
+	ws := websocket.IsWebSocketUpgrade(r)
+	if !ws && r.Method != http.MethodPost {
+		writeError(w, errors.MethodNotAllowed(server.DefaultName, "method %s is not allowed", r.Method))
+		return
+	}
+
+	serviceName, methodName, err := rpc.ServiceMethod(r.URL.Path)
+	if err != nil {
+		writeError(w, errors.BadRequest(server.DefaultName, "%v", err))
+		return
+	}
+
+	// copy the headers to vine.metadata
+	md := meta.Metadata{}
+	for k, v := range r.Header {
+		if skipHeaders[k] {
+			continue
+		}
+		md.Set(k, strings.Join(v, ", "))
+	}
+
+	// timeout for server deadline
+	to, _ := md.Get("timeout")
+	md.Delete("timeout")
+
+	// get content type
+	ct := defaultContentType
+	if v := r.Header.Get("Content-Type"); len(v) > 0 {
+		if mt, _, err := mime.ParseMediaType(v); err == nil {
+			ct = mt
+		}
+	}
+
+	cc, err := s.newCodec(ct)
+	if err != nil {
+		writeError(w, errors.New(server.DefaultName, err.Error(), http.StatusUnsupportedMediaType))
+		return
+	}
+
+	md.Set("Remote", r.RemoteAddr)
+
+	// create new context
+	ctx := meta.NewContext(r.Context(), md)
+
+	// set the timeout if we have it, the inbound timeout is the remaining
+	// time of caller so the deadline shrinks across the call chain
+	if len(to) > 0 {
+		if n, err := strconv.ParseInt(to, 10, 64); err == nil {
+			if n <= 0 {
+				writeError(w, errors.Timeout(server.DefaultName, "deadline exceeded"))
+				return
+			}
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, time.Duration(n))
+			defer cancel()
+		}
+	}
+
+	var t transport
+	if ws {
+		upgrader := websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool { return true },
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// the upgrader has replied the error
+			return
+		}
+		conn.SetReadLimit(int64(s.getMaxMsgSize()))
+
+		op := websocket.BinaryMessage
+		if ct == "application/json" {
+			op = websocket.TextMessage
+		}
+		t = &wsTransport{conn: conn, op: op}
+	} else {
+		t = &unaryTransport{w: w, r: r, contentType: ct, maxSize: int64(s.getMaxMsgSize())}
+	}
+
+	if err := t.Close(s.serve(ctx, t, serviceName, methodName, ct, cc, md, ws)); err != nil {
+		log.Debugf("Server [http] write response error: %v", err)
+	}
+}
+
+// serve dispatches the request to the router or the handler
+func (s *httpServer) serve(ctx context.Context, t transport, serviceName, methodName, ct string, cc codec.Marshaler, md meta.Metadata, ws bool) error {
+	method := fmt.Sprintf("%s.%s", serviceName, methodName)
+	stream := rpc.NewMsgStream(ctx, t, cc)
+
+	// process via router
+	if s.opts.Router != nil {
+		codec := rpc.NewRouterCodec(s.String(), s.opts.Name, method, md, stream, cc)
+
+		// create a client.Request
+		request := rpc.NewRequest(s.opts.Name, method, ct, md, codec, ws)
+
+		// serve the actual request using the request router
+		return rpc.ServeRouter(ctx, s.opts, request, codec)
+	}
+
+	// process the standard request flow
+	ep, err := s.router.Endpoint(serviceName, methodName)
+	if err != nil {
+		return errors.NotFound(server.DefaultName, "%v", err)
+	}
+
+	// process unary, it's served over websocket as well
+	if !ep.Stream() {
+		reply, err := ep.Call(ctx, s.opts, ct, md, stream.RecvMsg)
+		if err != nil {
+			return err
+		}
+		return stream.SendMsg(reply)
+	}
+
+	if !ws {
+		return errors.BadRequest(server.DefaultName, "stream %s requires websocket", method)
+	}
+
+	// process stream
+	return ep.Serve(ctx, s.opts, ct, md, stream)
+}
+
+// writeError replies the vine error, the code of error is the status
+func writeError(w http.ResponseWriter, err error) {
+	verr := vineError(err)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(int(verr.Code))
+	_, _ = w.Write([]byte(verr.Error()))
+}
+
+func (s *httpServer) Options() server.Options {
+	s.RLock()
+	opts := s.opts
+	s.RUnlock()
+
+	return opts
+}
+
+func (s *httpServer) Init(opts ...server.Option) error {
+	s.configure(opts...)
+	return nil
+}
+
+func (s *httpServer) NewHandler(h interface{}, opts ...server.HandlerOption) server.Handler {
+	return rpc.NewHandler(h, opts...)
+}
+
+func (s *httpServer) Handle(h server.Handler) error {
+	if err := s.router.Register(h.Handler()); err != nil {
+		return err
+	}
+
+	s.handlers[h.Name()] = h
+	return nil
+}
+
+func (s *httpServer) NewSubscriber(topic string, sb interface{}, opts ...server.SubscriberOption) server.Subscriber {
+	return rpc.NewSubscriber(topic, sb, opts...)
+}
+
+func (s *httpServer) Subscribe(sb server.Subscriber) error {
+	sub, ok := sb.(*rpc.Subscriber)
+	if !ok {
+		return fmt.Errorf("invalid subscriber: expected *rpc.Subscriber")
+	}
+
+	if err := rpc.Validate(sb); err != nil {
+		return err
+	}
+
+	s.Lock()
+	if _, ok = s.subscribers[sub]; ok {
+		s.Unlock()
+		return fmt.Errorf("subscriber %v already exists", sub)
+	}
+
+	s.subscribers[sub] = nil
+	s.Unlock()
+	return nil
+}
+
+// Handlers returns the registered handlers sorted by name
+func (s *httpServer) Handlers() []server.Handler {
+	s.RLock()
+	defer s.RUnlock()
+	handlers := make([]server.Handler, 0, len(s.handlers))
+	for _, h := range s.handlers {
+		handlers = append(handlers, h)
+	}
+	sort.Slice(handlers, func(i, j int) bool {
+		return handlers[i].Name() < handlers[j].Name()
+	})
+	return handlers
+}
+
+// Subscribers returns the registered subscribers sorted by topic
+func (s *httpServer) Subscribers() []server.Subscriber {
+	s.RLock()
+	defer s.RUnlock()
+	subscribers := make([]server.Subscriber, 0, len(s.subscribers))
+	for sb := range s.subscribers {
+		subscribers = append(subscribers, sb)
+	}
+	sort.Slice(subscribers, func(i, j int) bool {
+		return subscribers[i].Topic() < subscribers[j].Topic()
+	})
+	return subscribers
+}
+
+func (s *httpServer) Register() error {
+	s.RLock()
+	rsvc := s.rsvc
+	config := s.opts
+	s.RUnlock()
+
+	regFunc := func(service *regpb.Service) error {
+		var regErr error
+
+		for i := 0; i < 3; i++ {
+			// set the ttl
+			rOpts := []registry.RegisterOption{registry.RegisterTTL(config.RegisterTTL)}
+			// attempt to register
+			if err := config.Registry.Register(service, rOpts...); err != nil {
+				// set the error
+				regErr = err
+				// backoff then retry
+				time.Sleep(backoff.Do(i + 1))
+				continue
+			}
+			// success so nil error
+			regErr = nil
+			break
+		}
+
+		return regErr
+	}
+
+	// if service already filled, reuse it and return early
+	if rsvc != nil {
+		if err := regFunc(rsvc); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	var err error
+	var advt, host, port string
+	var cacheService bool
+
+	// check the advertise address first
+	// if it exists then use it, otherwise
+	// use the address
+	if len(config.Advertise) > 0 {
+		advt = config.Advertise
+	} else {
+		advt = config.Address
+	}
+
+	if cnt := strings.Count(advt, ":"); cnt >= 1 {
+		// ipv6 address in format [host]:port or ipv4 host:port
+		host, port, err = net.SplitHostPort(advt)
+		if err != nil {
+			return err
+		}
+	} else {
+		host = advt
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		cacheService = true
+	}
+
+	saddr, err := addr.Extract(host)
+	if err != nil {
+		return err
+	}
+
+	// make copy of metadata
+	md := meta.Copy(config.Metadata)
+
+	// register service
+	node := &regpb.Node{
+		Id:       config.Name + "-" + config.Id,
+		Address:  mnet.HostPort(saddr, port),
+		Metadata: md,
+	}
+
+	node.Metadata["broker"] = config.Broker.String()
+	node.Metadata["registry"] = config.Registry.String()
+	node.Metadata["server"] = s.String()
+	node.Metadata["transport"] = s.String()
+	node.Metadata["protocol"] = "http"
+	s.RLock()
+	if s.draining {
+		node.Metadata[registry.DrainingKey] = "true"
+	}
+	s.RUnlock()
+	// region and zone of the node
+	locality.Populate(node.Metadata)
+
+	s.RLock()
+	// Maps are ordered randomly, sort the keys for consistency
+	var handlerList []string
+	for n, e := range s.handlers {
+		// Only advertise non internal handlers
+		if !e.Options().Internal {
+			handlerList = append(handlerList, n)
+		}
+	}
+	sort.Strings(handlerList)
+
+	var subscriberList []*rpc.Subscriber
+	for e := range s.subscribers {
+		// Only advertise non internal subscribers
+		if !e.Options().Internal {
+			subscriberList = append(subscriberList, e)
+		}
+	}
+	sort.Slice(subscriberList, func(i, j int) bool {
+		return subscriberList[i].Topic() > subscriberList[j].Topic()
+	})
+
+	endpoints := make([]*regpb.Endpoint, 0, len(handlerList)+len(subscriberList))
+	apis := make([]*openapipb.OpenAPI, 0, len(handlerList))
+	for _, n := range handlerList {
+		endpoints = append(endpoints, s.handlers[n].Endpoints()...)
+		apis = append(apis, s.handlers[n].Options().OpenAPI)
+	}
+	for _, e := range subscriberList {
+		endpoints = append(endpoints, e.Endpoints()...)
+	}
+	s.RUnlock()
+
+	service := &regpb.Service{
+		Name:      config.Name,
+		Version:   config.Version,
+		Nodes:     []*regpb.Node{node},
+		Endpoints: endpoints,
+		Apis:      apis,
+	}
+
+	s.RLock()
+	registered := s.registered
+	s.RUnlock()
+
+	if !registered {
+		log.Infof("Registry [%s] Registering node: %s", config.Registry.String(), node.Id)
+	}
+
+	// register the service
+	if err := regFunc(service); err != nil {
+		return err
+	}
+
+	// already registered? don't need to register subscribers
+	if registered {
+		return nil
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	d := &rpc.Dispatcher{
+		ContentType: defaultContentType,
+		Codec:       s.newCodec,
+		Acquire:     s.acquire,
+		Release:     s.inflight.Done,
+		Wait:        s.wg,
+	}
+	for sb := range s.subscribers {
+		handler := d.Handler(sb, s.opts)
+		var opts []broker.SubscribeOption
+		if queue := sb.Options().Queue; len(queue) > 0 {
+			opts = append(opts, broker.Queue(queue))
+		}
+
+		if cx := sb.Options().Context; cx != nil {
+			opts = append(opts, broker.SubscribeContext(cx))
+		}
+
+		if !sb.Options().AutoAck {
+			opts = append(opts, broker.DisableAutoAck())
+		}
+
+		log.Infof("Subscribing to topic: %s", sb.Topic())
+		sub, err := config.Broker.Subscribe(sb.Topic(), handler, opts...)
+		if err != nil {
+			return err
+		}
+		s.subscribers[sb] = []broker.Subscriber{sub}
+	}
+
+	s.registered = true
+	if cacheService {
+		s.rsvc = service
+	}
+
+	return nil
+}
+
+func (s *httpServer) Deregister() error {
+	var err error
+	var advt, host, port string
+
+	s.RLock()
+	config := s.opts
+	s.RUnlock()
+
+	// check the advertise address first
+	// if it exists then use it, otherwise
+	// use the address
+	if len(config.Advertise) > 0 {
+		advt = config.Advertise
+	} else {
+		advt = config.Address
+	}
+
+	if cnt := strings.Count(advt, ":"); cnt >= 1 {
+		// ipv6 address in format [host]:port or ipv4 host:port
+		host, port, err = net.SplitHostPort(advt)
+		if err != nil {
+			return err
+		}
+	} else {
+		host = advt
+	}
+
+	addr, err := addr.Extract(host)
+	if err != nil {
+		return err
+	}
+
+	node := &regpb.Node{
+		Id:      config.Name + "-" + config.Id,
+		Address: mnet.HostPort(addr, port),
+	}
+
+	service := &regpb.Service{
+		Name:    config.Name,
+		Version: config.Version,
+		Nodes:   []*regpb.Node{node},
+	}
+
+	log.Infof("Deregistering node: %s", node.Id)
+	if err := config.Registry.Deregister(service); err != nil {
+		return err
+	}
+
+	s.Lock()
+	s.rsvc = nil
+
+	if !s.registered {
+		s.Unlock()
+		return nil
+	}
+
+	s.registered = false
+	s.unsubscribe()
+
+	s.Unlock()
+	return nil
+}
+
+// unsubscribe unsubscribes the subscribers from the broker, it must be
+// called with the lock held
+func (s *httpServer) unsubscribe() {
+	wg := sync.WaitGroup{}
+	for sb, subs := range s.subscribers {
+		for _, sub := range subs {
+			wg.Add(1)
+			go func(s broker.Subscriber) {
+				defer wg.Done()
+				log.Infof("unsubscribing from topic: %s", s.Topic())
+				s.Unsubscribe()
+			}(sub)
+		}
+		s.subscribers[sb] = nil
+	}
+	wg.Wait()
+}
+
+// acquire tracks a request or message in flight, it fails once the
+// server is refusing the new ones
+func (s *httpServer) acquire() bool {
+	s.RLock()
+	defer s.RUnlock()
+	if s.refusing {
+		return false
+	}
+	s.inflight.Add(1)
+	return true
+}
+
+// drain is the lame duck phase of stop. The node is advertised draining so
+// that the clients stop picking it, after the drain delay the new requests
+// are refused with a retryable error and the ones in flight are waited for
+// up to the drain timeout, then the subscribers are unsubscribed.
+func (s *httpServer) drain() {
+	s.Lock()
+	s.draining = true
+	s.rsvc = nil
+	config := s.opts
+	s.Unlock()
+
+	if config.DrainDelay > 0 {
+		if err := s.Register(); err != nil {
+			log.Errorf("Server register error: %v", err)
+		}
+		log.Infof("Server [http] Draining, waiting %v for the clients", config.DrainDelay)
+		time.Sleep(config.DrainDelay)
+	}
+
+	s.Lock()
+	s.refusing = true
+	s.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(config.DrainTimeout):
+		log.Warnf("Server [http] Drain timeout after %v, requests are still in flight", config.DrainTimeout)
+	}
+
+	s.Lock()
+	s.unsubscribe()
+	s.Unlock()
+}
+
+func (s *httpServer) Start() error {
+	s.RLock()
+	if s.started {
+		s.RUnlock()
+		return nil
+	}
+	s.RUnlock()
+
+	config := s.Options()
+
+	var ts net.Listener
+
+	if l := s.getListener(); l != nil {
+		ts = l
+	} else {
+		var err error
+
+		// check the tls config for secure connect
+		if tc := config.TLSConfig; tc != nil {
+			tc = tc.Clone()
+			// negotiate http2 over tls
+			if len(tc.NextProtos) == 0 {
+				tc.NextProtos = []string{http2.NextProtoTLS, "http/1.1"}
+			}
+			ts, err = tls.Listen("tcp", config.Address, tc)
+			// otherwise just plain tcp listener
+		} else {
+			ts, err = net.Listen("tcp", config.Address)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.opts.Context != nil {
+		if c, ok := s.opts.Context.Value(maxConnKey{}).(int); ok && c > 0 {
+			ts = netutil.LimitListener(ts, c)
+		}
+	}
+
+	log.Infof("Server [http] Listening on %s", ts.Addr().String())
+
+	s.Lock()
+	s.opts.Address = ts.Addr().String()
+	s.Unlock()
+
+	// the cleartext http2 is served by h2c
+	srv := &http.Server{Handler: h2c.NewHandler(s, &http2.Server{})}
+
+	// only connect if we're subscribed
+	if len(s.subscribers) > 0 {
+		// connect to the broker
+		if err := config.Broker.Connect(); err != nil {
+			log.Errorf("Broker [%s] connect error: %v", config.Broker.String(), err)
+			return err
+		}
+
+		log.Infof("Broker [%s] Connected to %s", config.Broker.String(), config.Broker.Address())
+	}
+
+	// announce self to the world
+	if err := s.Register(); err != nil {
+		log.Errorf("Server register error: %v", err)
+	}
+
+	go func() {
+		if err := srv.Serve(ts); err != nil && err != http.ErrServerClosed {
+			log.Errorf("HTTP Server start error: %v", err)
+		}
+	}()
+
+	go func() {
+		t := new(time.Ticker)
+
+		// only process if it exists
+		if s.opts.RegisterInterval > time.Duration(0) {
+			// new ticker
+			t = time.NewTicker(s.opts.RegisterInterval)
+		}
+
+		// return error chan
+		var ch chan error
+
+	Loop:
+		for {
+			select {
+			case <-t.C:
+				if err := s.Register(); err != nil {
+					log.Errorf("Server register error: %v", err)
+				}
+			// wait for exit
+			case ch = <-s.exit:
+				break Loop
+			}
+		}
+
+		// finish the requests and messages in flight
+		s.drain()
+
+		// deregister self
+		if err := s.Deregister(); err != nil {
+			log.Errorf("Server deregister error: %v", err)
+		}
+
+		// wait for waitgroup
+		if s.wg != nil {
+			s.wg.Wait()
+		}
+
+		// stop the http server
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		if err := srv.Shutdown(ctx); err != nil {
+			_ = srv.Close()
+		}
+		cancel()
+
+		// close transport
+		ch <- nil
+
+		log.Infof("Broker [%s] Disconnected from %s", config.Broker.String(), config.Broker.Address())
+		// disconnect broker
+		if err := config.Broker.Disconnect(); err != nil {
+			log.Errorf("Broker [%s] disconnect error: %v", config.Broker.String(), err)
+		}
+	}()
+
+	// mark the server as started
+	s.Lock()
+	s.started = true
+	s.draining = false
+	s.refusing = false
+	s.Unlock()
+
+	return nil
+}
+
+func (s *httpServer) Stop() error {
+	s.RLock()
+	if !s.started {
+		s.RUnlock()
+		return nil
+	}
+	s.RUnlock()
+
+	ch := make(chan error)
+	s.exit <- ch
+
+	var err error
+	select {
+	case err = <-ch:
+		s.Lock()
+		s.rsvc = nil
+		s.started = false
+		s.Unlock()
+	}
+
+	return err
+}
+
+func (s *httpServer) String() string {
+	return "http"
+}
+
+func NewServer(opts ...server.Option) server.Server {
+	return newHTTPServer(opts...)
+}
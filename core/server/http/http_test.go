@@ -0,0 +1,174 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package http
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/lack-io/vine/core/broker/memory"
+	"github.com/lack-io/vine/core/client"
+	chttp "github.com/lack-io/vine/core/client/http"
+	rmemory "github.com/lack-io/vine/core/registry/memory"
+	"github.com/lack-io/vine/core/server"
+	"github.com/lack-io/vine/proto/apis/errors"
+	regpb "github.com/lack-io/vine/proto/apis/registry"
+)
+
+type Test struct{}
+
+func (t *Test) Echo(ctx context.Context, req *regpb.Value, rsp *regpb.Value) error {
+	if req.Name == "fail" {
+		return errors.Conflict("go.vine.test", "conflict of %s", req.Type)
+	}
+	rsp.Name = req.Name
+	rsp.Type = req.Type
+	return nil
+}
+
+func (t *Test) Stream(ctx context.Context, stream server.Stream) error {
+	for {
+		v := &regpb.Value{}
+		if err := stream.Recv(v); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if v.Name == "fail" {
+			return errors.Forbidden("go.vine.test", "forbidden")
+		}
+		if err := stream.Send(v); err != nil {
+			return err
+		}
+	}
+}
+
+func newTestServer(t *testing.T) (server.Server, client.Client) {
+	r := rmemory.NewRegistry()
+	b := memory.NewBroker()
+	s := NewServer(
+		server.Name("go.vine.test"),
+		server.Address("127.0.0.1:0"),
+		server.Registry(r),
+		server.Broker(b),
+	)
+	if err := s.Handle(s.NewHandler(&Test{})); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Stop() })
+
+	c := chttp.NewClient(client.Registry(r), client.Broker(b), client.Retries(0))
+	return s, c
+}
+
+func TestCall(t *testing.T) {
+	_, c := newTestServer(t)
+
+	for _, ct := range []string{"application/json", "application/protobuf", "application/msgpack"} {
+		req := c.NewRequest("go.vine.test", "Test.Echo", &regpb.Value{Name: "vine", Type: "string"}, client.WithContentType(ct))
+		rsp := &regpb.Value{}
+		if err := c.Call(context.Background(), req, rsp); err != nil {
+			t.Fatalf("%s: %v", ct, err)
+		}
+		if rsp.Name != "vine" || rsp.Type != "string" {
+			t.Fatalf("%s: unexpected response %v", ct, rsp)
+		}
+	}
+
+	req := c.NewRequest("go.vine.test", "Test.Echo", &regpb.Value{Name: "fail", Type: "echo"})
+	err := c.Call(context.Background(), req, &regpb.Value{})
+	if e := errors.FromErr(err); e == nil || e.Code != 409 || e.Detail != "conflict of echo" {
+		t.Fatalf("expected the conflict error, got %v", err)
+	}
+
+	req = c.NewRequest("go.vine.test", "Test.Missing", &regpb.Value{})
+	err = c.Call(context.Background(), req, &regpb.Value{})
+	if e := errors.FromErr(err); e == nil || e.Code != 404 {
+		t.Fatalf("expected the not found error, got %v", err)
+	}
+}
+
+func TestPlainHTTP(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	url := "http://" + s.Options().Address + "/Test.Echo"
+	rsp, err := http.Post(url, "application/json; charset=utf-8", strings.NewReader(`{"name":"vine"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(rsp.Body)
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK || string(b) != `{"name":"vine"}` {
+		t.Fatalf("unexpected response %d %s", rsp.StatusCode, b)
+	}
+
+	rsp, err = http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rsp.StatusCode)
+	}
+}
+
+func TestStream(t *testing.T) {
+	_, c := newTestServer(t)
+
+	for _, ct := range []string{"application/json", "application/protobuf"} {
+		req := c.NewRequest("go.vine.test", "Test.Stream", &regpb.Value{}, client.WithContentType(ct))
+		stream, err := c.Stream(context.Background(), req)
+		if err != nil {
+			t.Fatalf("%s: %v", ct, err)
+		}
+
+		for _, name := range []string{"a", "b", "c"} {
+			if err := stream.Send(&regpb.Value{Name: name}); err != nil {
+				t.Fatal(err)
+			}
+			v := &regpb.Value{}
+			if err := stream.Recv(v); err != nil {
+				t.Fatal(err)
+			}
+			if v.Name != name {
+				t.Fatalf("%s: expected %s, got %s", ct, name, v.Name)
+			}
+		}
+
+		// the error of handler closes the stream
+		if err := stream.Send(&regpb.Value{Name: "fail"}); err != nil {
+			t.Fatal(err)
+		}
+		err = stream.Recv(&regpb.Value{})
+		if e := errors.FromErr(err); e == nil || e.Code != 403 || e.Detail != "forbidden" {
+			t.Fatalf("%s: expected the forbidden error, got %v", ct, err)
+		}
+	}
+}
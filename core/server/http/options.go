@@ -0,0 +1,56 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package http
+
+import (
+	"net"
+
+	"github.com/lack-io/vine/core/codec"
+	"github.com/lack-io/vine/core/server"
+	"github.com/lack-io/vine/core/server/rpc"
+)
+
+type netListener struct{}
+type maxMsgSizeKey struct{}
+type maxConnKey struct{}
+
+// Codec to be used to encode/decode requests for a given content type
+func Codec(contentType string, c codec.Marshaler) server.Option {
+	return rpc.Codec(contentType, c)
+}
+
+// MaxConn specifies maximum number of max simultaneous connections to server
+func MaxConn(n int) server.Option {
+	return rpc.SetOption(maxConnKey{}, n)
+}
+
+// Listener specifies the net.Listener to use instead of the default
+func Listener(l net.Listener) server.Option {
+	return rpc.SetOption(netListener{}, l)
+}
+
+// MaxMsgSize set the maximum size in bytes of the request body and the
+// stream messages. Default maximum message size is 100 MB
+func MaxMsgSize(s int) server.Option {
+	return rpc.SetOption(maxMsgSizeKey{}, s)
+}
@@ -0,0 +1,155 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package http
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/fasthttp/websocket"
+
+	"github.com/lack-io/vine/core/server"
+	"github.com/lack-io/vine/core/server/rpc"
+	"github.com/lack-io/vine/proto/apis/errors"
+)
+
+const (
+	// closeErrorBase is added to the code of error when the websocket is
+	// closed by an error, the close codes 4000-4999 are for applications
+	closeErrorBase = 4000
+	// maxCloseReason is the maximum size of the reason of close frame
+	maxCloseReason = 123
+)
+
+// transport reads and writes the encoded messages of a request
+type transport interface {
+	rpc.Transport
+	// Close finishes the request with the error of handler
+	Close(error) error
+}
+
+// unaryTransport reads the body of request and writes the body of response
+type unaryTransport struct {
+	w           http.ResponseWriter
+	r           *http.Request
+	contentType string
+	maxSize     int64
+	read        bool
+	written     bool
+}
+
+func (u *unaryTransport) Recv() ([]byte, error) {
+	if u.read {
+		return nil, io.EOF
+	}
+	u.read = true
+	return ioutil.ReadAll(http.MaxBytesReader(u.w, u.r.Body, u.maxSize))
+}
+
+func (u *unaryTransport) Send(b []byte) error {
+	if !u.written {
+		u.w.Header().Set("Content-Type", u.contentType)
+		u.w.WriteHeader(http.StatusOK)
+		u.written = true
+	}
+	_, err := u.w.Write(b)
+	return err
+}
+
+func (u *unaryTransport) Close(err error) error {
+	if u.written {
+		return nil
+	}
+	if err == nil {
+		u.w.WriteHeader(http.StatusOK)
+		return nil
+	}
+	writeError(u.w, err)
+	return nil
+}
+
+// wsTransport exchanges the messages of stream over websocket, the stream
+// ends with a close frame whose code carries the error of handler
+type wsTransport struct {
+	sync.Mutex
+	conn *websocket.Conn
+	op   int
+}
+
+func (t *wsTransport) Recv() ([]byte, error) {
+	_, b, err := t.conn.ReadMessage()
+	if err != nil {
+		if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseNoStatusReceived) {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+func (t *wsTransport) Send(b []byte) error {
+	t.Lock()
+	defer t.Unlock()
+	return t.conn.WriteMessage(t.op, b)
+}
+
+func (t *wsTransport) Close(err error) error {
+	code, reason := websocket.CloseNormalClosure, ""
+	if err != nil {
+		verr := vineError(err)
+		code = closeErrorBase + int(verr.Code)
+		if reason = verr.Error(); len(reason) > maxCloseReason {
+			reason = verr.Detail
+		}
+		if len(reason) > maxCloseReason {
+			reason = reason[:maxCloseReason]
+		}
+	}
+
+	t.Lock()
+	_ = t.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(time.Second))
+	t.Unlock()
+	return t.conn.Close()
+}
+
+// vineError converts the error of handler to the vine error, its code is
+// the status of response
+func vineError(err error) *errors.Error {
+	verr, ok := err.(*errors.Error)
+	if !ok {
+		switch err {
+		case context.DeadlineExceeded:
+			verr = errors.Timeout(server.DefaultName, "%v", err)
+		default:
+			verr = errors.InternalServerError(server.DefaultName, "%v", err)
+		}
+	}
+	if verr.Code < 100 || verr.Code >= 1000 {
+		verr.Code = http.StatusInternalServerError
+	}
+	return verr
+}
@@ -52,6 +52,12 @@ type Options struct {
 	RegisterTTL time.Duration
 	// The interval on which to register
 	RegisterInterval time.Duration
+	// DrainDelay is the delay between advertising the draining and
+	// refusing the new requests on stop
+	DrainDelay time.Duration
+	// DrainTimeout is the deadline of the in-flight requests and
+	// messages on stop
+	DrainTimeout time.Duration
 
 	// The router for requests
 	Router Router
@@ -70,6 +76,8 @@ func NewOptions(opt ...Option) Options {
 		Metadata:         map[string]string{},
 		RegisterInterval: DefaultRegisterInterval,
 		RegisterTTL:      DefaultRegisterTTL,
+		DrainDelay:       DefaultDrainDelay,
+		DrainTimeout:     DefaultDrainTimeout,
 	}
 
 	for _, o := range opt {
@@ -182,6 +190,23 @@ func Metadata(md map[string]string) Option {
 	}
 }
 
+// Drain sets the lame duck phase of stop, the server advertises the draining
+// and waits delay before refusing the new requests, then the in-flight
+// requests and messages are waited for at most timeout
+func Drain(delay, timeout time.Duration) Option {
+	return func(o *Options) {
+		o.DrainDelay = delay
+		o.DrainTimeout = timeout
+	}
+}
+
+// TLSConfig sets the tls config of the listener
+func TLSConfig(t *tls.Config) Option {
+	return func(o *Options) {
+		o.TLSConfig = t
+	}
+}
+
 // RegisterCheck run func before registry service
 func RegisterCheck(fn func(context.Context) error) Option {
 	return func(o *Options) {
@@ -0,0 +1,268 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package rpc
+
+import (
+	b "bytes"
+	"context"
+	"fmt"
+
+	"github.com/gogo/protobuf/proto"
+	json "github.com/json-iterator/go"
+
+	"github.com/lack-io/vine/core/codec"
+	"github.com/lack-io/vine/core/codec/bytes"
+	"github.com/lack-io/vine/core/codec/cbor"
+	"github.com/lack-io/vine/core/codec/msgpack"
+	"github.com/lack-io/vine/core/server"
+	"github.com/lack-io/vine/util/jsonpb"
+)
+
+type codecsKey struct{}
+
+type jsonCodec struct{}
+type bytesCodec struct{}
+type protoCodec struct{}
+type wrapCodec struct{ codec.Marshaler }
+
+var jsonpbMarshaler = &jsonpb.Marshaler{
+	EnumsAsInts:  false,
+	EmitDefaults: false,
+	OrigName:     true,
+}
+
+var (
+	// JSON is the marshaler of json, the proto messages are encoded by jsonpb
+	JSON codec.Marshaler = jsonCodec{}
+	// Proto is the marshaler of the proto messages
+	Proto codec.Marshaler = protoCodec{}
+	// Bytes is the marshaler of the raw bytes
+	Bytes codec.Marshaler = bytesCodec{}
+	// Msgpack is the marshaler of msgpack
+	Msgpack codec.Marshaler = msgpack.Marshaler{}
+	// Cbor is the marshaler of cbor
+	Cbor codec.Marshaler = cbor.Marshaler{}
+
+	// DefaultCodecs are the marshalers of the content types
+	DefaultCodecs = map[string]codec.Marshaler{
+		"application/json":         JSON,
+		"application/proto":        Proto,
+		"application/protobuf":     Proto,
+		"application/octet-stream": Proto,
+		"application/bytes":        Bytes,
+		"application/msgpack":      Msgpack,
+		"application/x-msgpack":    Msgpack,
+		"application/cbor":         Cbor,
+	}
+)
+
+// Codec sets the marshaler to encode/decode the requests of the content type
+func Codec(contentType string, c codec.Marshaler) server.Option {
+	return func(o *server.Options) {
+		codecs := make(map[string]codec.Marshaler)
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		if v, ok := o.Context.Value(codecsKey{}).(map[string]codec.Marshaler); ok && v != nil {
+			codecs = v
+		}
+		codecs[contentType] = c
+		o.Context = context.WithValue(o.Context, codecsKey{}, codecs)
+	}
+}
+
+// NewCodec returns the wrapped marshaler of the content type, the ones set
+// by Codec take precedence over DefaultCodecs
+func NewCodec(opts server.Options, contentType string) (codec.Marshaler, error) {
+	if opts.Context != nil {
+		if v, ok := opts.Context.Value(codecsKey{}).(map[string]codec.Marshaler); ok {
+			if c, ok := v[contentType]; ok {
+				return Wrap(c), nil
+			}
+		}
+	}
+	if c, ok := DefaultCodecs[contentType]; ok {
+		return Wrap(c), nil
+	}
+	return nil, fmt.Errorf("unsupported Content-Type: %s", contentType)
+}
+
+// Wrap wraps the marshaler, the raw frames are passed through and the
+// empty data is decoded as the zero value
+func Wrap(c codec.Marshaler) codec.Marshaler {
+	if w, ok := c.(wrapCodec); ok {
+		return w
+	}
+	return wrapCodec{c}
+}
+
+// Marshal passes the raw frame through, it's used by the router
+func (w wrapCodec) Marshal(v interface{}) ([]byte, error) {
+	if f, ok := v.(*bytes.Frame); ok {
+		return f.Data, nil
+	}
+	return w.Marshaler.Marshal(v)
+}
+
+func (w wrapCodec) Unmarshal(data []byte, v interface{}) error {
+	if f, ok := v.(*bytes.Frame); ok {
+		f.Data = data
+		return nil
+	}
+	if v == nil || len(data) == 0 {
+		return nil
+	}
+	return w.Marshaler.Unmarshal(data, v)
+}
+
+func (protoCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, codec.ErrInvalidMessage
+	}
+	return proto.Marshal(m)
+}
+
+func (protoCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return codec.ErrInvalidMessage
+	}
+	return proto.Unmarshal(data, m)
+}
+
+func (protoCodec) String() string {
+	return "proto"
+}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	if pb, ok := v.(proto.Message); ok {
+		s, err := jsonpbMarshaler.MarshalToString(pb)
+		return []byte(s), err
+	}
+
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	if pb, ok := v.(proto.Message); ok {
+		return jsonpb.Unmarshal(b.NewReader(data), pb)
+	}
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) String() string {
+	return "json"
+}
+
+func (bytesCodec) Marshal(v interface{}) ([]byte, error) {
+	switch ve := v.(type) {
+	case *[]byte:
+		return *ve, nil
+	case []byte:
+		return ve, nil
+	}
+	return nil, codec.ErrInvalidMessage
+}
+
+func (bytesCodec) Unmarshal(data []byte, v interface{}) error {
+	ve, ok := v.(*[]byte)
+	if !ok {
+		return codec.ErrInvalidMessage
+	}
+	*ve = data
+	return nil
+}
+
+func (bytesCodec) String() string {
+	return "bytes"
+}
+
+// routerCodec is the codec.Codec of the request served by the router, the
+// body of unary request or the messages of stream are exchanged by the stream
+type routerCodec struct {
+	// headers
+	target   string
+	method   string
+	endpoint string
+	header   map[string]string
+	name     string
+
+	s MsgStream
+	c codec.Marshaler
+}
+
+// NewRouterCodec returns the codec of the request served by the router, the
+// messages are exchanged by s and encoded by c. name is the name of transport.
+func NewRouterCodec(name, target, method string, header map[string]string, s MsgStream, c codec.Marshaler) codec.Codec {
+	return &routerCodec{
+		target:   target,
+		method:   method,
+		endpoint: method,
+		header:   header,
+		name:     name,
+		s:        s,
+		c:        c,
+	}
+}
+
+func (r *routerCodec) ReadHeader(m *codec.Message, mt codec.MessageType) error {
+	if m == nil {
+		m = new(codec.Message)
+	}
+	if m.Header == nil {
+		m.Header = make(map[string]string, len(r.header))
+	}
+	for k, v := range r.header {
+		m.Header[k] = v
+	}
+	m.Target = r.target
+	m.Method = r.method
+	m.Endpoint = r.endpoint
+	return nil
+}
+
+func (r *routerCodec) ReadBody(v interface{}) error {
+	return r.s.RecvMsg(v)
+}
+
+func (r *routerCodec) Write(m *codec.Message, v interface{}) error {
+	// if we don't have a body
+	if v != nil {
+		data, err := r.c.Marshal(v)
+		if err != nil {
+			return err
+		}
+		m.Body = data
+	}
+	// write the body as the raw frame
+	return r.s.SendMsg(&bytes.Frame{Data: m.Body})
+}
+
+func (r *routerCodec) Close() error {
+	return nil
+}
+
+func (r *routerCodec) String() string {
+	return r.name
+}
@@ -20,7 +20,7 @@
 // OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
 // SOFTWARE.
 
-package grpc
+package rpc
 
 import (
 	"reflect"
@@ -36,7 +36,9 @@ type rpcHandler struct {
 	opts      server.HandlerOptions
 }
 
-func newRpcHandler(handler interface{}, opts ...server.HandlerOption) server.Handler {
+// NewHandler returns the handler of the receiver, the endpoints are extracted
+// from the exported methods of it
+func NewHandler(handler interface{}, opts ...server.HandlerOption) server.Handler {
 	options := server.HandlerOptions{
 		Metadata: make(map[string]map[string]string),
 	}
@@ -20,11 +20,12 @@
 // OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
 // SOFTWARE.
 
-package grpc
+package rpc
 
 import (
 	"github.com/lack-io/vine/core/codec"
 	"github.com/lack-io/vine/core/codec/bytes"
+	"github.com/lack-io/vine/core/server"
 )
 
 type rpcRequest struct {
@@ -33,7 +34,6 @@ type rpcRequest struct {
 	contentType string
 	codec       codec.Codec
 	header      map[string]string
-	body        []byte
 	stream      bool
 	payload     interface{}
 }
@@ -47,6 +47,18 @@ type rpcMessage struct {
 	codec       codec.Codec
 }
 
+// NewRequest returns the request served by the router, it's read by the codec
+func NewRequest(service, method, contentType string, header map[string]string, c codec.Codec, stream bool) server.Request {
+	return &rpcRequest{
+		service:     service,
+		method:      method,
+		contentType: contentType,
+		header:      header,
+		codec:       c,
+		stream:      stream,
+	}
+}
+
 func (r *rpcRequest) ContentType() string {
 	return r.contentType
 }
@@ -20,18 +20,22 @@
 // OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
 // SOFTWARE.
 
-package grpc
+package rpc
 
 import (
 	"context"
 	"errors"
+	"fmt"
 	"reflect"
+	"runtime/debug"
 	"sync"
 	"unicode"
 	"unicode/utf8"
 
+	"github.com/lack-io/vine/core/codec"
 	"github.com/lack-io/vine/core/server"
 	log "github.com/lack-io/vine/lib/logger"
+	verrors "github.com/lack-io/vine/proto/apis/errors"
 )
 
 var (
@@ -55,12 +59,23 @@ type service struct {
 	method map[string]*methodType // registered methods
 }
 
-// server represents an RPC Server.
-type rServer struct {
+// Router dispatches the requests to the methods of the registered handlers
+type Router struct {
 	mu         sync.Mutex // protects the serviceMap
 	serviceMap map[string]*service
 }
 
+// Endpoint is a method of the registered handler
+type Endpoint struct {
+	service *service
+	mtype   *methodType
+}
+
+// NewRouter returns an empty router
+func NewRouter() *Router {
+	return &Router{serviceMap: make(map[string]*service)}
+}
+
 // Is this an exported - upper case - name?
 func isExported(name string) bool {
 	r, _ := utf8.DecodeRuneInString(name)
@@ -147,11 +162,12 @@ func prepareEndpoint(method reflect.Method) *methodType {
 	return &methodType{method: method, ArgType: argType, ReplyType: replyType, ContextType: contextType, stream: stream}
 }
 
-func (server *rServer) register(rcvr interface{}) error {
-	server.mu.Lock()
-	defer server.mu.Unlock()
-	if server.serviceMap == nil {
-		server.serviceMap = make(map[string]*service)
+// Register registers the exported methods of the receiver
+func (router *Router) Register(rcvr interface{}) error {
+	router.mu.Lock()
+	defer router.mu.Unlock()
+	if router.serviceMap == nil {
+		router.serviceMap = make(map[string]*service)
 	}
 	s := new(service)
 	s.typ = reflect.TypeOf(rcvr)
@@ -165,7 +181,7 @@ func (server *rServer) register(rcvr interface{}) error {
 		log.Error(s)
 		return errors.New(s)
 	}
-	if _, present := server.serviceMap[sname]; present {
+	if _, present := router.serviceMap[sname]; present {
 		return errors.New("rpc: service already defined: " + sname)
 	}
 	s.name = sname
@@ -184,13 +200,169 @@ func (server *rServer) register(rcvr interface{}) error {
 		log.Error(s)
 		return errors.New(s)
 	}
-	server.serviceMap[s.name] = s
+	router.serviceMap[s.name] = s
 	return nil
 }
 
+// Endpoint returns the registered method of the service
+func (router *Router) Endpoint(serviceName, methodName string) (*Endpoint, error) {
+	router.mu.Lock()
+	service := router.serviceMap[serviceName]
+	router.mu.Unlock()
+
+	if service == nil {
+		return nil, fmt.Errorf("unknown service %s", serviceName)
+	}
+
+	mtype := service.method[methodName]
+	if mtype == nil {
+		return nil, fmt.Errorf("unknown service %s.%s", serviceName, methodName)
+	}
+	return &Endpoint{service: service, mtype: mtype}, nil
+}
+
+// Name returns the name of endpoint, e.g. Foo.Bar
+func (e *Endpoint) Name() string {
+	return e.service.name + "." + e.mtype.method.Name
+}
+
+// Stream reports whether the method is a stream
+func (e *Endpoint) Stream() bool {
+	return e.mtype.stream
+}
+
 func (m *methodType) prepareContext(ctx context.Context) reflect.Value {
 	if contextv := reflect.ValueOf(ctx); contextv.IsValid() {
 		return contextv
 	}
 	return reflect.Zero(m.ContextType)
 }
+
+// Call serves the unary request, the argument is read by read and the
+// method is called through the handler wrappers, it returns the reply.
+// The error of reading the argument is a bad request.
+func (e *Endpoint) Call(ctx context.Context, opts server.Options, ct string, header map[string]string, read func(interface{}) error) (interface{}, error) {
+	service, mtype := e.service, e.mtype
+
+	// Decode the argument value.
+	var argv reflect.Value
+	if mtype.ArgType.Kind() == reflect.Ptr {
+		argv = reflect.New(mtype.ArgType.Elem())
+	} else {
+		argv = reflect.New(mtype.ArgType)
+	}
+
+	if err := read(argv.Interface()); err != nil {
+		return nil, verrors.BadRequest(server.DefaultName, "%v", err)
+	}
+
+	// if true, need to indirect before calling.
+	if mtype.ArgType.Kind() != reflect.Ptr {
+		argv = argv.Elem()
+	}
+
+	// reply value
+	replyv := reflect.New(mtype.ReplyType.Elem())
+
+	function := mtype.method.Func
+
+	// create a client.Request
+	r := &rpcRequest{
+		service:     opts.Name,
+		contentType: ct,
+		method:      e.Name(),
+		header:      header,
+		payload:     argv.Interface(),
+	}
+
+	// define the handler func
+	fn := func(ctx context.Context, req server.Request, rsp interface{}) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Error("panic recovered: ", r)
+				log.Error(string(debug.Stack()))
+				err = verrors.InternalServerError(server.DefaultName, "panic recovered: %v", r)
+			}
+		}()
+		returnValues := function.Call([]reflect.Value{service.rcvr, mtype.prepareContext(ctx), reflect.ValueOf(argv.Interface()), reflect.ValueOf(rsp)})
+
+		// The return value for the method is an error.
+		if rerr := returnValues[0].Interface(); rerr != nil {
+			err = rerr.(error)
+		}
+
+		return err
+	}
+
+	// wrap the handler func
+	for i := len(opts.HdlrWrappers); i > 0; i-- {
+		fn = opts.HdlrWrappers[i-1](fn)
+	}
+
+	// execute the handler
+	if err := fn(ctx, r, replyv.Interface()); err != nil {
+		return nil, err
+	}
+
+	return replyv.Interface(), nil
+}
+
+// Serve serves the stream request, the messages of stream are exchanged
+// by s and the method is called through the handler wrappers.
+func (e *Endpoint) Serve(ctx context.Context, opts server.Options, ct string, header map[string]string, s MsgStream) error {
+	service, mtype := e.service, e.mtype
+
+	r := &rpcRequest{
+		service:     opts.Name,
+		contentType: ct,
+		method:      e.Name(),
+		header:      header,
+		stream:      true,
+	}
+
+	ss := &rpcStream{
+		ctx:     ctx,
+		s:       s,
+		request: r,
+	}
+
+	function := mtype.method.Func
+
+	// Invoke the method, providing a new value for the reply.
+	fn := func(ctx context.Context, req server.Request, stream interface{}) error {
+		returnValues := function.Call([]reflect.Value{service.rcvr, mtype.prepareContext(ctx), reflect.ValueOf(stream)})
+		if err := returnValues[0].Interface(); err != nil {
+			return err.(error)
+		}
+
+		return nil
+	}
+
+	for i := len(opts.HdlrWrappers); i > 0; i-- {
+		fn = opts.HdlrWrappers[i-1](fn)
+	}
+
+	return fn(ctx, r, ss)
+}
+
+// ServeRouter serves the request by the router of options through the
+// handler wrappers, the request and the response are read and written by c
+func ServeRouter(ctx context.Context, opts server.Options, req server.Request, c codec.Codec) error {
+	response := &rpcResponse{
+		header: make(map[string]string),
+		codec:  c,
+	}
+
+	// create a wrapped function
+	handler := func(ctx context.Context, req server.Request, rsp interface{}) error {
+		return opts.Router.ServeRequest(ctx, req, rsp.(server.Response))
+	}
+
+	// execute the wrapper for it
+	for i := len(opts.HdlrWrappers); i > 0; i-- {
+		handler = opts.HdlrWrappers[i-1](handler)
+	}
+
+	// serve the actual request using the request router
+	return handler(ctx, req, response)
+}
@@ -0,0 +1,107 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package rpc
+
+import (
+	"context"
+
+	"github.com/lack-io/vine/core/codec"
+	"github.com/lack-io/vine/core/server"
+)
+
+// MsgStream sends and receives the messages of a request, e.g. the stream
+// of grpc server
+type MsgStream interface {
+	Context() context.Context
+	SendMsg(m interface{}) error
+	RecvMsg(m interface{}) error
+}
+
+// Transport sends and receives the encoded messages of a request
+type Transport interface {
+	Recv() ([]byte, error)
+	Send([]byte) error
+}
+
+// NewMsgStream returns the stream of the messages exchanged by the transport
+// and encoded by the codec
+func NewMsgStream(ctx context.Context, t Transport, c codec.Marshaler) MsgStream {
+	return &msgStream{ctx: ctx, t: t, c: c}
+}
+
+type msgStream struct {
+	ctx context.Context
+	t   Transport
+	c   codec.Marshaler
+}
+
+func (m *msgStream) Context() context.Context {
+	return m.ctx
+}
+
+func (m *msgStream) SendMsg(v interface{}) error {
+	b, err := m.c.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return m.t.Send(b)
+}
+
+func (m *msgStream) RecvMsg(v interface{}) error {
+	b, err := m.t.Recv()
+	if err != nil {
+		return err
+	}
+	return m.c.Unmarshal(b, v)
+}
+
+// rpcStream implements a server side Stream.
+type rpcStream struct {
+	ctx     context.Context
+	s       MsgStream
+	request server.Request
+}
+
+func (r *rpcStream) Close() error {
+	return nil
+}
+
+func (r *rpcStream) Error() error {
+	return nil
+}
+
+func (r *rpcStream) Request() server.Request {
+	return r.request
+}
+
+func (r *rpcStream) Context() context.Context {
+	return r.ctx
+}
+
+func (r *rpcStream) Send(m interface{}) error {
+	return r.s.SendMsg(m)
+}
+
+func (r *rpcStream) Recv(m interface{}) error {
+	return r.s.RecvMsg(m)
+}
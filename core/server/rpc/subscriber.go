@@ -20,7 +20,7 @@
 // OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
 // SOFTWARE.
 
-package grpc
+package rpc
 
 import (
 	"context"
@@ -28,8 +28,10 @@ import (
 	"reflect"
 	"runtime/debug"
 	"strings"
+	"sync"
 
 	"github.com/lack-io/vine/core/broker"
+	"github.com/lack-io/vine/core/codec"
 	"github.com/lack-io/vine/core/server"
 	log "github.com/lack-io/vine/lib/logger"
 	"github.com/lack-io/vine/proto/apis/errors"
@@ -47,7 +49,8 @@ type handler struct {
 	ctxType reflect.Type
 }
 
-type subscriber struct {
+// Subscriber is the server.Subscriber whose messages are dispatched by reflection
+type Subscriber struct {
 	topic      string
 	rcvr       reflect.Value
 	typ        reflect.Type
@@ -57,7 +60,9 @@ type subscriber struct {
 	opts       server.SubscriberOptions
 }
 
-func newSubscriber(topic string, sub interface{}, opts ...server.SubscriberOption) server.Subscriber {
+// NewSubscriber returns the subscriber of the topic, sub is either a func or
+// a receiver whose exported methods are called with the messages
+func NewSubscriber(topic string, sub interface{}, opts ...server.SubscriberOption) server.Subscriber {
 	options := server.SubscriberOptions{AutoAck: true}
 
 	for _, o := range opts {
@@ -117,7 +122,7 @@ func newSubscriber(topic string, sub interface{}, opts ...server.SubscriberOptio
 		}
 	}
 
-	return &subscriber{
+	return &Subscriber{
 		rcvr:       reflect.ValueOf(sub),
 		typ:        reflect.TypeOf(sub),
 		topic:      topic,
@@ -128,7 +133,12 @@ func newSubscriber(topic string, sub interface{}, opts ...server.SubscriberOptio
 	}
 }
 
-func validateSubscriber(sub server.Subscriber) error {
+// Validate checks the signatures of the handlers of subscriber
+func Validate(sub server.Subscriber) error {
+	if s, ok := sub.(*Subscriber); ok && len(s.handlers) == 0 {
+		return fmt.Errorf("invalid subscriber: no handler functions")
+	}
+
 	typ := reflect.TypeOf(sub.Subscriber())
 	var argType reflect.Type
 
@@ -181,7 +191,23 @@ func validateSubscriber(sub server.Subscriber) error {
 	return nil
 }
 
-func (g *grpcServer) createSubHandler(sb *subscriber, opts server.Options) broker.Handler {
+// Dispatcher dispatches the messages of broker to the subscribers
+type Dispatcher struct {
+	// ContentType is the content type of the messages without it
+	ContentType string
+	// Codec returns the marshaler of the content type
+	Codec func(contentType string) (codec.Marshaler, error)
+	// Acquire tracks the message in flight, the message is refused when it
+	// returns false. Release is called once the acquired message is processed
+	Acquire func() bool
+	Release func()
+	// Wait is the optional wait group of the handlers of subscribers
+	Wait *sync.WaitGroup
+}
+
+// Handler returns the broker handler of the subscriber, the message is
+// passed to every handler of subscriber through the subscriber wrappers
+func (d *Dispatcher) Handler(sb *Subscriber, opts server.Options) broker.Handler {
 	return func(p broker.Event) (err error) {
 
 		defer func() {
@@ -192,18 +218,28 @@ func (g *grpcServer) createSubHandler(sb *subscriber, opts server.Options) broke
 			}
 		}()
 
+		// refuse the new messages in lame duck, they are redelivered
+		if !d.Acquire() {
+			return errors.ServiceUnavailable(server.DefaultName, "server is draining")
+		}
+		defer d.Release()
+
 		msg := p.Message()
 		// if we don't have headers, create empty map
 		if msg.Header == nil {
 			msg.Header = make(map[string]string)
 		}
 
+		if err = broker.DecompressMessage(msg); err != nil {
+			return err
+		}
+
 		ct := msg.Header["Content-Type"]
 		if len(ct) == 0 {
-			msg.Header["Content-Type"] = defaultContentType
-			ct = defaultContentType
+			msg.Header["Content-Type"] = d.ContentType
+			ct = d.ContentType
 		}
-		cf, err := g.newGRPCCodec(ct)
+		cf, err := d.Codec(ct)
 		if err != nil {
 			return err
 		}
@@ -233,7 +269,8 @@ func (g *grpcServer) createSubHandler(sb *subscriber, opts server.Options) broke
 				req = req.Elem()
 			}
 
-			if err = cf.Unmarshal(msg.Body, req.Interface()); err != nil {
+			// the subscribers taking *bytes.Frame receive the raw payload
+			if err = Wrap(cf).Unmarshal(msg.Body, req.Interface()); err != nil {
 				return err
 			}
 
@@ -259,12 +296,12 @@ func (g *grpcServer) createSubHandler(sb *subscriber, opts server.Options) broke
 				fn = opts.SubWrappers[i-1](fn)
 			}
 
-			if g.wg != nil {
-				g.wg.Add(1)
+			if d.Wait != nil {
+				d.Wait.Add(1)
 			}
 			go func() {
-				if g.wg != nil {
-					defer g.wg.Done()
+				if d.Wait != nil {
+					defer d.Wait.Done()
 				}
 				err := fn(ctx, &rpcMessage{
 					topic:       sb.topic,
@@ -291,18 +328,18 @@ func (g *grpcServer) createSubHandler(sb *subscriber, opts server.Options) broke
 	}
 }
 
-func (s *subscriber) Topic() string {
+func (s *Subscriber) Topic() string {
 	return s.topic
 }
 
-func (s *subscriber) Subscriber() interface{} {
+func (s *Subscriber) Subscriber() interface{} {
 	return s.subscriber
 }
 
-func (s *subscriber) Endpoints() []*regpb.Endpoint {
+func (s *Subscriber) Endpoints() []*regpb.Endpoint {
 	return s.endpoints
 }
 
-func (s *subscriber) Options() server.SubscriberOptions {
+func (s *Subscriber) Options() server.SubscriberOptions {
 	return s.opts
 }
@@ -167,6 +167,18 @@ var (
 	DefaultRegisterCheck    = func(context.Context) error { return nil }
 	DefaultRegisterInterval = time.Second * 30
 	DefaultRegisterTTL      = time.Second * 90
+	// DefaultDrainDelay is the delay between advertising the draining
+	// and refusing the new requests, it's the time for the clients to
+	// see the draining node. It's zero by default so that stop isn't
+	// slowed down: the new requests are refused at once with 503 which
+	// the clients retry on the other nodes, and the requests in flight
+	// are still waited for. Set it to the time the clients take to see
+	// the registry changes, e.g. the ttl of the registry cache, to avoid
+	// the retries
+	DefaultDrainDelay = time.Duration(0)
+	// DefaultDrainTimeout is the deadline of the in-flight requests
+	// and messages on stop
+	DefaultDrainTimeout = time.Second * 10
 )
 
 // DefaultOptions returns config options for the default service
@@ -9,14 +9,17 @@ require (
 	github.com/fasthttp/websocket v1.4.3
 	github.com/felixge/httpsnoop v1.0.1
 	github.com/fsnotify/fsnotify v1.4.9
+	github.com/fxamacker/cbor/v2 v2.4.0
 	github.com/gofiber/fiber/v2 v2.7.1
 	github.com/gogo/protobuf v1.3.2
 	github.com/google/uuid v1.2.0
+	github.com/graphql-go/graphql v0.8.0
 	github.com/hashicorp/hcl v1.0.0
 	github.com/imdario/mergo v0.3.11
 	github.com/jinzhu/inflection v1.0.0
 	github.com/jinzhu/now v1.1.1
 	github.com/json-iterator/go v1.1.11
+	github.com/klauspost/compress v1.11.8
 	github.com/kr/pretty v0.2.1
 	github.com/lack-io/cli v1.2.4
 	github.com/lack-io/gscheduler v0.2.0
@@ -29,6 +32,7 @@ require (
 	github.com/serenize/snaker v0.0.0-20201027110005-a7ad2135616e
 	github.com/stretchr/testify v1.7.0
 	github.com/valyala/fasthttp v1.23.0
+	github.com/vmihailenco/msgpack/v5 v5.3.5
 	github.com/xlab/treeprint v1.0.0
 	go.etcd.io/etcd/api/v3 v3.5.0
 	go.etcd.io/etcd/client/v3 v3.5.0
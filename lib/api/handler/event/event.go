@@ -24,7 +24,6 @@
 package event
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"path"
@@ -36,9 +35,13 @@ import (
 	"github.com/google/uuid"
 	"github.com/oxtoacart/bpool"
 
+	"github.com/lack-io/vine/core/client"
+	"github.com/lack-io/vine/core/codec/bytes"
 	"github.com/lack-io/vine/lib/api/handler"
+	cehttp "github.com/lack-io/vine/lib/event/http"
 	"github.com/lack-io/vine/proto/apis/api"
 	ctx "github.com/lack-io/vine/util/context"
+	"github.com/lack-io/vine/util/context/metadata"
 )
 
 var (
@@ -103,6 +106,15 @@ func (e *event) Handle(c *fiber.Ctx) error {
 	// publish to topic
 	topic, action := evRoute(e.opts.Namespace, string(c.Request().URI().Path()))
 
+	// CloudEvents are published as is with the attributes in header
+	header := make(map[string]string)
+	c.Request().Header.VisitAll(func(k, v []byte) {
+		header[string(k)] = string(v)
+	})
+	if cehttp.IsEvent(header) {
+		return e.handleCloudEvents(c, topic, header)
+	}
+
 	// create event
 	ev := &api.Event{
 		Name: action,
@@ -132,7 +144,7 @@ func (e *event) Handle(c *fiber.Ctx) error {
 		// Read body
 		buf := bufferPool.Get()
 		defer bufferPool.Put(buf)
-		if _, err := buf.ReadFrom(bytes.NewBuffer(c.Body())); err != nil {
+		if _, err := buf.Write(c.Body()); err != nil {
 			return fiber.NewError(500, err.Error())
 		}
 		ev.Data = buf.String()
@@ -151,6 +163,33 @@ func (e *event) Handle(c *fiber.Ctx) error {
 	return nil
 }
 
+// handleCloudEvents publishes the CloudEvents of the http binding to topic,
+// the body of the message is the data of event
+func (e *event) handleCloudEvents(c *fiber.Ctx, topic string, header map[string]string) error {
+	events, err := cehttp.Decode(header, c.Body())
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+
+	cc := e.opts.Client
+	for _, ev := range events {
+		msg := ev.Message()
+		md := metadata.Metadata{}
+		for k, v := range msg.Header {
+			if k == "Content-Type" {
+				continue
+			}
+			md[k] = v
+		}
+		cx := metadata.MergeContext(ctx.FromRequest(c), md, true)
+		p := cc.NewMessage(topic, &bytes.Frame{Data: msg.Body}, client.WithMessageContentType(msg.Header["Content-Type"]))
+		if err := cc.Publish(cx, p); err != nil {
+			return fiber.NewError(500, err.Error())
+		}
+	}
+	return nil
+}
+
 func (e *event) String() string {
 	return "event"
 }
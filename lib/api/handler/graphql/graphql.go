@@ -0,0 +1,248 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package graphql is a graphql handler which builds the schema from the
+// endpoints of registered services
+package graphql
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/graphql-go/graphql"
+
+	"github.com/lack-io/vine/core/registry"
+	"github.com/lack-io/vine/lib/api/handler"
+	log "github.com/lack-io/vine/lib/logger"
+	regpb "github.com/lack-io/vine/proto/apis/registry"
+	ctx "github.com/lack-io/vine/util/context"
+)
+
+const (
+	Handler = "graphql"
+)
+
+type graphqlHandler struct {
+	opts handler.Options
+
+	once sync.Once
+	sync.RWMutex
+	schema *graphql.Schema
+	// dirty marks the schema to be rebuilt
+	dirty bool
+}
+
+// request is the graphql request over http
+type request struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+func (h *graphqlHandler) Handle(c *fiber.Ctx) error {
+	if isWebSocket(c) {
+		return h.serveWebSocket(c)
+	}
+
+	req, err := parseRequest(c)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	schema, err := h.getSchema()
+	if err != nil {
+		return fiber.NewError(fiber.StatusServiceUnavailable, err.Error())
+	}
+
+	result := graphql.Do(graphql.Params{
+		Schema:         *schema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        ctx.FromRequest(c),
+	})
+
+	b, err := json.Marshal(result)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	c.Set("Content-Type", "application/json")
+	return c.Send(b)
+}
+
+func (h *graphqlHandler) String() string {
+	return "graphql"
+}
+
+// parseRequest extracts the graphql request from query string or body
+func parseRequest(c *fiber.Ctx) (*request, error) {
+	req := &request{}
+
+	if c.Method() == fiber.MethodGet {
+		req.Query = c.Query("query")
+		req.OperationName = c.Query("operationName")
+		if v := c.Query("variables"); len(v) > 0 {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				return nil, err
+			}
+		}
+		return req, nil
+	}
+
+	ct := c.Get("Content-Type")
+	if idx := strings.IndexRune(ct, ';'); idx >= 0 {
+		ct = ct[:idx]
+	}
+	switch ct {
+	case "application/graphql":
+		req.Query = string(c.Body())
+	default:
+		if err := json.Unmarshal(c.Body(), req); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+func (h *graphqlHandler) registry() registry.Registry {
+	if h.opts.Registry != nil {
+		return h.opts.Registry
+	}
+	return registry.DefaultRegistry
+}
+
+// match checks if the service belongs to the namespace of handler
+func (h *graphqlHandler) match(name string) bool {
+	return len(h.opts.Namespace) == 0 || strings.HasPrefix(name, h.opts.Namespace+".")
+}
+
+// getSchema returns the schema, it's rebuilt when the registry changed
+func (h *graphqlHandler) getSchema() (*graphql.Schema, error) {
+	h.once.Do(func() {
+		go h.watch()
+	})
+
+	h.RLock()
+	schema, dirty := h.schema, h.dirty
+	h.RUnlock()
+	if schema != nil && !dirty {
+		return schema, nil
+	}
+
+	h.Lock()
+	defer h.Unlock()
+	if h.schema != nil && !h.dirty {
+		return h.schema, nil
+	}
+
+	s, err := h.build()
+	if err != nil {
+		if h.schema != nil {
+			// keep serving the stale schema
+			log.Errorf("rebuild graphql schema: %v", err)
+			return h.schema, nil
+		}
+		return nil, err
+	}
+	h.schema = s
+	h.dirty = false
+	return s, nil
+}
+
+// build creates the schema from the registry
+func (h *graphqlHandler) build() (*graphql.Schema, error) {
+	reg := h.registry()
+	list, err := reg.ListServices()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	var services []*regpb.Service
+	for _, item := range list {
+		if seen[item.Name] || !h.match(item.Name) {
+			continue
+		}
+		seen[item.Name] = true
+
+		versions, err := reg.GetService(item.Name)
+		if err != nil || len(versions) == 0 {
+			continue
+		}
+
+		// merge the endpoints of all versions
+		svc := &regpb.Service{Name: item.Name}
+		eps := map[string]bool{}
+		for _, v := range versions {
+			for _, ep := range v.Endpoints {
+				if eps[ep.Name] {
+					continue
+				}
+				eps[ep.Name] = true
+				svc.Endpoints = append(svc.Endpoints, ep)
+			}
+		}
+		services = append(services, svc)
+	}
+
+	schema, err := newBuilder(h.opts.Client).build(h.opts.Namespace, services)
+	if err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+// watch marks the schema dirty when the services of namespace changed
+func (h *graphqlHandler) watch() {
+	for {
+		w, err := h.registry().Watch()
+		if err != nil {
+			time.Sleep(time.Second)
+			continue
+		}
+
+		for {
+			res, err := w.Next()
+			if err != nil {
+				break
+			}
+			if res == nil || res.Service == nil || !h.match(res.Service.Name) {
+				continue
+			}
+			h.Lock()
+			h.dirty = true
+			h.Unlock()
+		}
+		w.Stop()
+		time.Sleep(time.Second)
+	}
+}
+
+// NewHandler returns a graphql handler
+func NewHandler(opts ...handler.Option) handler.Handler {
+	return &graphqlHandler{
+		opts: handler.NewOptions(opts...),
+	}
+}
@@ -0,0 +1,391 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+
+	"github.com/lack-io/vine/core/client"
+	"github.com/lack-io/vine/core/codec/bytes"
+	"github.com/lack-io/vine/proto/apis/errors"
+	regpb "github.com/lack-io/vine/proto/apis/registry"
+)
+
+const (
+	// OperationKey is the endpoint metadata key which annotates the graphql
+	// operation of the endpoint: query, mutation, subscription or none
+	OperationKey = "graphql"
+
+	Query        = "query"
+	Mutation     = "mutation"
+	Subscription = "subscription"
+	None         = "none"
+)
+
+var (
+	// QueryPrefixes the unary endpoints named with these prefixes become queries,
+	// the others become mutations
+	QueryPrefixes = []string{"Get", "List", "Find", "Search", "Query", "Read", "Fetch", "Count", "Describe", "Lookup", "Check"}
+
+	nameRe    = regexp.MustCompile(`^[_A-Za-z][_0-9A-Za-z]*$`)
+	invalidRe = regexp.MustCompile(`[^_0-9A-Za-z]`)
+)
+
+// JSON is the scalar of the values which can't be typed from the registry
+var JSON = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "JSON",
+	Description: "The arbitrary json value",
+	Serialize: func(value interface{}) interface{} {
+		return value
+	},
+	ParseValue: func(value interface{}) interface{} {
+		return value
+	},
+	ParseLiteral: parseLiteral,
+})
+
+func parseLiteral(value ast.Value) interface{} {
+	switch v := value.(type) {
+	case *ast.ObjectValue:
+		out := make(map[string]interface{}, len(v.Fields))
+		for _, f := range v.Fields {
+			out[f.Name.Value] = parseLiteral(f.Value)
+		}
+		return out
+	case *ast.ListValue:
+		out := make([]interface{}, 0, len(v.Values))
+		for _, item := range v.Values {
+			out = append(out, parseLiteral(item))
+		}
+		return out
+	default:
+		return value.GetValue()
+	}
+}
+
+// operation returns the graphql operation of endpoint
+func operation(ep *regpb.Endpoint) string {
+	if v := strings.ToLower(ep.Metadata[OperationKey]); len(v) > 0 {
+		return v
+	}
+	if ep.Metadata["stream"] == "true" {
+		return Subscription
+	}
+	method := ep.Name
+	if idx := strings.LastIndex(method, "."); idx >= 0 {
+		method = method[idx+1:]
+	}
+	for _, prefix := range QueryPrefixes {
+		if strings.HasPrefix(method, prefix) {
+			return Query
+		}
+	}
+	return Mutation
+}
+
+// sanitize replaces the characters which graphql names don't accept
+func sanitize(name string) string {
+	name = invalidRe.ReplaceAllString(name, "_")
+	if len(name) > 0 && name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+// scalar returns the graphql scalar of registry value type, the 64-bit
+// integers are strings as the jsonpb encodes them.
+func scalar(typ string) graphql.Output {
+	switch typ {
+	case "string", "[]uint8":
+		return graphql.String
+	case "bool":
+		return graphql.Boolean
+	case "int", "int8", "int16", "int32", "uint", "uint8", "uint16", "uint32":
+		return graphql.Int
+	case "int64", "uint64":
+		return graphql.String
+	case "float32", "float64":
+		return graphql.Float
+	}
+	return nil
+}
+
+// builder builds the schema of the services
+type builder struct {
+	cc      client.Client
+	outputs map[string]graphql.Output
+	inputs  map[string]graphql.Input
+}
+
+func newBuilder(cc client.Client) *builder {
+	return &builder{
+		cc:      cc,
+		outputs: map[string]graphql.Output{},
+		inputs:  map[string]graphql.Input{},
+	}
+}
+
+func (b *builder) output(prefix string, v *regpb.Value) graphql.Output {
+	if v == nil {
+		return JSON
+	}
+	if t := scalar(v.Type); t != nil {
+		return t
+	}
+	if strings.HasPrefix(v.Type, "[]") {
+		if t := scalar(v.Type[2:]); t != nil {
+			return graphql.NewList(t)
+		}
+		return graphql.NewList(JSON)
+	}
+
+	fields := graphql.Fields{}
+	for _, val := range v.Values {
+		if !nameRe.MatchString(val.Name) {
+			continue
+		}
+		fields[val.Name] = &graphql.Field{Type: b.output(prefix, val)}
+	}
+	if len(fields) == 0 || len(v.Type) == 0 {
+		return JSON
+	}
+
+	name := prefix + "_" + sanitize(v.Type)
+	if t, ok := b.outputs[name]; ok {
+		return t
+	}
+	t := graphql.NewObject(graphql.ObjectConfig{Name: name, Fields: fields})
+	b.outputs[name] = t
+	return t
+}
+
+func (b *builder) input(prefix string, v *regpb.Value) graphql.Input {
+	if v == nil {
+		return JSON
+	}
+	if t := scalar(v.Type); t != nil {
+		return t
+	}
+	if strings.HasPrefix(v.Type, "[]") {
+		if t := scalar(v.Type[2:]); t != nil {
+			return graphql.NewList(t)
+		}
+		return graphql.NewList(JSON)
+	}
+
+	fields := graphql.InputObjectConfigFieldMap{}
+	for _, val := range v.Values {
+		if !nameRe.MatchString(val.Name) {
+			continue
+		}
+		fields[val.Name] = &graphql.InputObjectFieldConfig{Type: b.input(prefix, val)}
+	}
+	if len(fields) == 0 || len(v.Type) == 0 {
+		return JSON
+	}
+
+	name := prefix + "_" + sanitize(v.Type) + "Input"
+	if t, ok := b.inputs[name]; ok {
+		return t
+	}
+	t := graphql.NewInputObject(graphql.InputObjectConfig{Name: name, Fields: fields})
+	b.inputs[name] = t
+	return t
+}
+
+func (b *builder) args(prefix string, v *regpb.Value) graphql.FieldConfigArgument {
+	args := graphql.FieldConfigArgument{}
+	if v == nil {
+		return args
+	}
+	for _, val := range v.Values {
+		if !nameRe.MatchString(val.Name) {
+			continue
+		}
+		args[val.Name] = &graphql.ArgumentConfig{Type: b.input(prefix, val)}
+	}
+	return args
+}
+
+// build returns the schema of services, the names of services are trimmed by namespace
+func (b *builder) build(namespace string, services []*regpb.Service) (graphql.Schema, error) {
+	query := graphql.Fields{}
+	mutation := graphql.Fields{}
+	subscription := graphql.Fields{}
+
+	var names []string
+	for _, svc := range services {
+		names = append(names, svc.Name)
+
+		short := strings.TrimPrefix(strings.TrimPrefix(svc.Name, namespace), ".")
+		prefix := sanitize(short)
+
+		for _, ep := range svc.Endpoints {
+			name := prefix + "_" + sanitize(ep.Name)
+			field := &graphql.Field{
+				Type:        b.output(prefix, ep.Response),
+				Args:        b.args(prefix, ep.Request),
+				Description: fmt.Sprintf("%s %s", svc.Name, ep.Name),
+			}
+
+			switch operation(ep) {
+			case Query:
+				field.Resolve = b.resolve(svc.Name, ep.Name)
+				query[name] = field
+			case Mutation:
+				field.Resolve = b.resolve(svc.Name, ep.Name)
+				mutation[name] = field
+			case Subscription:
+				field.Subscribe = b.subscribe(svc.Name, ep.Name)
+				field.Resolve = func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source, nil
+				}
+				subscription[name] = field
+			}
+		}
+	}
+
+	sort.Strings(names)
+	query["_services"] = &graphql.Field{
+		Type:        graphql.NewList(graphql.String),
+		Description: "The services of the schema",
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return names, nil
+		},
+	}
+
+	config := graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: query}),
+	}
+	if len(mutation) > 0 {
+		config.Mutation = graphql.NewObject(graphql.ObjectConfig{Name: "Mutation", Fields: mutation})
+	}
+	if len(subscription) > 0 {
+		config.Subscription = graphql.NewObject(graphql.ObjectConfig{Name: "Subscription", Fields: subscription})
+	}
+
+	return graphql.NewSchema(config)
+}
+
+// resolve calls the endpoint with the arguments of field
+func (b *builder) resolve(service, endpoint string) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		data, err := json.Marshal(p.Args)
+		if err != nil {
+			return nil, err
+		}
+		req := b.cc.NewRequest(service, endpoint, &bytes.Frame{Data: data}, client.WithContentType("application/json"))
+		rsp := &bytes.Frame{}
+		if err := b.cc.Call(p.Context, req, rsp); err != nil {
+			return nil, newError(err)
+		}
+		return decode(rsp.Data)
+	}
+}
+
+// subscribe opens a stream to the endpoint, the messages of stream are
+// delivered through the returned channel
+func (b *builder) subscribe(service, endpoint string) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		data, err := json.Marshal(p.Args)
+		if err != nil {
+			return nil, err
+		}
+		req := b.cc.NewRequest(service, endpoint, &bytes.Frame{Data: data},
+			client.WithContentType("application/json"), client.StreamingRequest())
+		stream, err := b.cc.Stream(p.Context, req)
+		if err != nil {
+			return nil, newError(err)
+		}
+		if err := stream.Send(&bytes.Frame{Data: data}); err != nil {
+			stream.Close()
+			return nil, newError(err)
+		}
+
+		ch := make(chan interface{})
+		go func() {
+			defer close(ch)
+			defer stream.Close()
+			for {
+				rsp := &bytes.Frame{}
+				if err := stream.Recv(rsp); err != nil {
+					return
+				}
+				v, err := decode(rsp.Data)
+				if err != nil {
+					return
+				}
+				select {
+				case ch <- v:
+				case <-p.Context.Done():
+					return
+				}
+			}
+		}()
+		return ch, nil
+	}
+}
+
+func decode(data []byte) (interface{}, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// callError is the graphql error of vine error, the id and code of error
+// are carried by extensions
+type callError struct {
+	e *errors.Error
+}
+
+func (e *callError) Error() string {
+	return e.e.Detail
+}
+
+func (e *callError) Extensions() map[string]interface{} {
+	return map[string]interface{}{
+		"id":     e.e.Id,
+		"code":   e.e.Code,
+		"status": e.e.Status,
+	}
+}
+
+func newError(err error) error {
+	if err == context.Canceled {
+		return err
+	}
+	return &callError{e: errors.Parse(err.Error())}
+}
@@ -0,0 +1,204 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+
+	"github.com/lack-io/vine/core/client"
+	"github.com/lack-io/vine/core/codec/bytes"
+	"github.com/lack-io/vine/proto/apis/errors"
+	regpb "github.com/lack-io/vine/proto/apis/registry"
+)
+
+type testRequest struct {
+	client.Request
+	service  string
+	endpoint string
+	body     interface{}
+}
+
+func (r *testRequest) Service() string     { return r.service }
+func (r *testRequest) Endpoint() string    { return r.endpoint }
+func (r *testRequest) Body() interface{}   { return r.body }
+func (r *testRequest) ContentType() string { return "application/json" }
+
+type testStream struct {
+	client.Stream
+	msgs []string
+}
+
+func (s *testStream) Send(interface{}) error { return nil }
+func (s *testStream) Close() error           { return nil }
+func (s *testStream) Recv(v interface{}) error {
+	if len(s.msgs) == 0 {
+		return io.EOF
+	}
+	v.(*bytes.Frame).Data = []byte(s.msgs[0])
+	s.msgs = s.msgs[1:]
+	return nil
+}
+
+type testClient struct {
+	client.Client
+}
+
+func (c *testClient) NewRequest(service, endpoint string, req interface{}, _ ...client.RequestOption) client.Request {
+	return &testRequest{service: service, endpoint: endpoint, body: req}
+}
+
+func (c *testClient) Call(_ context.Context, req client.Request, rsp interface{}, _ ...client.CallOption) error {
+	in := map[string]interface{}{}
+	if err := json.Unmarshal(req.Body().(*bytes.Frame).Data, &in); err != nil {
+		return err
+	}
+	switch req.Endpoint() {
+	case "Users.GetUser":
+		if in["id"] != "1" {
+			return errors.NotFound("go.vine.api.users", "user %v not found", in["id"])
+		}
+		rsp.(*bytes.Frame).Data = []byte(`{"user":{"id":"1","name":"vine","tags":["a"]}}`)
+	case "Users.Delete":
+		rsp.(*bytes.Frame).Data = []byte(`{}`)
+	}
+	return nil
+}
+
+func (c *testClient) Stream(_ context.Context, req client.Request, _ ...client.CallOption) (client.Stream, error) {
+	return &testStream{msgs: []string{`{"name":"a"}`, `{"name":"b"}`}}, nil
+}
+
+func testServices() []*regpb.Service {
+	user := &regpb.Value{Name: "user", Type: "User", Values: []*regpb.Value{
+		{Name: "id", Type: "int64"},
+		{Name: "name", Type: "string"},
+		{Name: "tags", Type: "[]string"},
+	}}
+	return []*regpb.Service{
+		{
+			Name: "go.vine.api.users",
+			Endpoints: []*regpb.Endpoint{
+				{
+					Name:     "Users.GetUser",
+					Request:  &regpb.Value{Name: "GetUserRequest", Type: "GetUserRequest", Values: []*regpb.Value{{Name: "id", Type: "int64"}}},
+					Response: &regpb.Value{Name: "GetUserResponse", Type: "GetUserResponse", Values: []*regpb.Value{user}},
+				},
+				{
+					Name:     "Users.Delete",
+					Request:  &regpb.Value{Name: "DeleteRequest", Type: "DeleteRequest", Values: []*regpb.Value{{Name: "id", Type: "int64"}}},
+					Response: &regpb.Value{Name: "DeleteResponse", Type: "DeleteResponse"},
+				},
+				{
+					Name:     "Users.Watch",
+					Request:  &regpb.Value{Name: "WatchRequest", Type: "WatchRequest"},
+					Response: &regpb.Value{Name: "Event", Type: "Event", Values: []*regpb.Value{{Name: "name", Type: "string"}}},
+					Metadata: map[string]string{"stream": "true"},
+				},
+				{
+					Name:     "Users.Internal",
+					Metadata: map[string]string{OperationKey: None},
+				},
+			},
+		},
+	}
+}
+
+func TestOperation(t *testing.T) {
+	testData := []struct {
+		ep *regpb.Endpoint
+		op string
+	}{
+		{&regpb.Endpoint{Name: "Users.ListUsers"}, Query},
+		{&regpb.Endpoint{Name: "Users.Create"}, Mutation},
+		{&regpb.Endpoint{Name: "Users.Create", Metadata: map[string]string{OperationKey: "Query"}}, Query},
+		{&regpb.Endpoint{Name: "Users.Watch", Metadata: map[string]string{"stream": "true"}}, Subscription},
+	}
+
+	for _, d := range testData {
+		if op := operation(d.ep); op != d.op {
+			t.Fatalf("%s: expect %s, got %s", d.ep.Name, d.op, op)
+		}
+	}
+}
+
+func TestSchema(t *testing.T) {
+	schema, err := newBuilder(&testClient{}).build("go.vine.api", testServices())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f := schema.QueryType().Fields()["users_Users_Internal"]; f != nil {
+		t.Fatal("expect endpoint to be skipped")
+	}
+
+	result := graphql.Do(graphql.Params{
+		Schema:        schema,
+		RequestString: `{ users_Users_GetUser(id: "1") { user { id name tags } } _services }`,
+		Context:       context.TODO(),
+	})
+	if len(result.Errors) > 0 {
+		t.Fatal(result.Errors)
+	}
+	b, _ := json.Marshal(result.Data)
+	if string(b) != `{"_services":["go.vine.api.users"],"users_Users_GetUser":{"user":{"id":"1","name":"vine","tags":["a"]}}}` {
+		t.Fatalf("unexpected result %s", b)
+	}
+
+	result = graphql.Do(graphql.Params{
+		Schema:        schema,
+		RequestString: `{ users_Users_GetUser(id: "2") { user { id } } }`,
+		Context:       context.TODO(),
+	})
+	if len(result.Errors) != 1 || result.Errors[0].Message != "user 2 not found" || result.Errors[0].Extensions["code"] != int32(404) {
+		t.Fatalf("unexpected errors %v", result.Errors)
+	}
+
+	result = graphql.Do(graphql.Params{
+		Schema:        schema,
+		RequestString: `mutation { users_Users_Delete(id: "1") }`,
+		Context:       context.TODO(),
+	})
+	if len(result.Errors) > 0 {
+		t.Fatal(result.Errors)
+	}
+
+	var names []string
+	for result := range graphql.Subscribe(graphql.Params{
+		Schema:        schema,
+		RequestString: `subscription { users_Users_Watch { name } }`,
+		Context:       context.TODO(),
+	}) {
+		if len(result.Errors) > 0 {
+			t.Fatal(result.Errors)
+		}
+		names = append(names, result.Data.(map[string]interface{})["users_Users_Watch"].(map[string]interface{})["name"].(string))
+	}
+	if len(names) != 2 || names[0] != "a" || names[1] != "b" {
+		t.Fatalf("unexpected subscription results %v", names)
+	}
+}
@@ -0,0 +1,246 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/valyala/fasthttp"
+
+	log "github.com/lack-io/vine/lib/logger"
+	ctx "github.com/lack-io/vine/util/context"
+	"github.com/lack-io/vine/util/context/metadata"
+)
+
+const (
+	// graphqlWS is the subprotocol of subscriptions-transport-ws
+	graphqlWS = "graphql-ws"
+	// graphqlTransportWS is the subprotocol of graphql-ws
+	graphqlTransportWS = "graphql-transport-ws"
+)
+
+// message is the websocket message of graphql subscription protocols
+type message struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+func isWebSocket(c *fiber.Ctx) bool {
+	contains := func(key, val string) bool {
+		vv := strings.Split(c.Get(key), ",")
+		for _, v := range vv {
+			if val == strings.ToLower(strings.TrimSpace(v)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return contains("Connection", "upgrade") && contains("Upgrade", "websocket")
+}
+
+// serveWebSocket serves the graphql operations over websocket, both of graphql-ws
+// and graphql-transport-ws subprotocols are supported.
+func (h *graphqlHandler) serveWebSocket(c *fiber.Ctx) error {
+	schema, err := h.getSchema()
+	if err != nil {
+		return fiber.NewError(fiber.StatusServiceUnavailable, err.Error())
+	}
+
+	// the request context is released after upgrading, keep the metadata only
+	md, _ := metadata.FromContext(ctx.FromRequest(c))
+	md = metadata.Copy(md)
+
+	upgrader := websocket.FastHTTPUpgrader{
+		HandshakeTimeout: 30 * time.Second,
+		ReadBufferSize:   1024 * 32,
+		WriteBufferSize:  1024 * 32,
+		Subprotocols:     []string{graphqlTransportWS, graphqlWS},
+		CheckOrigin: func(c *fasthttp.RequestCtx) bool {
+			return true
+		},
+	}
+
+	return upgrader.Upgrade(c.Context(), func(conn *websocket.Conn) {
+		s := &session{
+			conn:   conn,
+			schema: schema,
+			legacy: conn.Subprotocol() != graphqlTransportWS,
+			subs:   map[string]context.CancelFunc{},
+		}
+		cx, cancel := context.WithCancel(metadata.NewContext(context.Background(), md))
+		defer cancel()
+		s.serve(cx)
+	})
+}
+
+// session is a graphql websocket connection
+type session struct {
+	sync.Mutex
+	conn   *websocket.Conn
+	schema *graphql.Schema
+	legacy bool
+	subs   map[string]context.CancelFunc
+}
+
+func (s *session) write(m *message) {
+	s.Lock()
+	defer s.Unlock()
+	if err := s.conn.WriteJSON(m); err != nil {
+		log.Debugf("graphql websocket write: %v", err)
+	}
+}
+
+func (s *session) serve(cx context.Context) {
+	defer s.conn.Close()
+	defer func() {
+		s.Lock()
+		for _, cancel := range s.subs {
+			cancel()
+		}
+		s.Unlock()
+	}()
+
+	for {
+		m := &message{}
+		if err := s.conn.ReadJSON(m); err != nil {
+			return
+		}
+
+		switch m.Type {
+		case "connection_init":
+			s.write(&message{Type: "connection_ack"})
+		case "ping":
+			s.write(&message{Type: "pong", Payload: m.Payload})
+		case "start", "subscribe":
+			req := &request{}
+			if err := json.Unmarshal(m.Payload, req); err != nil {
+				s.error(m.ID, err)
+				continue
+			}
+			sub, cancel := context.WithCancel(cx)
+			s.Lock()
+			if _, ok := s.subs[m.ID]; ok {
+				s.Unlock()
+				cancel()
+				continue
+			}
+			s.subs[m.ID] = cancel
+			s.Unlock()
+			go s.run(sub, m.ID, req)
+		case "stop", "complete":
+			s.Lock()
+			if cancel, ok := s.subs[m.ID]; ok {
+				cancel()
+			}
+			s.Unlock()
+		case "connection_terminate":
+			return
+		}
+	}
+}
+
+// run executes the operation and sends the results
+func (s *session) run(cx context.Context, id string, req *request) {
+	defer func() {
+		s.Lock()
+		if cancel, ok := s.subs[id]; ok {
+			cancel()
+			delete(s.subs, id)
+		}
+		s.Unlock()
+	}()
+
+	params := graphql.Params{
+		Schema:         *s.schema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        cx,
+	}
+
+	typ := "next"
+	if s.legacy {
+		typ = "data"
+	}
+
+	if !isSubscription(req) {
+		s.result(id, typ, graphql.Do(params))
+		s.write(&message{ID: id, Type: "complete"})
+		return
+	}
+
+	// drain the results until the channel closed, the executor blocks on it
+	for result := range graphql.Subscribe(params) {
+		if cx.Err() != nil {
+			continue
+		}
+		s.result(id, typ, result)
+	}
+	if cx.Err() == nil {
+		s.write(&message{ID: id, Type: "complete"})
+	}
+}
+
+func (s *session) result(id, typ string, result *graphql.Result) {
+	b, err := json.Marshal(result)
+	if err != nil {
+		s.error(id, err)
+		return
+	}
+	s.write(&message{ID: id, Type: typ, Payload: b})
+}
+
+func (s *session) error(id string, err error) {
+	b, _ := json.Marshal([]map[string]string{{"message": err.Error()}})
+	s.write(&message{ID: id, Type: "error", Payload: b})
+}
+
+// isSubscription checks if the operation of request is a subscription
+func isSubscription(req *request) bool {
+	doc, err := parser.Parse(parser.ParseParams{Source: req.Query})
+	if err != nil {
+		return false
+	}
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if len(req.OperationName) > 0 && (op.Name == nil || op.Name.Value != req.OperationName) {
+			continue
+		}
+		return op.Operation == ast.OperationTypeSubscription
+	}
+	return false
+}
@@ -0,0 +1,204 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package grpcweb
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/lack-io/vine/proto/apis/errors"
+)
+
+const (
+	// dataFlag marks a message frame
+	dataFlag byte = 0x00
+	// compressedFlag marks a compressed message frame
+	compressedFlag byte = 0x01
+	// endStreamFlag marks the end of stream frame of connect protocol
+	endStreamFlag byte = 0x02
+	// trailerFlag marks the trailer frame of grpc-web
+	trailerFlag byte = 0x80
+
+	headerLen = 5
+)
+
+// frame is a length prefixed message of grpc-web and connect streaming
+type frame struct {
+	flag byte
+	data []byte
+}
+
+// readFrames splits the body into frames
+func readFrames(b []byte) ([]frame, error) {
+	var frames []frame
+	for len(b) > 0 {
+		if len(b) < headerLen {
+			return nil, io.ErrUnexpectedEOF
+		}
+		size := binary.BigEndian.Uint32(b[1:headerLen])
+		if uint64(len(b)-headerLen) < uint64(size) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		frames = append(frames, frame{flag: b[0], data: b[headerLen : headerLen+int(size)]})
+		b = b[headerLen+int(size):]
+	}
+	return frames, nil
+}
+
+// encodeFrame returns the length prefixed frame
+func encodeFrame(flag byte, data []byte) []byte {
+	b := make([]byte, headerLen+len(data))
+	b[0] = flag
+	binary.BigEndian.PutUint32(b[1:headerLen], uint32(len(data)))
+	copy(b[headerLen:], data)
+	return b
+}
+
+// encodeTrailer returns the grpc-web trailer block
+func encodeTrailer(code codes.Code, msg string) []byte {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "grpc-status: %d\r\n", code)
+	if len(msg) > 0 {
+		fmt.Fprintf(&sb, "grpc-message: %s\r\n", url.PathEscape(msg))
+	}
+	return []byte(sb.String())
+}
+
+// statusOf extracts grpc code and message from the error
+func statusOf(err error) (codes.Code, string) {
+	if err == nil || err == io.EOF {
+		return codes.OK, ""
+	}
+	if s, ok := status.FromError(err); ok && s.Code() != codes.Unknown {
+		return s.Code(), errors.Parse(s.Message()).Detail
+	}
+
+	verr := errors.Parse(err.Error())
+	switch verr.Code {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument, verr.Detail
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated, verr.Detail
+	case http.StatusForbidden:
+		return codes.PermissionDenied, verr.Detail
+	case http.StatusNotFound:
+		return codes.NotFound, verr.Detail
+	case http.StatusRequestTimeout:
+		return codes.DeadlineExceeded, verr.Detail
+	case http.StatusConflict:
+		return codes.AlreadyExists, verr.Detail
+	case http.StatusPreconditionFailed:
+		return codes.FailedPrecondition, verr.Detail
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted, verr.Detail
+	case http.StatusNotImplemented:
+		return codes.Unimplemented, verr.Detail
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return codes.Unavailable, verr.Detail
+	case http.StatusInternalServerError:
+		return codes.Internal, verr.Detail
+	}
+	return codes.Unknown, verr.Detail
+}
+
+// connectCodes is the connect protocol names of grpc codes
+var connectCodes = map[codes.Code]string{
+	codes.Canceled:           "canceled",
+	codes.Unknown:            "unknown",
+	codes.InvalidArgument:    "invalid_argument",
+	codes.DeadlineExceeded:   "deadline_exceeded",
+	codes.NotFound:           "not_found",
+	codes.AlreadyExists:      "already_exists",
+	codes.PermissionDenied:   "permission_denied",
+	codes.ResourceExhausted:  "resource_exhausted",
+	codes.FailedPrecondition: "failed_precondition",
+	codes.Aborted:            "aborted",
+	codes.OutOfRange:         "out_of_range",
+	codes.Unimplemented:      "unimplemented",
+	codes.Internal:           "internal",
+	codes.Unavailable:        "unavailable",
+	codes.DataLoss:           "data_loss",
+	codes.Unauthenticated:    "unauthenticated",
+}
+
+// connectStatus returns the http status of connect unary error
+func connectStatus(code codes.Code) int {
+	switch code {
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusRequestTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.FailedPrecondition:
+		return http.StatusPreconditionFailed
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	}
+	return http.StatusInternalServerError
+}
+
+type connectError struct {
+	Code    string `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type connectEnd struct {
+	Error *connectError `json:"error,omitempty"`
+}
+
+// encodeConnectError returns the json error of connect protocol
+func encodeConnectError(code codes.Code, msg string) []byte {
+	b, _ := json.Marshal(&connectError{Code: connectCodes[code], Message: msg})
+	return b
+}
+
+// encodeConnectEnd returns the end of stream message of connect protocol
+func encodeConnectEnd(code codes.Code, msg string) []byte {
+	end := &connectEnd{}
+	if code != codes.OK {
+		end.Error = &connectError{Code: connectCodes[code], Message: msg}
+	}
+	b, _ := json.Marshal(end)
+	return b
+}
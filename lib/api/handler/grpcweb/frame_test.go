@@ -0,0 +1,111 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package grpcweb
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+
+	"github.com/lack-io/vine/proto/apis/errors"
+)
+
+func TestFrames(t *testing.T) {
+	b := append(encodeFrame(dataFlag, []byte("hello")), encodeFrame(trailerFlag, encodeTrailer(codes.NotFound, "not found"))...)
+
+	frames, err := readFrames(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 2 {
+		t.Fatalf("expect 2 frames, got %d", len(frames))
+	}
+	if frames[0].flag != dataFlag || string(frames[0].data) != "hello" {
+		t.Fatalf("unexpected data frame %v", frames[0])
+	}
+	if frames[1].flag != trailerFlag || string(frames[1].data) != "grpc-status: 5\r\ngrpc-message: not%20found\r\n" {
+		t.Fatalf("unexpected trailer frame %q", frames[1].data)
+	}
+
+	if _, err := readFrames(b[:len(b)-1]); err == nil {
+		t.Fatal("expect error of truncated frame")
+	}
+}
+
+func TestParseContentType(t *testing.T) {
+	testData := []struct {
+		ct    string
+		p     protocol
+		codec string
+		ok    bool
+	}{
+		{"application/grpc-web", grpcWeb, "application/protobuf", true},
+		{"application/grpc-web+proto", grpcWeb, "application/protobuf", true},
+		{"application/grpc-web+json; charset=utf-8", grpcWeb, "application/json", true},
+		{"application/grpc-web-text", grpcWebText, "application/protobuf", true},
+		{"application/connect+json", connectStream, "application/json", true},
+		{"application/proto", connectUnary, "application/protobuf", true},
+		{"application/json", connectUnary, "application/json", true},
+		{"application/grpc-web+thrift", 0, "", false},
+		{"text/plain", 0, "", false},
+	}
+
+	for _, d := range testData {
+		p, codec, ok := parseContentType(d.ct)
+		if ok != d.ok || (ok && (p != d.p || codec != d.codec)) {
+			t.Fatalf("%s: expect %v %s %v, got %v %s %v", d.ct, d.p, d.codec, d.ok, p, codec, ok)
+		}
+	}
+}
+
+func TestReadPayload(t *testing.T) {
+	body := []byte(base64.StdEncoding.EncodeToString(encodeFrame(dataFlag, []byte("hello"))))
+	b, err := readPayload(grpcWebText, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "hello" {
+		t.Fatalf("expect hello, got %s", b)
+	}
+
+	if _, err := readPayload(grpcWeb, encodeFrame(compressedFlag, []byte("hello"))); err == nil {
+		t.Fatal("expect error of compressed message")
+	}
+}
+
+func TestStatus(t *testing.T) {
+	code, msg := statusOf(errors.NotFound("go.vine.api", "missing"))
+	if code != codes.NotFound || msg != "missing" {
+		t.Fatalf("unexpected status %v %s", code, msg)
+	}
+
+	end := encodeEnd(connectStream, code, msg)
+	frames, err := readFrames(end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frames[0].flag != endStreamFlag || string(frames[0].data) != `{"error":{"code":"not_found","message":"missing"}}` {
+		t.Fatalf("unexpected end stream %q", frames[0].data)
+	}
+}
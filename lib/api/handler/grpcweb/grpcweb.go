@@ -0,0 +1,348 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package grpcweb is a gRPC-Web and Connect protocol handler which lets
+// browsers call vine services over HTTP/1.1
+package grpcweb
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"google.golang.org/grpc/codes"
+
+	"github.com/lack-io/vine/core/client"
+	"github.com/lack-io/vine/core/client/selector"
+	"github.com/lack-io/vine/core/codec/bytes"
+	"github.com/lack-io/vine/lib/api/handler"
+	apipb "github.com/lack-io/vine/proto/apis/api"
+	regpb "github.com/lack-io/vine/proto/apis/registry"
+	ctx "github.com/lack-io/vine/util/context"
+)
+
+const (
+	Handler = "grpcweb"
+)
+
+// protocol is the wire protocol of request
+type protocol int
+
+const (
+	// grpcWeb application/grpc-web[+proto|+json]
+	grpcWeb protocol = iota
+	// grpcWebText application/grpc-web-text[+proto], base64 encoded grpc-web
+	grpcWebText
+	// connectStream application/connect+proto|json
+	connectStream
+	// connectUnary application/proto|json with Connect-Protocol-Version
+	connectUnary
+)
+
+// parseContentType returns the protocol and the content type used to call the service
+func parseContentType(ct string) (protocol, string, bool) {
+	if idx := strings.IndexRune(ct, ';'); idx >= 0 {
+		ct = ct[:idx]
+	}
+	ct = strings.ToLower(strings.TrimSpace(ct))
+
+	codec := func(sub string) (string, bool) {
+		switch sub {
+		case "", "+proto":
+			return "application/protobuf", true
+		case "+json":
+			return "application/json", true
+		}
+		return "", false
+	}
+
+	var (
+		p   protocol
+		sub string
+	)
+	switch {
+	case strings.HasPrefix(ct, "application/grpc-web-text"):
+		p, sub = grpcWebText, strings.TrimPrefix(ct, "application/grpc-web-text")
+	case strings.HasPrefix(ct, "application/grpc-web"):
+		p, sub = grpcWeb, strings.TrimPrefix(ct, "application/grpc-web")
+	case strings.HasPrefix(ct, "application/connect+"):
+		p, sub = connectStream, strings.TrimPrefix(ct, "application/connect")
+	case ct == "application/proto":
+		return connectUnary, "application/protobuf", true
+	case ct == "application/json":
+		return connectUnary, "application/json", true
+	default:
+		return 0, "", false
+	}
+
+	cc, ok := codec(sub)
+	return p, cc, ok
+}
+
+type grpcwebHandler struct {
+	opts handler.Options
+	s    *apipb.Service
+}
+
+// strategy is a hack for selection
+func strategy(services []*regpb.Service) selector.Strategy {
+	return func(_ []*regpb.Service) selector.Next {
+		// ignore input to this function, use services above
+		return selector.Random(services)
+	}
+}
+
+func (h *grpcwebHandler) Handle(c *fiber.Ctx) error {
+	ct := c.Get("Content-Type")
+	p, cct, ok := parseContentType(ct)
+	if !ok {
+		return fiber.NewError(fiber.StatusUnsupportedMediaType, "unsupported content type "+ct)
+	}
+	if c.Method() != fiber.MethodPost {
+		return fiber.NewError(fiber.StatusMethodNotAllowed)
+	}
+
+	// create context
+	cx := ctx.FromRequest(c)
+	r := ctx.NewRequestCtx(c, cx)
+
+	service, err := h.getService(r)
+	if err != nil {
+		return writeError(c, p, ct, codes.Unimplemented, err.Error())
+	}
+
+	if int64(len(c.Body())) > h.opts.MaxRecvSize {
+		return writeError(c, p, ct, codes.ResourceExhausted, "request entity too large")
+	}
+
+	// extract the request message
+	payload, err := readPayload(p, c.Body())
+	if err != nil {
+		return writeError(c, p, ct, codes.InvalidArgument, err.Error())
+	}
+
+	var callOpts []client.CallOption
+	if len(service.Services) > 0 {
+		callOpts = append(callOpts, client.WithSelectOption(selector.WithStrategy(strategy(service.Services))))
+	}
+
+	cc := h.opts.Client
+
+	if p != connectUnary && isStream(service) {
+		req := cc.NewRequest(
+			service.Name,
+			service.Endpoint.Name,
+			&bytes.Frame{Data: payload},
+			client.WithContentType(cct),
+			client.StreamingRequest(),
+		)
+		stream, err := cc.Stream(cx, req, callOpts...)
+		if err != nil {
+			code, msg := statusOf(err)
+			return writeError(c, p, ct, code, msg)
+		}
+		if err = stream.Send(&bytes.Frame{Data: payload}); err != nil {
+			stream.Close()
+			code, msg := statusOf(err)
+			return writeError(c, p, ct, code, msg)
+		}
+
+		setHeaders(c, ct)
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			defer stream.Close()
+			for {
+				rsp := &bytes.Frame{}
+				if err := stream.Recv(rsp); err != nil {
+					code, msg := statusOf(err)
+					w.Write(encodeEnd(p, code, msg))
+					w.Flush()
+					return
+				}
+				w.Write(encode(p, dataFlag, rsp.Data))
+				if err := w.Flush(); err != nil {
+					// the client has gone away
+					return
+				}
+			}
+		})
+		return nil
+	}
+
+	req := cc.NewRequest(
+		service.Name,
+		service.Endpoint.Name,
+		&bytes.Frame{Data: payload},
+		client.WithContentType(cct),
+	)
+	rsp := &bytes.Frame{}
+	if err := cc.Call(cx, req, rsp, callOpts...); err != nil {
+		code, msg := statusOf(err)
+		return writeError(c, p, ct, code, msg)
+	}
+
+	setHeaders(c, ct)
+	if p == connectUnary {
+		return c.Send(rsp.Data)
+	}
+	body := append(encode(p, dataFlag, rsp.Data), encodeEnd(p, codes.OK, "")...)
+	return c.Send(body)
+}
+
+// getService returns the service for this request from the router
+func (h *grpcwebHandler) getService(r *ctx.RequestCtx) (*apipb.Service, error) {
+	if h.s != nil {
+		// we were given the service
+		return h.s, nil
+	}
+	if h.opts.Router != nil {
+		// try get service from router
+		return h.opts.Router.Route(r)
+	}
+
+	// resolve /foo.Bar/Method to foo service and Bar.Method endpoint
+	parts := strings.Split(strings.Trim(r.Path(), "/"), "/")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("unknown method %s", r.Path())
+	}
+	idx := strings.LastIndex(parts[0], ".")
+	if idx <= 0 {
+		return nil, fmt.Errorf("unknown service %s", parts[0])
+	}
+	return &apipb.Service{
+		Name: parts[0][:idx],
+		Endpoint: &apipb.Endpoint{
+			Name: parts[0][idx+1:] + "." + parts[1],
+		},
+	}, nil
+}
+
+func (h *grpcwebHandler) String() string {
+	return "grpcweb"
+}
+
+// isStream checks if the endpoint is a server stream
+func isStream(svc *apipb.Service) bool {
+	if svc.Endpoint == nil {
+		return false
+	}
+	for _, service := range svc.Services {
+		for _, ep := range service.Endpoints {
+			if ep.Name == svc.Endpoint.Name && ep.Metadata["stream"] == "true" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// readPayload extracts the request message from the body
+func readPayload(p protocol, body []byte) ([]byte, error) {
+	if p == connectUnary {
+		return append([]byte(nil), body...), nil
+	}
+
+	if p == grpcWebText {
+		b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(body)))
+		if err != nil {
+			return nil, err
+		}
+		body = b
+	}
+
+	frames, err := readFrames(body)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range frames {
+		if f.flag&compressedFlag != 0 {
+			return nil, fmt.Errorf("compressed message is not supported")
+		}
+		if f.flag == dataFlag {
+			return append([]byte(nil), f.data...), nil
+		}
+	}
+	return nil, nil
+}
+
+// encode returns the frame in the wire format of protocol
+func encode(p protocol, flag byte, data []byte) []byte {
+	b := encodeFrame(flag, data)
+	if p == grpcWebText {
+		return []byte(base64.StdEncoding.EncodeToString(b))
+	}
+	return b
+}
+
+// encodeEnd returns the frame finishing the response stream
+func encodeEnd(p protocol, code codes.Code, msg string) []byte {
+	if p == connectStream {
+		return encode(p, endStreamFlag, encodeConnectEnd(code, msg))
+	}
+	return encode(p, trailerFlag, encodeTrailer(code, msg))
+}
+
+func setHeaders(c *fiber.Ctx, ct string) {
+	c.Set("Content-Type", ct)
+	c.Set("Access-Control-Expose-Headers", "grpc-status, grpc-message")
+	c.Status(fiber.StatusOK)
+}
+
+// writeError writes the error response, the status of grpc-web is carried by
+// both headers and trailer frame for the clients reading either of them.
+func writeError(c *fiber.Ctx, p protocol, ct string, code codes.Code, msg string) error {
+	switch p {
+	case connectUnary:
+		c.Set("Content-Type", "application/json")
+		c.Status(connectStatus(code))
+		return c.Send(encodeConnectError(code, msg))
+	case connectStream:
+		setHeaders(c, ct)
+		return c.Send(encodeEnd(p, code, msg))
+	}
+
+	setHeaders(c, ct)
+	c.Set("grpc-status", fmt.Sprintf("%d", code))
+	if len(msg) > 0 {
+		c.Set("grpc-message", url.PathEscape(msg))
+	}
+	return c.Send(encodeEnd(p, code, msg))
+}
+
+// NewHandler returns a grpc-web handler
+func NewHandler(opts ...handler.Option) handler.Handler {
+	options := handler.NewOptions(opts...)
+	return &grpcwebHandler{
+		opts: options,
+	}
+}
+
+// WithService creates a handler with a service
+func WithService(s *apipb.Service, opts ...handler.Option) handler.Handler {
+	options := handler.NewOptions(opts...)
+	return &grpcwebHandler{
+		opts: options,
+		s:    s,
+	}
+}
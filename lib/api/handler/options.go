@@ -25,6 +25,7 @@ package handler
 import (
 	"github.com/lack-io/vine/core/client"
 	"github.com/lack-io/vine/core/client/grpc"
+	"github.com/lack-io/vine/core/registry"
 	"github.com/lack-io/vine/lib/api/router"
 )
 
@@ -37,6 +38,7 @@ type Options struct {
 	Namespace   string
 	Router      router.Router
 	Client      client.Client
+	Registry    registry.Registry
 }
 
 type Option func(o *Options)
@@ -84,6 +86,13 @@ func WithClient(c client.Client) Option {
 	}
 }
 
+// WithRegistry specifies the registry which the handler builds routes from
+func WithRegistry(r registry.Registry) Option {
+	return func(o *Options) {
+		o.Registry = r
+	}
+}
+
 // WithMaxRecvSize WithmaxRecvSize specifies max body size
 func WithMaxRecvSize(size int64) Option {
 	return func(o *Options) {
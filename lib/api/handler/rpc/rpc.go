@@ -158,6 +158,17 @@ func (h *rpcHandler) Handle(c *fiber.Ctx) error {
 		ct = ct[:idx]
 	}
 
+	// binary codecs are transcoded to json before calling the service
+	bc, transcode := binaryCodecs[ct]
+	if transcode {
+		b, err := transcodeRequest(bc, c.Body())
+		if err != nil {
+			return writeError(c, errors.BadRequest("go.vine.api", err.Error()))
+		}
+		c.Request().SetBody(b)
+		ct = "application/json"
+	}
+
 	// vine client
 	cc := h.opts.Client
 
@@ -245,6 +256,13 @@ func (h *rpcHandler) Handle(c *fiber.Ctx) error {
 		}
 	}
 
+	if transcode {
+		rsp, err = transcodeResponse(bc, rsp)
+		if err != nil {
+			return writeError(c, err)
+		}
+	}
+
 	// write the response
 	writeResponse(c, rsp)
 
@@ -0,0 +1,64 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package rpc
+
+import (
+	"encoding/json"
+
+	"github.com/lack-io/vine/core/codec"
+	"github.com/lack-io/vine/core/codec/cbor"
+	"github.com/lack-io/vine/core/codec/msgpack"
+)
+
+var (
+	// binary codecs transcoded from and to json
+	binaryCodecs = map[string]codec.Marshaler{
+		"application/msgpack":   msgpack.Marshaler{},
+		"application/x-msgpack": msgpack.Marshaler{},
+		"application/cbor":      cbor.Marshaler{},
+	}
+)
+
+// transcodeRequest converts the binary encoded body to json
+func transcodeRequest(m codec.Marshaler, b []byte) ([]byte, error) {
+	if len(b) == 0 {
+		return b, nil
+	}
+	var v interface{}
+	if err := m.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// transcodeResponse converts the json response to binary encoding
+func transcodeResponse(m codec.Marshaler, b []byte) ([]byte, error) {
+	if len(b) == 0 {
+		return b, nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	return m.Marshal(v)
+}
@@ -0,0 +1,45 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package rpc
+
+import (
+	"testing"
+)
+
+func TestTranscode(t *testing.T) {
+	in := []byte(`{"name":"vine","tags":["a","b"],"meta":{"k":"v"}}`)
+
+	for ct, m := range binaryCodecs {
+		b, err := transcodeResponse(m, in)
+		if err != nil {
+			t.Fatalf("%s: %v", ct, err)
+		}
+		out, err := transcodeRequest(m, b)
+		if err != nil {
+			t.Fatalf("%s: %v", ct, err)
+		}
+		if string(out) != `{"meta":{"k":"v"},"name":"vine","tags":["a","b"]}` {
+			t.Fatalf("%s: unexpected json %s", ct, out)
+		}
+	}
+}
@@ -27,12 +27,15 @@ import (
 	"github.com/lack-io/vine/core/registry/mdns"
 	"github.com/lack-io/vine/lib/api/resolver"
 	"github.com/lack-io/vine/lib/api/resolver/vpath"
+	"github.com/lack-io/vine/lib/traffic"
 )
 
 type Options struct {
 	Handler  string
 	Registry registry.Registry
 	Resolver resolver.Resolver
+	// Traffic splits the requests between the versions of services
+	Traffic *traffic.Traffic
 }
 
 type Option func(o *Options)
@@ -77,3 +80,10 @@ func WithResolver(r resolver.Resolver) Option {
 		o.Resolver = r
 	}
 }
+
+// WithTraffic sets the traffic policies applied to the routed services
+func WithTraffic(t *traffic.Traffic) Option {
+	return func(o *Options) {
+		o.Traffic = t
+	}
+}
@@ -36,6 +36,7 @@ import (
 	"github.com/lack-io/vine/lib/api/router"
 	"github.com/lack-io/vine/lib/api/router/util"
 	"github.com/lack-io/vine/lib/logger"
+	"github.com/lack-io/vine/lib/traffic"
 	apipb "github.com/lack-io/vine/proto/apis/api"
 	regpb "github.com/lack-io/vine/proto/apis/registry"
 	ctx "github.com/lack-io/vine/util/context"
@@ -409,6 +410,37 @@ func (r *registryRouter) Route(c *ctx.RequestCtx) (*apipb.Service, error) {
 		return nil, errors.New("router closed")
 	}
 
+	service, err := r.route(c)
+	if err != nil || r.opts.Traffic == nil {
+		return service, err
+	}
+
+	// match the policies against the request headers and metadata
+	md, ok := metadata.FromContext(c.Context())
+	if ok {
+		md = metadata.Copy(md)
+	} else {
+		md = metadata.Metadata{}
+	}
+	c.Request().Header.VisitAll(func(k, v []byte) {
+		md.Set(string(k), string(v))
+	})
+
+	version, services := r.opts.Traffic.Select(service.Name, md, service.Services)
+	if len(version) == 0 {
+		return service, nil
+	}
+	c.Response().Header.Set(traffic.VersionHeader, version)
+
+	return &apipb.Service{
+		Name:     service.Name,
+		Endpoint: service.Endpoint,
+		Services: services,
+	}, nil
+}
+
+func (r *registryRouter) route(c *ctx.RequestCtx) (*apipb.Service, error) {
+
 	// try get an endpoint
 	ep, err := r.Endpoint(c)
 	if err == nil {
@@ -26,16 +26,21 @@ import (
 	"encoding/base64"
 	"fmt"
 	"math/rand"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/lack-io/cli"
 	"github.com/lack-io/vine/core/broker"
+	brokerDisk "github.com/lack-io/vine/core/broker/disk"
 	brokerGrpc "github.com/lack-io/vine/core/broker/grpc"
 	brokerHttp "github.com/lack-io/vine/core/broker/http"
 	"github.com/lack-io/vine/core/broker/memory"
 	"github.com/lack-io/vine/core/client"
+	cBroker "github.com/lack-io/vine/core/client/broker"
 	cGrpc "github.com/lack-io/vine/core/client/grpc"
+	cHttp "github.com/lack-io/vine/core/client/http"
 	"github.com/lack-io/vine/core/client/selector"
 	"github.com/lack-io/vine/core/client/selector/dns"
 	"github.com/lack-io/vine/core/client/selector/static"
@@ -50,11 +55,14 @@ import (
 	configSrc "github.com/lack-io/vine/lib/config/source"
 	"github.com/lack-io/vine/lib/dao"
 	log "github.com/lack-io/vine/lib/logger"
+	"github.com/lack-io/vine/lib/mtls"
 	"github.com/lack-io/vine/lib/trace"
 	memTracer "github.com/lack-io/vine/lib/trace/memory"
 
 	// servers
+	sbroker "github.com/lack-io/vine/core/server/broker"
 	sgrpc "github.com/lack-io/vine/core/server/grpc"
+	shttp "github.com/lack-io/vine/core/server/http"
 
 	daoNop "github.com/lack-io/vine/lib/dao/nop"
 
@@ -87,7 +95,7 @@ var (
 		&cli.StringFlag{
 			Name:    "client",
 			EnvVars: []string{"VINE_CLIENT"},
-			Usage:   "Client for vine; rpc",
+			Usage:   "Client for vine; broker, grpc, http",
 		},
 		&cli.StringFlag{
 			Name:    "client-request-timeout",
@@ -122,10 +130,20 @@ var (
 			Value:   30,
 			Usage:   "Register interval in seconds",
 		},
+		&cli.StringFlag{
+			Name:    "server-drain-delay",
+			EnvVars: []string{"VINE_SERVER_DRAIN_DELAY"},
+			Usage:   "Delay between advertising the draining and refusing the new requests on shutdown. e.g 5s",
+		},
+		&cli.StringFlag{
+			Name:    "server-drain-timeout",
+			EnvVars: []string{"VINE_SERVER_DRAIN_TIMEOUT"},
+			Usage:   "Deadline of the in-flight requests on shutdown. e.g 10s. Default: 10s",
+		},
 		&cli.StringFlag{
 			Name:    "server",
 			EnvVars: []string{"VINE_SERVER"},
-			Usage:   "Server for vine; rpc",
+			Usage:   "Server for vine; broker, grpc, http",
 		},
 		&cli.StringFlag{
 			Name:    "server-name",
@@ -161,7 +179,7 @@ var (
 		&cli.StringFlag{
 			Name:    "broker",
 			EnvVars: []string{"VINE_BROKER"},
-			Usage:   "Broker for pub/sub. http, nats, rabbitmq",
+			Usage:   "Broker for pub/sub. http, nats, rabbitmq, disk",
 		},
 		&cli.StringFlag{
 			Name:    "broker-address",
@@ -208,16 +226,49 @@ var (
 			EnvVars: []string{"VINE_TRACER_ADDRESS"},
 			Usage:   "Comma-separated list of tracer addresses",
 		},
+		&cli.BoolFlag{
+			Name:    "mtls",
+			EnvVars: []string{"VINE_MTLS"},
+			Usage:   "Enable the mutual TLS between services, the CA in ~/.vine/mtls is used when no files are specified",
+		},
+		&cli.StringFlag{
+			Name:    "mtls-ca-file",
+			EnvVars: []string{"VINE_MTLS_CA_FILE"},
+			Usage:   "Path of the CA certificates trusted by the services",
+		},
+		&cli.StringFlag{
+			Name:    "mtls-ca-key-file",
+			EnvVars: []string{"VINE_MTLS_CA_KEY_FILE"},
+			Usage:   "Path of the CA key, the service issues its own certificate when it's specified",
+		},
+		&cli.StringFlag{
+			Name:    "mtls-cert-file",
+			EnvVars: []string{"VINE_MTLS_CERT_FILE"},
+			Usage:   "Path of the service certificate, it's reloaded on change",
+		},
+		&cli.StringFlag{
+			Name:    "mtls-key-file",
+			EnvVars: []string{"VINE_MTLS_KEY_FILE"},
+			Usage:   "Path of the service certificate key, it's reloaded on change",
+		},
+		&cli.StringFlag{
+			Name:    "mtls-cert-ttl",
+			EnvVars: []string{"VINE_MTLS_CERT_TTL"},
+			Usage:   "Lifetime of the issued service certificate. e.g 1h, 24h. Default: 24h",
+		},
 	}
 
 	DefaultBrokers = map[string]func(...broker.Option) broker.Broker{
 		"service": brokerGrpc.NewBroker,
 		"memory":  memory.NewBroker,
 		"http":    brokerHttp.NewBroker,
+		"disk":    brokerDisk.NewBroker,
 	}
 
 	DefaultClients = map[string]func(...client.Option) client.Client{
-		"grpc": cGrpc.NewClient,
+		"broker": cBroker.NewClient,
+		"grpc":   cGrpc.NewClient,
+		"http":   cHttp.NewClient,
 	}
 
 	DefaultRegistries = map[string]func(...registry.Option) registry.Registry{
@@ -233,7 +284,9 @@ var (
 	}
 
 	DefaultServers = map[string]func(...server.Option) server.Server{
-		"grpc": sgrpc.NewServer,
+		"broker": sbroker.NewServer,
+		"grpc":   sgrpc.NewServer,
+		"http":   shttp.NewServer,
 	}
 
 	DefaultDialects = map[string]func(...dao.Option) dao.Dialect{
@@ -483,6 +536,22 @@ func (c *cmd) Before(ctx *cli.Context) error {
 		serverOpts = append(serverOpts, server.RegisterInterval(val*time.Second))
 	}
 
+	if delay, timeout := ctx.String("server-drain-delay"), ctx.String("server-drain-timeout"); len(delay) > 0 || len(timeout) > 0 {
+		d, t := server.DefaultDrainDelay, server.DefaultDrainTimeout
+		var err error
+		if len(delay) > 0 {
+			if d, err = time.ParseDuration(delay); err != nil {
+				return fmt.Errorf("failed to parse server-drain-delay: %v", delay)
+			}
+		}
+		if len(timeout) > 0 {
+			if t, err = time.ParseDuration(timeout); err != nil {
+				return fmt.Errorf("failed to parse server-drain-timeout: %v", timeout)
+			}
+		}
+		serverOpts = append(serverOpts, server.Drain(d, t))
+	}
+
 	if ctx.String("config") == "service" {
 		opt := config.WithSource(configSrv.NewSource(configSrc.WithClient(vineClient)))
 		if err := (*c.opts.Config).Init(opt); err != nil {
@@ -530,9 +599,67 @@ func (c *cmd) Before(ctx *cli.Context) error {
 		}
 	}
 
+	// the certificate is issued after the server name is set
+	if ctx.Bool("mtls") {
+		if err := c.setupMTLS(ctx); err != nil {
+			return fmt.Errorf("failed to setup mtls: %v", err)
+		}
+	}
+
 	return nil
 }
 
+// setupMTLS secures the server, client and broker by the certificate
+// of the service
+func (c *cmd) setupMTLS(ctx *cli.Context) error {
+	opts := []mtls.Option{mtls.Name((*c.opts.Server).Options().Name)}
+
+	caFile, caKeyFile := ctx.String("mtls-ca-file"), ctx.String("mtls-ca-key-file")
+	certFile, keyFile := ctx.String("mtls-cert-file"), ctx.String("mtls-key-file")
+	switch {
+	case len(certFile) > 0 || len(keyFile) > 0:
+		opts = append(opts, mtls.CertFiles(certFile, keyFile), mtls.CAFiles(caFile, ""))
+	case len(caFile) > 0:
+		opts = append(opts, mtls.CAFiles(caFile, caKeyFile))
+	default:
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return err
+		}
+		dir := filepath.Join(home, ".vine", "mtls")
+		ca, err := mtls.LoadOrCreateCA(filepath.Join(dir, "ca.pem"), filepath.Join(dir, "ca-key.pem"))
+		if err != nil {
+			return err
+		}
+		opts = append(opts, mtls.WithCA(ca))
+	}
+
+	if t := ctx.String("mtls-cert-ttl"); len(t) > 0 {
+		d, err := time.ParseDuration(t)
+		if err != nil {
+			return fmt.Errorf("failed to parse mtls-cert-ttl: %v", t)
+		}
+		opts = append(opts, mtls.TTL(d))
+	}
+
+	p, err := mtls.NewProvider(opts...)
+	if err != nil {
+		return err
+	}
+
+	if err := (*c.opts.Server).Init(server.TLSConfig(p.ServerConfig())); err != nil {
+		return err
+	}
+	auth := cGrpc.AuthServiceTLS(p.ClientConfig)
+	if (*c.opts.Client).String() == "http" {
+		auth = cHttp.AuthServiceTLS(p.ClientConfig)
+	}
+	if err := (*c.opts.Client).Init(auth); err != nil {
+		return err
+	}
+	return (*c.opts.Broker).Init(broker.TLSConfig(p.ServerConfig()))
+}
+
 func DefaultOptions() Options {
 	return DefaultCmd.Options()
 }
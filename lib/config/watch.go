@@ -0,0 +1,74 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package config
+
+import (
+	"time"
+
+	"github.com/lack-io/vine/lib/config/reader"
+	log "github.com/lack-io/vine/lib/logger"
+)
+
+// WatchFunc watches the path of the config and calls fn with the value on
+// every change until exit is closed, the watcher is recreated when it fails.
+// It blocks so run it in a goroutine.
+func WatchFunc(c Config, exit <-chan bool, fn func(reader.Value), path ...string) {
+	for {
+		select {
+		case <-exit:
+			return
+		default:
+		}
+
+		w, err := c.Watch(path...)
+		if err != nil {
+			log.Errorf("config watch error: %v", err)
+			select {
+			case <-exit:
+				return
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+
+		// stop the watcher on exit or once it fails, so that the next
+		// iteration doesn't leave it behind
+		done := make(chan struct{})
+		go func() {
+			select {
+			case <-exit:
+			case <-done:
+			}
+			w.Stop()
+		}()
+
+		for {
+			v, err := w.Next()
+			if err != nil {
+				break
+			}
+			fn(v)
+		}
+		close(done)
+	}
+}
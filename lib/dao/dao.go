@@ -76,6 +76,12 @@ type TxCommitter interface {
 	Rollback() error
 }
 
+// Plugin DAO plugin interface
+type Plugin interface {
+	Name() string
+	Initialize(*DB) error
+}
+
 // Valuer dao valuer interface
 type Valuer interface {
 	DaoValue(context.Context, *DB) clause.Expr
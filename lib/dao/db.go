@@ -69,6 +69,10 @@ func Open(dialect Dialect) (db *DB, err error) {
 		db.ClauseBuilders = map[string]clause.ClauseBuilder{}
 	}
 
+	if db.Plugins == nil {
+		db.Plugins = map[string]Plugin{}
+	}
+
 	preparedStmt := &PreparedStmtDB{
 		ConnPool:    db.ConnPool,
 		Stmts:       map[string]Stmt{},
@@ -223,6 +227,19 @@ func (db *DB) Callback() *callbacks {
 	return db.callbacks
 }
 
+// Use use plugin
+func (db *DB) Use(plugin Plugin) error {
+	name := plugin.Name()
+	if _, ok := db.Plugins[name]; ok {
+		return ErrRegistered
+	}
+	if err := plugin.Initialize(db); err != nil {
+		return err
+	}
+	db.Plugins[name] = plugin
+	return nil
+}
+
 // AddError add error to db
 func (db *DB) AddError(err error) error {
 	if db.Error == nil {
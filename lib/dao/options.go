@@ -67,7 +67,9 @@ type Options struct {
 	// ClauseBuilders clause builder
 	ClauseBuilders map[string]clause.ClauseBuilder
 	// ConnPool db conn pool
-	ConnPool   ConnPool
+	ConnPool ConnPool
+	// Plugins registered plugins
+	Plugins    map[string]Plugin
 	callbacks  *callbacks
 	cacheStore *sync.Map
 
@@ -97,6 +99,10 @@ func NewOptions(opts ...Option) Options {
 		options.cacheStore = &sync.Map{}
 	}
 
+	if options.Plugins == nil {
+		options.Plugins = map[string]Plugin{}
+	}
+
 	options.Context = context.Background()
 
 	return options
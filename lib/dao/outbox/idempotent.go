@@ -0,0 +1,126 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package outbox
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lack-io/vine/core/broker"
+	"github.com/lack-io/vine/core/server"
+	"github.com/lack-io/vine/lib/store"
+)
+
+var (
+	// ErrInFlight the message with the same id is being processed
+	ErrInFlight = errors.New("message in flight")
+)
+
+// deduper remembers the processed message ids in the store
+type deduper struct {
+	sync.Mutex
+	s        store.Store
+	opts     IdempotentOptions
+	inflight map[string]struct{}
+}
+
+func newDeduper(s store.Store, opts ...IdempotentOption) *deduper {
+	if s == nil {
+		s = store.DefaultStore
+	}
+	return &deduper{
+		s:        s,
+		opts:     NewIdempotentOptions(opts...),
+		inflight: map[string]struct{}{},
+	}
+}
+
+// do calls fn once per message id, the id is recorded only when fn succeeds
+// so that a failed message is processed again on redelivery.
+func (d *deduper) do(id string, fn func() error) error {
+	if id == "" {
+		return fn()
+	}
+
+	d.Lock()
+	if _, ok := d.inflight[id]; ok {
+		d.Unlock()
+		return ErrInFlight
+	}
+	d.inflight[id] = struct{}{}
+	d.Unlock()
+
+	defer func() {
+		d.Lock()
+		delete(d.inflight, id)
+		d.Unlock()
+	}()
+
+	recs, err := d.s.Read(id, store.ReadFrom(d.opts.Database, d.opts.Table))
+	if err == nil && len(recs) > 0 {
+		return nil
+	}
+	if err != nil && err != store.ErrNotFound {
+		return err
+	}
+
+	if err := fn(); err != nil {
+		return err
+	}
+
+	rec := &store.Record{
+		Key:   id,
+		Value: []byte(strconv.FormatInt(time.Now().UnixNano(), 10)),
+	}
+	return d.s.Write(rec, store.WriteTo(d.opts.Database, d.opts.Table), store.WriteTTL(d.opts.TTL))
+}
+
+// Handler wraps the broker handler, the message already processed according
+// to the IdKey header is acknowledged without calling the handler again.
+func Handler(s store.Store, h broker.Handler, opts ...IdempotentOption) broker.Handler {
+	d := newDeduper(s, opts...)
+	return func(e broker.Event) error {
+		var id string
+		if msg := e.Message(); msg != nil {
+			id = msg.Header[IdKey]
+		}
+		return d.do(id, func() error {
+			return h(e)
+		})
+	}
+}
+
+// SubscriberWrapper is a server.SubscriberWrapper dedupes messages by the IdKey header
+func SubscriberWrapper(s store.Store, opts ...IdempotentOption) server.SubscriberWrapper {
+	d := newDeduper(s, opts...)
+	return func(fn server.SubscriberFunc) server.SubscriberFunc {
+		return func(ctx context.Context, msg server.Message) error {
+			return d.do(msg.Header()[IdKey], func() error {
+				return fn(ctx, msg)
+			})
+		}
+	}
+}
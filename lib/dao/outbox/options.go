@@ -0,0 +1,146 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package outbox
+
+import (
+	"time"
+
+	"github.com/lack-io/vine/core/broker"
+)
+
+type Options struct {
+	// Broker the broker which events are forwarded to, defaults to broker.DefaultBroker
+	Broker broker.Broker
+	// Interval the interval of polling the outbox
+	Interval time.Duration
+	// BatchSize the maximum number of events forwarded per poll
+	BatchSize int
+	// MaxAttempts the attempts before an event is given up, zero means retry forever
+	MaxAttempts int32
+	// Retention how long the sent events are kept, zero keeps them forever
+	Retention time.Duration
+	// Lease how long the claimed events are owned by a relay, the other
+	// relays take them over once it expires
+	Lease time.Duration
+}
+
+func NewOptions(opts ...Option) Options {
+	options := Options{
+		Interval:  time.Second,
+		BatchSize: 100,
+		Lease:     time.Second * 30,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	if options.Broker == nil {
+		options.Broker = broker.DefaultBroker
+	}
+
+	return options
+}
+
+type Option func(*Options)
+
+// Broker sets the broker of relay
+func Broker(b broker.Broker) Option {
+	return func(o *Options) {
+		o.Broker = b
+	}
+}
+
+// Interval sets the polling interval of relay
+func Interval(d time.Duration) Option {
+	return func(o *Options) {
+		o.Interval = d
+	}
+}
+
+// BatchSize sets the maximum number of events forwarded per poll
+func BatchSize(n int) Option {
+	return func(o *Options) {
+		o.BatchSize = n
+	}
+}
+
+// MaxAttempts sets the attempts before an event is given up
+func MaxAttempts(n int32) Option {
+	return func(o *Options) {
+		o.MaxAttempts = n
+	}
+}
+
+// Retention sets how long the sent events are kept
+func Retention(d time.Duration) Option {
+	return func(o *Options) {
+		o.Retention = d
+	}
+}
+
+// Lease sets how long the claimed events are owned by a relay
+func Lease(d time.Duration) Option {
+	return func(o *Options) {
+		o.Lease = d
+	}
+}
+
+type IdempotentOptions struct {
+	// Database the store database of processed ids
+	Database string
+	// Table the store table of processed ids
+	Table string
+	// TTL how long the processed ids are remembered
+	TTL time.Duration
+}
+
+func NewIdempotentOptions(opts ...IdempotentOption) IdempotentOptions {
+	options := IdempotentOptions{
+		Table: "idempotent",
+		TTL:   time.Hour * 24,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+type IdempotentOption func(*IdempotentOptions)
+
+// IdempotentTable sets the store database and table of processed ids
+func IdempotentTable(database, table string) IdempotentOption {
+	return func(o *IdempotentOptions) {
+		o.Database = database
+		o.Table = table
+	}
+}
+
+// IdempotentTTL sets how long the processed ids are remembered
+func IdempotentTTL(d time.Duration) IdempotentOption {
+	return func(o *IdempotentOptions) {
+		o.TTL = d
+	}
+}
@@ -0,0 +1,115 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package outbox implements the transactional outbox pattern on top of dao.
+// Events are written to an outbox table within the caller's transaction and
+// forwarded to the broker by a Relay after the transaction commits.
+package outbox
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/lack-io/vine/core/broker"
+	"github.com/lack-io/vine/lib/dao"
+)
+
+const (
+	// IdKey is the message header carries the unique id of the event
+	IdKey = "Vine-Id"
+	// TopicKey is the message header carries the topic of the event
+	TopicKey = "Vine-Topic"
+	// DefaultTable is the default table of outbox
+	DefaultTable = "vine_outbox"
+)
+
+// Message is a row of the outbox table
+type Message struct {
+	ID        string `dao:"column:id;primaryKey;size:64"`
+	Topic     string `dao:"column:topic;size:255;index"`
+	Header    string `dao:"column:header"`
+	Body      []byte `dao:"column:body"`
+	Attempts  int32  `dao:"column:attempts"`
+	LastError string `dao:"column:last_error"`
+	CreatedAt int64  `dao:"column:created_at;index"`
+	SentAt    int64  `dao:"column:sent_at;index"`
+	// ClaimedBy is the relay which owns the event until ClaimedUntil
+	ClaimedBy    string `dao:"column:claimed_by;size:64"`
+	ClaimedUntil int64  `dao:"column:claimed_until"`
+}
+
+// TableName implements schema.Tabler
+func (Message) TableName() string {
+	return DefaultTable
+}
+
+// Migrate creates or updates the outbox table
+func Migrate(db *dao.DB) error {
+	return db.AutoMigrate(&Message{})
+}
+
+// Publish writes the message to the outbox within the given transaction. The
+// message is delivered by the Relay only when the transaction commits. The id
+// of the event is taken from the IdKey header, a new one is generated when absent.
+func Publish(tx *dao.DB, topic string, msg *broker.Message) (string, error) {
+	header := make(map[string]string, len(msg.Header)+2)
+	for k, v := range msg.Header {
+		header[k] = v
+	}
+	id, ok := header[IdKey]
+	if !ok || id == "" {
+		id = uuid.New().String()
+		header[IdKey] = id
+	}
+	header[TopicKey] = topic
+
+	b, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+
+	m := &Message{
+		ID:        id,
+		Topic:     topic,
+		Header:    string(b),
+		Body:      msg.Body,
+		CreatedAt: time.Now().UnixNano(),
+	}
+	if err := tx.Create(m).Error; err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// brokerMessage converts the outbox row to broker message
+func (m *Message) brokerMessage() (*broker.Message, error) {
+	header := map[string]string{}
+	if m.Header != "" {
+		if err := json.Unmarshal([]byte(m.Header), &header); err != nil {
+			return nil, err
+		}
+	}
+	header[IdKey] = m.ID
+	return &broker.Message{Header: header, Body: m.Body}, nil
+}
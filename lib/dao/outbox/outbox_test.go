@@ -0,0 +1,143 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package outbox
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/lack-io/vine/core/broker"
+	"github.com/lack-io/vine/lib/dao"
+	"github.com/lack-io/vine/lib/dao/callbacks"
+	"github.com/lack-io/vine/lib/dao/nop"
+	"github.com/lack-io/vine/lib/store/memory"
+)
+
+func newDB(t *testing.T) *dao.DB {
+	db, err := dao.Open(nop.NewDialect())
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.DryRun = true
+	db.SkipDefaultTransaction = true
+	callbacks.RegisterDefaultCallbacks(db, &callbacks.Options{})
+	return db
+}
+
+func TestPublish(t *testing.T) {
+	db := newDB(t)
+
+	msg := &broker.Message{Header: map[string]string{"foo": "bar"}, Body: []byte("hello")}
+	tx := db.Session(&dao.Session{})
+	id, err := Publish(tx, "go.vine.topic", msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id == "" {
+		t.Fatal("expect message id")
+	}
+	if _, ok := msg.Header[IdKey]; ok {
+		t.Fatal("the header of caller should not be modified")
+	}
+
+	id2, err := Publish(tx, "go.vine.topic", &broker.Message{Header: map[string]string{IdKey: "1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id2 != "1" {
+		t.Fatalf("expect id 1, got %s", id2)
+	}
+
+	stmt := db.Create(&Message{ID: "2"}).Statement
+	if sql := stmt.SQL.String(); !strings.Contains(sql, "INSERT INTO `vine_outbox`") {
+		t.Fatalf("unexpected sql: %s", sql)
+	}
+}
+
+func TestBrokerMessage(t *testing.T) {
+	m := &Message{ID: "1", Topic: "topic", Header: `{"foo":"bar","Vine-Topic":"topic"}`, Body: []byte("hello")}
+	msg, err := m.brokerMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Header[IdKey] != "1" || msg.Header["foo"] != "bar" || msg.Header[TopicKey] != "topic" {
+		t.Fatalf("unexpected header: %v", msg.Header)
+	}
+	if string(msg.Body) != "hello" {
+		t.Fatalf("unexpected body: %s", msg.Body)
+	}
+}
+
+type testEvent struct {
+	msg *broker.Message
+}
+
+func (e *testEvent) Topic() string            { return "topic" }
+func (e *testEvent) Message() *broker.Message { return e.msg }
+func (e *testEvent) Ack() error               { return nil }
+func (e *testEvent) Error() error             { return nil }
+
+func TestHandler(t *testing.T) {
+	calls := 0
+	fail := true
+	h := Handler(memory.NewStore(), func(e broker.Event) error {
+		calls++
+		if fail {
+			return errors.New("failed")
+		}
+		return nil
+	})
+
+	e := &testEvent{msg: &broker.Message{Header: map[string]string{IdKey: "1"}}}
+	if err := h(e); err == nil {
+		t.Fatal("expect error")
+	}
+	fail = false
+	for i := 0; i < 3; i++ {
+		if err := h(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("expect 2 calls, got %d", calls)
+	}
+
+	// messages without id are always processed
+	e = &testEvent{msg: &broker.Message{Header: map[string]string{}}}
+	_ = h(e)
+	_ = h(e)
+	if calls != 4 {
+		t.Fatalf("expect 4 calls, got %d", calls)
+	}
+}
+
+func TestInFlight(t *testing.T) {
+	d := newDeduper(memory.NewStore())
+	err := d.do("1", func() error {
+		return d.do("1", func() error { return nil })
+	})
+	if !errors.Is(err, ErrInFlight) {
+		t.Fatalf("expect in flight error, got %v", err)
+	}
+}
@@ -0,0 +1,246 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package outbox
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/lack-io/vine/lib/dao"
+	log "github.com/lack-io/vine/lib/logger"
+)
+
+// Relay forwards the committed events of outbox to the broker. Delivery is at
+// least once, an event may be published again when the relay crashes before it
+// is marked sent, so consumers are expected to be idempotent. The relays of
+// all replicas may share the outbox, every event is claimed by one of them.
+type Relay struct {
+	sync.Mutex
+	id   string
+	db   *dao.DB
+	opts Options
+
+	running bool
+	exit    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewRelay creates a relay of the outbox stored in db
+func NewRelay(db *dao.DB, opts ...Option) *Relay {
+	return &Relay{
+		id:   uuid.New().String(),
+		db:   db,
+		opts: NewOptions(opts...),
+	}
+}
+
+// Options returns the options of relay
+func (r *Relay) Options() Options {
+	return r.opts
+}
+
+// Start starts polling the outbox in background
+func (r *Relay) Start() error {
+	r.Lock()
+	defer r.Unlock()
+	if r.running {
+		return nil
+	}
+	r.running = true
+	r.exit = make(chan struct{})
+	r.wg.Add(1)
+	go r.run(r.exit)
+	return nil
+}
+
+// Stop stops the relay and waits for the pending batch
+func (r *Relay) Stop() error {
+	r.Lock()
+	if !r.running {
+		r.Unlock()
+		return nil
+	}
+	r.running = false
+	close(r.exit)
+	r.Unlock()
+	r.wg.Wait()
+	return nil
+}
+
+func (r *Relay) run(exit chan struct{}) {
+	defer r.wg.Done()
+
+	t := time.NewTicker(r.opts.Interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-exit:
+			return
+		case <-t.C:
+		}
+
+		ctx := context.Background()
+		for {
+			n, err := r.Flush(ctx)
+			if err != nil {
+				log.Errorf("[outbox]: failed to forward events: %v", err)
+				break
+			}
+			// drain the backlog without waiting for the next tick
+			if n < r.opts.BatchSize {
+				break
+			}
+			select {
+			case <-exit:
+				return
+			default:
+			}
+		}
+
+		if r.opts.Retention > 0 {
+			if err := r.Purge(ctx); err != nil {
+				log.Errorf("[outbox]: failed to purge events: %v", err)
+			}
+		}
+	}
+}
+
+// Flush forwards a batch of pending events in the order they were written
+// and returns the number of events sent. The events following a failed one
+// of the same topic are left for the next round to keep them in order.
+func (r *Relay) Flush(ctx context.Context) (int, error) {
+	db := r.db.WithContext(ctx)
+
+	rows, err := r.claim(db)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	failed := map[string]struct{}{}
+	// the events after a failed one of the topic are held back to keep them
+	// in order, their claims are released for the next flush of any relay
+	var held []*Message
+	defer func() {
+		r.release(db, held)
+	}()
+	for i, m := range rows {
+		if _, ok := failed[m.Topic]; ok {
+			held = append(held, m)
+			continue
+		}
+
+		msg, err := m.brokerMessage()
+		if err == nil {
+			err = r.opts.Broker.Publish(m.Topic, msg)
+		}
+
+		// release the claim whatever the result is
+		updates := map[string]interface{}{"attempts": m.Attempts + 1, "claimed_until": 0}
+		if err != nil {
+			failed[m.Topic] = struct{}{}
+			updates["last_error"] = err.Error()
+			log.Warnf("[outbox]: failed to publish %s to %s: %v", m.ID, m.Topic, err)
+		} else {
+			sent++
+			updates["sent_at"] = time.Now().UnixNano()
+			updates["last_error"] = ""
+		}
+
+		if err := db.Model(&Message{}).Where("id = ? AND claimed_by = ?", m.ID, r.id).Updates(updates).Error; err != nil {
+			held = append(held, rows[i+1:]...)
+			return sent, err
+		}
+	}
+
+	return sent, nil
+}
+
+// release clears the leases of the claimed events which aren't published
+func (r *Relay) release(db *dao.DB, rows []*Message) {
+	for _, m := range rows {
+		if err := db.Model(&Message{}).Where("id = ? AND claimed_by = ?", m.ID, r.id).Update("claimed_until", 0).Error; err != nil {
+			log.Warnf("[outbox]: failed to release %s: %v", m.ID, err)
+		}
+	}
+}
+
+// claim reads a batch of pending events and claims them for the relay. An
+// event is claimed by a conditional update, so it's owned by one relay only
+// until the lease expires. The topics of the events owned by other relays are
+// skipped to keep the events of a topic in order.
+func (r *Relay) claim(db *dao.DB) ([]*Message, error) {
+	tx := db.Where("sent_at = ?", 0)
+	if r.opts.MaxAttempts > 0 {
+		tx = tx.Where("attempts < ?", r.opts.MaxAttempts)
+	}
+
+	var rows []*Message
+	if err := tx.Order("created_at").Limit(r.opts.BatchSize).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UnixNano()
+	until := now + r.opts.Lease.Nanoseconds()
+	claimed := make([]*Message, 0, len(rows))
+	skipped := map[string]struct{}{}
+	for _, m := range rows {
+		if _, ok := skipped[m.Topic]; ok {
+			continue
+		}
+		if m.ClaimedUntil > now && m.ClaimedBy != r.id {
+			skipped[m.Topic] = struct{}{}
+			continue
+		}
+
+		res := db.Model(&Message{}).
+			Where("id = ? AND sent_at = ? AND (claimed_until < ? OR claimed_by = ?)", m.ID, 0, now, r.id).
+			Updates(map[string]interface{}{"claimed_by": r.id, "claimed_until": until})
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		// taken by another relay in the meantime
+		if res.RowsAffected == 0 {
+			skipped[m.Topic] = struct{}{}
+			continue
+		}
+		claimed = append(claimed, m)
+	}
+
+	return claimed, nil
+}
+
+// Purge deletes the sent events older than the retention
+func (r *Relay) Purge(ctx context.Context) error {
+	if r.opts.Retention <= 0 {
+		return nil
+	}
+	deadline := time.Now().Add(-r.opts.Retention).UnixNano()
+	return r.db.WithContext(ctx).
+		Where("sent_at > ? AND sent_at < ?", 0, deadline).
+		Delete(&Message{}).Error
+}
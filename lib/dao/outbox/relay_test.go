@@ -0,0 +1,360 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lack-io/vine/core/broker"
+	"github.com/lack-io/vine/lib/dao"
+	"github.com/lack-io/vine/lib/dao/callbacks"
+	"github.com/lack-io/vine/lib/dao/nop"
+)
+
+var columns = []string{"id", "topic", "header", "body", "attempts", "last_error",
+	"created_at", "sent_at", "claimed_by", "claimed_until"}
+
+// table is an in memory outbox table shared by the connections of the test
+// driver, every statement is executed atomically as a database would do
+type table struct {
+	sync.Mutex
+	rows []map[string]driver.Value
+}
+
+var (
+	selectRe = regexp.MustCompile("^SELECT \\* FROM `vine_outbox` WHERE (.+) ORDER BY created_at LIMIT (\\d+)$")
+	updateRe = regexp.MustCompile("^UPDATE `vine_outbox` SET (.+) WHERE (.+)$")
+	setRe    = regexp.MustCompile("`(\\w+)`=\\?")
+	condRe   = regexp.MustCompile(`^(\w+) (=|<) \?$`)
+)
+
+// match evaluates the conditions joined by AND, a condition may be a group
+// joined by OR, and returns the number of args consumed
+func match(row map[string]driver.Value, where string, args []driver.Value) (bool, int) {
+	ok, n := true, 0
+	for _, term := range strings.Split(where, " AND ") {
+		matched := false
+		for _, cond := range strings.Split(strings.Trim(term, "()"), " OR ") {
+			m := condRe.FindStringSubmatch(cond)
+			if m == nil {
+				panic("unsupported condition " + cond)
+			}
+			if compare(row[m[1]], m[2], args[n]) {
+				matched = true
+			}
+			n++
+		}
+		ok = ok && matched
+	}
+	return ok, n
+}
+
+func compare(v interface{}, op string, arg interface{}) bool {
+	switch v := v.(type) {
+	case int64:
+		a := arg.(int64)
+		if op == "<" {
+			return v < a
+		}
+		return v == a
+	default:
+		return fmt.Sprint(v) == fmt.Sprint(arg)
+	}
+}
+
+func (t *table) query(query string, args []driver.Value) (driver.Rows, error) {
+	t.Lock()
+	defer t.Unlock()
+
+	m := selectRe.FindStringSubmatch(query)
+	if m == nil {
+		return nil, fmt.Errorf("unsupported query %s", query)
+	}
+	limit, _ := strconv.Atoi(m[2])
+
+	var rows [][]driver.Value
+	for _, row := range t.rows {
+		if ok, _ := match(row, m[1], args); !ok {
+			continue
+		}
+		values := make([]driver.Value, len(columns))
+		for i, c := range columns {
+			values[i] = row[c]
+		}
+		rows = append(rows, values)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i][6].(int64) < rows[j][6].(int64)
+	})
+	if len(rows) > limit {
+		rows = rows[:limit]
+	}
+	return &testRows{rows: rows}, nil
+}
+
+func (t *table) exec(query string, args []driver.Value) (driver.Result, error) {
+	t.Lock()
+	defer t.Unlock()
+
+	m := updateRe.FindStringSubmatch(query)
+	if m == nil {
+		return nil, fmt.Errorf("unsupported statement %s", query)
+	}
+	sets := setRe.FindAllStringSubmatch(m[1], -1)
+
+	var affected int64
+	for _, row := range t.rows {
+		if ok, _ := match(row, m[2], args[len(sets):]); !ok {
+			continue
+		}
+		for i, s := range sets {
+			row[s[1]] = args[i]
+		}
+		affected++
+	}
+	return driver.RowsAffected(affected), nil
+}
+
+type testRows struct {
+	rows [][]driver.Value
+}
+
+func (r *testRows) Columns() []string { return columns }
+
+func (r *testRows) Close() error { return nil }
+
+func (r *testRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+type testDriver struct {
+	sync.Mutex
+	tables map[string]*table
+}
+
+func (d *testDriver) Open(name string) (driver.Conn, error) {
+	d.Lock()
+	defer d.Unlock()
+	t, ok := d.tables[name]
+	if !ok {
+		t = &table{}
+		d.tables[name] = t
+	}
+	return &testConn{t: t}, nil
+}
+
+type testConn struct {
+	t *table
+}
+
+func (c *testConn) Prepare(query string) (driver.Stmt, error) {
+	return &testStmt{t: c.t, query: query}, nil
+}
+
+func (c *testConn) Close() error { return nil }
+
+func (c *testConn) Begin() (driver.Tx, error) {
+	return nil, fmt.Errorf("transactions are not supported")
+}
+
+type testStmt struct {
+	t     *table
+	query string
+}
+
+func (s *testStmt) Close() error { return nil }
+
+func (s *testStmt) NumInput() int { return -1 }
+
+func (s *testStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.t.exec(s.query, args)
+}
+
+func (s *testStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.t.query(s.query, args)
+}
+
+var testTables = &testDriver{tables: map[string]*table{}}
+
+func init() {
+	sql.Register("outbox", testTables)
+}
+
+// openDB opens a new connection pool to the outbox table of the name
+func openDB(t *testing.T, name string) *dao.DB {
+	pool, err := sql.Open("outbox", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := dao.Open(nop.NewDialect(func(o *dao.Options) {
+		o.ConnPool = pool
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SkipDefaultTransaction = true
+	callbacks.RegisterDefaultCallbacks(db, &callbacks.Options{})
+	return db
+}
+
+type testBroker struct {
+	broker.Broker
+
+	sync.Mutex
+	published map[string][]string
+	// fail is the topic which the publishing fails
+	fail string
+}
+
+func (b *testBroker) Publish(topic string, m *broker.Message, opts ...broker.PublishOption) error {
+	if topic == b.fail {
+		return fmt.Errorf("publish %s failed", topic)
+	}
+	// leave time for the other relay to race on the events
+	time.Sleep(time.Millisecond)
+	b.Lock()
+	defer b.Unlock()
+	b.published[topic] = append(b.published[topic], m.Header[IdKey])
+	return nil
+}
+
+func TestRelays(t *testing.T) {
+	name := t.Name()
+	testTables.Lock()
+	tb := &table{}
+	testTables.tables[name] = tb
+	testTables.Unlock()
+
+	topics := []string{"a", "b", "c"}
+	now := time.Now().UnixNano()
+	for i := 0; i < 60; i++ {
+		tb.rows = append(tb.rows, map[string]driver.Value{
+			"id":            fmt.Sprintf("%02d", i),
+			"topic":         topics[i%len(topics)],
+			"header":        "",
+			"body":          []byte("hello"),
+			"attempts":      int64(0),
+			"last_error":    "",
+			"created_at":    now + int64(i),
+			"sent_at":       int64(0),
+			"claimed_by":    "",
+			"claimed_until": int64(0),
+		})
+	}
+
+	b := &testBroker{published: map[string][]string{}}
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		r := NewRelay(openDB(t, name), Broker(b), BatchSize(10))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if _, err := r.Flush(context.TODO()); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	seen := map[string]bool{}
+	for topic, ids := range b.published {
+		for i, id := range ids {
+			if seen[id] {
+				t.Fatalf("event %s is published more than once", id)
+			}
+			seen[id] = true
+			if i > 0 && ids[i-1] > id {
+				t.Fatalf("events of %s are out of order: %v", topic, ids)
+			}
+		}
+	}
+	if len(seen) != 60 {
+		t.Fatalf("expect 60 events published, got %d", len(seen))
+	}
+	for _, row := range tb.rows {
+		if row["sent_at"].(int64) == 0 {
+			t.Fatalf("event %s isn't marked sent", row["id"])
+		}
+	}
+}
+
+func TestRelayRelease(t *testing.T) {
+	name := t.Name()
+	testTables.Lock()
+	tb := &table{}
+	testTables.tables[name] = tb
+	testTables.Unlock()
+
+	topics := []string{"a", "b"}
+	now := time.Now().UnixNano()
+	for i := 0; i < 6; i++ {
+		tb.rows = append(tb.rows, map[string]driver.Value{
+			"id":            fmt.Sprintf("%02d", i),
+			"topic":         topics[i%len(topics)],
+			"header":        "",
+			"body":          []byte("hello"),
+			"attempts":      int64(0),
+			"last_error":    "",
+			"created_at":    now + int64(i),
+			"sent_at":       int64(0),
+			"claimed_by":    "",
+			"claimed_until": int64(0),
+		})
+	}
+
+	b := &testBroker{published: map[string][]string{}, fail: "a"}
+	r := NewRelay(openDB(t, name), Broker(b), BatchSize(10))
+	sent, err := r.Flush(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent != 3 {
+		t.Fatalf("expect the events of b to be sent, got %d", sent)
+	}
+
+	// the events of the failed topic aren't leased any more
+	for _, row := range tb.rows {
+		if row["topic"] == "a" && row["claimed_until"].(int64) != 0 {
+			t.Fatalf("the claim of event %s isn't released", row["id"])
+		}
+	}
+}
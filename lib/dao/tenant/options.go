@@ -0,0 +1,81 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tenant
+
+type Options struct {
+	// Strategy the isolation strategy, defaults to ColumnStrategy
+	Strategy Strategy
+	// Column the default tenant column, field tagged by `dao:"tenant"` takes precedence
+	Column string
+	// SchemaFunc maps tenant to database schema when using SchemaStrategy
+	SchemaFunc func(tenant string) string
+	// Optional skips isolation instead of failing when the context carries no tenant
+	Optional bool
+}
+
+func NewOptions(opts ...Option) Options {
+	options := Options{
+		Strategy: ColumnStrategy,
+		Column:   DefaultColumn,
+		SchemaFunc: func(tenant string) string {
+			return tenant
+		},
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+type Option func(*Options)
+
+// WithStrategy sets the isolation strategy
+func WithStrategy(s Strategy) Option {
+	return func(o *Options) {
+		o.Strategy = s
+	}
+}
+
+// Column sets the tenant column
+func Column(column string) Option {
+	return func(o *Options) {
+		o.Column = column
+	}
+}
+
+// SchemaFunc sets the function maps tenant to schema, it implies SchemaStrategy
+func SchemaFunc(fn func(tenant string) string) Option {
+	return func(o *Options) {
+		o.Strategy = SchemaStrategy
+		o.SchemaFunc = fn
+	}
+}
+
+// Optional allows statements without tenant in context
+func Optional() Option {
+	return func(o *Options) {
+		o.Optional = true
+	}
+}
@@ -0,0 +1,305 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package tenant is a dao plugin which isolates records by tenant
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/lack-io/vine/lib/dao"
+	"github.com/lack-io/vine/lib/dao/clause"
+	"github.com/lack-io/vine/lib/dao/schema"
+	"github.com/lack-io/vine/util/context/metadata"
+)
+
+const (
+	// TenantKey is used to set/get the tenant from the context metadata
+	TenantKey = "Vine-Tenant"
+	// DefaultColumn is the default column of tenant
+	DefaultColumn = "tenant_id"
+
+	skipKey = "dao:tenant_skip"
+)
+
+var (
+	// ErrMissingTenant the statement is executed without tenant in context
+	ErrMissingTenant = errors.New("missing tenant")
+	// ErrCrossTenant the statement trying to write the record of other tenant
+	ErrCrossTenant = errors.New("cross tenant write")
+	// ErrTableExpr the statement of SchemaStrategy names the table by an
+	// expression outside of the tenant schema, use Skip for it
+	ErrTableExpr = errors.New("table expression outside of tenant schema")
+)
+
+// Strategy isolation strategy of tenant
+type Strategy int32
+
+const (
+	// ColumnStrategy all tenants share tables, every row carries tenant column
+	ColumnStrategy Strategy = iota
+	// SchemaStrategy every tenant owns a database schema
+	SchemaStrategy
+)
+
+func (s Strategy) String() string {
+	switch s {
+	case ColumnStrategy:
+		return "column"
+	case SchemaStrategy:
+		return "schema"
+	default:
+		return fmt.Sprintf("%d", s)
+	}
+}
+
+// FromContext gets the tenant from the context
+func FromContext(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	tenant, ok := metadata.Get(ctx, TenantKey)
+	return tenant, ok && tenant != ""
+}
+
+// NewContext sets the tenant in the context
+func NewContext(ctx context.Context, tenant string) context.Context {
+	return metadata.Set(ctx, TenantKey, tenant)
+}
+
+// Skip disables tenant isolation for the returned db session, it's used for
+// migrations and cross tenant maintenance jobs.
+func Skip(db *dao.DB) *dao.DB {
+	return db.Set(skipKey, true)
+}
+
+type tenantPlugin struct {
+	opts Options
+}
+
+// New creates a dao plugin isolating records by tenant
+func New(opts ...Option) dao.Plugin {
+	return &tenantPlugin{opts: NewOptions(opts...)}
+}
+
+func (p *tenantPlugin) Name() string {
+	return "dao:tenant"
+}
+
+func (p *tenantPlugin) Initialize(db *dao.DB) error {
+	cb := db.Callback()
+	if err := cb.Create().Before("dao:before_create").Register("dao:tenant_create", p.create); err != nil {
+		return err
+	}
+	if err := cb.Query().Before("dao:query").Register("dao:tenant_query", p.query); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("dao:before_update").Register("dao:tenant_update", p.update); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("dao:before_delete").Register("dao:tenant_delete", p.query); err != nil {
+		return err
+	}
+	return cb.Row().Before("dao:row").Register("dao:tenant_row", p.query)
+}
+
+// field returns the tenant field of the statement's schema
+func (p *tenantPlugin) field(stmt *dao.Statement) *schema.Field {
+	if stmt.Schema == nil {
+		return nil
+	}
+	for _, field := range stmt.Schema.Fields {
+		if _, ok := field.TagSettings["TENANT"]; ok && field.DBName != "" {
+			return field
+		}
+	}
+	return stmt.Schema.LookUpField(p.opts.Column)
+}
+
+// prepare extracts tenant and tenant field of the statement, returns false
+// when the statement doesn't need isolation
+func (p *tenantPlugin) prepare(db *dao.DB) (string, *schema.Field, bool) {
+	if db.Error != nil {
+		return "", nil, false
+	}
+	if v, ok := db.Get(skipKey); ok && v == true {
+		return "", nil, false
+	}
+
+	stmt := db.Statement
+	tenant, ok := FromContext(stmt.Context)
+
+	if p.opts.Strategy == SchemaStrategy {
+		if !ok {
+			if !p.opts.Optional {
+				db.AddError(ErrMissingTenant)
+			}
+			return "", nil, false
+		}
+		if stmt.Table == "" && stmt.TableExpr == nil {
+			return "", nil, false
+		}
+		// the schema and the table are quoted separately
+		name := p.opts.SchemaFunc(tenant)
+		table := stmt.Quote(name) + "." + stmt.Quote(clause.Table{Name: stmt.Table})
+		switch {
+		case stmt.TableExpr == nil:
+			stmt.TableExpr = &clause.Expr{SQL: table}
+		case len(stmt.TableExpr.Vars) == 0 && (stmt.TableExpr.SQL == table || stmt.TableExpr.SQL == stmt.Quote(name+"."+stmt.Table)):
+			// the table of the tenant schema
+		default:
+			// the expression can't be rewritten safely, it may name the
+			// table of other schema or a sub query
+			db.AddError(fmt.Errorf("%w: %s", ErrTableExpr, stmt.TableExpr.SQL))
+		}
+		return "", nil, false
+	}
+
+	field := p.field(stmt)
+	if field == nil {
+		return "", nil, false
+	}
+	if !ok {
+		if !p.opts.Optional {
+			db.AddError(ErrMissingTenant)
+		}
+		return "", nil, false
+	}
+
+	return tenant, field, true
+}
+
+func (p *tenantPlugin) create(db *dao.DB) {
+	tenant, field, ok := p.prepare(db)
+	if !ok {
+		return
+	}
+
+	stmt := db.Statement
+	switch dest := stmt.Dest.(type) {
+	case map[string]interface{}:
+		stampMap(db, dest, field, tenant)
+	case []map[string]interface{}:
+		for _, m := range dest {
+			stampMap(db, m, field, tenant)
+		}
+	default:
+		switch stmt.ReflectValue.Kind() {
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < stmt.ReflectValue.Len(); i++ {
+				stampValue(db, reflect.Indirect(stmt.ReflectValue.Index(i)), field, tenant)
+			}
+		case reflect.Struct:
+			stampValue(db, stmt.ReflectValue, field, tenant)
+		}
+	}
+}
+
+func (p *tenantPlugin) query(db *dao.DB) {
+	tenant, field, ok := p.prepare(db)
+	if !ok {
+		return
+	}
+
+	addWhere(db.Statement, field, tenant)
+}
+
+func (p *tenantPlugin) update(db *dao.DB) {
+	tenant, field, ok := p.prepare(db)
+	if !ok {
+		return
+	}
+
+	stmt := db.Statement
+	switch dest := stmt.Dest.(type) {
+	case map[string]interface{}:
+		checkMap(db, dest, field, tenant)
+	default:
+		rv := reflect.Indirect(reflect.ValueOf(dest))
+		if rv.Kind() == reflect.Struct && rv.Type() == stmt.Schema.ModelType {
+			stampValue(db, rv, field, tenant)
+		}
+	}
+
+	addWhere(stmt, field, tenant)
+}
+
+// addWhere appends tenant condition to the where clause of statement
+func addWhere(stmt *dao.Statement, field *schema.Field, tenant string) {
+	if c, ok := stmt.Clauses["WHERE"]; ok {
+		if where, ok := c.Expression.(clause.Where); ok && len(where.Exprs) > 1 {
+			for _, expr := range where.Exprs {
+				if orCond, ok := expr.(clause.OrConditions); ok && len(orCond.Exprs) == 1 {
+					where.Exprs = []clause.Expression{clause.And(where.Exprs...)}
+					c.Expression = where
+					stmt.Clauses["WHERE"] = c
+					break
+				}
+			}
+		}
+	}
+
+	stmt.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: tenant},
+	}})
+}
+
+// stampValue sets the tenant of the record, the record owned by other tenant is rejected
+func stampValue(db *dao.DB, rv reflect.Value, field *schema.Field, tenant string) {
+	if rv.Kind() != reflect.Struct {
+		return
+	}
+
+	v, zero := field.ValueOf(rv)
+	if !zero {
+		if fmt.Sprintf("%v", v) != tenant {
+			db.AddError(fmt.Errorf("%w: record belongs to tenant %v", ErrCrossTenant, v))
+		}
+		return
+	}
+
+	if rv.CanAddr() {
+		if err := field.Set(rv, tenant); err != nil {
+			db.AddError(err)
+		}
+	}
+}
+
+// checkMap checks and sets the tenant of map values
+func checkMap(db *dao.DB, m map[string]interface{}, field *schema.Field, tenant string) {
+	for _, key := range []string{field.DBName, field.Name} {
+		if v, ok := m[key]; ok && fmt.Sprintf("%v", v) != tenant {
+			db.AddError(fmt.Errorf("%w: record belongs to tenant %v", ErrCrossTenant, v))
+			return
+		}
+	}
+}
+
+func stampMap(db *dao.DB, m map[string]interface{}, field *schema.Field, tenant string) {
+	checkMap(db, m, field, tenant)
+	if _, ok := m[field.Name]; !ok {
+		m[field.DBName] = tenant
+	}
+}
@@ -0,0 +1,162 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tenant
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/lack-io/vine/lib/dao"
+	"github.com/lack-io/vine/lib/dao/callbacks"
+	"github.com/lack-io/vine/lib/dao/nop"
+)
+
+type User struct {
+	ID     int64  `dao:"column:id;primaryKey"`
+	Name   string `dao:"column:name"`
+	Tenant string `dao:"column:tenant;tenant"`
+}
+
+func newDB(t *testing.T, opts ...Option) *dao.DB {
+	db, err := dao.Open(nop.NewDialect())
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.DryRun = true
+	db.SkipDefaultTransaction = true
+	callbacks.RegisterDefaultCallbacks(db, &callbacks.Options{})
+	if err := db.Use(New(opts...)); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestQuery(t *testing.T) {
+	db := newDB(t)
+	ctx := NewContext(context.TODO(), "t1")
+
+	var users []User
+	tx := db.WithContext(ctx).Where("name = ?", "a").Find(&users)
+	if tx.Error != nil {
+		t.Fatal(tx.Error)
+	}
+	sql := tx.Statement.SQL.String()
+	if !strings.Contains(sql, "`users`.`tenant` = ?") {
+		t.Fatalf("missing tenant condition: %s", sql)
+	}
+	if v := tx.Statement.Vars[len(tx.Statement.Vars)-1]; v != "t1" {
+		t.Fatalf("expect tenant var t1, got %v", v)
+	}
+
+	tx = db.Find(&users)
+	if !errors.Is(tx.Error, ErrMissingTenant) {
+		t.Fatalf("expect missing tenant error, got %v", tx.Error)
+	}
+
+	tx = Skip(db).Find(&users)
+	if tx.Error != nil {
+		t.Fatal(tx.Error)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	db := newDB(t)
+	ctx := NewContext(context.TODO(), "t1")
+
+	user := &User{Name: "a"}
+	if err := db.WithContext(ctx).Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if user.Tenant != "t1" {
+		t.Fatalf("expect tenant t1, got %s", user.Tenant)
+	}
+
+	other := &User{Name: "b", Tenant: "t2"}
+	if err := db.WithContext(ctx).Create(other).Error; !errors.Is(err, ErrCrossTenant) {
+		t.Fatalf("expect cross tenant error, got %v", err)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	db := newDB(t)
+	ctx := NewContext(context.TODO(), "t1")
+
+	tx := db.WithContext(ctx).Model(&User{ID: 1}).Updates(map[string]interface{}{"tenant": "t2"})
+	if !errors.Is(tx.Error, ErrCrossTenant) {
+		t.Fatalf("expect cross tenant error, got %v", tx.Error)
+	}
+}
+
+func TestSchemaStrategy(t *testing.T) {
+	db := newDB(t, SchemaFunc(func(tenant string) string { return "tenant_" + tenant }))
+	ctx := NewContext(context.TODO(), "t1")
+
+	var users []User
+	tx := db.WithContext(ctx).Find(&users)
+	if tx.Error != nil {
+		t.Fatal(tx.Error)
+	}
+	sql := tx.Statement.SQL.String()
+	if sql != "SELECT * FROM `tenant_t1`.`users`" {
+		t.Fatalf("expect tenant schema: %s", sql)
+	}
+
+	tx = db.WithContext(ctx).Create(&User{ID: 1, Name: "foo"})
+	if tx.Error != nil {
+		t.Fatal(tx.Error)
+	}
+	if sql := tx.Statement.SQL.String(); !strings.HasPrefix(sql, "INSERT INTO `tenant_t1`.`users` ") {
+		t.Fatalf("expect tenant schema: %s", sql)
+	}
+
+	tx = db.WithContext(ctx).Model(&User{}).Where("id = ?", 1).Update("name", "bar")
+	if tx.Error != nil {
+		t.Fatal(tx.Error)
+	}
+	if sql := tx.Statement.SQL.String(); !strings.HasPrefix(sql, "UPDATE `tenant_t1`.`users` SET") {
+		t.Fatalf("expect tenant schema: %s", sql)
+	}
+
+	// the explicit table is qualified by the tenant schema
+	tx = db.WithContext(ctx).Table("users").Find(&users)
+	if sql := tx.Statement.SQL.String(); tx.Error != nil || sql != "SELECT * FROM `tenant_t1`.`users`" {
+		t.Fatalf("expect tenant schema: %s %v", sql, tx.Error)
+	}
+	tx = db.WithContext(ctx).Table("tenant_t1.users").Find(&users)
+	if sql := tx.Statement.SQL.String(); tx.Error != nil || !strings.Contains(sql, "tenant_t1") {
+		t.Fatalf("expect tenant schema: %s %v", sql, tx.Error)
+	}
+
+	// the table expressions outside of the tenant schema are rejected
+	for _, table := range []string{"tenant_t2.users", "users AS u", "(SELECT * FROM users) AS u"} {
+		tx = db.WithContext(ctx).Table(table).Find(&users)
+		if !errors.Is(tx.Error, ErrTableExpr) {
+			t.Fatalf("expect table expression error of %s, got %v", table, tx.Error)
+		}
+	}
+	if tx = Skip(db).WithContext(ctx).Table("tenant_t2.users").Find(&users); tx.Error != nil {
+		t.Fatal(tx.Error)
+	}
+}
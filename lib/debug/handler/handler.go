@@ -0,0 +1,156 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package handler implements the debug service registered on every service
+package handler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lack-io/vine/lib/logger"
+	dlog "github.com/lack-io/vine/lib/logger/log"
+	pb "github.com/lack-io/vine/proto/services/debug"
+)
+
+// Debug is the handler of the debug service
+type Debug struct {
+	opts    Options
+	started time.Time
+}
+
+// NewHandler returns the debug handler
+func NewHandler(opts ...Option) *Debug {
+	return &Debug{
+		opts:    NewOptions(opts...),
+		started: time.Now(),
+	}
+}
+
+// Logs sends the recent records of the log and the new ones when stream is
+// set, the records are filtered by level and fields
+func (d *Debug) Logs(ctx context.Context, req *pb.LogRequest, stream pb.Debug_LogsStream) error {
+	match, err := filter(req)
+	if err != nil {
+		return err
+	}
+
+	var opts []dlog.ReadOption
+	if req.Since > 0 {
+		opts = append(opts, dlog.Since(time.Unix(req.Since, 0)))
+	}
+	if req.Count > 0 {
+		opts = append(opts, dlog.Count(int(req.Count)))
+	}
+
+	// subscribe before reading so that no record is missed in between
+	var st dlog.Stream
+	if req.Stream {
+		st, err = d.opts.Log.Stream()
+		if err != nil {
+			return err
+		}
+		defer st.Stop()
+	}
+
+	records, err := d.opts.Log.Read(opts...)
+	if err != nil {
+		return err
+	}
+	// the position of the records sent, the sequence is preferred as the
+	// records may share the timestamp
+	var last time.Time
+	var seq uint64
+	for _, r := range records {
+		last, seq = r.Timestamp, r.Sequence
+		if !match(r) {
+			continue
+		}
+		if err := stream.Send(toRecord(r)); err != nil {
+			return err
+		}
+	}
+
+	if st == nil {
+		return nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case r, ok := <-st.Chan():
+			if !ok {
+				return nil
+			}
+			// skip the records which were sent already
+			if r.Sequence > 0 {
+				if r.Sequence <= seq {
+					continue
+				}
+			} else if !r.Timestamp.After(last) {
+				continue
+			}
+			if !match(r) {
+				continue
+			}
+			if err := stream.Send(toRecord(r)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func filter(req *pb.LogRequest) (func(dlog.Record) bool, error) {
+	var level logger.Level
+	if len(req.Level) > 0 {
+		lvl, err := logger.GetLevel(req.Level)
+		if err != nil {
+			return nil, err
+		}
+		level = lvl
+	}
+
+	return func(r dlog.Record) bool {
+		if len(req.Level) > 0 {
+			lvl, err := logger.GetLevel(r.Metadata["level"])
+			if err == nil && !level.Enabled(lvl) {
+				return false
+			}
+		}
+		for k, v := range req.Fields {
+			if r.Metadata[k] != v {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+func toRecord(r dlog.Record) *pb.Record {
+	return &pb.Record{
+		Timestamp: r.Timestamp.UnixNano(),
+		Metadata:  r.Metadata,
+		Message:   fmt.Sprint(r.Message),
+	}
+}
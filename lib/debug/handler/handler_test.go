@@ -0,0 +1,143 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	dlog "github.com/lack-io/vine/lib/logger/log"
+	pb "github.com/lack-io/vine/proto/services/debug"
+)
+
+type testStream struct {
+	pb.Debug_LogsStream
+	records chan *pb.Record
+}
+
+func (s *testStream) Send(r *pb.Record) error {
+	s.records <- r
+	return nil
+}
+
+func write(l dlog.Log, level, message string, md map[string]string) {
+	metadata := map[string]string{"level": level}
+	for k, v := range md {
+		metadata[k] = v
+	}
+	l.Write(dlog.Record{Timestamp: time.Now(), Message: message, Metadata: metadata})
+}
+
+func TestLogs(t *testing.T) {
+	l := dlog.NewLog()
+	write(l, "debug", "one", nil)
+	write(l, "error", "two", map[string]string{"trace_id": "t1"})
+	write(l, "error", "three", nil)
+
+	d := NewHandler(Log(l))
+	stream := &testStream{records: make(chan *pb.Record, 10)}
+	req := &pb.LogRequest{Level: "warn", Fields: map[string]string{"trace_id": "t1"}}
+	if err := d.Logs(context.Background(), req, stream); err != nil {
+		t.Fatal(err)
+	}
+	close(stream.records)
+
+	var messages []string
+	for r := range stream.records {
+		messages = append(messages, r.Message)
+	}
+	if len(messages) != 1 || messages[0] != "two" {
+		t.Fatalf("unexpected records %v", messages)
+	}
+}
+
+func TestLogsStream(t *testing.T) {
+	l := dlog.NewLog()
+	write(l, "info", "recent", nil)
+
+	d := NewHandler(Log(l))
+	stream := &testStream{records: make(chan *pb.Record, 10)}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- d.Logs(ctx, &pb.LogRequest{Stream: true}, stream)
+	}()
+
+	expect := func(message string) {
+		select {
+		case r := <-stream.records:
+			if r.Message != message {
+				t.Fatalf("expected %s got %s", message, r.Message)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for %s", message)
+		}
+	}
+
+	expect("recent")
+	time.Sleep(10 * time.Millisecond)
+	write(l, "info", "new", nil)
+	expect("new")
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLogsStreamSameTimestamp(t *testing.T) {
+	l := dlog.NewLog()
+	now := time.Now()
+	l.Write(dlog.Record{Timestamp: now, Message: "recent", Metadata: map[string]string{}})
+
+	d := NewHandler(Log(l))
+	stream := &testStream{records: make(chan *pb.Record, 10)}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- d.Logs(ctx, &pb.LogRequest{Stream: true}, stream)
+	}()
+
+	expect := func(message string) {
+		select {
+		case r := <-stream.records:
+			if r.Message != message {
+				t.Fatalf("expected %s got %s", message, r.Message)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for %s", message)
+		}
+	}
+
+	expect("recent")
+	time.Sleep(10 * time.Millisecond)
+	// the new record at the timestamp of the last one isn't dropped
+	l.Write(dlog.Record{Timestamp: now, Message: "same", Metadata: map[string]string{}})
+	expect("same")
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,122 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"strconv"
+	"time"
+
+	pb "github.com/lack-io/vine/proto/services/debug"
+)
+
+// HTTPHandler returns the http handler of the debug endpoints:
+//
+//	/debug/info      the handlers, options and stats of the node
+//	/debug/logs      the recent log records, ?count=&since=&level=
+//	/debug/pprof/    the pprof profiles
+//
+// The command line of the process isn't served since it may carry secrets.
+// The endpoints aren't authenticated, serve them on localhost or a private
+// interface only.
+func (d *Debug) HTTPHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/info", d.serveInfo)
+	mux.HandleFunc("/debug/logs", d.serveLogs)
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+func (d *Debug) serveInfo(w http.ResponseWriter, r *http.Request) {
+	rsp := &pb.InfoResponse{}
+	if err := d.Info(r.Context(), &pb.InfoRequest{}, rsp); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, rsp)
+}
+
+func (d *Debug) serveLogs(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	req := &pb.LogRequest{Level: q.Get("level")}
+	if v := q.Get("count"); len(v) > 0 {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid count", http.StatusBadRequest)
+			return
+		}
+		req.Count = n
+	}
+	if v := q.Get("since"); len(v) > 0 {
+		since, err := time.ParseDuration(v)
+		if err != nil {
+			http.Error(w, "invalid since", http.StatusBadRequest)
+			return
+		}
+		req.Since = time.Now().Add(-since).Unix()
+	}
+
+	stream := &recordStream{ctx: r.Context()}
+	if err := d.Logs(r.Context(), req, stream); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if stream.records == nil {
+		stream.records = []*pb.Record{}
+	}
+	writeJSON(w, stream.records)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+// recordStream collects the records of a Logs call without streaming
+type recordStream struct {
+	ctx     context.Context
+	records []*pb.Record
+}
+
+func (s *recordStream) Context() context.Context { return s.ctx }
+
+func (s *recordStream) SendMsg(v interface{}) error {
+	return s.Send(v.(*pb.Record))
+}
+
+func (s *recordStream) RecvMsg(interface{}) error { return nil }
+
+func (s *recordStream) Close() error { return nil }
+
+func (s *recordStream) Send(r *pb.Record) error {
+	s.records = append(s.records, r)
+	return nil
+}
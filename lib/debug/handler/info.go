@@ -0,0 +1,207 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/lack-io/vine/core/client/selector"
+	"github.com/lack-io/vine/core/server"
+	pb "github.com/lack-io/vine/proto/services/debug"
+)
+
+var (
+	// Redacted replaces the secret option values
+	Redacted = "[redacted]"
+	// secretKeys are the parts of the option keys holding secrets
+	secretKeys = []string{"secret", "password", "passwd", "token", "credential", "key", "dsn"}
+)
+
+// introspector is implemented by the servers which report their handlers
+type introspector interface {
+	Handlers() []server.Handler
+	Subscribers() []server.Subscriber
+}
+
+// Info returns the handlers, options and stats of the node
+func (d *Debug) Info(ctx context.Context, req *pb.InfoRequest, rsp *pb.InfoResponse) error {
+	opts := d.opts.Server.Options()
+	rsp.Name = opts.Name
+	rsp.Version = opts.Version
+	rsp.Id = opts.Name + "-" + opts.Id
+	rsp.Address = opts.Address
+	if len(opts.Advertise) > 0 {
+		rsp.Address = opts.Advertise
+	}
+
+	if s, ok := d.opts.Server.(introspector); ok {
+		for _, h := range s.Handlers() {
+			rsp.Handlers = append(rsp.Handlers, &pb.Handler{
+				Name:      h.Name(),
+				Internal:  h.Options().Internal,
+				Endpoints: h.Endpoints(),
+			})
+		}
+		for _, sb := range s.Subscribers() {
+			rsp.Subscribers = append(rsp.Subscribers, &pb.Subscriber{
+				Topic:     sb.Topic(),
+				Internal:  sb.Options().Internal,
+				Endpoints: sb.Endpoints(),
+			})
+		}
+	}
+
+	if opts.Registry != nil {
+		services, err := opts.Registry.GetService(opts.Name)
+		if err == nil {
+			for _, s := range services {
+				for _, n := range s.Nodes {
+					if n.Id == rsp.Id {
+						rsp.Registered = true
+					}
+				}
+			}
+		}
+	}
+
+	rsp.Options = d.options()
+	rsp.Stats = d.stats()
+
+	if d.opts.Client != nil {
+		if hr, ok := d.opts.Client.Options().Selector.(selector.HealthReporter); ok {
+			for _, n := range hr.Health("") {
+				rsp.Nodes = append(rsp.Nodes, &pb.NodeStats{
+					Service:   n.Service,
+					Id:        n.Id,
+					Address:   n.Address,
+					Ejected:   n.Ejected,
+					Ejections: int64(n.Ejections),
+					Requests:  int64(n.Requests),
+					Errors:    int64(n.Errors),
+				})
+			}
+		}
+	}
+
+	return nil
+}
+
+// options returns the options of the service, the secrets are redacted
+func (d *Debug) options() map[string]string {
+	out := map[string]string{}
+	set := func(k string, v interface{}) {
+		out[k] = redact(k, fmt.Sprintf("%v", v))
+	}
+
+	opts := d.opts.Server.Options()
+	set("server", d.opts.Server.String())
+	set("server.name", opts.Name)
+	set("server.id", opts.Id)
+	set("server.version", opts.Version)
+	set("server.address", opts.Address)
+	set("server.advertise", opts.Advertise)
+	set("server.register_ttl", opts.RegisterTTL)
+	set("server.register_interval", opts.RegisterInterval)
+	set("server.tls", opts.TLSConfig != nil)
+	for k, v := range opts.Metadata {
+		set("server.metadata."+k, v)
+	}
+
+	if r := opts.Registry; r != nil {
+		ropts := r.Options()
+		set("registry", r.String())
+		set("registry.addrs", strings.Join(ropts.Addrs, ","))
+		set("registry.timeout", ropts.Timeout)
+		set("registry.secure", ropts.Secure)
+	}
+
+	if b := opts.Broker; b != nil {
+		bopts := b.Options()
+		set("broker", b.String())
+		set("broker.addrs", strings.Join(bopts.Addrs, ","))
+		set("broker.secure", bopts.Secure)
+	}
+
+	if c := d.opts.Client; c != nil {
+		copts := c.Options()
+		set("client", c.String())
+		set("client.content_type", copts.ContentType)
+		set("client.pool_size", copts.PoolSize)
+		set("client.pool_ttl", copts.PoolTTL)
+		set("client.retries", copts.CallOptions.Retries)
+		set("client.request_timeout", copts.CallOptions.RequestTimeout)
+		if copts.Selector != nil {
+			set("client.selector", copts.Selector.String())
+		}
+	}
+
+	return out
+}
+
+// redact hides the values of the secret keys and the passwords of urls
+func redact(k, v string) string {
+	lk := strings.ToLower(k)
+	for _, s := range secretKeys {
+		if strings.Contains(lk, s) {
+			return Redacted
+		}
+	}
+
+	parts := strings.Split(v, ",")
+	for i, p := range parts {
+		if !strings.Contains(p, "@") {
+			continue
+		}
+		u, err := url.Parse(p)
+		if err != nil || u.User == nil {
+			// e.g. user:pass@tcp(host) of a dsn
+			parts[i] = Redacted + p[strings.LastIndex(p, "@"):]
+			continue
+		}
+		parts[i] = u.Redacted()
+	}
+	return strings.Join(parts, ",")
+}
+
+func (d *Debug) stats() *pb.Stats {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	threads, _ := runtime.ThreadCreateProfile(nil)
+
+	return &pb.Stats{
+		Started:     d.started.Unix(),
+		Uptime:      int64(time.Since(d.started).Seconds()),
+		Goroutines:  int64(runtime.NumGoroutine()),
+		Threads:     int64(threads),
+		HeapAlloc:   ms.HeapAlloc,
+		HeapSys:     ms.HeapSys,
+		HeapObjects: ms.HeapObjects,
+		Gc:          uint64(ms.NumGC),
+		GcPause:     ms.PauseTotalNs,
+	}
+}
@@ -0,0 +1,76 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package handler
+
+import (
+	"github.com/lack-io/vine/core/client"
+	"github.com/lack-io/vine/core/server"
+	dlog "github.com/lack-io/vine/lib/logger/log"
+)
+
+type Options struct {
+	// Log is read by the Logs endpoint
+	Log dlog.Log
+	// Server is reported by the Info endpoint, the registry
+	// and broker are taken from its options
+	Server server.Server
+	// Client is reported by the Info endpoint with the stats of its selector
+	Client client.Client
+}
+
+type Option func(*Options)
+
+// Log sets the log of records
+func Log(l dlog.Log) Option {
+	return func(o *Options) {
+		o.Log = l
+	}
+}
+
+// Server sets the server to report
+func Server(s server.Server) Option {
+	return func(o *Options) {
+		o.Server = s
+	}
+}
+
+// Client sets the client to report
+func Client(c client.Client) Option {
+	return func(o *Options) {
+		o.Client = c
+	}
+}
+
+func NewOptions(opts ...Option) Options {
+	options := Options{
+		Log:    dlog.DefaultLog,
+		Server: server.DefaultServer,
+		Client: client.DefaultClient,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
@@ -0,0 +1,76 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package handler
+
+import (
+	"bytes"
+	"context"
+	"runtime/pprof"
+	"time"
+
+	"github.com/lack-io/vine/proto/apis/errors"
+	pb "github.com/lack-io/vine/proto/services/debug"
+)
+
+var (
+	// DefaultProfileSeconds is the default duration of the cpu profile
+	DefaultProfileSeconds int64 = 10
+	// MaxProfileSeconds is the longest cpu profile
+	MaxProfileSeconds int64 = 60
+)
+
+// Profile returns the named pprof profile, "cpu" is sampled for the
+// requested seconds
+func (d *Debug) Profile(ctx context.Context, req *pb.ProfileRequest, rsp *pb.ProfileResponse) error {
+	buf := bytes.NewBuffer(nil)
+	if req.Name == "cpu" {
+		seconds := req.Seconds
+		if seconds <= 0 {
+			seconds = DefaultProfileSeconds
+		}
+		if seconds > MaxProfileSeconds {
+			seconds = MaxProfileSeconds
+		}
+
+		if err := pprof.StartCPUProfile(buf); err != nil {
+			return errors.Conflict("go.vine.debug", "cpu profile: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Duration(seconds) * time.Second):
+		}
+		pprof.StopCPUProfile()
+		rsp.Data = buf.Bytes()
+		return nil
+	}
+
+	p := pprof.Lookup(req.Name)
+	if p == nil {
+		return errors.BadRequest("go.vine.debug", "unknown profile %s", req.Name)
+	}
+	if err := p.WriteTo(buf, 0); err != nil {
+		return errors.InternalServerError("go.vine.debug", "%v", err)
+	}
+	rsp.Data = buf.Bytes()
+	return nil
+}
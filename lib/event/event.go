@@ -0,0 +1,210 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package event is a typed event bus of proto messages on top of the client
+// publications and the server subscribers. Every event carries a CloudEvents
+// envelope in the headers of broker.Message, the subscribers dispatch the
+// events of a topic by type and check the schema version of the payload.
+package event
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+)
+
+const (
+	// SpecVersion is the version of CloudEvents spec
+	SpecVersion = "1.0"
+
+	// the headers follow the binary content mode of CloudEvents
+	IdKey            = "ce-id"
+	SourceKey        = "ce-source"
+	TypeKey          = "ce-type"
+	SpecVersionKey   = "ce-specversion"
+	TimeKey          = "ce-time"
+	SubjectKey       = "ce-subject"
+	DataSchemaKey    = "ce-dataschema"
+	SchemaVersionKey = "ce-schemaversion"
+	// DataContentTypeKey carries the content type of the payload, the
+	// subscribers don't see the Content-Type of broker.Message
+	DataContentTypeKey = "ce-datacontenttype"
+)
+
+const headerPrefix = "ce-"
+
+// attributes are the headers of the attributes of Envelope
+var attributes = map[string]bool{
+	IdKey:              true,
+	SourceKey:          true,
+	TypeKey:            true,
+	SpecVersionKey:     true,
+	TimeKey:            true,
+	SubjectKey:         true,
+	DataSchemaKey:      true,
+	DataContentTypeKey: true,
+	SchemaVersionKey:   true,
+}
+
+var (
+	// ErrSchemaVersion is returned when the schema version of the event is
+	// older than the subscriber accepts and there is no upgrade
+	ErrSchemaVersion = errors.New("unsupported schema version")
+)
+
+// Envelope is the CloudEvents attributes of an event
+type Envelope struct {
+	Id          string
+	Source      string
+	Type        string
+	SpecVersion string
+	Time        time.Time
+	Subject     string
+	DataSchema  string
+	// DataContentType is the content type of the payload
+	DataContentType string
+	// SchemaVersion is the version of the payload schema, an extension
+	// attribute of CloudEvents
+	SchemaVersion int
+	// Extensions are the other extension attributes by name
+	Extensions map[string]string
+}
+
+// Header writes the attributes to the header
+func (e *Envelope) Header(h map[string]string) {
+	set := func(k, v string) {
+		if len(v) > 0 {
+			h[k] = v
+		}
+	}
+	set(IdKey, e.Id)
+	set(SourceKey, e.Source)
+	set(TypeKey, e.Type)
+	set(SpecVersionKey, e.SpecVersion)
+	if !e.Time.IsZero() {
+		h[TimeKey] = e.Time.UTC().Format(time.RFC3339Nano)
+	}
+	set(SubjectKey, e.Subject)
+	set(DataSchemaKey, e.DataSchema)
+	set(DataContentTypeKey, e.DataContentType)
+	if e.SchemaVersion > 0 {
+		h[SchemaVersionKey] = strconv.Itoa(e.SchemaVersion)
+	}
+	for k, v := range e.Extensions {
+		set(headerPrefix+strings.ToLower(k), v)
+	}
+}
+
+// FromHeader reads the attributes from the header, it returns false when
+// the header carries no event type
+func FromHeader(h map[string]string) (*Envelope, bool) {
+	get := func(k string) string {
+		if v, ok := h[k]; ok {
+			return v
+		}
+		// the keys may be capitalized by the transport
+		for key, v := range h {
+			if strings.EqualFold(key, k) {
+				return v
+			}
+		}
+		return ""
+	}
+
+	e := &Envelope{
+		Id:          get(IdKey),
+		Source:      get(SourceKey),
+		Type:        get(TypeKey),
+		SpecVersion: get(SpecVersionKey),
+		Subject:     get(SubjectKey),
+		DataSchema:  get(DataSchemaKey),
+
+		DataContentType: get(DataContentTypeKey),
+	}
+	if len(e.Type) == 0 {
+		return nil, false
+	}
+	if t, err := time.Parse(time.RFC3339Nano, get(TimeKey)); err == nil {
+		e.Time = t
+	}
+	if v, err := strconv.Atoi(get(SchemaVersionKey)); err == nil {
+		e.SchemaVersion = v
+	}
+	for k, v := range h {
+		k = strings.ToLower(k)
+		if !strings.HasPrefix(k, headerPrefix) || attributes[k] {
+			continue
+		}
+		if e.Extensions == nil {
+			e.Extensions = map[string]string{}
+		}
+		e.Extensions[strings.TrimPrefix(k, headerPrefix)] = v
+	}
+	return e, true
+}
+
+type envelopeKey struct{}
+
+// FromContext returns the envelope of the event being handled
+func FromContext(ctx context.Context) (*Envelope, bool) {
+	e, ok := ctx.Value(envelopeKey{}).(*Envelope)
+	return e, ok
+}
+
+// NewContext returns the context carries the envelope
+func NewContext(ctx context.Context, e *Envelope) context.Context {
+	return context.WithValue(ctx, envelopeKey{}, e)
+}
+
+// TypeOf returns the event type of the message, it's the full name of the proto message
+func TypeOf(msg proto.Message) string {
+	return proto.MessageName(msg)
+}
+
+var (
+	mu       sync.RWMutex
+	versions = map[string]int{}
+)
+
+// RegisterVersion registers the current schema version of the event type of
+// the message, it's stamped by the publishers and expected by the subscribers.
+func RegisterVersion(msg proto.Message, version int) {
+	mu.Lock()
+	versions[TypeOf(msg)] = version
+	mu.Unlock()
+}
+
+// Version returns the registered schema version of the event type, it's 1
+// when the version isn't registered
+func Version(typ string) int {
+	mu.RLock()
+	defer mu.RUnlock()
+	if v, ok := versions[typ]; ok {
+		return v
+	}
+	return 1
+}
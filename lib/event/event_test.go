@@ -0,0 +1,299 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package event
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+
+	"github.com/lack-io/vine/core/broker/memory"
+	"github.com/lack-io/vine/core/client"
+	cgrpc "github.com/lack-io/vine/core/client/grpc"
+	rmemory "github.com/lack-io/vine/core/registry/memory"
+	"github.com/lack-io/vine/core/server"
+	sgrpc "github.com/lack-io/vine/core/server/grpc"
+	regpb "github.com/lack-io/vine/proto/apis/registry"
+	"github.com/lack-io/vine/util/context/metadata"
+	"github.com/lack-io/vine/util/jsonpb"
+)
+
+type testMessage struct {
+	topic   string
+	payload interface{}
+	ct      string
+}
+
+func (m *testMessage) Topic() string        { return m.topic }
+func (m *testMessage) Payload() interface{} { return m.payload }
+func (m *testMessage) ContentType() string  { return m.ct }
+
+type published struct {
+	header map[string]string
+	data   []byte
+}
+
+// testClient publishes the messages to the subscriber directly
+type testClient struct {
+	client.Client
+	ct  string
+	out []published
+}
+
+func (c *testClient) NewMessage(topic string, msg interface{}, opts ...client.MessageOption) client.Message {
+	return &testMessage{topic: topic, payload: msg, ct: c.ct}
+}
+
+func (c *testClient) Publish(ctx context.Context, msg client.Message, opts ...client.PublishOption) error {
+	md, _ := metadata.FromContext(ctx)
+	var b []byte
+	var err error
+	if c.ct == "application/json" {
+		var s string
+		s, err = (&jsonpb.Marshaler{}).MarshalToString(msg.Payload().(proto.Message))
+		b = []byte(s)
+	} else {
+		b, err = proto.Marshal(msg.Payload().(proto.Message))
+	}
+	if err != nil {
+		return err
+	}
+	c.out = append(c.out, published{header: md, data: b})
+	return nil
+}
+
+func TestEnvelope(t *testing.T) {
+	e := &Envelope{
+		Id:            "1",
+		Source:        "go.vine.test",
+		Type:          "registry.Value",
+		SpecVersion:   SpecVersion,
+		Time:          time.Now(),
+		SchemaVersion: 2,
+		Extensions:    map[string]string{"traceparent": "00-1"},
+	}
+	h := map[string]string{}
+	e.Header(h)
+	if h[IdKey] != "1" || h[SpecVersionKey] != "1.0" || h[SchemaVersionKey] != "2" {
+		t.Fatalf("unexpected header %v", h)
+	}
+	if _, ok := h[SubjectKey]; ok {
+		t.Fatal("empty attribute is written")
+	}
+
+	// capitalized by the transport
+	h = map[string]string{"Ce-Id": "1", "Ce-Type": "registry.Value", "Ce-Time": h[TimeKey], "Ce-Traceparent": "00-1"}
+	e2, ok := FromHeader(h)
+	if !ok {
+		t.Fatal("missing envelope")
+	}
+	if e2.Id != "1" || e2.Type != "registry.Value" || !e2.Time.Equal(e.Time) || e2.Extensions["traceparent"] != "00-1" {
+		t.Fatalf("unexpected envelope %+v", e2)
+	}
+
+	if _, ok := FromHeader(map[string]string{"Content-Type": "application/json"}); ok {
+		t.Fatal("expect no envelope")
+	}
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	for _, ct := range []string{"application/protobuf", "application/json"} {
+		c := &testClient{ct: ct}
+		p := NewPublisher("go.vine.topic", c, Source("go.vine.test"))
+		if _, err := p.Publish(context.TODO(), &regpb.Value{Name: "a"}, WithSubject("s")); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := p.Publish(context.TODO(), &regpb.Node{Id: "n"}); err != nil {
+			t.Fatal(err)
+		}
+
+		var values []*regpb.Value
+		var env *Envelope
+		s := NewSubscriber("go.vine.topic")
+		err := s.Handle(func(ctx context.Context, v *regpb.Value) error {
+			values = append(values, v)
+			env, _ = FromContext(ctx)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if types := s.Types(); len(types) != 1 || types[0] != "registry.Value" {
+			t.Fatalf("unexpected types %v", types)
+		}
+
+		for _, m := range c.out {
+			if err := s.Process(context.TODO(), m.header, m.data); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// the node is filtered
+		if len(values) != 1 || values[0].Name != "a" {
+			t.Fatalf("unexpected values %v", values)
+		}
+		if env.Source != "go.vine.test" || env.Subject != "s" || env.SchemaVersion != 1 || env.DataContentType != ct {
+			t.Fatalf("unexpected envelope %+v", env)
+		}
+	}
+}
+
+func TestPublishInherit(t *testing.T) {
+	c := &testClient{ct: "application/json"}
+	p := NewPublisher("go.vine.topic", c, Source("go.vine.test"))
+
+	// the context of the handler of an inbound event
+	ctx := metadata.NewContext(context.TODO(), metadata.Metadata{
+		"Ce-Id":          "1",
+		"Ce-Subject":     "inbound",
+		"Ce-Traceparent": "00-1",
+		"Authorization":  "token",
+	})
+	env, err := p.Publish(ctx, &regpb.Value{Name: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := c.out[0].header
+	if h[IdKey] != env.Id || len(h[SubjectKey]) > 0 || len(h["ce-traceparent"]) > 0 {
+		t.Fatalf("expect the inbound attributes to be removed, got %v", h)
+	}
+	if h["authorization"] != "token" {
+		t.Fatalf("expect the other metadata to be inherited, got %v", h)
+	}
+}
+
+func TestHandle(t *testing.T) {
+	s := NewSubscriber("go.vine.topic")
+	for _, fn := range []interface{}{
+		func(v *regpb.Value) error { return nil },
+		func(ctx context.Context, v regpb.Value) error { return nil },
+		func(ctx context.Context, v *string) error { return nil },
+		func(ctx context.Context, v *regpb.Value) {},
+	} {
+		if err := s.Handle(fn); err == nil {
+			t.Fatalf("expect invalid handler %T", fn)
+		}
+	}
+}
+
+func TestSchemaVersion(t *testing.T) {
+	c := &testClient{ct: "application/protobuf"}
+	p := NewPublisher("go.vine.topic", c)
+	if _, err := p.Publish(context.TODO(), &regpb.Value{Name: "v1"}, WithSchemaVersion(1)); err != nil {
+		t.Fatal(err)
+	}
+	m := c.out[0]
+
+	// rejected
+	s := NewSubscriber("go.vine.topic")
+	called := false
+	err := s.Handle(func(ctx context.Context, v *regpb.Value) error {
+		called = true
+		return nil
+	}, HandleVersion(2), MinVersion(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Process(context.TODO(), m.header, m.data); !errors.Is(err, ErrSchemaVersion) {
+		t.Fatalf("expect schema version error, got %v", err)
+	}
+	if called {
+		t.Fatal("old payload is handled")
+	}
+
+	// upgraded
+	s = NewSubscriber("go.vine.topic")
+	var name string
+	err = s.Handle(func(ctx context.Context, v *regpb.Value) error {
+		name = v.Name
+		return nil
+	}, HandleVersion(2), MinVersion(2), Upgrade(func(version int, data []byte) ([]byte, error) {
+		v := &regpb.Value{}
+		if err := proto.Unmarshal(data, v); err != nil {
+			return nil, err
+		}
+		v.Name = "v2"
+		return proto.Marshal(v)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Process(context.TODO(), m.header, m.data); err != nil {
+		t.Fatal(err)
+	}
+	if name != "v2" {
+		t.Fatalf("expect upgraded payload, got %s", name)
+	}
+}
+
+func TestServer(t *testing.T) {
+	r := rmemory.NewRegistry()
+	b := memory.NewBroker()
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	srv := sgrpc.NewServer(
+		server.Name("go.vine.test"),
+		server.Address("127.0.0.1:0"),
+		server.Registry(r),
+		server.Broker(b),
+	)
+
+	got := make(chan string, 2)
+	sub := NewSubscriber("go.vine.topic")
+	err := sub.Handle(func(ctx context.Context, v *regpb.Value) error {
+		e, _ := FromContext(ctx)
+		got <- e.Source + ":" + v.Name
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sub.Register(srv); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Stop()
+
+	c := cgrpc.NewClient(client.Registry(r), client.Broker(b))
+	p := NewPublisher("go.vine.topic", c, Source("go.vine.test"))
+	if _, err := p.Publish(context.TODO(), &regpb.Node{Id: "n"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Publish(context.TODO(), &regpb.Value{Name: "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case v := <-got:
+		if v != "go.vine.test:a" {
+			t.Fatalf("unexpected event %s", v)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("event isn't received")
+	}
+}
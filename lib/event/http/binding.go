@@ -0,0 +1,296 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package http implements the HTTP binding of CloudEvents, it decodes the
+// events in binary, structured and batch modes and delivers the broker
+// messages to the HTTP sinks as CloudEvents.
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lack-io/vine/core/broker"
+	"github.com/lack-io/vine/lib/event"
+)
+
+const (
+	// ContentType is the content type of the structured mode
+	ContentType = "application/cloudevents+json"
+	// BatchContentType is the content type of the batch mode
+	BatchContentType = "application/cloudevents-batch+json"
+)
+
+var (
+	// ErrNotEvent is returned when the request carries no CloudEvents
+	ErrNotEvent = errors.New("not cloudevents")
+)
+
+// Event is a CloudEvent with its data
+type Event struct {
+	event.Envelope
+	Data []byte
+}
+
+// getter looks up the header ignoring the case of key
+func getter(header map[string]string) func(string) string {
+	return func(k string) string {
+		if v, ok := header[k]; ok {
+			return v
+		}
+		for key, v := range header {
+			if strings.EqualFold(key, k) {
+				return v
+			}
+		}
+		return ""
+	}
+}
+
+// IsEvent reports whether the http request of the header is CloudEvents
+func IsEvent(header map[string]string) bool {
+	get := getter(header)
+	if len(get(event.SpecVersionKey)) > 0 {
+		return true
+	}
+	ct := mediaType(get("Content-Type"))
+	return ct == ContentType || ct == BatchContentType
+}
+
+func mediaType(ct string) string {
+	if i := strings.Index(ct, ";"); i >= 0 {
+		ct = ct[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(ct))
+}
+
+// Decode decodes the events of the http request, the structured and batch
+// modes are decided by Content-Type, otherwise the binary mode is used
+func Decode(header map[string]string, body []byte) ([]*Event, error) {
+	get := getter(header)
+	switch mediaType(get("Content-Type")) {
+	case ContentType:
+		e, err := decodeStructured(body)
+		if err != nil {
+			return nil, err
+		}
+		return []*Event{e}, nil
+	case BatchContentType:
+		var items []json.RawMessage
+		if err := json.Unmarshal(body, &items); err != nil {
+			return nil, err
+		}
+		events := make([]*Event, 0, len(items))
+		for _, item := range items {
+			e, err := decodeStructured(item)
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, e)
+		}
+		return events, nil
+	}
+
+	if len(get(event.SpecVersionKey)) == 0 {
+		return nil, ErrNotEvent
+	}
+	env, ok := event.FromHeader(header)
+	if !ok {
+		return nil, fmt.Errorf("missing attribute type")
+	}
+	// the content type of the data is Content-Type in binary mode
+	if ct := get("Content-Type"); len(ct) > 0 {
+		env.DataContentType = ct
+	}
+	e := &Event{Envelope: *env, Data: body}
+	return []*Event{e}, e.validate()
+}
+
+func (e *Event) validate() error {
+	if len(e.Id) == 0 || len(e.Source) == 0 || len(e.Type) == 0 || len(e.SpecVersion) == 0 {
+		return fmt.Errorf("missing required attributes of event")
+	}
+	if !strings.HasPrefix(e.SpecVersion, "1.") {
+		return fmt.Errorf("unsupported specversion %s", e.SpecVersion)
+	}
+	return nil
+}
+
+func decodeStructured(b []byte) (*Event, error) {
+	var attrs map[string]json.RawMessage
+	if err := json.Unmarshal(b, &attrs); err != nil {
+		return nil, err
+	}
+
+	header := make(map[string]string, len(attrs))
+	var data, data64 json.RawMessage
+	for k, v := range attrs {
+		switch k {
+		case "data":
+			data = v
+			continue
+		case "data_base64":
+			data64 = v
+			continue
+		}
+		// the extensions may be numbers or booleans
+		var s string
+		if err := json.Unmarshal(v, &s); err != nil {
+			s = string(v)
+		}
+		header["ce-"+strings.ToLower(k)] = s
+	}
+
+	env, ok := event.FromHeader(header)
+	if !ok {
+		return nil, fmt.Errorf("missing attribute type")
+	}
+	e := &Event{Envelope: *env}
+	if len(e.DataContentType) == 0 {
+		e.DataContentType = "application/json"
+	}
+
+	switch {
+	case len(data64) > 0:
+		var s string
+		if err := json.Unmarshal(data64, &s); err != nil {
+			return nil, err
+		}
+		d, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, err
+		}
+		e.Data = d
+	case len(data) > 0 && string(data) != "null":
+		if isJSON(e.DataContentType) {
+			e.Data = data
+			break
+		}
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, fmt.Errorf("data of %s isn't string", e.DataContentType)
+		}
+		e.Data = []byte(s)
+	}
+
+	return e, e.validate()
+}
+
+func isJSON(ct string) bool {
+	ct = mediaType(ct)
+	return ct == "application/json" || ct == "text/json" || strings.HasSuffix(ct, "+json")
+}
+
+// Header returns the http header of the event in binary mode
+func (e *Event) Header() map[string]string {
+	h := map[string]string{}
+	env := e.Envelope
+	env.DataContentType = ""
+	env.Header(h)
+	if len(e.DataContentType) > 0 {
+		h["Content-Type"] = e.DataContentType
+	}
+	return h
+}
+
+// Structured returns the event in structured mode
+func (e *Event) Structured() ([]byte, error) {
+	m := map[string]interface{}{}
+	h := map[string]string{}
+	e.Envelope.Header(h)
+	for k, v := range h {
+		m[strings.TrimPrefix(k, "ce-")] = v
+	}
+	if len(e.Data) > 0 {
+		if isJSON(e.DataContentType) && json.Valid(e.Data) {
+			m["data"] = json.RawMessage(e.Data)
+		} else {
+			m["data_base64"] = base64.StdEncoding.EncodeToString(e.Data)
+		}
+	}
+	return json.Marshal(m)
+}
+
+// Message converts the event to the broker message, the attributes are kept in header
+func (e *Event) Message() *broker.Message {
+	h := map[string]string{}
+	e.Envelope.Header(h)
+	ct := "application/octet-stream"
+	if isJSON(e.DataContentType) {
+		ct = "application/json"
+	}
+	h["Content-Type"] = ct
+	return &broker.Message{Header: h, Body: e.Data}
+}
+
+// FromMessage converts the broker message of topic to the event, the
+// attributes missing in the header are filled in
+func FromMessage(topic, source string, msg *broker.Message) *Event {
+	env, ok := event.FromHeader(msg.Header)
+	if !ok {
+		env = &event.Envelope{Type: topic}
+	}
+	get := getter(msg.Header)
+	if len(env.Id) == 0 {
+		env.Id = messageID(topic, msg)
+	}
+	if len(env.Source) == 0 {
+		env.Source = source
+	}
+	if len(env.SpecVersion) == 0 {
+		env.SpecVersion = event.SpecVersion
+	}
+	if env.Time.IsZero() {
+		env.Time = time.Now()
+	}
+	if len(env.DataContentType) == 0 {
+		env.DataContentType = get("Content-Type")
+	}
+	return &Event{Envelope: *env, Data: msg.Body}
+}
+
+// messageID derives the id of message from its topic, header and body, the
+// redelivered message gets the same id so that the sinks can dedupe it
+func messageID(topic string, msg *broker.Message) string {
+	keys := make([]string, 0, len(msg.Header))
+	for k := range msg.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	h.Write([]byte(topic))
+	for _, k := range keys {
+		h.Write([]byte{0})
+		h.Write([]byte(k + "=" + msg.Header[k]))
+	}
+	h.Write([]byte{0})
+	h.Write(msg.Body)
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
@@ -0,0 +1,269 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package http
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lack-io/vine/core/broker"
+	"github.com/lack-io/vine/core/broker/memory"
+	"github.com/lack-io/vine/lib/event"
+	"github.com/lack-io/vine/util/compress"
+)
+
+func TestDecodeBinary(t *testing.T) {
+	header := map[string]string{
+		"Ce-Id":          "1",
+		"Ce-Source":      "/github",
+		"Ce-Type":        "com.github.push",
+		"Ce-Specversion": "1.0",
+		"Ce-Traceparent": "00-1",
+		"Content-Type":   "application/json",
+	}
+	events, err := Decode(header, []byte(`{"ref":"main"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(events))
+	}
+	e := events[0]
+	if e.Id != "1" || e.Type != "com.github.push" || e.DataContentType != "application/json" {
+		t.Fatalf("unexpected event %+v", e)
+	}
+	if e.Extensions["traceparent"] != "00-1" {
+		t.Fatalf("unexpected extensions %v", e.Extensions)
+	}
+	if string(e.Data) != `{"ref":"main"}` {
+		t.Fatalf("unexpected data %s", e.Data)
+	}
+
+	// missing the required attributes
+	delete(header, "Ce-Source")
+	if _, err := Decode(header, nil); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, err := Decode(map[string]string{}, nil); err != ErrNotEvent {
+		t.Fatalf("expected ErrNotEvent, got %v", err)
+	}
+}
+
+func TestDecodeStructured(t *testing.T) {
+	header := map[string]string{"Content-Type": ContentType + "; charset=utf-8"}
+	if !IsEvent(header) {
+		t.Fatal("expected cloudevents")
+	}
+	body := `{"specversion":"1.0","id":"1","source":"/s","type":"t","schemaversion":2,"data":{"a":1}}`
+	events, err := Decode(header, []byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := events[0]
+	if e.SchemaVersion != 2 || e.DataContentType != "application/json" || string(e.Data) != `{"a":1}` {
+		t.Fatalf("unexpected event %+v", e)
+	}
+
+	body = `{"specversion":"1.0","id":"1","source":"/s","type":"t","datacontenttype":"text/plain","data_base64":"aGVsbG8="}`
+	events, err = Decode(header, []byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(events[0].Data) != "hello" {
+		t.Fatalf("unexpected data %s", events[0].Data)
+	}
+
+	// round trip
+	b, err := events[0].Structured()
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err = Decode(header, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(events[0].Data) != "hello" || events[0].DataContentType != "text/plain" {
+		t.Fatalf("unexpected event %+v", events[0])
+	}
+}
+
+func TestDecodeBatch(t *testing.T) {
+	header := map[string]string{"Content-Type": BatchContentType}
+	body := `[
+		{"specversion":"1.0","id":"1","source":"/s","type":"t","data":"a","datacontenttype":"text/plain"},
+		{"specversion":"1.0","id":"2","source":"/s","type":"t","data":{"b":2}}
+	]`
+	events, err := Decode(header, []byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+	if string(events[0].Data) != "a" || string(events[1].Data) != `{"b":2}` {
+		t.Fatalf("unexpected data %s %s", events[0].Data, events[1].Data)
+	}
+
+	if _, err := Decode(header, []byte(`[{"id":"1"}]`)); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestMessage(t *testing.T) {
+	e := &Event{
+		Envelope: event.Envelope{Id: "1", Source: "/s", Type: "t", SpecVersion: "1.0", DataContentType: "application/json"},
+		Data:     []byte(`{}`),
+	}
+	msg := e.Message()
+	if msg.Header[event.IdKey] != "1" || msg.Header["Content-Type"] != "application/json" {
+		t.Fatalf("unexpected header %v", msg.Header)
+	}
+
+	e2 := FromMessage("go.vine.topic", "/vine", &broker.Message{Header: map[string]string{}, Body: []byte("x")})
+	if e2.Type != "go.vine.topic" || e2.Source != "/vine" || len(e2.Id) == 0 || e2.SpecVersion != event.SpecVersion {
+		t.Fatalf("unexpected event %+v", e2)
+	}
+	// the redelivered message has the same id
+	e4 := FromMessage("go.vine.topic", "/vine", &broker.Message{Header: map[string]string{}, Body: []byte("x")})
+	if e4.Id != e2.Id {
+		t.Fatalf("expect the same id, got %s and %s", e2.Id, e4.Id)
+	}
+	e3 := FromMessage("go.vine.topic", "/vine", msg)
+	if e3.Id != "1" || e3.Source != "/s" {
+		t.Fatalf("unexpected event %+v", e3)
+	}
+}
+
+func TestSink(t *testing.T) {
+	var calls int32
+	received := make(chan *http.Request, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		r.Body = ioutil.NopCloser(strings.NewReader(string(b)))
+		received <- r
+	}))
+	defer srv.Close()
+
+	e := &Event{
+		Envelope: event.Envelope{Id: "1", Source: "/s", Type: "t", SpecVersion: "1.0", DataContentType: "text/plain"},
+		Data:     []byte("hello"),
+	}
+
+	s := NewSink(srv.URL, Header("Authorization", "token"))
+	if err := s.Deliver(context.Background(), e); err != nil {
+		t.Fatal(err)
+	}
+	r := <-received
+	if r.Header.Get("Ce-Id") != "1" || r.Header.Get("Content-Type") != "text/plain" || r.Header.Get("Authorization") != "token" {
+		t.Fatalf("unexpected header %v", r.Header)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Fatalf("expected 2 calls, got %d", n)
+	}
+
+	s = NewSink(srv.URL, Structured())
+	if err := s.Deliver(context.Background(), e); err != nil {
+		t.Fatal(err)
+	}
+	r = <-received
+	b, _ := ioutil.ReadAll(r.Body)
+	events, err := Decode(map[string]string{"Content-Type": r.Header.Get("Content-Type")}, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(events[0].Data) != "hello" {
+		t.Fatalf("unexpected data %s", events[0].Data)
+	}
+}
+
+func TestSinkError(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	e := &Event{Envelope: event.Envelope{Id: "1", Source: "/s", Type: "t", SpecVersion: "1.0"}}
+	s := NewSink(srv.URL, Retries(2), Timeout(time.Second))
+	if err := s.Deliver(context.Background(), e); err == nil {
+		t.Fatal("expected error")
+	}
+	// 400 isn't retried
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("expected 1 call, got %d", n)
+	}
+}
+
+func TestSinkSubscribe(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		r.Body = ioutil.NopCloser(strings.NewReader(string(b)))
+		received <- r
+	}))
+	defer srv.Close()
+
+	b := memory.NewBroker()
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Disconnect()
+
+	sub, err := NewSink(srv.URL).Subscribe(b, "go.vine.topic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	msg, err := broker.CompressMessage(&broker.Message{
+		Header: map[string]string{"Content-Type": "text/plain"},
+		Body:   []byte("hello"),
+	}, compress.Gzip, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Publish("go.vine.topic", msg); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case r := <-received:
+		body, _ := ioutil.ReadAll(r.Body)
+		if string(body) != "hello" || len(r.Header.Get(broker.CompressHeader)) > 0 {
+			t.Fatalf("expect the decompressed message, got %q %v", body, r.Header)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expect the message to be delivered")
+	}
+}
@@ -0,0 +1,100 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package http
+
+import (
+	"net/http"
+	"time"
+)
+
+type SinkOptions struct {
+	// Structured delivers the events in structured mode instead of binary mode
+	Structured bool
+	// Retries is the number of retries of a failed delivery
+	Retries int
+	// Timeout of a single delivery
+	Timeout time.Duration
+	// Source of the events which carry no ce-source
+	Source string
+	// Header is added to every request
+	Header map[string]string
+	Client *http.Client
+}
+
+type SinkOption func(*SinkOptions)
+
+func newSinkOptions(opts ...SinkOption) SinkOptions {
+	options := SinkOptions{
+		Retries: 3,
+		Timeout: time.Second * 10,
+		Source:  "go.vine.api",
+		Header:  map[string]string{},
+		Client:  http.DefaultClient,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	return options
+}
+
+// Structured delivers the events as application/cloudevents+json
+func Structured() SinkOption {
+	return func(o *SinkOptions) {
+		o.Structured = true
+	}
+}
+
+// Retries sets the number of retries of a failed delivery
+func Retries(n int) SinkOption {
+	return func(o *SinkOptions) {
+		o.Retries = n
+	}
+}
+
+// Timeout sets the timeout of a single delivery
+func Timeout(t time.Duration) SinkOption {
+	return func(o *SinkOptions) {
+		o.Timeout = t
+	}
+}
+
+// Source sets the source of the events which carry no ce-source
+func Source(s string) SinkOption {
+	return func(o *SinkOptions) {
+		o.Source = s
+	}
+}
+
+// Header adds the header to every request of the sink
+func Header(k, v string) SinkOption {
+	return func(o *SinkOptions) {
+		o.Header[k] = v
+	}
+}
+
+// WithClient sets the http client of the sink
+func WithClient(c *http.Client) SinkOption {
+	return func(o *SinkOptions) {
+		o.Client = c
+	}
+}
@@ -0,0 +1,141 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/lack-io/vine/core/broker"
+	log "github.com/lack-io/vine/lib/logger"
+	"github.com/lack-io/vine/util/backoff"
+)
+
+// Sink delivers the events to the http endpoint
+type Sink struct {
+	url  string
+	opts SinkOptions
+}
+
+// NewSink returns a sink which POSTs the events to url
+func NewSink(url string, opts ...SinkOption) *Sink {
+	return &Sink{url: url, opts: newSinkOptions(opts...)}
+}
+
+func (s *Sink) Options() SinkOptions {
+	return s.opts
+}
+
+func (s *Sink) String() string {
+	return s.url
+}
+
+// Deliver POSTs the event to the sink, the delivery is retried on the
+// transport errors, 408, 429 and 5xx responses
+func (s *Sink) Deliver(ctx context.Context, e *Event) error {
+	var err error
+	for i := 0; i <= s.opts.Retries; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff.Do(i)):
+			}
+		}
+		var retry bool
+		if retry, err = s.deliver(ctx, e); err == nil || !retry {
+			return err
+		}
+	}
+	return err
+}
+
+func (s *Sink) deliver(ctx context.Context, e *Event) (bool, error) {
+	var body []byte
+	header := map[string]string{}
+	if s.opts.Structured {
+		b, err := e.Structured()
+		if err != nil {
+			return false, err
+		}
+		body = b
+		header["Content-Type"] = ContentType
+	} else {
+		body = e.Data
+		header = e.Header()
+	}
+
+	if s.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.opts.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req = req.WithContext(ctx)
+	for k, v := range s.opts.Header {
+		req.Header.Set(k, v)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+
+	rsp, err := s.opts.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer rsp.Body.Close()
+	io.Copy(ioutil.Discard, rsp.Body)
+
+	code := rsp.StatusCode
+	if code >= 200 && code < 300 {
+		return false, nil
+	}
+	retry := code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= 500
+	return retry, fmt.Errorf("sink %s responded %s", s.url, rsp.Status)
+}
+
+// Subscribe delivers the messages of topic to the sink, the compressed
+// messages are decompressed and the message isn't acked when the delivery fails
+func (s *Sink) Subscribe(b broker.Broker, topic string, opts ...broker.SubscribeOption) (broker.Subscriber, error) {
+	return b.Subscribe(topic, func(p broker.Event) error {
+		msg := p.Message()
+		if err := broker.DecompressMessage(msg); err != nil {
+			log.Errorf("[event]: decompress %s: %v", p.Topic(), err)
+			return err
+		}
+		e := FromMessage(p.Topic(), s.opts.Source, msg)
+		if err := s.Deliver(context.Background(), e); err != nil {
+			log.Errorf("[event]: deliver %s to %s: %v", p.Topic(), s, err)
+			return err
+		}
+		return nil
+	}, opts...)
+}
@@ -0,0 +1,126 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package event
+
+import (
+	"github.com/lack-io/vine/core/client"
+)
+
+type PublisherOptions struct {
+	// Source is the source of the events, e.g. the name of the service
+	Source string
+}
+
+type PublisherOption func(*PublisherOptions)
+
+// Source sets the source of the events
+func Source(s string) PublisherOption {
+	return func(o *PublisherOptions) {
+		o.Source = s
+	}
+}
+
+type PublishOptions struct {
+	// Id of the event, a new one is generated when it's empty
+	Id         string
+	Subject    string
+	DataSchema string
+	// SchemaVersion overrides the registered version of the event type
+	SchemaVersion int
+	// ClientOptions are passed to client.Publish
+	ClientOptions []client.PublishOption
+}
+
+type PublishOption func(*PublishOptions)
+
+// WithId sets the id of the event, it's used by the consumers to dedupe
+func WithId(id string) PublishOption {
+	return func(o *PublishOptions) {
+		o.Id = id
+	}
+}
+
+// WithSubject sets the subject of the event
+func WithSubject(s string) PublishOption {
+	return func(o *PublishOptions) {
+		o.Subject = s
+	}
+}
+
+// WithDataSchema sets the uri of the payload schema
+func WithDataSchema(uri string) PublishOption {
+	return func(o *PublishOptions) {
+		o.DataSchema = uri
+	}
+}
+
+// WithSchemaVersion sets the schema version of the payload
+func WithSchemaVersion(v int) PublishOption {
+	return func(o *PublishOptions) {
+		o.SchemaVersion = v
+	}
+}
+
+// WithClientOptions sets the options of client.Publish
+func WithClientOptions(opts ...client.PublishOption) PublishOption {
+	return func(o *PublishOptions) {
+		o.ClientOptions = append(o.ClientOptions, opts...)
+	}
+}
+
+// UpgradeFunc upgrades the payload from the old schema version to the current one
+type UpgradeFunc func(version int, data []byte) ([]byte, error)
+
+type HandlerOptions struct {
+	// Version is the schema version the handler expects, defaults to the
+	// registered version of the event type
+	Version int
+	// MinVersion is the oldest schema version accepted without upgrade
+	MinVersion int
+	// Upgrade upgrades the payloads older than Version, the payloads older
+	// than MinVersion are rejected when it's nil
+	Upgrade UpgradeFunc
+}
+
+type HandlerOption func(*HandlerOptions)
+
+// HandleVersion sets the schema version the handler expects
+func HandleVersion(v int) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.Version = v
+	}
+}
+
+// MinVersion sets the oldest schema version accepted without upgrade
+func MinVersion(v int) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.MinVersion = v
+	}
+}
+
+// Upgrade sets the function upgrades the old payloads
+func Upgrade(fn UpgradeFunc) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.Upgrade = fn
+	}
+}
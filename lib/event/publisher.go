@@ -0,0 +1,106 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package event
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/google/uuid"
+
+	"github.com/lack-io/vine/core/client"
+	"github.com/lack-io/vine/util/context/metadata"
+)
+
+// Publisher publishes the proto messages to a topic with the envelope
+type Publisher struct {
+	topic string
+	c     client.Client
+	opts  PublisherOptions
+}
+
+// NewPublisher creates a publisher of the topic
+func NewPublisher(topic string, c client.Client, opts ...PublisherOption) *Publisher {
+	var options PublisherOptions
+	for _, o := range opts {
+		o(&options)
+	}
+	if c == nil {
+		c = client.DefaultClient
+	}
+	return &Publisher{topic: topic, c: c, opts: options}
+}
+
+// Topic returns the topic of publisher
+func (p *Publisher) Topic() string {
+	return p.topic
+}
+
+// Publish publishes the message, the type of the event is the full name of the message
+func (p *Publisher) Publish(ctx context.Context, msg proto.Message, opts ...PublishOption) (*Envelope, error) {
+	var options PublishOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	e := &Envelope{
+		Id:            options.Id,
+		Source:        p.opts.Source,
+		Type:          TypeOf(msg),
+		SpecVersion:   SpecVersion,
+		Time:          time.Now(),
+		Subject:       options.Subject,
+		DataSchema:    options.DataSchema,
+		SchemaVersion: options.SchemaVersion,
+	}
+	if len(e.Id) == 0 {
+		e.Id = uuid.New().String()
+	}
+	if e.SchemaVersion == 0 {
+		e.SchemaVersion = Version(e.Type)
+	}
+
+	m := p.c.NewMessage(p.topic, msg)
+	e.DataContentType = m.ContentType()
+
+	// the attributes of the inbound event aren't inherited, or the
+	// extensions of it would be published with the new event
+	md, ok := metadata.FromContext(ctx)
+	if !ok {
+		md = metadata.Metadata{}
+	}
+	for k := range md {
+		if strings.HasPrefix(k, headerPrefix) {
+			delete(md, k)
+		}
+	}
+	e.Header(md)
+	ctx = metadata.NewContext(ctx, md)
+
+	if err := p.c.Publish(ctx, m, options.ClientOptions...); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
@@ -0,0 +1,207 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package event
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gogo/protobuf/proto"
+
+	vbytes "github.com/lack-io/vine/core/codec/bytes"
+	"github.com/lack-io/vine/core/server"
+	"github.com/lack-io/vine/util/context/metadata"
+	"github.com/lack-io/vine/util/jsonpb"
+)
+
+var (
+	typeOfContext = reflect.TypeOf((*context.Context)(nil)).Elem()
+	typeOfError   = reflect.TypeOf((*error)(nil)).Elem()
+	typeOfMessage = reflect.TypeOf((*proto.Message)(nil)).Elem()
+)
+
+type handler struct {
+	typ  string
+	msg  reflect.Type
+	fn   reflect.Value
+	opts HandlerOptions
+}
+
+// Subscriber dispatches the events of a topic to the handlers by type, the
+// events without handler are skipped.
+type Subscriber struct {
+	sync.RWMutex
+	topic    string
+	handlers map[string][]*handler
+}
+
+// NewSubscriber creates a subscriber of the topic
+func NewSubscriber(topic string) *Subscriber {
+	return &Subscriber{
+		topic:    topic,
+		handlers: map[string][]*handler{},
+	}
+}
+
+// Topic returns the topic of subscriber
+func (s *Subscriber) Topic() string {
+	return s.topic
+}
+
+// Handle registers the handler of the event type, fn is a function of
+// signature func(context.Context, *T) error where *T is a proto message. The
+// envelope of the event is returned by FromContext.
+func (s *Subscriber) Handle(fn interface{}, opts ...HandlerOption) error {
+	v := reflect.ValueOf(fn)
+	t := v.Type()
+	if t.Kind() != reflect.Func || t.NumIn() != 2 || t.NumOut() != 1 {
+		return fmt.Errorf("handler %v requires signature func(context.Context, proto.Message) error", t)
+	}
+	if t.In(0) != typeOfContext {
+		return fmt.Errorf("handler %v takes %v not context.Context", t, t.In(0))
+	}
+	if t.In(1).Kind() != reflect.Ptr || !t.In(1).Implements(typeOfMessage) {
+		return fmt.Errorf("handler %v takes %v not proto message", t, t.In(1))
+	}
+	if t.Out(0) != typeOfError {
+		return fmt.Errorf("handler %v returns %v not error", t, t.Out(0))
+	}
+
+	h := &handler{
+		typ: TypeOf(reflect.New(t.In(1).Elem()).Interface().(proto.Message)),
+		msg: t.In(1).Elem(),
+		fn:  v,
+	}
+	if len(h.typ) == 0 {
+		return fmt.Errorf("message %v isn't registered", t.In(1))
+	}
+	for _, o := range opts {
+		o(&h.opts)
+	}
+
+	s.Lock()
+	s.handlers[h.typ] = append(s.handlers[h.typ], h)
+	s.Unlock()
+	return nil
+}
+
+// Types returns the event types handled by the subscriber
+func (s *Subscriber) Types() []string {
+	s.RLock()
+	defer s.RUnlock()
+	types := make([]string, 0, len(s.handlers))
+	for typ := range s.handlers {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Register subscribes the topic on the server
+func (s *Subscriber) Register(srv server.Server, opts ...server.SubscriberOption) error {
+	return srv.Subscribe(srv.NewSubscriber(s.topic, s.handle, opts...))
+}
+
+func (s *Subscriber) handle(ctx context.Context, f *vbytes.Frame) error {
+	md, _ := metadata.FromContext(ctx)
+	return s.Process(ctx, md, f.Data)
+}
+
+// Process dispatches the event of the header and the payload
+func (s *Subscriber) Process(ctx context.Context, header map[string]string, data []byte) error {
+	e, ok := FromHeader(header)
+	if !ok {
+		return nil
+	}
+
+	s.RLock()
+	handlers := s.handlers[e.Type]
+	s.RUnlock()
+
+	ctx = NewContext(ctx, e)
+	var errs []error
+	for _, h := range handlers {
+		if err := h.call(ctx, e, data); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("event %s of %s: %w", e.Id, e.Type, errs[0])
+	default:
+		msgs := make([]string, len(errs))
+		for i, err := range errs {
+			msgs[i] = err.Error()
+		}
+		return fmt.Errorf("event %s of %s: %s", e.Id, e.Type, strings.Join(msgs, "\n"))
+	}
+}
+
+func (h *handler) call(ctx context.Context, e *Envelope, data []byte) error {
+	version := e.SchemaVersion
+	if version == 0 {
+		version = 1
+	}
+	expect := h.opts.Version
+	if expect == 0 {
+		expect = Version(h.typ)
+	}
+
+	if version < expect && h.opts.Upgrade != nil {
+		b, err := h.opts.Upgrade(version, data)
+		if err != nil {
+			return fmt.Errorf("upgrade from version %d: %v", version, err)
+		}
+		data = b
+	} else if version < h.opts.MinVersion {
+		return fmt.Errorf("%w %d, requires %d at least", ErrSchemaVersion, version, h.opts.MinVersion)
+	}
+
+	msg := reflect.New(h.msg)
+	if err := unmarshal(e.DataContentType, data, msg.Interface().(proto.Message)); err != nil {
+		return err
+	}
+
+	out := h.fn.Call([]reflect.Value{reflect.ValueOf(ctx), msg})
+	if err := out[0].Interface(); err != nil {
+		return err.(error)
+	}
+	return nil
+}
+
+func unmarshal(ct string, data []byte, msg proto.Message) error {
+	if strings.Contains(ct, "json") {
+		if len(data) == 0 {
+			return nil
+		}
+		return jsonpb.Unmarshal(bytes.NewReader(data), msg)
+	}
+	return proto.Unmarshal(data, msg)
+}
@@ -0,0 +1,154 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package job
+
+import (
+	"context"
+
+	"github.com/lack-io/vine/proto/apis/errors"
+	pb "github.com/lack-io/vine/proto/services/job"
+)
+
+// Handler manages the jobs over RPC, it's registered by pb.RegisterJobHandler
+type Handler struct {
+	s *Scheduler
+}
+
+// NewHandler returns the job handler of scheduler
+func NewHandler(s *Scheduler) *Handler {
+	return &Handler{s: s}
+}
+
+func (h *Handler) List(ctx context.Context, req *pb.ListRequest, rsp *pb.ListResponse) error {
+	jobs, err := h.s.List()
+	if err != nil {
+		return errors.InternalServerError("go.vine.job", "%v", err)
+	}
+	rsp.Jobs = make([]*pb.Definition, 0, len(jobs))
+	for _, j := range jobs {
+		st, err := h.s.State(j.Id)
+		if err != nil {
+			return errors.InternalServerError("go.vine.job", "%v", err)
+		}
+		rsp.Jobs = append(rsp.Jobs, toDefinition(j, st))
+	}
+	return nil
+}
+
+func (h *Handler) Add(ctx context.Context, req *pb.AddRequest, rsp *pb.AddResponse) error {
+	if req.Job == nil {
+		return errors.BadRequest("go.vine.job", "missing job")
+	}
+	j := &Job{
+		Id:      req.Job.Id,
+		Name:    req.Job.Name,
+		Handler: req.Job.Handler,
+		Spec:    req.Job.Spec,
+		Args:    req.Job.Args,
+		Paused:  req.Job.Paused,
+	}
+	if err := h.s.Add(j); err != nil {
+		return errors.BadRequest("go.vine.job", "%v", err)
+	}
+	rsp.Job = toDefinition(j, &State{})
+	return nil
+}
+
+func (h *Handler) Remove(ctx context.Context, req *pb.RemoveRequest, rsp *pb.RemoveResponse) error {
+	return fromErr(req.Id, h.s.Remove(req.Id))
+}
+
+func (h *Handler) Pause(ctx context.Context, req *pb.PauseRequest, rsp *pb.PauseResponse) error {
+	return fromErr(req.Id, h.s.Pause(req.Id))
+}
+
+func (h *Handler) Resume(ctx context.Context, req *pb.ResumeRequest, rsp *pb.ResumeResponse) error {
+	return fromErr(req.Id, h.s.Resume(req.Id))
+}
+
+func (h *Handler) Trigger(ctx context.Context, req *pb.TriggerRequest, rsp *pb.TriggerResponse) error {
+	r, err := h.s.Trigger(ctx, req.Id)
+	if r != nil {
+		// the failure of the job is reported in the run
+		rsp.Run = toRun(r)
+		return nil
+	}
+	if err == ErrRunning {
+		return errors.Conflict("go.vine.job", "job %s is running", req.Id)
+	}
+	return fromErr(req.Id, err)
+}
+
+func (h *Handler) History(ctx context.Context, req *pb.HistoryRequest, rsp *pb.HistoryResponse) error {
+	if _, err := h.s.Get(req.Id); err != nil {
+		return fromErr(req.Id, err)
+	}
+	runs, err := h.s.History(req.Id, int(req.Limit))
+	if err != nil {
+		return errors.InternalServerError("go.vine.job", "%v", err)
+	}
+	rsp.Runs = make([]*pb.Run, 0, len(runs))
+	for _, r := range runs {
+		rsp.Runs = append(rsp.Runs, toRun(r))
+	}
+	return nil
+}
+
+func fromErr(id string, err error) error {
+	switch err {
+	case nil:
+		return nil
+	case ErrNotFound:
+		return errors.NotFound("go.vine.job", "job %s not found", id)
+	default:
+		return errors.InternalServerError("go.vine.job", "%v", err)
+	}
+}
+
+func toDefinition(j *Job, st *State) *pb.Definition {
+	return &pb.Definition{
+		Id:        j.Id,
+		Name:      j.Name,
+		Handler:   j.Handler,
+		Spec:      j.Spec,
+		Args:      j.Args,
+		Paused:    j.Paused,
+		Created:   j.Created,
+		Updated:   j.Updated,
+		LastRun:   st.LastRun,
+		LastError: st.LastError,
+		Runs:      st.Runs,
+		Failures:  st.Failures,
+	}
+}
+
+func toRun(r *Run) *pb.Run {
+	return &pb.Run{
+		Job:      r.Job,
+		Node:     r.Node,
+		Started:  r.Started,
+		Finished: r.Finished,
+		Error:    r.Error,
+		Trigger:  r.Trigger,
+	}
+}
@@ -0,0 +1,619 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package job schedules the jobs across the nodes of a service. The jobs are
+// persisted in the store and fired by gscheduler, the nodes elect a leader by
+// sync so that every firing runs on exactly one node.
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	gosync "sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lack-io/gscheduler"
+	"github.com/lack-io/gscheduler/cron"
+
+	log "github.com/lack-io/vine/lib/logger"
+	"github.com/lack-io/vine/lib/store"
+	"github.com/lack-io/vine/lib/sync"
+)
+
+const (
+	defPrefix   = "def/"
+	statePrefix = "state/"
+	runPrefix   = "run/"
+	lockPrefix  = "job/"
+)
+
+var (
+	// ErrNotFound is returned when the job doesn't exist
+	ErrNotFound = errors.New("job not found")
+	// ErrRunning is returned when the job is running
+	ErrRunning = errors.New("job is running")
+)
+
+// Func is the function of job, args are the arguments of the job definition
+type Func func(ctx context.Context, args []byte) error
+
+// Job is the definition of a job
+type Job struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	// Handler is the name of the function registered by Handle
+	Handler string `json:"handler"`
+	// Spec is the cron spec, e.g. "0 */5 * * * *", "@every 1m"
+	Spec    string `json:"spec"`
+	Args    []byte `json:"args,omitempty"`
+	Paused  bool   `json:"paused"`
+	Created int64  `json:"created"`
+	Updated int64  `json:"updated"`
+}
+
+// State is the last run state of a job
+type State struct {
+	LastRun   int64  `json:"last_run"`
+	LastError string `json:"last_error,omitempty"`
+	Runs      int64  `json:"runs"`
+	Failures  int64  `json:"failures"`
+}
+
+// Run is a run of a job
+type Run struct {
+	Job      string `json:"job"`
+	Node     string `json:"node"`
+	Started  int64  `json:"started"`
+	Finished int64  `json:"finished"`
+	Error    string `json:"error,omitempty"`
+	// Trigger is true when the run is triggered manually
+	Trigger bool `json:"trigger"`
+}
+
+type entry struct {
+	job *Job
+	gj  *gscheduler.Job
+}
+
+// Scheduler schedules the persisted jobs
+type Scheduler struct {
+	gosync.RWMutex
+	opts Options
+
+	handlers map[string]Func
+	entries  map[string]*entry
+	inflight map[string]struct{}
+
+	// own is true when the gscheduler is started by the scheduler
+	own     bool
+	leading int32
+	running bool
+	exit    chan struct{}
+	wg      gosync.WaitGroup
+	runs    gosync.WaitGroup
+}
+
+// NewScheduler creates a job scheduler
+func NewScheduler(opts ...Option) *Scheduler {
+	return &Scheduler{
+		opts:     NewOptions(opts...),
+		handlers: map[string]Func{},
+		entries:  map[string]*entry{},
+		inflight: map[string]struct{}{},
+	}
+}
+
+// Init initialises the options, it must be called before Start
+func (s *Scheduler) Init(opts ...Option) error {
+	s.Lock()
+	defer s.Unlock()
+	for _, o := range opts {
+		o(&s.opts)
+	}
+	return nil
+}
+
+// Options returns the options of scheduler
+func (s *Scheduler) Options() Options {
+	s.RLock()
+	defer s.RUnlock()
+	return s.opts
+}
+
+// Handle registers the function of the jobs with the handler name
+func (s *Scheduler) Handle(name string, fn Func) {
+	s.Lock()
+	s.handlers[name] = fn
+	s.Unlock()
+}
+
+// Leader reports whether the node runs the jobs
+func (s *Scheduler) Leader() bool {
+	return atomic.LoadInt32(&s.leading) == 1
+}
+
+// Start loads the jobs from the store and starts firing them
+func (s *Scheduler) Start() error {
+	s.Lock()
+	if s.running {
+		s.Unlock()
+		return nil
+	}
+	if s.opts.Scheduler == nil {
+		gs := gscheduler.NewScheduler()
+		gs.Start()
+		s.opts.Scheduler = gs
+		s.own = true
+	}
+	s.running = true
+	s.exit = make(chan struct{})
+	exit := s.exit
+	s.Unlock()
+
+	if err := s.refresh(); err != nil {
+		log.Errorf("[job]: failed to load jobs: %v", err)
+	}
+
+	if s.opts.Sync == nil {
+		atomic.StoreInt32(&s.leading, 1)
+	} else {
+		// not waited by Stop, Leader blocks until the node is elected
+		go s.elect(exit)
+	}
+
+	s.wg.Add(1)
+	go s.watch(exit)
+
+	return nil
+}
+
+// Stop stops firing the jobs and waits for the running ones, it must be
+// called before the gscheduler of options stops
+func (s *Scheduler) Stop() error {
+	s.Lock()
+	if !s.running {
+		s.Unlock()
+		return nil
+	}
+	s.running = false
+	close(s.exit)
+	for id, e := range s.entries {
+		if !s.own {
+			_ = s.opts.Scheduler.RemoveJob(e.gj)
+		}
+		delete(s.entries, id)
+	}
+	if s.own {
+		s.opts.Scheduler.Stop()
+		s.opts.Scheduler = nil
+		s.own = false
+	}
+	s.Unlock()
+
+	atomic.StoreInt32(&s.leading, 0)
+	s.wg.Wait()
+	s.runs.Wait()
+	return nil
+}
+
+func (s *Scheduler) elect(exit chan struct{}) {
+	id := lockPrefix + s.opts.Name
+	for {
+		leader, err := s.opts.Sync.Leader(id)
+		select {
+		case <-exit:
+			if err == nil {
+				_ = leader.Resign()
+			}
+			return
+		default:
+		}
+		if err != nil {
+			log.Errorf("[job]: failed to elect leader of %s: %v", id, err)
+			select {
+			case <-exit:
+				return
+			case <-time.After(s.opts.RefreshInterval):
+			}
+			continue
+		}
+
+		log.Infof("[job]: node %s elected to run the jobs of %s", s.opts.Node, s.opts.Name)
+		atomic.StoreInt32(&s.leading, 1)
+		select {
+		case <-leader.Status():
+			atomic.StoreInt32(&s.leading, 0)
+			log.Infof("[job]: node %s lost the leadership of %s", s.opts.Node, s.opts.Name)
+		case <-exit:
+			atomic.StoreInt32(&s.leading, 0)
+			_ = leader.Resign()
+			return
+		}
+	}
+}
+
+func (s *Scheduler) watch(exit chan struct{}) {
+	defer s.wg.Done()
+
+	t := time.NewTicker(s.opts.RefreshInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-exit:
+			return
+		case <-t.C:
+			if err := s.refresh(); err != nil {
+				log.Errorf("[job]: failed to load jobs: %v", err)
+			}
+		}
+	}
+}
+
+// refresh syncs the gscheduler with the jobs in the store
+func (s *Scheduler) refresh() error {
+	jobs, err := s.List()
+	if err != nil {
+		return err
+	}
+	defs := make(map[string]*Job, len(jobs))
+	for _, j := range jobs {
+		defs[j.Id] = j
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	if !s.running {
+		return nil
+	}
+
+	for id, e := range s.entries {
+		j, ok := defs[id]
+		if ok && !j.Paused && j.Spec == e.job.Spec {
+			e.job = j
+			continue
+		}
+		if err := s.opts.Scheduler.RemoveJob(e.gj); err != nil {
+			return err
+		}
+		delete(s.entries, id)
+	}
+
+	for id, j := range defs {
+		if _, ok := s.entries[id]; ok || j.Paused {
+			continue
+		}
+		spec, err := cron.Parse(j.Spec)
+		if err != nil {
+			log.Errorf("[job]: invalid spec '%s' of %s: %v", j.Spec, id, err)
+			continue
+		}
+		id := id
+		gj := gscheduler.JobBuilder().Name(j.Name).Spec(spec).Fn(func() { s.fire(id) }).Out()
+		if err := s.opts.Scheduler.AddJob(gj); err != nil {
+			return err
+		}
+		s.entries[id] = &entry{job: j, gj: gj}
+	}
+
+	return nil
+}
+
+func (s *Scheduler) fire(id string) {
+	s.RLock()
+	running := s.running
+	if running {
+		s.runs.Add(1)
+	}
+	s.RUnlock()
+	if !running {
+		return
+	}
+	defer s.runs.Done()
+
+	if !s.Leader() {
+		return
+	}
+	if _, err := s.run(context.Background(), id, false); err != nil && err != ErrRunning {
+		log.Errorf("[job]: failed to run %s: %v", id, err)
+	}
+}
+
+// Trigger runs the job on the node immediately, the paused job is run as well
+func (s *Scheduler) Trigger(ctx context.Context, id string) (*Run, error) {
+	return s.run(ctx, id, true)
+}
+
+func (s *Scheduler) run(ctx context.Context, id string, trigger bool) (*Run, error) {
+	j, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if j.Paused && !trigger {
+		return nil, nil
+	}
+
+	s.Lock()
+	fn, ok := s.handlers[j.Handler]
+	if !ok {
+		s.Unlock()
+		return nil, fmt.Errorf("handler %s of job %s not found", j.Handler, id)
+	}
+	if _, ok := s.inflight[id]; ok {
+		s.Unlock()
+		return nil, ErrRunning
+	}
+	s.inflight[id] = struct{}{}
+	s.Unlock()
+
+	defer func() {
+		s.Lock()
+		delete(s.inflight, id)
+		s.Unlock()
+	}()
+
+	// guards the job across the nodes, the leadership may change while
+	// the job is running
+	if s.opts.Sync != nil {
+		lock := lockPrefix + s.opts.Name + "/" + id
+		if err := s.opts.Sync.Lock(lock, sync.LockWait(time.Millisecond*100)); err != nil {
+			if err == sync.ErrLockTimeout {
+				return nil, ErrRunning
+			}
+			return nil, err
+		}
+		defer s.opts.Sync.Unlock(lock)
+	}
+
+	r := &Run{
+		Job:     id,
+		Node:    s.opts.Node,
+		Started: time.Now().UnixNano(),
+		Trigger: trigger,
+	}
+	err = call(ctx, fn, j.Args)
+	r.Finished = time.Now().UnixNano()
+	if err != nil {
+		r.Error = err.Error()
+	}
+
+	if e := s.record(r); e != nil {
+		log.Errorf("[job]: failed to record the run of %s: %v", id, e)
+	}
+
+	return r, err
+}
+
+func call(ctx context.Context, fn Func, args []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn(ctx, args)
+}
+
+// record persists the run and updates the state of the job
+func (s *Scheduler) record(r *Run) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	key := fmt.Sprintf("%s%s/%020d", runPrefix, r.Job, r.Started)
+	if err := s.write(key, b, store.WriteTTL(s.opts.History)); err != nil {
+		return err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	st, err := s.State(r.Job)
+	if err != nil {
+		return err
+	}
+	st.LastRun = r.Started
+	st.LastError = r.Error
+	st.Runs++
+	if r.Error != "" {
+		st.Failures++
+	}
+	b, err = json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	return s.write(statePrefix+r.Job, b)
+}
+
+// Add persists the job and schedules it, a new id is generated when it's empty
+func (s *Scheduler) Add(j *Job) error {
+	if j.Name == "" || j.Handler == "" {
+		return errors.New("missing name or handler")
+	}
+	if _, err := cron.Parse(j.Spec); err != nil {
+		return fmt.Errorf("invalid spec '%s': %v", j.Spec, err)
+	}
+	s.RLock()
+	_, ok := s.handlers[j.Handler]
+	s.RUnlock()
+	if !ok {
+		return fmt.Errorf("handler %s not found", j.Handler)
+	}
+
+	now := time.Now().UnixNano()
+	if j.Id == "" {
+		j.Id = uuid.New().String()
+	}
+	if j.Created == 0 {
+		j.Created = now
+	}
+	j.Updated = now
+	return s.save(j)
+}
+
+// Remove removes the job and its state, the run history expires by itself
+func (s *Scheduler) Remove(id string) error {
+	if _, err := s.Get(id); err != nil {
+		return err
+	}
+	if err := s.delete(defPrefix + id); err != nil {
+		return err
+	}
+	if err := s.delete(statePrefix + id); err != nil {
+		return err
+	}
+	return s.refresh()
+}
+
+// Pause stops firing the job until it's resumed
+func (s *Scheduler) Pause(id string) error {
+	return s.update(id, func(j *Job) {
+		j.Paused = true
+	})
+}
+
+// Resume resumes the paused job
+func (s *Scheduler) Resume(id string) error {
+	return s.update(id, func(j *Job) {
+		j.Paused = false
+	})
+}
+
+func (s *Scheduler) update(id string, fn func(j *Job)) error {
+	j, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	fn(j)
+	j.Updated = time.Now().UnixNano()
+	return s.save(j)
+}
+
+func (s *Scheduler) save(j *Job) error {
+	b, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	if err := s.write(defPrefix+j.Id, b); err != nil {
+		return err
+	}
+	return s.refresh()
+}
+
+// Get returns the job
+func (s *Scheduler) Get(id string) (*Job, error) {
+	b, err := s.read(defPrefix + id)
+	if err != nil {
+		return nil, err
+	}
+	j := &Job{}
+	if err := json.Unmarshal(b, j); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// List returns the jobs ordered by creation
+func (s *Scheduler) List() ([]*Job, error) {
+	recs, err := s.opts.Store.Read(defPrefix, store.ReadFrom(s.opts.Database, s.opts.Table), store.ReadPrefix())
+	if err != nil && err != store.ErrNotFound {
+		return nil, err
+	}
+	jobs := make([]*Job, 0, len(recs))
+	for _, rec := range recs {
+		j := &Job{}
+		if err := json.Unmarshal(rec.Value, j); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].Created < jobs[j].Created
+	})
+	return jobs, nil
+}
+
+// State returns the last run state of the job
+func (s *Scheduler) State(id string) (*State, error) {
+	st := &State{}
+	b, err := s.read(statePrefix + id)
+	if err == ErrNotFound {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, st); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// History returns the recent runs of the job in order, zero limit returns all
+func (s *Scheduler) History(id string, limit int) ([]*Run, error) {
+	recs, err := s.opts.Store.Read(runPrefix+id+"/", store.ReadFrom(s.opts.Database, s.opts.Table), store.ReadPrefix())
+	if err != nil && err != store.ErrNotFound {
+		return nil, err
+	}
+	sort.Slice(recs, func(i, j int) bool {
+		return recs[i].Key < recs[j].Key
+	})
+	if limit > 0 && len(recs) > limit {
+		recs = recs[len(recs)-limit:]
+	}
+	runs := make([]*Run, 0, len(recs))
+	for _, rec := range recs {
+		r := &Run{}
+		if err := json.Unmarshal(rec.Value, r); err != nil {
+			return nil, err
+		}
+		runs = append(runs, r)
+	}
+	return runs, nil
+}
+
+func (s *Scheduler) read(key string) ([]byte, error) {
+	recs, err := s.opts.Store.Read(key, store.ReadFrom(s.opts.Database, s.opts.Table))
+	if err == store.ErrNotFound || (err == nil && len(recs) == 0) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return recs[0].Value, nil
+}
+
+func (s *Scheduler) write(key string, b []byte, opts ...store.WriteOption) error {
+	opts = append([]store.WriteOption{store.WriteTo(s.opts.Database, s.opts.Table)}, opts...)
+	return s.opts.Store.Write(&store.Record{Key: key, Value: b}, opts...)
+}
+
+func (s *Scheduler) delete(key string) error {
+	err := s.opts.Store.Delete(key, store.DeleteFrom(s.opts.Database, s.opts.Table))
+	if err == store.ErrNotFound {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,234 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package job
+
+import (
+	"context"
+	"errors"
+	gosync "sync"
+	"testing"
+	"time"
+
+	smemory "github.com/lack-io/vine/lib/store/memory"
+	"github.com/lack-io/vine/lib/sync/memory"
+	verrors "github.com/lack-io/vine/proto/apis/errors"
+	pb "github.com/lack-io/vine/proto/services/job"
+)
+
+type counter struct {
+	gosync.Mutex
+	nodes map[string]int
+}
+
+func (c *counter) fn(node string) Func {
+	return func(ctx context.Context, args []byte) error {
+		c.Lock()
+		c.nodes[node]++
+		c.Unlock()
+		return nil
+	}
+}
+
+func (c *counter) get(node string) int {
+	c.Lock()
+	defer c.Unlock()
+	return c.nodes[node]
+}
+
+func wait(t *testing.T, fn func() bool) {
+	deadline := time.Now().Add(time.Second * 5)
+	for !fn() {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	c := &counter{nodes: map[string]int{}}
+	s := NewScheduler(Store(smemory.NewStore()), Node("1"))
+	s.Handle("count", c.fn("1"))
+
+	if err := s.Add(&Job{Name: "count", Handler: "missing", Spec: "@every 1s"}); err == nil {
+		t.Fatal("expect missing handler error")
+	}
+	if err := s.Add(&Job{Name: "count", Handler: "count", Spec: "invalid"}); err == nil {
+		t.Fatal("expect invalid spec error")
+	}
+
+	j := &Job{Name: "count", Handler: "count", Spec: "@every 1s"}
+	if err := s.Add(j); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	wait(t, func() bool { return c.get("1") >= 2 })
+
+	if err := s.Pause(j.Id); err != nil {
+		t.Fatal(err)
+	}
+	n := c.get("1")
+	time.Sleep(time.Millisecond * 200)
+	// the firing in progress may be done after pausing
+	if c.get("1") > n+1 {
+		t.Fatalf("paused job is fired, %d > %d", c.get("1"), n+1)
+	}
+
+	r, err := s.Trigger(context.TODO(), j.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Trigger || r.Node != "1" {
+		t.Fatalf("unexpected run %+v", r)
+	}
+
+	st, err := s.State(j.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runs, err := s.History(j.Id, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int(st.Runs) != len(runs) || st.LastRun != runs[len(runs)-1].Started {
+		t.Fatalf("state %+v doesn't match history of %d runs", st, len(runs))
+	}
+	if runs, _ := s.History(j.Id, 1); len(runs) != 1 || !runs[0].Trigger {
+		t.Fatalf("unexpected recent runs %v", runs)
+	}
+
+	if err := s.Remove(j.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(j.Id); err != ErrNotFound {
+		t.Fatalf("expect not found, got %v", err)
+	}
+}
+
+func TestRestart(t *testing.T) {
+	st := smemory.NewStore()
+	c := &counter{nodes: map[string]int{}}
+
+	s := NewScheduler(Store(st))
+	s.Handle("count", c.fn("1"))
+	if err := s.Add(&Job{Name: "count", Handler: "count", Spec: "@every 1s"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the job definition survives the restart
+	s = NewScheduler(Store(st))
+	s.Handle("count", c.fn("2"))
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	wait(t, func() bool { return c.get("2") >= 1 })
+}
+
+func TestLeader(t *testing.T) {
+	st := smemory.NewStore()
+	sc := memory.NewSync()
+	c := &counter{nodes: map[string]int{}}
+
+	nodes := make([]*Scheduler, 2)
+	for i, id := range []string{"1", "2"} {
+		s := NewScheduler(Name("test"), Node(id), Store(st), Sync(sc))
+		s.Handle("count", c.fn(id))
+		if err := s.Start(); err != nil {
+			t.Fatal(err)
+		}
+		// the first node is elected before the second one starts
+		if i == 0 {
+			wait(t, s.Leader)
+		}
+		nodes[i] = s
+	}
+	defer nodes[1].Stop()
+
+	if err := nodes[0].Add(&Job{Name: "count", Handler: "count", Spec: "@every 1s"}); err != nil {
+		t.Fatal(err)
+	}
+	wait(t, func() bool { return c.get("1") >= 2 })
+	if n := c.get("2"); n != 0 {
+		t.Fatalf("follower runs the job %d times", n)
+	}
+
+	// the follower takes over after the leader stops
+	if err := nodes[0].Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err := nodes[1].refresh(); err != nil {
+		t.Fatal(err)
+	}
+	wait(t, func() bool { return c.get("2") >= 1 })
+}
+
+func TestHandler(t *testing.T) {
+	s := NewScheduler(Store(smemory.NewStore()))
+	s.Handle("fail", func(ctx context.Context, args []byte) error {
+		return errors.New(string(args))
+	})
+	h := NewHandler(s)
+	ctx := context.TODO()
+
+	add := &pb.AddResponse{}
+	err := h.Add(ctx, &pb.AddRequest{Job: &pb.Definition{Name: "fail", Handler: "fail", Spec: "0 0 * * * *", Args: []byte("boom")}}, add)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := add.Job.Id
+
+	trigger := &pb.TriggerResponse{}
+	if err := h.Trigger(ctx, &pb.TriggerRequest{Id: id}, trigger); err != nil {
+		t.Fatal(err)
+	}
+	if trigger.Run.Error != "boom" {
+		t.Fatalf("expect error boom, got %s", trigger.Run.Error)
+	}
+
+	list := &pb.ListResponse{}
+	if err := h.List(ctx, &pb.ListRequest{}, list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Jobs) != 1 || list.Jobs[0].Failures != 1 || list.Jobs[0].LastError != "boom" {
+		t.Fatalf("unexpected jobs %v", list.Jobs)
+	}
+
+	history := &pb.HistoryResponse{}
+	if err := h.History(ctx, &pb.HistoryRequest{Id: id}, history); err != nil {
+		t.Fatal(err)
+	}
+	if len(history.Runs) != 1 {
+		t.Fatalf("expect 1 run, got %d", len(history.Runs))
+	}
+
+	err = h.Pause(ctx, &pb.PauseRequest{Id: "missing"}, &pb.PauseResponse{})
+	if e := verrors.FromErr(err); e.Code != 404 {
+		t.Fatalf("expect not found, got %v", err)
+	}
+}
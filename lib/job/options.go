@@ -0,0 +1,131 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package job
+
+import (
+	"time"
+
+	"github.com/lack-io/gscheduler"
+
+	"github.com/lack-io/vine/lib/store"
+	"github.com/lack-io/vine/lib/sync"
+)
+
+type Options struct {
+	// Name is the name of the scheduler, the nodes with the same name share
+	// the jobs, it's usually the name of the service
+	Name string
+	// Node is the id of the node recorded in the run history
+	Node string
+	// Scheduler fires the jobs, a new scheduler is started when it's nil
+	Scheduler gscheduler.Scheduler
+	// Store persists the jobs and the run history, defaults to store.DefaultStore
+	Store store.Store
+	// Database the store database of jobs
+	Database string
+	// Table the store table of jobs
+	Table string
+	// Sync elects the node running the jobs, every node runs the jobs when it's nil
+	Sync sync.Sync
+	// RefreshInterval is the interval of reloading the jobs from the store
+	RefreshInterval time.Duration
+	// History is how long the run history is kept
+	History time.Duration
+}
+
+func NewOptions(opts ...Option) Options {
+	options := Options{
+		Table:           "job",
+		RefreshInterval: time.Second * 10,
+		History:         time.Hour * 24 * 7,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	if options.Store == nil {
+		options.Store = store.DefaultStore
+	}
+
+	return options
+}
+
+type Option func(*Options)
+
+// Name sets the name of scheduler
+func Name(n string) Option {
+	return func(o *Options) {
+		o.Name = n
+	}
+}
+
+// Node sets the node id of scheduler
+func Node(id string) Option {
+	return func(o *Options) {
+		o.Node = id
+	}
+}
+
+// WithScheduler sets the gscheduler fires the jobs
+func WithScheduler(s gscheduler.Scheduler) Option {
+	return func(o *Options) {
+		o.Scheduler = s
+	}
+}
+
+// Store sets the store persists the jobs
+func Store(s store.Store) Option {
+	return func(o *Options) {
+		o.Store = s
+	}
+}
+
+// Table sets the store database and table of jobs
+func Table(database, table string) Option {
+	return func(o *Options) {
+		o.Database = database
+		o.Table = table
+	}
+}
+
+// Sync sets the sync elects the node running the jobs
+func Sync(s sync.Sync) Option {
+	return func(o *Options) {
+		o.Sync = s
+	}
+}
+
+// RefreshInterval sets the interval of reloading the jobs
+func RefreshInterval(d time.Duration) Option {
+	return func(o *Options) {
+		o.RefreshInterval = d
+	}
+}
+
+// History sets how long the run history is kept
+func History(d time.Duration) Option {
+	return func(o *Options) {
+		o.History = d
+	}
+}
@@ -22,15 +22,65 @@
 
 package logger
 
-import "context"
+import (
+	"context"
+
+	"github.com/lack-io/vine/lib/trace"
+)
+
+const (
+	// TraceIDKey is the field of the trace id of the request
+	TraceIDKey = "trace_id"
+	// SpanIDKey is the field of the span id of the request
+	SpanIDKey = "span_id"
+	// ServiceKey is the field of the service handling the request
+	ServiceKey = "service"
+	// EndpointKey is the field of the endpoint handling the request
+	EndpointKey = "endpoint"
+	// CallerKey is the field of the service which sent the request
+	CallerKey = "caller"
+	// RemoteKey is the field of the remote address of the request
+	RemoteKey = "remote"
+)
 
 type loggerKey struct{}
 
+// FromContext returns the logger in the context
 func FromContext(ctx context.Context) (Logger, bool) {
 	l, ok := ctx.Value(loggerKey{}).(Logger)
 	return l, ok
 }
 
+// HelperFromContext returns the helper of the request logger in the context,
+// the default logger is used when there is none. The records carry the trace
+// and span ids of the context.
+func HelperFromContext(ctx context.Context) *Helper {
+	l, ok := ctx.Value(loggerKey{}).(Logger)
+	if !ok {
+		l = DefaultLogger
+	}
+
+	fields := map[string]interface{}{}
+	traceID, spanID, _ := trace.FromContext(ctx)
+	if len(traceID) > 0 {
+		fields[TraceIDKey] = traceID
+	}
+	if len(spanID) > 0 {
+		fields[SpanIDKey] = spanID
+	}
+	return NewHelper(l).WithFields(fields)
+}
+
+// NewContext returns a context carrying the logger
 func NewContext(ctx context.Context, l Logger) context.Context {
 	return context.WithValue(ctx, loggerKey{}, l)
 }
+
+// ContextWithFields returns a context whose logger logs the fields too
+func ContextWithFields(ctx context.Context, fields map[string]interface{}) context.Context {
+	l, ok := ctx.Value(loggerKey{}).(Logger)
+	if !ok {
+		l = DefaultLogger
+	}
+	return NewContext(ctx, l.Fields(fields))
+}
@@ -50,16 +50,33 @@ func init() {
 	logSourceDir = regexp.MustCompile(`default\.go`).ReplaceAllString(file, "")
 }
 
+// ComponentKey is the field naming the component of a logger, the
+// component level overrides the logger level
+const ComponentKey = "component"
+
 type defaultLogger struct {
+	// state is shared by the loggers returned by Fields
+	*state
+	fields map[string]interface{}
+}
+
+type state struct {
 	sync.RWMutex
-	opts Options
+	opts    Options
+	sampler *sampler
 }
 
 // Init should only overwrite provided options
 func (l *defaultLogger) Init(opts ...Option) error {
+	l.Lock()
+	defer l.Unlock()
+	sampling := l.opts.Sampling
 	for _, o := range opts {
 		o(&l.opts)
 	}
+	if l.opts.Sampling != sampling {
+		l.sampler = newSampler(l.opts.Sampling)
+	}
 	return nil
 }
 
@@ -67,11 +84,24 @@ func (l *defaultLogger) String() string {
 	return "default"
 }
 
+// Fields returns a logger which logs the fields too, it shares the options
+// with the parent so that the level changes apply to both
 func (l *defaultLogger) Fields(fields map[string]interface{}) Logger {
-	l.Lock()
-	l.opts.Fields = copyFields(fields)
-	l.Unlock()
-	return l
+	nfields := copyFields(l.fields)
+	for k, v := range fields {
+		nfields[k] = v
+	}
+	return &defaultLogger{state: l.state, fields: nfields}
+}
+
+// level returns the level of the logger, the caller must hold the lock
+func (l *defaultLogger) level() Level {
+	if c, ok := l.fields[ComponentKey].(string); ok {
+		if lvl, ok := l.opts.Levels[c]; ok {
+			return lvl
+		}
+	}
+	return l.opts.Level
 }
 
 func copyFields(src map[string]interface{}) map[string]interface{} {
@@ -118,15 +148,43 @@ func logCallerfilePath(loggingFilePath string) string {
 }
 
 func (l *defaultLogger) Log(level Level, args ...interface{}) {
-	// TODO decide does we need to write message if log level not used?
-	if !l.opts.Level.Enabled(level) {
-		return
-	}
+	l.log(level, "", args)
+}
 
+func (l *defaultLogger) Logf(level Level, format string, args ...interface{}) {
+	l.log(level, format, args)
+}
+
+func (l *defaultLogger) log(level Level, format string, args []interface{}) {
 	l.RLock()
+	enabled := l.level().Enabled(level)
+	sampler := l.sampler
+	out, formatter := l.opts.Out, l.opts.Format
 	fields := copyFields(l.opts.Fields)
 	l.RUnlock()
 
+	if !enabled {
+		return
+	}
+
+	var message string
+	if len(format) > 0 {
+		// sample by the format so that the entries with different
+		// arguments are counted as the same message
+		if !sampler.sample(level, format) {
+			return
+		}
+		message = fmt.Sprintf(format, args...)
+	} else {
+		message = fmt.Sprint(args...)
+		if !sampler.sample(level, message) {
+			return
+		}
+	}
+
+	for k, v := range l.fields {
+		fields[k] = v
+	}
 	fields["level"] = level.String()
 	if fields["file"] == "" || fields["file"] == nil {
 		fields["file"] = fileWithLineNum()
@@ -134,7 +192,7 @@ func (l *defaultLogger) Log(level Level, args ...interface{}) {
 
 	rec := dlog.Record{
 		Timestamp: time.Now(),
-		Message:   fmt.Sprint(args...),
+		Message:   message,
 		Metadata:  make(map[string]string, len(fields)),
 	}
 
@@ -144,44 +202,14 @@ func (l *defaultLogger) Log(level Level, args ...interface{}) {
 		rec.Metadata[k] = fmt.Sprintf("%v", v)
 	}
 
-	sort.Strings(keys)
-	metadata := ""
-
-	for _, k := range keys {
-		metadata += fmt.Sprintf(" %s=%v", k, fields[k])
-	}
-
 	dlog.DefaultLog.Write(rec)
 
-	t := rec.Timestamp.Format("2006-01-02 15:04:05")
-	fmt.Printf("%s %s %v\n", t, metadata, rec.Message)
-}
-
-func (l *defaultLogger) Logf(level Level, format string, args ...interface{}) {
-	//	 TODO decide does we need to write message if log level not used?
-	if level < l.opts.Level {
+	if out == nil {
 		return
 	}
-
-	l.RLock()
-	fields := copyFields(l.opts.Fields)
-	l.RUnlock()
-
-	fields["level"] = level.String()
-	if fields["file"] == "" || fields["file"] == nil {
-		fields["file"] = fileWithLineNum()
-	}
-
-	rec := dlog.Record{
-		Timestamp: time.Now(),
-		Message:   fmt.Sprintf(format, args...),
-		Metadata:  make(map[string]string, len(fields)),
-	}
-
-	keys := make([]string, 0, len(fields))
-	for k, v := range fields {
-		keys = append(keys, k)
-		rec.Metadata[k] = fmt.Sprintf("%v", v)
+	if formatter != nil {
+		fmt.Fprintln(out, formatter(rec))
+		return
 	}
 
 	sort.Strings(keys)
@@ -191,17 +219,19 @@ func (l *defaultLogger) Logf(level Level, format string, args ...interface{}) {
 		metadata += fmt.Sprintf(" %s=%v", k, fields[k])
 	}
 
-	dlog.DefaultLog.Write(rec)
-
 	t := rec.Timestamp.Format("2006-01-02 15:04:05")
-	fmt.Printf("%s %s %v\n", t, metadata, rec.Message)
+	fmt.Fprintf(out, "%s %s %v\n", t, metadata, rec.Message)
 }
 
 func (l *defaultLogger) Options() Options {
 	// not guard against options Context values
 	l.RLock()
 	opts := l.opts
+	opts.Level = l.level()
 	opts.Fields = copyFields(l.opts.Fields)
+	for k, v := range l.fields {
+		opts.Fields[k] = v
+	}
 	l.RUnlock()
 	return opts
 }
@@ -212,11 +242,11 @@ func NewLogger(opts ...Option) Logger {
 	options := Options{
 		Level:   InfoLevel,
 		Fields:  make(map[string]interface{}),
-		Out:     os.Stderr,
+		Out:     os.Stdout,
 		Context: context.Background(),
 	}
 
-	l := &defaultLogger{opts: options}
+	l := &defaultLogger{state: &state{opts: options}}
 	if err := l.Init(opts...); err != nil {
 		l.Log(FatalLevel, err)
 	}
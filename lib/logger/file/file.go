@@ -0,0 +1,303 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package file is a log file sink, the file is rotated by size and time and
+// the rotated files are compressed and removed by the retention options.
+package file
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	timeFormat = "20060102T150405.000"
+	gzExt      = ".gz"
+)
+
+// File is an io.Writer which rotates the log file,
+// use it as the logger output by logger.WithOutput
+type File struct {
+	opts Options
+
+	sync.Mutex
+	// file is nil when reopening it failed on rotation
+	file   *os.File
+	size   int64
+	closed bool
+	// next is the time of the next rotation
+	next time.Time
+
+	// mill compresses and removes the rotated files in the background
+	mill chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+type backup struct {
+	path string
+	t    time.Time
+}
+
+// Write writes p to the file, it rotates the file first when writing p
+// exceeds the max size or the interval is passed
+func (f *File) Write(p []byte) (int, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+	// retry to open the file which failed to be reopened on rotation
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	if (f.opts.Interval > 0 && !time.Now().Before(f.next)) ||
+		(f.opts.MaxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.opts.MaxSize) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Rotate rotates the file, e.g. on SIGHUP
+func (f *File) Rotate() error {
+	f.Lock()
+	defer f.Unlock()
+	if f.closed {
+		return os.ErrClosed
+	}
+	if f.file == nil {
+		return f.open()
+	}
+	return f.rotate()
+}
+
+// Close closes the file and waits for the rotated files to be processed
+func (f *File) Close() error {
+	f.Lock()
+	defer f.Unlock()
+
+	var err error
+	f.once.Do(func() {
+		if f.file != nil {
+			err = f.file.Close()
+		}
+		f.file = nil
+		f.closed = true
+		close(f.mill)
+		<-f.done
+	})
+	return err
+}
+
+func (f *File) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.opts.Path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(f.opts.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	f.file = file
+	f.size = info.Size()
+	if f.opts.Interval > 0 {
+		f.next = time.Now().Truncate(f.opts.Interval).Add(f.opts.Interval)
+	}
+	return nil
+}
+
+// rotate renames the file to a backup and opens a new file,
+// the caller must hold the lock
+func (f *File) rotate() error {
+	err := f.file.Close()
+	// the file can't be used any more even if closing it failed
+	f.file = nil
+	if err != nil {
+		return err
+	}
+
+	// the name of the backup must be unique
+	t := time.Now()
+	name := f.backupName(t)
+	for exists(name) || exists(name+gzExt) {
+		t = t.Add(time.Millisecond)
+		name = f.backupName(t)
+	}
+	if err := os.Rename(f.opts.Path, name); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	select {
+	case f.mill <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (f *File) prefix() (string, string) {
+	ext := filepath.Ext(f.opts.Path)
+	return strings.TrimSuffix(filepath.Base(f.opts.Path), ext) + "-", ext
+}
+
+func (f *File) backupName(t time.Time) string {
+	prefix, ext := f.prefix()
+	return filepath.Join(filepath.Dir(f.opts.Path), prefix+t.Format(timeFormat)+ext)
+}
+
+// backups returns the rotated files, the newest first
+func (f *File) backups() ([]backup, error) {
+	dir := filepath.Dir(f.opts.Path)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix, ext := f.prefix()
+	var backups []backup
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		ts := strings.TrimPrefix(strings.TrimSuffix(name, gzExt), prefix)
+		if !strings.HasSuffix(ts, ext) {
+			continue
+		}
+		t, err := time.ParseInLocation(timeFormat, strings.TrimSuffix(ts, ext), time.Local)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{path: filepath.Join(dir, name), t: t})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].t.After(backups[j].t)
+	})
+	return backups, nil
+}
+
+func (f *File) run() {
+	defer close(f.done)
+	for range f.mill {
+		f.clean()
+	}
+}
+
+// clean compresses the rotated files and removes the ones out of retention
+func (f *File) clean() {
+	backups, err := f.backups()
+	if err != nil {
+		return
+	}
+
+	for i, b := range backups {
+		remove := (f.opts.MaxBackups > 0 && i >= f.opts.MaxBackups) ||
+			(f.opts.MaxAge > 0 && time.Since(b.t) > f.opts.MaxAge)
+		if remove {
+			os.Remove(b.path)
+			continue
+		}
+		if f.opts.Compress && !strings.HasSuffix(b.path, gzExt) {
+			compress(b.path)
+		}
+	}
+}
+
+func compress(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+gzExt, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	if _, err = io.Copy(gz, src); err == nil {
+		err = gz.Close()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path + gzExt)
+		return err
+	}
+	return os.Remove(path)
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// New opens the log file, the file is appended when it exists
+func New(opts ...Option) (*File, error) {
+	var options Options
+	for _, o := range opts {
+		o(&options)
+	}
+	if len(options.Path) == 0 {
+		return nil, errors.New("file: missing path")
+	}
+
+	f := &File{
+		opts: options,
+		mill: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	go f.run()
+
+	// process the files of the previous runs
+	f.mill <- struct{}{}
+	return f, nil
+}
@@ -0,0 +1,158 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSizeRotation(t *testing.T) {
+	dir := t.TempDir()
+	f, err := New(Path(filepath.Join(dir, "vine.log")), MaxSize(10), MaxBackups(2), Compress(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if _, err := f.Write([]byte("0123456789")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := f.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("expected 2 backups, got %d", len(backups))
+	}
+	for _, b := range backups {
+		if !strings.HasSuffix(b.path, gzExt) {
+			t.Fatalf("expected compressed backup, got %s", b.path)
+		}
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, "vine.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "0123456789" {
+		t.Fatalf("unexpected content %q", b)
+	}
+}
+
+func TestIntervalRotation(t *testing.T) {
+	dir := t.TempDir()
+	f, err := New(Path(filepath.Join(dir, "vine.log")), Interval(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	f.Write([]byte("first"))
+	// pretend the interval passed
+	f.Lock()
+	f.next = time.Now().Add(-time.Second)
+	f.Unlock()
+	f.Write([]byte("second"))
+
+	backups, err := f.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 {
+		t.Fatalf("expected 1 backup, got %d", len(backups))
+	}
+	b, _ := os.ReadFile(backups[0].path)
+	if string(b) != "first" {
+		t.Fatalf("unexpected backup content %q", b)
+	}
+}
+
+func TestMaxAge(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "vine.log")
+
+	// a backup of a previous run
+	f := &File{opts: Options{Path: path}}
+	old := f.backupName(time.Now().Add(-48 * time.Hour))
+	if err := os.WriteFile(old, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := New(Path(path), MaxAge(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Fatal("expected the old backup to be removed")
+	}
+}
+
+func TestReopenAfterFailedRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "vine.log")
+	f, err := New(Path(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write([]byte("0123456789")); err != nil {
+		t.Fatal(err)
+	}
+
+	// the file can't be created under a regular file
+	blocker := filepath.Join(dir, "blocker")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	f.opts.Path = filepath.Join(blocker, "vine.log")
+	if err := f.Rotate(); err == nil {
+		t.Fatal("expected rotation error")
+	}
+	if f.file != nil {
+		t.Fatal("expected the closed file to be released")
+	}
+
+	f.opts.Path = path
+	if _, err := f.Write([]byte("abc")); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "0123456789abc" {
+		t.Fatalf("unexpected content %q", b)
+	}
+}
@@ -0,0 +1,85 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package file
+
+import "time"
+
+// Options are the options of the file sink
+type Options struct {
+	// Path of the log file
+	Path string
+	// MaxSize is the size in bytes the file is rotated at, zero disables
+	MaxSize int64
+	// Interval is the period the file is rotated at, zero disables
+	Interval time.Duration
+	// MaxBackups is the number of rotated files kept, zero keeps all
+	MaxBackups int
+	// MaxAge is the age the rotated files are removed at, zero keeps all
+	MaxAge time.Duration
+	// Compress gzips the rotated files
+	Compress bool
+}
+
+type Option func(*Options)
+
+// Path sets the path of the log file
+func Path(p string) Option {
+	return func(o *Options) {
+		o.Path = p
+	}
+}
+
+// MaxSize rotates the file when it's larger than size bytes
+func MaxSize(size int64) Option {
+	return func(o *Options) {
+		o.MaxSize = size
+	}
+}
+
+// Interval rotates the file every interval, e.g. time.Hour * 24
+func Interval(d time.Duration) Option {
+	return func(o *Options) {
+		o.Interval = d
+	}
+}
+
+// MaxBackups sets the number of rotated files kept
+func MaxBackups(n int) Option {
+	return func(o *Options) {
+		o.MaxBackups = n
+	}
+}
+
+// MaxAge removes the rotated files older than d
+func MaxAge(d time.Duration) Option {
+	return func(o *Options) {
+		o.MaxAge = d
+	}
+}
+
+// Compress gzips the rotated files
+func Compress(b bool) Option {
+	return func(o *Options) {
+		o.Compress = b
+	}
+}
@@ -36,77 +36,77 @@ func NewHelper(log Logger) *Helper {
 }
 
 func (h *Helper) Info(args ...interface{}) {
-	if !h.Logger.Options().Level.Enabled(InfoLevel) {
+	if !h.enabled(InfoLevel) {
 		return
 	}
 	h.Logger.Fields(h.fields).Log(InfoLevel, args...)
 }
 
 func (h *Helper) Infof(template string, args ...interface{}) {
-	if !h.Logger.Options().Level.Enabled(InfoLevel) {
+	if !h.enabled(InfoLevel) {
 		return
 	}
 	h.Logger.Fields(h.fields).Logf(InfoLevel, template, args...)
 }
 
 func (h *Helper) Trace(args ...interface{}) {
-	if !h.Logger.Options().Level.Enabled(TraceLevel) {
+	if !h.enabled(TraceLevel) {
 		return
 	}
 	h.Logger.Fields(h.fields).Log(TraceLevel, args...)
 }
 
 func (h *Helper) Tracef(template string, args ...interface{}) {
-	if !h.Logger.Options().Level.Enabled(TraceLevel) {
+	if !h.enabled(TraceLevel) {
 		return
 	}
 	h.Logger.Fields(h.fields).Logf(TraceLevel, template, args...)
 }
 
 func (h *Helper) Debug(args ...interface{}) {
-	if !h.Logger.Options().Level.Enabled(DebugLevel) {
+	if !h.enabled(DebugLevel) {
 		return
 	}
 	h.Logger.Fields(h.fields).Log(DebugLevel, args...)
 }
 
 func (h *Helper) Debugf(template string, args ...interface{}) {
-	if !h.Logger.Options().Level.Enabled(DebugLevel) {
+	if !h.enabled(DebugLevel) {
 		return
 	}
 	h.Logger.Fields(h.fields).Logf(DebugLevel, template, args...)
 }
 
 func (h *Helper) Warn(args ...interface{}) {
-	if !h.Logger.Options().Level.Enabled(WarnLevel) {
+	if !h.enabled(WarnLevel) {
 		return
 	}
 	h.Logger.Fields(h.fields).Log(WarnLevel, args...)
 }
 
 func (h *Helper) Warnf(template string, args ...interface{}) {
-	if !h.Logger.Options().Level.Enabled(WarnLevel) {
+	if !h.enabled(WarnLevel) {
 		return
 	}
 	h.Logger.Fields(h.fields).Logf(WarnLevel, template, args...)
 }
 
 func (h *Helper) Error(args ...interface{}) {
-	if !h.Logger.Options().Level.Enabled(ErrorLevel) {
+	if !h.enabled(ErrorLevel) {
 		return
 	}
 	h.Logger.Fields(h.fields).Log(ErrorLevel, args...)
 }
 
 func (h *Helper) Errorf(template string, args ...interface{}) {
-	if !h.Logger.Options().Level.Enabled(ErrorLevel) {
+	if !h.enabled(ErrorLevel) {
 		return
 	}
 	h.Logger.Fields(h.fields).Logf(ErrorLevel, template, args...)
 }
 
 func (h *Helper) Fatal(args ...interface{}) {
-	if !h.Logger.Options().Level.Enabled(FatalLevel) {
+	if !h.enabled(FatalLevel) {
 		return
 	}
 	h.Logger.Fields(h.fields).Log(FatalLevel, args...)
@@ -114,7 +114,7 @@ func (h *Helper) Fatal(args ...interface{}) {
 }
 
 func (h *Helper) Fatalf(template string, args ...interface{}) {
-	if !h.Logger.Options().Level.Enabled(FatalLevel) {
+	if !h.enabled(FatalLevel) {
 		return
 	}
 	h.Logger.Fields(h.fields).Logf(FatalLevel, template, args...)
@@ -134,3 +134,12 @@ func (h *Helper) WithFields(fields map[string]interface{}) *Helper {
 	}
 	return &Helper{Logger: h.Logger, fields: nfields}
 }
+
+// enabled returns true if the level is enabled, the component of the
+// fields may override the level of the logger
+func (h *Helper) enabled(level Level) bool {
+	if _, ok := h.fields[ComponentKey]; ok {
+		return h.Logger.Fields(h.fields).Options().Level.Enabled(level)
+	}
+	return h.Logger.Options().Level.Enabled(level)
+}
@@ -0,0 +1,153 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package levels changes the levels of a logger at runtime,
+// by a config watch or an http endpoint
+package levels
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/lack-io/vine/lib/config"
+	"github.com/lack-io/vine/lib/config/reader"
+	"github.com/lack-io/vine/lib/logger"
+)
+
+var (
+	// DefaultPath is the path of the levels in the config
+	DefaultPath = []string{"logger"}
+)
+
+// Levels is the level of a logger and the levels of its components, e.g.
+// {"level": "info", "components": {"grpc": "debug"}}
+type Levels struct {
+	Level      string            `json:"level,omitempty"`
+	Components map[string]string `json:"components,omitempty"`
+}
+
+// Get returns the levels of the logger
+func Get(l logger.Logger) Levels {
+	opts := l.Options()
+	lv := Levels{
+		Level:      opts.Level.String(),
+		Components: make(map[string]string, len(opts.Levels)),
+	}
+	for k, v := range opts.Levels {
+		lv.Components[k] = v.String()
+	}
+	return lv
+}
+
+// Set sets the levels of the logger, the components are replaced
+func Set(l logger.Logger, lv Levels) error {
+	var opts []logger.Option
+	if len(lv.Level) > 0 {
+		level, err := logger.GetLevel(lv.Level)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, logger.WithLevel(level))
+	}
+
+	levels := make(map[string]logger.Level, len(lv.Components))
+	for k, v := range lv.Components {
+		level, err := logger.GetLevel(v)
+		if err != nil {
+			return err
+		}
+		levels[k] = level
+	}
+	opts = append(opts, logger.WithLevels(levels))
+
+	return l.Init(opts...)
+}
+
+// Handler returns an http handler which gets the levels of the logger by GET
+// and sets them by PUT or POST, the components are merged and a component
+// with an empty level is removed
+func Handler(l logger.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			var lv Levels
+			if err := json.NewDecoder(r.Body).Decode(&lv); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			cur := Get(l)
+			for k, v := range lv.Components {
+				if len(v) == 0 {
+					delete(cur.Components, k)
+				} else {
+					cur.Components[k] = v
+				}
+			}
+			lv.Components = cur.Components
+			if err := Set(l, lv); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Get(l))
+	})
+}
+
+// Watch sets the levels of the logger from the path of the config and on
+// every change until stop is called, DefaultPath is used by default
+func Watch(c config.Config, l logger.Logger, path ...string) (stop func(), err error) {
+	if len(path) == 0 {
+		path = DefaultPath
+	}
+
+	var lv Levels
+	if err := c.Get(path...).Scan(&lv); err != nil {
+		return nil, err
+	}
+	if err := Set(l, lv); err != nil {
+		return nil, err
+	}
+
+	exit := make(chan bool)
+	go config.WatchFunc(c, exit, func(v reader.Value) {
+		var lv Levels
+		if err := v.Scan(&lv); err != nil {
+			logger.Errorf("logger levels error: %v", err)
+			return
+		}
+		if err := Set(l, lv); err != nil {
+			logger.Errorf("logger levels error: %v", err)
+		}
+	}, path...)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(exit) })
+	}, nil
+}
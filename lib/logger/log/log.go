@@ -57,6 +57,9 @@ type Record struct {
 	Metadata map[string]string `json:"metadata"`
 	// Value contains log entry
 	Message interface{} `json:"message"`
+	// Sequence is the position of record in the log, it's set by the
+	// log which keeps the records
+	Sequence uint64 `json:"sequence,omitempty"`
 }
 
 // Stream returns a log stream
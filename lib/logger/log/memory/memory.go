@@ -97,6 +97,7 @@ func (l *memoryLog) Read(opts ...log2.ReadOption) ([]log2.Record, error) {
 		record := log2.Record{
 			Timestamp: entry.Timestamp,
 			Message:   entry.Value,
+			Sequence:  entry.Sequence,
 		}
 		records = append(records, record)
 	}
@@ -122,6 +123,7 @@ func (l *memoryLog) Stream() (log2.Stream, error) {
 				Timestamp: entry.Timestamp,
 				Message:   entry.Value,
 				Metadata:  make(map[string]string),
+				Sequence:  entry.Sequence,
 			}
 		}
 		// now stream continuously
@@ -130,6 +132,7 @@ func (l *memoryLog) Stream() (log2.Stream, error) {
 				Timestamp: entry.Timestamp,
 				Message:   entry.Value,
 				Metadata:  make(map[string]string),
+				Sequence:  entry.Sequence,
 			}
 		}
 	}()
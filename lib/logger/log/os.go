@@ -25,32 +25,45 @@ package log
 import (
 	"sync"
 
-	"github.com/google/uuid"
-
 	"github.com/lack-io/vine/util/ring"
 )
 
 // Should stream from OS
 type osLog struct {
 	format FormatFunc
-	once   sync.Once
 
-	sync.RWMutex
 	buffer *ring.Buffer
-	subs   map[string]*osStream
 }
 
 type osStream struct {
 	stream chan Record
+	stop   chan bool
+	once   sync.Once
 }
 
 // Read reads log entries from the logger
-func (o *osLog) Read(...ReadOption) ([]Record, error) {
-	var records []Record
+func (o *osLog) Read(opts ...ReadOption) ([]Record, error) {
+	var options ReadOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
 
-	// read the last 100 records
-	for _, v := range o.buffer.Get(100) {
-		records = append(records, v.Value.(Record))
+	var entries []*ring.Entry
+	if !options.Since.IsZero() {
+		entries = o.buffer.Since(options.Since)
+		if options.Count > 0 && options.Count < len(entries) {
+			entries = entries[len(entries)-options.Count:]
+		}
+	} else if options.Count > 0 {
+		entries = o.buffer.Get(options.Count)
+	} else {
+		// read the last 100 records
+		entries = o.buffer.Get(100)
+	}
+
+	records := make([]Record, 0, len(entries))
+	for _, v := range entries {
+		records = append(records, record(v))
 	}
 
 	return records, nil
@@ -64,31 +77,57 @@ func (o *osLog) Write(r Record) error {
 
 // Stream log records
 func (o *osLog) Stream() (Stream, error) {
-	o.Lock()
-	defer o.Unlock()
+	entries, stop := o.buffer.Stream(ring.Drop())
 
-	// create stream
 	st := &osStream{
 		stream: make(chan Record, 128),
+		stop:   stop,
 	}
 
-	// save stream
-	o.subs[uuid.New().String()] = st
+	go func() {
+		defer close(st.stream)
+		for {
+			select {
+			case <-stop:
+				return
+			case entry, ok := <-entries:
+				if !ok {
+					return
+				}
+				select {
+				case st.stream <- record(entry):
+				case <-stop:
+					return
+				}
+			}
+		}
+	}()
 
 	return st, nil
 }
 
+// record returns the record of entry with the sequence of it
+func record(e *ring.Entry) Record {
+	r := e.Value.(Record)
+	r.Sequence = e.Sequence
+	return r
+}
+
 func (o *osStream) Chan() <-chan Record {
 	return o.stream
 }
 
 func (o *osStream) Stop() error {
+	o.once.Do(func() {
+		close(o.stop)
+	})
 	return nil
 }
 
 func NewLog(opts ...Option) Log {
 	options := Options{
 		Format: DefaultFormat,
+		Size:   DefaultSize,
 	}
 	for _, o := range opts {
 		o(&options)
@@ -96,8 +135,7 @@ func NewLog(opts ...Option) Log {
 
 	l := &osLog{
 		format: options.Format,
-		buffer: ring.New(1024),
-		subs:   make(map[string]*osStream),
+		buffer: ring.New(options.Size),
 	}
 
 	return l
@@ -1,7 +1,13 @@
 package logger
 
 import (
+	"bytes"
+	"context"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/lack-io/vine/lib/trace"
 )
 
 func TestLogger(t *testing.T) {
@@ -16,3 +22,66 @@ func TestLogger(t *testing.T) {
 
 	l.Fields(map[string]interface{}{"key3": "val4"}).Log(InfoLevel, "test_msg")
 }
+
+func TestComponentLevel(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	l := NewLogger(WithLevel(InfoLevel), WithOutput(buf), WithComponentLevel("grpc", DebugLevel))
+	grpc := l.Fields(map[string]interface{}{ComponentKey: "grpc"})
+
+	l.Log(DebugLevel, "root_debug")
+	grpc.Log(DebugLevel, "grpc_debug")
+	NewHelper(l).WithFields(map[string]interface{}{ComponentKey: "grpc"}).Debug("helper_debug")
+
+	if strings.Contains(buf.String(), "root_debug") {
+		t.Fatal("expected the root debug entry to be dropped")
+	}
+	if !strings.Contains(buf.String(), "grpc_debug") || !strings.Contains(buf.String(), "helper_debug") {
+		t.Fatalf("expected the grpc debug entries, got %s", buf.String())
+	}
+
+	// the fields of the child don't leak into the parent
+	if _, ok := l.Options().Fields[ComponentKey]; ok {
+		t.Fatal("unexpected component field in the parent")
+	}
+
+	// runtime change applies to the children
+	l.Init(WithLevels(nil), WithLevel(ErrorLevel))
+	buf.Reset()
+	grpc.Log(InfoLevel, "grpc_info")
+	if buf.Len() > 0 {
+		t.Fatalf("expected no entries, got %s", buf.String())
+	}
+}
+
+func TestSampling(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	l := NewLogger(WithLevel(DebugLevel), WithOutput(buf), WithSampling(InfoLevel, 2, 3, time.Minute))
+
+	for i := 0; i < 11; i++ {
+		l.Logf(InfoLevel, "entry %d", i)
+		l.Log(ErrorLevel, "failure")
+	}
+
+	// the first 2 and then every 3rd
+	if n := strings.Count(buf.String(), "entry"); n != 5 {
+		t.Fatalf("expected 5 sampled entries, got %d", n)
+	}
+	if n := strings.Count(buf.String(), "failure"); n != 11 {
+		t.Fatalf("expected 11 failure entries, got %d", n)
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	l := NewLogger(WithOutput(buf))
+
+	ctx := trace.ToContext(NewContext(context.Background(), l), "t1", "s1")
+	ctx = ContextWithFields(ctx, map[string]interface{}{ServiceKey: "foo", EndpointKey: "Foo.Bar"})
+	HelperFromContext(ctx).Info("handled")
+
+	for _, field := range []string{"trace_id=t1", "span_id=s1", "service=foo", "endpoint=Foo.Bar", "handled"} {
+		if !strings.Contains(buf.String(), field) {
+			t.Fatalf("expected %s in %s", field, buf.String())
+		}
+	}
+}
@@ -25,6 +25,9 @@ package logger
 import (
 	"context"
 	"io"
+	"time"
+
+	dlog "github.com/lack-io/vine/lib/logger/log"
 )
 
 type Option func(*Options)
@@ -34,8 +37,15 @@ type Options struct {
 	Level Level
 	// fields to always be logged
 	Fields map[string]interface{}
-	// It's common to set this to a file, or leave it default which is `os.Stderr`
+	// It's common to set this to a file, or leave it default which is `os.Stdout`
 	Out io.Writer
+	// Format formats the records written to Out, the default is text
+	Format dlog.FormatFunc
+	// Levels overrides the level of the components, the component
+	// of a logger is the ComponentKey of its fields
+	Levels map[string]Level
+	// Sampling limits the entries of high volume levels, nil is disabled
+	Sampling *Sampling
 	// Alternative options
 	Context context.Context
 }
@@ -61,6 +71,48 @@ func WithOutput(out io.Writer) Option {
 	}
 }
 
+// WithFormat set the format of the records written to the output
+func WithFormat(f dlog.FormatFunc) Option {
+	return func(args *Options) {
+		args.Format = f
+	}
+}
+
+// WithLevels replaces the levels of the components
+func WithLevels(levels map[string]Level) Option {
+	return func(args *Options) {
+		args.Levels = make(map[string]Level, len(levels))
+		for k, v := range levels {
+			args.Levels[k] = v
+		}
+	}
+}
+
+// WithComponentLevel set the level of a component, it overrides the level
+// of the loggers which have the component in their fields
+func WithComponentLevel(component string, level Level) Option {
+	return func(args *Options) {
+		levels := make(map[string]Level, len(args.Levels)+1)
+		for k, v := range args.Levels {
+			levels[k] = v
+		}
+		levels[component] = level
+		args.Levels = levels
+	}
+}
+
+// WithSampling samples the entries at or below level, in every tick the
+// first entries of each message are logged and then every thereafter entry
+func WithSampling(level Level, first, thereafter int, tick time.Duration) Option {
+	return func(args *Options) {
+		args.Sampling = &Sampling{
+			Level:      level,
+			First:      first,
+			Thereafter: thereafter,
+			Tick:       tick,
+		}
+	}
+}
 
 func SetOption(k, v interface{}) Option {
 	return func(o *Options) {
@@ -0,0 +1,89 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package logger
+
+import (
+	"sync"
+	"time"
+)
+
+// Sampling limits the repeated entries of high volume levels
+type Sampling struct {
+	// Level is the highest level which is sampled
+	Level Level
+	// First is the number of entries of each message logged in every tick
+	First int
+	// Thereafter logs every Thereafter entry after the first ones,
+	// zero drops them all
+	Thereafter int
+	// Tick is the period the counts are reset after
+	Tick time.Duration
+}
+
+type sampler struct {
+	opts Sampling
+
+	sync.Mutex
+	reset  time.Time
+	counts map[samplerKey]int
+}
+
+type samplerKey struct {
+	level   Level
+	message string
+}
+
+func newSampler(opts *Sampling) *sampler {
+	if opts == nil {
+		return nil
+	}
+	s := &sampler{opts: *opts, counts: make(map[samplerKey]int)}
+	if s.opts.Tick <= 0 {
+		s.opts.Tick = time.Second
+	}
+	return s
+}
+
+// sample returns true if the entry should be logged
+func (s *sampler) sample(level Level, message string) bool {
+	if s == nil || level > s.opts.Level {
+		return true
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	now := time.Now()
+	if now.After(s.reset) {
+		s.reset = now.Add(s.opts.Tick)
+		s.counts = make(map[samplerKey]int)
+	}
+
+	key := samplerKey{level, message}
+	s.counts[key]++
+	n := s.counts[key]
+	if n <= s.opts.First {
+		return true
+	}
+	return s.opts.Thereafter > 0 && (n-s.opts.First)%s.opts.Thereafter == 0
+}
@@ -0,0 +1,305 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package mtls provides the mutual TLS between services, an internal CA
+// issues short-lived certificates carrying the service identity, which are
+// rotated and reloaded without restarts
+package mtls
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var (
+	// Scheme is the scheme of the identity URI in the certificates
+	Scheme = "vine"
+	// DefaultCAValidity is the lifetime of the generated CA
+	DefaultCAValidity = time.Hour * 24 * 365 * 10
+
+	ErrNoCertificate = errors.New("no certificate found")
+	ErrNoPrivateKey  = errors.New("no private key found")
+)
+
+// CA is the certificate authority which issues the certificates of services
+type CA struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+	// certPEM is the trust bundle, it may hold the previous CAs
+	// during a rotation of the CA
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// NewCA generates a self-signed CA
+func NewCA(name string) (*CA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	serial, err := serialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: name, Organization: []string{"vine"}},
+		NotBefore:             now.Add(-time.Minute),
+		NotAfter:              now.Add(DefaultCAValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := encodeKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseCA(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), keyPEM)
+}
+
+// ParseCA parses the pem encoded CA, the first certificate signs the new
+// certificates and all of them are trusted
+func ParseCA(certPEM, keyPEM []byte) (*CA, error) {
+	certs, err := parseCertificates(certPEM)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, ErrNoPrivateKey
+	}
+	key, err := parseKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	if !certs[0].IsCA {
+		return nil, fmt.Errorf("certificate %s is not a CA", certs[0].Subject)
+	}
+
+	return &CA{cert: certs[0], key: key, certPEM: certPEM, keyPEM: keyPEM}, nil
+}
+
+// LoadCA reads the CA from the files
+func LoadCA(certFile, keyFile string) (*CA, error) {
+	certPEM, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCA(certPEM, keyPEM)
+}
+
+// LoadOrCreateCA reads the CA from the files, a new CA is generated and
+// written to them when they don't exist
+func LoadOrCreateCA(certFile, keyFile string) (*CA, error) {
+	ca, err := LoadCA(certFile, keyFile)
+	if err == nil || !os.IsNotExist(err) {
+		return ca, err
+	}
+
+	ca, err = NewCA("vine")
+	if err != nil {
+		return nil, err
+	}
+	if err := ca.Write(certFile, keyFile); err != nil {
+		return nil, err
+	}
+	return ca, nil
+}
+
+// Write writes the CA to the files, the key is readable by the owner only
+func (ca *CA) Write(certFile, keyFile string) error {
+	for _, f := range []string{certFile, keyFile} {
+		if err := os.MkdirAll(filepath.Dir(f), 0700); err != nil {
+			return err
+		}
+	}
+	if err := ioutil.WriteFile(keyFile, ca.keyPEM, 0600); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(certFile, ca.certPEM, 0644)
+}
+
+// Certificate returns the certificate of the CA
+func (ca *CA) Certificate() *x509.Certificate {
+	return ca.cert
+}
+
+// Bundle returns the pem encoded certificates trusted by the CA
+func (ca *CA) Bundle() []byte {
+	return ca.certPEM
+}
+
+// Issue issues the certificate of the service which is valid for ttl, the
+// service name is in the SAN as a DNS name and as the identity URI. The
+// certificate is used by both the servers and the clients.
+func (ca *CA) Issue(service string, ttl time.Duration, hosts ...string) (certPEM []byte, keyPEM []byte, err error) {
+	if len(service) == 0 {
+		return nil, nil, errors.New("require service name")
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := serialNumber()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	notAfter := now.Add(ttl)
+	if notAfter.After(ca.cert.NotAfter) {
+		notAfter = ca.cert.NotAfter
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: service, Organization: []string{"vine"}},
+		// allow for the clock skew between the nodes
+		NotBefore:   now.Add(-time.Minute),
+		NotAfter:    notAfter,
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:    []string{service},
+		URIs:        []*url.URL{URI(service)},
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else if len(h) > 0 {
+			template.DNSNames = append(template.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyPEM, err = encodeKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// append the CA so that the peers can build the chain
+	buf := bytes.NewBuffer(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	_ = pem.Encode(buf, &pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})
+
+	return buf.Bytes(), keyPEM, nil
+}
+
+// URI returns the identity URI of the service, e.g. vine://go.vine.helloworld
+func URI(service string) *url.URL {
+	return &url.URL{Scheme: Scheme, Host: service}
+}
+
+// Identity returns the name of the service in the certificate
+func Identity(cert *x509.Certificate) string {
+	for _, u := range cert.URIs {
+		if u.Scheme == Scheme {
+			return u.Host
+		}
+	}
+	return ""
+}
+
+func serialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+func encodeKey(key *ecdsa.PrivateKey) ([]byte, error) {
+	b, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: b}), nil
+}
+
+func parseKey(der []byte) (crypto.Signer, error) {
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported private key")
+	}
+	return signer, nil
+}
+
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, ErrNoCertificate
+	}
+	return certs, nil
+}
@@ -0,0 +1,202 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package mtls
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIssue(t *testing.T) {
+	ca, err := NewCA("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPEM, keyPEM, err := ca.Issue("go.vine.helloworld", time.Hour, "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs, err := parseCertificates(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cert.Certificate) != 2 || len(certs) != 2 {
+		t.Fatalf("expected the chain of the CA, got %d certificates", len(certs))
+	}
+	if id := Identity(certs[0]); id != "go.vine.helloworld" {
+		t.Fatalf("unexpected identity %s", id)
+	}
+	if d := certs[0].NotAfter.Sub(certs[0].NotBefore); d > time.Hour+time.Minute {
+		t.Fatalf("unexpected lifetime %v", d)
+	}
+}
+
+func TestLoadOrCreateCA(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "ca-key.pem")
+
+	ca, err := LoadOrCreateCA(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadOrCreateCA(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ca.Certificate().Equal(loaded.Certificate()) {
+		t.Fatal("the CA is not loaded from the files")
+	}
+}
+
+func handshake(t *testing.T, server, client *tls.Config) error {
+	l, err := tls.Listen("tcp", "127.0.0.1:0", server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		_ = conn.(*tls.Conn).Handshake()
+		conn.Close()
+	}()
+
+	conn, err := tls.Dial("tcp", l.Addr().String(), client)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// the server verifies the client certificate after the client finished
+	_, err = conn.Read(make([]byte, 1))
+	if err != nil && err.Error() == "EOF" {
+		err = nil
+	}
+	return err
+}
+
+func TestProvider(t *testing.T) {
+	ca, err := NewCA("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, err := NewProvider(Name("go.vine.server"), WithCA(ca))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+	client, err := NewProvider(Name("go.vine.client"), WithCA(ca))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Stop()
+
+	if err := handshake(t, server.ServerConfig(), client.ClientConfig("go.vine.server")); err != nil {
+		t.Fatalf("unexpected handshake error: %v", err)
+	}
+	if err := handshake(t, server.ServerConfig(), client.ClientConfig("go.vine.other")); err == nil {
+		t.Fatal("expected error of the identity")
+	}
+
+	// the client of another CA is refused
+	other, err := NewCA("other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stranger, err := NewProvider(Name("go.vine.client"), WithCA(other))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stranger.Stop()
+	if err := handshake(t, server.ServerConfig(), stranger.ClientConfig("")); err == nil {
+		t.Fatal("expected error of the unknown CA")
+	}
+}
+
+func TestProviderReload(t *testing.T) {
+	dir := t.TempDir()
+	ca, err := NewCA("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	caFile, certFile, keyFile := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	write := func(service string) {
+		certPEM, keyPEM, err := ca.Issue(service, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = ioutil.WriteFile(caFile, ca.Bundle(), 0644)
+		_ = ioutil.WriteFile(certFile, certPEM, 0644)
+		_ = ioutil.WriteFile(keyFile, keyPEM, 0600)
+	}
+
+	write("go.vine.one")
+	p, err := NewProvider(CAFiles(caFile, ""), CertFiles(certFile, keyFile), Interval(time.Millisecond*10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+	if id := Identity(p.Certificate().Leaf); id != "go.vine.one" {
+		t.Fatalf("unexpected identity %s", id)
+	}
+
+	write("go.vine.two")
+	time.Sleep(time.Millisecond * 100)
+	if id := Identity(p.Certificate().Leaf); id != "go.vine.two" {
+		t.Fatalf("the certificate is not reloaded, identity %s", id)
+	}
+}
+
+func TestProviderRenew(t *testing.T) {
+	ca, err := NewCA("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewProvider(Name("go.vine.test"), WithCA(ca), TTL(time.Minute), Interval(time.Millisecond*10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+
+	serial := p.Certificate().Leaf.SerialNumber
+	// the certificate is due to renew
+	p.Lock()
+	p.renew = time.Now()
+	p.Unlock()
+	time.Sleep(time.Millisecond * 100)
+	if p.Certificate().Leaf.SerialNumber.Cmp(serial) == 0 {
+		t.Fatal("the certificate is not renewed")
+	}
+}
@@ -0,0 +1,122 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package mtls
+
+import "time"
+
+var (
+	// DefaultTTL is the lifetime of the issued certificates
+	DefaultTTL = time.Hour * 24
+	// DefaultInterval is the interval of checking the files and
+	// the expiry of the certificate
+	DefaultInterval = time.Second * 30
+)
+
+type Options struct {
+	// Name is the service name of the issued certificate
+	Name string
+	// Hosts are added to the SAN of the issued certificate
+	Hosts []string
+	// CA issues the certificate in memory
+	CA *CA
+	// CAFile is the trust bundle, the CA issues the certificate when
+	// CAKeyFile is set too
+	CAFile    string
+	CAKeyFile string
+	// CertFile and KeyFile is the certificate of the service which
+	// is issued by an external CA
+	CertFile string
+	KeyFile  string
+	// TTL is the lifetime of the issued certificate, it's renewed
+	// after two thirds of it
+	TTL time.Duration
+	// Interval is the interval of reloading the files
+	Interval time.Duration
+}
+
+type Option func(o *Options)
+
+// Name sets the service name of the issued certificate
+func Name(n string) Option {
+	return func(o *Options) {
+		o.Name = n
+	}
+}
+
+// Hosts adds the hosts to the SAN of the issued certificate
+func Hosts(hosts ...string) Option {
+	return func(o *Options) {
+		o.Hosts = append(o.Hosts, hosts...)
+	}
+}
+
+// WithCA issues the certificate by the CA
+func WithCA(ca *CA) Option {
+	return func(o *Options) {
+		o.CA = ca
+	}
+}
+
+// CAFiles sets the CA certificate and key, the key is optional
+// when the certificate is issued by an external CA
+func CAFiles(certFile, keyFile string) Option {
+	return func(o *Options) {
+		o.CAFile = certFile
+		o.CAKeyFile = keyFile
+	}
+}
+
+// CertFiles sets the certificate and key of the service
+func CertFiles(certFile, keyFile string) Option {
+	return func(o *Options) {
+		o.CertFile = certFile
+		o.KeyFile = keyFile
+	}
+}
+
+// TTL sets the lifetime of the issued certificate
+func TTL(d time.Duration) Option {
+	return func(o *Options) {
+		o.TTL = d
+	}
+}
+
+// Interval sets the interval of reloading the files
+func Interval(d time.Duration) Option {
+	return func(o *Options) {
+		o.Interval = d
+	}
+}
+
+func NewOptions(opts ...Option) Options {
+	options := Options{
+		TTL:      DefaultTTL,
+		Interval: DefaultInterval,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
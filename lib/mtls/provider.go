@@ -0,0 +1,277 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package mtls
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	log "github.com/lack-io/vine/lib/logger"
+)
+
+// Provider provides the certificate and the trust bundle of the service,
+// they are reloaded from the files on change and the issued certificate
+// is renewed before it expires. The tls configs of the provider always
+// use the current ones.
+type Provider struct {
+	sync.RWMutex
+	opts Options
+
+	cert *tls.Certificate
+	pool *x509.CertPool
+	// renew is the time to renew the issued certificate
+	renew time.Time
+	// files are the contents of the files last loaded
+	files map[string][]byte
+
+	exit chan bool
+	once sync.Once
+}
+
+// NewProvider loads the certificate and starts reloading it
+func NewProvider(opts ...Option) (*Provider, error) {
+	options := NewOptions(opts...)
+
+	issuer := options.CA != nil || len(options.CAKeyFile) > 0
+	switch {
+	case issuer && len(options.Name) == 0:
+		return nil, errors.New("require the service name to issue certificate")
+	case !issuer && (len(options.CertFile) == 0 || len(options.KeyFile) == 0):
+		return nil, errors.New("require the certificate files or the CA")
+	case !issuer && len(options.CAFile) == 0:
+		return nil, errors.New("require the CA file")
+	}
+
+	p := &Provider{
+		opts: options,
+		exit: make(chan bool),
+	}
+	if err := p.load(); err != nil {
+		return nil, err
+	}
+
+	go p.run()
+
+	return p, nil
+}
+
+func (p *Provider) run() {
+	t := time.NewTicker(p.opts.Interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-p.exit:
+			return
+		case <-t.C:
+			if err := p.load(); err != nil {
+				log.Errorf("mtls: error reloading certificate: %v", err)
+			}
+		}
+	}
+}
+
+// load reads the files and swaps the certificate and the trust bundle
+// when they changed or the issued certificate is due to renew
+func (p *Provider) load() error {
+	files := make(map[string][]byte)
+	for _, name := range []string{p.opts.CAFile, p.opts.CAKeyFile, p.opts.CertFile, p.opts.KeyFile} {
+		if len(name) == 0 {
+			continue
+		}
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		files[name] = b
+	}
+
+	p.RLock()
+	changed := p.cert == nil || !time.Now().Before(p.renew)
+	for name, b := range files {
+		if !bytes.Equal(b, p.files[name]) {
+			changed = true
+		}
+	}
+	p.RUnlock()
+
+	if !changed {
+		return nil
+	}
+
+	var bundle, certPEM, keyPEM []byte
+	ca := p.opts.CA
+	if len(p.opts.CAKeyFile) > 0 {
+		var err error
+		ca, err = ParseCA(files[p.opts.CAFile], files[p.opts.CAKeyFile])
+		if err != nil {
+			return err
+		}
+	}
+
+	if ca != nil {
+		var err error
+		certPEM, keyPEM, err = ca.Issue(p.opts.Name, p.opts.TTL, p.opts.Hosts...)
+		if err != nil {
+			return err
+		}
+		bundle = ca.Bundle()
+	} else {
+		certPEM, keyPEM = files[p.opts.CertFile], files[p.opts.KeyFile]
+		bundle = files[p.opts.CAFile]
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return err
+	}
+	cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(bundle) {
+		return ErrNoCertificate
+	}
+
+	// renew after two thirds of the lifetime, the files are reloaded
+	// by the interval only
+	renew := cert.Leaf.NotAfter
+	if ca != nil {
+		lifetime := cert.Leaf.NotAfter.Sub(cert.Leaf.NotBefore)
+		renew = cert.Leaf.NotBefore.Add(lifetime * 2 / 3)
+	}
+
+	p.Lock()
+	p.cert = &cert
+	p.pool = pool
+	p.renew = renew
+	p.files = files
+	p.Unlock()
+
+	log.Infof("mtls: loaded certificate of %s, expires at %v", Identity(cert.Leaf), cert.Leaf.NotAfter)
+	return nil
+}
+
+// Certificate returns the current certificate
+func (p *Provider) Certificate() *tls.Certificate {
+	p.RLock()
+	defer p.RUnlock()
+	return p.cert
+}
+
+// Pool returns the current trust bundle
+func (p *Provider) Pool() *x509.CertPool {
+	p.RLock()
+	defer p.RUnlock()
+	return p.pool
+}
+
+// ServerConfig returns the tls config of the servers, the clients must
+// present a certificate issued by the CA. It also dials the peers which
+// share the config, e.g. the http broker, without checking their identity.
+func (p *Provider) ServerConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return &tls.Config{
+				MinVersion:            tls.VersionTLS12,
+				Certificates:          []tls.Certificate{*p.Certificate()},
+				NextProtos:            []string{"h2", "http/1.1"},
+				ClientAuth:            tls.RequireAnyClientCert,
+				VerifyPeerCertificate: p.verify(""),
+			}, nil
+		},
+		GetClientCertificate: p.getClientCertificate,
+		// the chain is verified by VerifyPeerCertificate against the
+		// current trust bundle
+		InsecureSkipVerify:    true,
+		VerifyPeerCertificate: p.verify(""),
+	}
+}
+
+// ClientConfig returns the tls config of the clients, the identity of the
+// server must be the service, any identity is accepted when it's empty
+func (p *Provider) ClientConfig(service string) *tls.Config {
+	return &tls.Config{
+		MinVersion:            tls.VersionTLS12,
+		GetClientCertificate:  p.getClientCertificate,
+		InsecureSkipVerify:    true,
+		VerifyPeerCertificate: p.verify(service),
+	}
+}
+
+// Stop stops reloading the certificate
+func (p *Provider) Stop() {
+	p.once.Do(func() {
+		close(p.exit)
+	})
+}
+
+func (p *Provider) getClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return p.Certificate(), nil
+}
+
+// verify verifies the chain of the peer against the trust bundle and the
+// identity of the peer against the service
+func (p *Provider) verify(service string) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return ErrNoCertificate
+		}
+
+		certs := make([]*x509.Certificate, 0, len(rawCerts))
+		for _, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return err
+			}
+			certs = append(certs, cert)
+		}
+
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err := certs[0].Verify(x509.VerifyOptions{
+			Roots:         p.Pool(),
+			Intermediates: intermediates,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		})
+		if err != nil {
+			return err
+		}
+
+		if id := Identity(certs[0]); len(service) > 0 && id != service {
+			return fmt.Errorf("peer identity %q doesn't match service %q", id, service)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,90 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package saga
+
+import (
+	"context"
+
+	"github.com/lack-io/vine/proto/apis/errors"
+	pb "github.com/lack-io/vine/proto/services/saga"
+)
+
+// Handler exposes the status of the sagas over RPC, it's registered by
+// pb.RegisterSagaHandler
+type Handler struct {
+	c *Coordinator
+}
+
+// NewHandler returns the saga handler of coordinator
+func NewHandler(c *Coordinator) *Handler {
+	return &Handler{c: c}
+}
+
+func (h *Handler) Get(ctx context.Context, req *pb.GetRequest, rsp *pb.GetResponse) error {
+	if req.Id == "" {
+		return errors.BadRequest("go.vine.saga", "missing saga id")
+	}
+	st, err := h.c.Get(req.Id)
+	if err == ErrNotFound {
+		return errors.NotFound("go.vine.saga", "saga %s not found", req.Id)
+	}
+	if err != nil {
+		return errors.InternalServerError("go.vine.saga", "%v", err)
+	}
+	rsp.State = toProto(st)
+	return nil
+}
+
+func (h *Handler) List(ctx context.Context, req *pb.ListRequest, rsp *pb.ListResponse) error {
+	states, err := h.c.List(req.Name, Status(req.Status))
+	if err != nil {
+		return errors.InternalServerError("go.vine.saga", "%v", err)
+	}
+	rsp.States = make([]*pb.State, 0, len(states))
+	for _, st := range states {
+		rsp.States = append(rsp.States, toProto(st))
+	}
+	return nil
+}
+
+func toProto(st *State) *pb.State {
+	s := &pb.State{
+		Id:      st.Id,
+		Name:    st.Name,
+		Status:  string(st.Status),
+		Step:    int64(st.Step),
+		Steps:   make([]*pb.Step, 0, len(st.Steps)),
+		Error:   st.Error,
+		Created: st.Created,
+		Updated: st.Updated,
+	}
+	for _, ss := range st.Steps {
+		s.Steps = append(s.Steps, &pb.Step{
+			Name:     ss.Name,
+			Status:   string(ss.Status),
+			Attempts: int64(ss.Attempts),
+			Error:    ss.Error,
+		})
+	}
+	return s
+}
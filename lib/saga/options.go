@@ -0,0 +1,168 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package saga
+
+import (
+	"github.com/lack-io/vine/core/broker"
+	"github.com/lack-io/vine/core/client"
+	"github.com/lack-io/vine/lib/store"
+	"github.com/lack-io/vine/lib/sync"
+	"github.com/lack-io/vine/proto/apis/errors"
+)
+
+const (
+	// DefaultTopic is the topic of the progress events
+	DefaultTopic = "go.vine.saga"
+	// DefaultRetries is the default retries of a step
+	DefaultRetries = 3
+)
+
+type Options struct {
+	// Client calls the steps, defaults to client.DefaultClient
+	Client client.Client
+	// Store persists the state of sagas, defaults to store.DefaultStore
+	Store store.Store
+	// Database the store database of sagas
+	Database string
+	// Table the store table of sagas
+	Table string
+	// Broker the progress events are published to, nil disables the events
+	Broker broker.Broker
+	// Topic the topic of the progress events
+	Topic string
+	// Sync locks the saga while it's executed when the coordinator runs on
+	// several nodes sharing the store
+	Sync sync.Sync
+	// Retries the retries of a step after the first attempt
+	Retries int
+	// Retry reports whether the error of a step is retryable
+	Retry func(err error) bool
+	// ContentType of the step requests
+	ContentType string
+}
+
+func NewOptions(opts ...Option) Options {
+	options := Options{
+		Table:       "saga",
+		Topic:       DefaultTopic,
+		Retries:     DefaultRetries,
+		Retry:       Retryable,
+		ContentType: "application/json",
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	if options.Client == nil {
+		options.Client = client.DefaultClient
+	}
+	if options.Store == nil {
+		options.Store = store.DefaultStore
+	}
+
+	return options
+}
+
+type Option func(*Options)
+
+// Client sets the client which calls the steps
+func Client(c client.Client) Option {
+	return func(o *Options) {
+		o.Client = c
+	}
+}
+
+// Store sets the store which persists the sagas
+func Store(s store.Store) Option {
+	return func(o *Options) {
+		o.Store = s
+	}
+}
+
+// Table sets the store database and table of sagas
+func Table(database, table string) Option {
+	return func(o *Options) {
+		o.Database = database
+		o.Table = table
+	}
+}
+
+// Broker sets the broker which the progress events are published to
+func Broker(b broker.Broker) Option {
+	return func(o *Options) {
+		o.Broker = b
+	}
+}
+
+// Topic sets the topic of the progress events
+func Topic(t string) Option {
+	return func(o *Options) {
+		o.Topic = t
+	}
+}
+
+// Sync sets the sync which locks the running sagas
+func Sync(s sync.Sync) Option {
+	return func(o *Options) {
+		o.Sync = s
+	}
+}
+
+// Retries sets the retries of a step
+func Retries(n int) Option {
+	return func(o *Options) {
+		o.Retries = n
+	}
+}
+
+// Retry sets the function reports whether the error of a step is retryable
+func Retry(fn func(err error) bool) Option {
+	return func(o *Options) {
+		o.Retry = fn
+	}
+}
+
+// ContentType sets the content type of the step requests
+func ContentType(ct string) Option {
+	return func(o *Options) {
+		o.ContentType = ct
+	}
+}
+
+// Retryable retries the errors of transport, timeout and server, the
+// rejections of the services (4xx) are not retried.
+func Retryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	e := errors.FromErr(err)
+	switch {
+	case e == nil, e.Code == 0:
+		return true
+	case e.Code == 408, e.Code == 429, e.Code >= 500:
+		return true
+	default:
+		return false
+	}
+}
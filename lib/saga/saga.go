@@ -0,0 +1,565 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package saga orchestrates the business transactions across services. A saga
+// is a sequence of steps which are client calls, when a step fails the
+// compensations of the completed steps are called in reverse order.
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/lack-io/vine/core/broker"
+	"github.com/lack-io/vine/core/client"
+	"github.com/lack-io/vine/core/codec/bytes"
+	log "github.com/lack-io/vine/lib/logger"
+	"github.com/lack-io/vine/lib/store"
+	"github.com/lack-io/vine/util/backoff"
+	"github.com/lack-io/vine/util/context/metadata"
+)
+
+const (
+	// IdKey is the metadata key of the saga id, it's set on the step calls
+	// and the progress events so that services are able to dedupe the calls
+	IdKey = "Vine-Saga-Id"
+	// StepKey is the metadata key of the step name
+	StepKey = "Vine-Saga-Step"
+	// StatusKey is the header key of the saga status in the progress events
+	StatusKey = "Vine-Saga-Status"
+	// NameKey is the header key of the saga name in the progress events
+	NameKey = "Vine-Saga-Name"
+
+	lockPrefix = "saga/"
+)
+
+var (
+	// ErrNotFound is returned when the saga or the definition doesn't exist
+	ErrNotFound = errors.New("saga not found")
+)
+
+// Status is the status of a saga or a step
+type Status string
+
+const (
+	StatusPending      Status = "pending"
+	StatusRunning      Status = "running"
+	StatusCompleted    Status = "completed"
+	StatusCompensating Status = "compensating"
+	StatusCompensated  Status = "compensated"
+	// StatusFailed marks the step failed, the saga is failed when the
+	// compensation fails and it needs manual intervention
+	StatusFailed Status = "failed"
+)
+
+// Done reports whether the saga is finished
+func (s Status) Done() bool {
+	return s == StatusCompleted || s == StatusCompensated || s == StatusFailed
+}
+
+// Call is a client call of a step
+type Call struct {
+	Service  string
+	Endpoint string
+	// Request builds the request body from the state, the payload of the
+	// saga is sent when it's nil
+	Request func(s *State) ([]byte, error)
+}
+
+// Step is a step of saga
+type Step struct {
+	Name string
+	// Action is called when the saga goes forward
+	Action Call
+	// Compensate undoes the action when a following step fails, the step
+	// has nothing to undo when it's nil
+	Compensate *Call
+	// Retries overrides the retries of coordinator when it's not zero, the
+	// negative value disables the retries
+	Retries int
+}
+
+// Definition defines the steps of a saga, the steps and compensations may be
+// called more than once after a restart so they are expected to be idempotent.
+type Definition struct {
+	Name  string
+	Steps []*Step
+}
+
+func (d *Definition) validate() error {
+	if d.Name == "" {
+		return errors.New("missing saga name")
+	}
+	if len(d.Steps) == 0 {
+		return fmt.Errorf("saga %s has no steps", d.Name)
+	}
+	names := map[string]struct{}{}
+	for _, step := range d.Steps {
+		if _, ok := names[step.Name]; ok || step.Name == "" {
+			return fmt.Errorf("saga %s has invalid step name '%s'", d.Name, step.Name)
+		}
+		names[step.Name] = struct{}{}
+		if step.Action.Service == "" || step.Action.Endpoint == "" {
+			return fmt.Errorf("step %s of saga %s missing action", step.Name, d.Name)
+		}
+		if c := step.Compensate; c != nil && (c.Service == "" || c.Endpoint == "") {
+			return fmt.Errorf("step %s of saga %s has invalid compensation", step.Name, d.Name)
+		}
+	}
+	return nil
+}
+
+// StepState is the state of a step
+type StepState struct {
+	Name     string `json:"name"`
+	Status   Status `json:"status"`
+	Attempts int    `json:"attempts"`
+	// Result is the response body of the action
+	Result []byte `json:"result,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// State is the persisted state of a saga
+type State struct {
+	Id      string       `json:"id"`
+	Name    string       `json:"name"`
+	Status  Status       `json:"status"`
+	Payload []byte       `json:"payload,omitempty"`
+	Step    int          `json:"step"`
+	Steps   []*StepState `json:"steps"`
+	Error   string       `json:"error,omitempty"`
+	Created int64        `json:"created"`
+	Updated int64        `json:"updated"`
+}
+
+// Result returns the response body of the action of the given step
+func (s *State) Result(step string) []byte {
+	for _, ss := range s.Steps {
+		if ss.Name == step {
+			return ss.Result
+		}
+	}
+	return nil
+}
+
+// Coordinator executes and persists the sagas
+type Coordinator struct {
+	sync.RWMutex
+	opts Options
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	defs    map[string]*Definition
+	running map[string]struct{}
+}
+
+// New creates a saga coordinator
+func New(opts ...Option) *Coordinator {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Coordinator{
+		opts:    NewOptions(opts...),
+		ctx:     ctx,
+		cancel:  cancel,
+		defs:    map[string]*Definition{},
+		running: map[string]struct{}{},
+	}
+}
+
+// Options returns the options of coordinator
+func (c *Coordinator) Options() Options {
+	return c.opts
+}
+
+// Register registers the saga definitions
+func (c *Coordinator) Register(defs ...*Definition) error {
+	for _, d := range defs {
+		if err := d.validate(); err != nil {
+			return err
+		}
+	}
+	c.Lock()
+	for _, d := range defs {
+		c.defs[d.Name] = d
+	}
+	c.Unlock()
+	return nil
+}
+
+func (c *Coordinator) create(name string, payload []byte) (*Definition, *State, error) {
+	c.RLock()
+	def, ok := c.defs[name]
+	c.RUnlock()
+	if !ok {
+		return nil, nil, ErrNotFound
+	}
+
+	now := time.Now().UnixNano()
+	st := &State{
+		Id:      uuid.New().String(),
+		Name:    name,
+		Status:  StatusPending,
+		Payload: payload,
+		Steps:   make([]*StepState, len(def.Steps)),
+		Created: now,
+	}
+	for i, step := range def.Steps {
+		st.Steps[i] = &StepState{Name: step.Name, Status: StatusPending}
+	}
+	if err := c.save(st); err != nil {
+		return nil, nil, err
+	}
+	return def, st, nil
+}
+
+// Execute executes the saga until it's finished, the error is returned when
+// the saga isn't completed.
+func (c *Coordinator) Execute(ctx context.Context, name string, payload []byte) (*State, error) {
+	def, st, err := c.create(name, payload)
+	if err != nil {
+		return nil, err
+	}
+	if !c.acquire(st.Id) {
+		return st, nil
+	}
+	defer c.release(st.Id)
+
+	if err := c.run(ctx, def, st); err != nil {
+		return st, err
+	}
+	if st.Status != StatusCompleted {
+		return st, fmt.Errorf("saga %s %s: %s", st.Id, st.Status, st.Error)
+	}
+	return st, nil
+}
+
+// Start persists the saga and executes it in background
+func (c *Coordinator) Start(ctx context.Context, name string, payload []byte) (*State, error) {
+	def, st, err := c.create(name, payload)
+	if err != nil {
+		return nil, err
+	}
+	// the state is changed by the saga in background once it starts
+	cp := st.copy()
+	c.background(ctx, def, st)
+	return cp, nil
+}
+
+// Resume executes the unfinished sagas in the store in background, it's
+// called after the process restarts.
+func (c *Coordinator) Resume(ctx context.Context) error {
+	states, err := c.List("", "")
+	if err != nil {
+		return err
+	}
+	for _, st := range states {
+		if st.Status.Done() {
+			continue
+		}
+		c.RLock()
+		def, ok := c.defs[st.Name]
+		c.RUnlock()
+		if !ok {
+			log.Warnf("[saga]: definition of %s not found, skip %s", st.Name, st.Id)
+			continue
+		}
+		c.background(ctx, def, st)
+	}
+	return nil
+}
+
+// Get returns the state of the saga
+func (c *Coordinator) Get(id string) (*State, error) {
+	recs, err := c.opts.Store.Read(id, store.ReadFrom(c.opts.Database, c.opts.Table))
+	if err == store.ErrNotFound || (err == nil && len(recs) == 0) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	st := &State{}
+	if err := json.Unmarshal(recs[0].Value, st); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// List returns the sagas filtered by name and status, the empty filter matches all
+func (c *Coordinator) List(name string, status Status) ([]*State, error) {
+	keys, err := c.opts.Store.List(store.ListFrom(c.opts.Database, c.opts.Table))
+	if err != nil {
+		return nil, err
+	}
+	states := make([]*State, 0, len(keys))
+	for _, key := range keys {
+		st, err := c.Get(key)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if (name != "" && st.Name != name) || (status != "" && st.Status != status) {
+			continue
+		}
+		states = append(states, st)
+	}
+	return states, nil
+}
+
+// Close stops the sagas running in background and waits for them, the
+// interrupted sagas are resumed by Resume later.
+func (c *Coordinator) Close() error {
+	c.cancel()
+	c.wg.Wait()
+	return nil
+}
+
+func (c *Coordinator) acquire(id string) bool {
+	c.Lock()
+	defer c.Unlock()
+	if _, ok := c.running[id]; ok {
+		return false
+	}
+	c.running[id] = struct{}{}
+	return true
+}
+
+func (c *Coordinator) release(id string) {
+	c.Lock()
+	delete(c.running, id)
+	c.Unlock()
+}
+
+func (c *Coordinator) background(ctx context.Context, def *Definition, st *State) {
+	if !c.acquire(st.Id) {
+		return
+	}
+
+	// keeps the metadata of caller but not its deadline
+	md, _ := metadata.FromContext(ctx)
+	ctx = metadata.NewContext(c.ctx, metadata.Copy(md))
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		defer c.release(st.Id)
+		if err := c.run(ctx, def, st); err != nil && ctx.Err() == nil {
+			log.Errorf("[saga]: %s %s interrupted: %v", st.Name, st.Id, err)
+		}
+	}()
+}
+
+func (c *Coordinator) run(ctx context.Context, def *Definition, st *State) error {
+	if c.opts.Sync != nil {
+		if err := c.opts.Sync.Lock(lockPrefix + st.Id); err != nil {
+			return err
+		}
+		defer c.opts.Sync.Unlock(lockPrefix + st.Id)
+
+		// the saga may be progressed by other nodes before the lock acquired
+		latest, err := c.Get(st.Id)
+		if err != nil {
+			return err
+		}
+		*st = *latest
+	}
+
+	if len(st.Steps) != len(def.Steps) {
+		return fmt.Errorf("saga %s has %d steps, but definition %s has %d", st.Id, len(st.Steps), def.Name, len(def.Steps))
+	}
+
+	for !st.Status.Done() {
+		var err error
+		switch st.Status {
+		case StatusCompensating:
+			err = c.backward(ctx, def, st)
+		default:
+			err = c.forward(ctx, def, st)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forward calls the actions from the current step
+func (c *Coordinator) forward(ctx context.Context, def *Definition, st *State) error {
+	st.Status = StatusRunning
+	for ; st.Step < len(def.Steps); st.Step++ {
+		step, ss := def.Steps[st.Step], st.Steps[st.Step]
+		ss.Status = StatusRunning
+		if err := c.save(st); err != nil {
+			return err
+		}
+
+		result, err := c.call(ctx, st, step, &step.Action)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			ss.Status = StatusFailed
+			ss.Error = err.Error()
+			st.Status = StatusCompensating
+			st.Error = fmt.Sprintf("step %s failed: %v", step.Name, err)
+			return c.save(st)
+		}
+		ss.Status = StatusCompleted
+		ss.Result = result
+		ss.Error = ""
+	}
+
+	st.Step = len(def.Steps) - 1
+	st.Status = StatusCompleted
+	return c.save(st)
+}
+
+// backward calls the compensations of the completed steps in reverse order
+func (c *Coordinator) backward(ctx context.Context, def *Definition, st *State) error {
+	for ; st.Step >= 0; st.Step-- {
+		step, ss := def.Steps[st.Step], st.Steps[st.Step]
+		if ss.Status != StatusCompleted && ss.Status != StatusCompensating {
+			continue
+		}
+		if step.Compensate == nil {
+			ss.Status = StatusCompensated
+			continue
+		}
+
+		ss.Status = StatusCompensating
+		if err := c.save(st); err != nil {
+			return err
+		}
+
+		_, err := c.call(ctx, st, step, step.Compensate)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			ss.Status = StatusFailed
+			ss.Error = err.Error()
+			st.Status = StatusFailed
+			st.Error = fmt.Sprintf("compensation of step %s failed: %v", step.Name, err)
+			return c.save(st)
+		}
+		ss.Status = StatusCompensated
+	}
+
+	st.Step = 0
+	st.Status = StatusCompensated
+	return c.save(st)
+}
+
+// call calls the step with retries
+func (c *Coordinator) call(ctx context.Context, st *State, step *Step, call *Call) ([]byte, error) {
+	body := st.Payload
+	if call.Request != nil {
+		b, err := call.Request(st)
+		if err != nil {
+			return nil, err
+		}
+		body = b
+	}
+
+	retries := step.Retries
+	if retries == 0 {
+		retries = c.opts.Retries
+	}
+	if retries < 0 {
+		retries = 0
+	}
+
+	ctx = metadata.Set(ctx, IdKey, st.Id)
+	ctx = metadata.Set(ctx, StepKey, step.Name)
+
+	ss := st.Steps[st.Step]
+	var err error
+	for i := 0; i <= retries; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(backoff.Do(i)):
+			}
+		}
+
+		ss.Attempts++
+		req := c.opts.Client.NewRequest(call.Service, call.Endpoint, &bytes.Frame{Data: body}, client.WithContentType(c.opts.ContentType))
+		rsp := &bytes.Frame{}
+		if err = c.opts.Client.Call(ctx, req, rsp); err == nil {
+			return rsp.Data, nil
+		}
+		if ctx.Err() != nil || !c.opts.Retry(err) {
+			return nil, err
+		}
+		log.Warnf("[saga]: step %s of %s failed, attempt %d: %v", step.Name, st.Id, ss.Attempts, err)
+	}
+	return nil, err
+}
+
+// save persists the state and publishes the progress event
+func (c *Coordinator) save(st *State) error {
+	st.Updated = time.Now().UnixNano()
+	b, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	rec := &store.Record{Key: st.Id, Value: b}
+	if err := c.opts.Store.Write(rec, store.WriteTo(c.opts.Database, c.opts.Table)); err != nil {
+		return err
+	}
+
+	if c.opts.Broker == nil {
+		return nil
+	}
+	msg := &broker.Message{
+		Header: map[string]string{
+			IdKey:     st.Id,
+			NameKey:   st.Name,
+			StatusKey: string(st.Status),
+			StepKey:   st.Steps[st.Step].Name,
+		},
+		Body: b,
+	}
+	if err := c.opts.Broker.Publish(c.opts.Topic, msg); err != nil {
+		log.Warnf("[saga]: failed to publish the progress of %s: %v", st.Id, err)
+	}
+	return nil
+}
+
+func (s *State) copy() *State {
+	st := *s
+	st.Steps = make([]*StepState, len(s.Steps))
+	for i, ss := range s.Steps {
+		v := *ss
+		st.Steps[i] = &v
+	}
+	return &st
+}
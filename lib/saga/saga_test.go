@@ -0,0 +1,415 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lack-io/vine/core/broker"
+	"github.com/lack-io/vine/core/broker/memory"
+	"github.com/lack-io/vine/core/client"
+	"github.com/lack-io/vine/core/codec/bytes"
+	"github.com/lack-io/vine/lib/store"
+	smemory "github.com/lack-io/vine/lib/store/memory"
+	"github.com/lack-io/vine/proto/apis/errors"
+	pb "github.com/lack-io/vine/proto/services/saga"
+	"github.com/lack-io/vine/util/context/metadata"
+)
+
+type testRequest struct {
+	client.Request
+	service  string
+	endpoint string
+	body     interface{}
+}
+
+func (r *testRequest) Service() string   { return r.service }
+func (r *testRequest) Endpoint() string  { return r.endpoint }
+func (r *testRequest) Body() interface{} { return r.body }
+
+type testClient struct {
+	client.Client
+	sync.Mutex
+	calls    []string
+	handlers map[string]func(ctx context.Context, body []byte) ([]byte, error)
+}
+
+func newClient() *testClient {
+	return &testClient{handlers: map[string]func(context.Context, []byte) ([]byte, error){}}
+}
+
+func (c *testClient) handle(endpoint string, fn func(ctx context.Context, body []byte) ([]byte, error)) {
+	c.handlers[endpoint] = fn
+}
+
+func (c *testClient) NewRequest(service, endpoint string, req interface{}, opts ...client.RequestOption) client.Request {
+	return &testRequest{service: service, endpoint: endpoint, body: req}
+}
+
+func (c *testClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	c.Lock()
+	c.calls = append(c.calls, req.Endpoint())
+	c.Unlock()
+
+	fn, ok := c.handlers[req.Endpoint()]
+	if !ok {
+		return errors.NotFound("test", "%s not found", req.Endpoint())
+	}
+	b, err := fn(ctx, req.Body().(*bytes.Frame).Data)
+	if err != nil {
+		return err
+	}
+	rsp.(*bytes.Frame).Data = b
+	return nil
+}
+
+func (c *testClient) Calls() []string {
+	c.Lock()
+	defer c.Unlock()
+	return append([]string{}, c.calls...)
+}
+
+func ok(ctx context.Context, body []byte) ([]byte, error) {
+	return body, nil
+}
+
+func order() *Definition {
+	return &Definition{
+		Name: "order",
+		Steps: []*Step{
+			{
+				Name:       "reserve",
+				Action:     Call{Service: "stock", Endpoint: "Stock.Reserve"},
+				Compensate: &Call{Service: "stock", Endpoint: "Stock.Release"},
+			},
+			{
+				Name: "charge",
+				Action: Call{Service: "payment", Endpoint: "Payment.Charge", Request: func(s *State) ([]byte, error) {
+					return s.Result("reserve"), nil
+				}},
+				Compensate: &Call{Service: "payment", Endpoint: "Payment.Refund"},
+			},
+			{
+				Name:   "ship",
+				Action: Call{Service: "shipping", Endpoint: "Shipping.Ship"},
+			},
+		},
+	}
+}
+
+func TestExecute(t *testing.T) {
+	c := newClient()
+	c.handle("Stock.Reserve", func(ctx context.Context, body []byte) ([]byte, error) {
+		if id, _ := metadata.Get(ctx, IdKey); id == "" {
+			t.Fatal("missing saga id")
+		}
+		return []byte("reserved"), nil
+	})
+	c.handle("Payment.Charge", func(ctx context.Context, body []byte) ([]byte, error) {
+		if string(body) != "reserved" {
+			t.Fatalf("unexpected body %s", body)
+		}
+		return []byte("charged"), nil
+	})
+	c.handle("Shipping.Ship", ok)
+
+	b := memory.NewBroker()
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var events []string
+	_, err := b.Subscribe(DefaultTopic, func(e broker.Event) error {
+		mu.Lock()
+		events = append(events, e.Message().Header[StepKey]+":"+e.Message().Header[StatusKey])
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	co := New(Client(c), Store(smemory.NewStore()), Broker(b))
+	if err := co.Register(order()); err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := co.Execute(context.TODO(), "order", []byte("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Status != StatusCompleted {
+		t.Fatalf("expect completed, got %s", st.Status)
+	}
+	if string(st.Result("charge")) != "charged" {
+		t.Fatalf("unexpected result %s", st.Result("charge"))
+	}
+
+	saved, err := co.Get(st.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Status != StatusCompleted || saved.Steps[2].Status != StatusCompleted {
+		t.Fatalf("unexpected saved state %+v", saved)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) == 0 || events[len(events)-1] != "ship:completed" {
+		t.Fatalf("unexpected events %v", events)
+	}
+
+	if _, err := co.Execute(context.TODO(), "missing", nil); err != ErrNotFound {
+		t.Fatalf("expect not found, got %v", err)
+	}
+}
+
+func TestCompensate(t *testing.T) {
+	c := newClient()
+	c.handle("Stock.Reserve", ok)
+	c.handle("Stock.Release", ok)
+	c.handle("Payment.Charge", ok)
+	c.handle("Payment.Refund", ok)
+	c.handle("Shipping.Ship", func(ctx context.Context, body []byte) ([]byte, error) {
+		return nil, errors.BadRequest("shipping", "no address")
+	})
+
+	co := New(Client(c), Store(smemory.NewStore()))
+	if err := co.Register(order()); err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := co.Execute(context.TODO(), "order", nil)
+	if err == nil {
+		t.Fatal("expect error")
+	}
+	if st.Status != StatusCompensated {
+		t.Fatalf("expect compensated, got %s", st.Status)
+	}
+	expect := "Stock.Reserve,Payment.Charge,Shipping.Ship,Payment.Refund,Stock.Release"
+	if calls := strings.Join(c.Calls(), ","); calls != expect {
+		t.Fatalf("expect calls %s, got %s", expect, calls)
+	}
+	if st.Steps[2].Status != StatusFailed || st.Steps[2].Attempts != 1 {
+		t.Fatalf("unexpected failed step %+v", st.Steps[2])
+	}
+}
+
+func TestCompensationFailed(t *testing.T) {
+	c := newClient()
+	c.handle("Stock.Reserve", ok)
+	c.handle("Payment.Charge", func(ctx context.Context, body []byte) ([]byte, error) {
+		return nil, errors.Forbidden("payment", "insufficient balance")
+	})
+
+	co := New(Client(c), Store(smemory.NewStore()), Retries(-1))
+	if err := co.Register(order()); err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := co.Execute(context.TODO(), "order", nil)
+	if err == nil {
+		t.Fatal("expect error")
+	}
+	if st.Status != StatusFailed || st.Steps[0].Status != StatusFailed {
+		t.Fatalf("unexpected state %+v", st)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	c := newClient()
+	var mu sync.Mutex
+	attempts := 0
+	c.handle("Stock.Reserve", func(ctx context.Context, body []byte) ([]byte, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if attempts == 1 {
+			return nil, errors.ServiceUnavailable("stock", "unavailable")
+		}
+		return nil, nil
+	})
+	c.handle("Payment.Charge", ok)
+	c.handle("Shipping.Ship", ok)
+
+	co := New(Client(c), Store(smemory.NewStore()), Retries(1))
+	if err := co.Register(order()); err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := co.Execute(context.TODO(), "order", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Steps[0].Attempts != 2 {
+		t.Fatalf("expect 2 attempts, got %d", st.Steps[0].Attempts)
+	}
+}
+
+func TestStart(t *testing.T) {
+	c := newClient()
+	c.handle("Stock.Reserve", ok)
+	c.handle("Payment.Charge", ok)
+	c.handle("Shipping.Ship", ok)
+
+	co := New(Client(c), Store(smemory.NewStore()))
+	defer co.Close()
+	if err := co.Register(order()); err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := co.Start(context.TODO(), "order", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the returned state isn't changed by the saga in background
+	if st.Status != StatusPending || len(st.Id) == 0 {
+		t.Fatalf("unexpected state %+v", st)
+	}
+
+	deadline := time.Now().Add(time.Second * 5)
+	for {
+		saved, err := co.Get(st.Id)
+		if err != nil && err != ErrNotFound {
+			t.Fatal(err)
+		}
+		if saved != nil && saved.Status.Done() {
+			if saved.Status != StatusCompleted {
+				t.Fatalf("expect completed, got %s", saved.Status)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("saga isn't completed")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	if _, err := co.Start(context.TODO(), "missing", nil); err != ErrNotFound {
+		t.Fatalf("expect not found, got %v", err)
+	}
+}
+
+func TestResume(t *testing.T) {
+	s := smemory.NewStore()
+	c := newClient()
+	c.handle("Payment.Charge", ok)
+	c.handle("Shipping.Ship", ok)
+
+	// the process crashed while charging
+	st := &State{
+		Id:     "1",
+		Name:   "order",
+		Status: StatusRunning,
+		Step:   1,
+		Steps: []*StepState{
+			{Name: "reserve", Status: StatusCompleted, Attempts: 1},
+			{Name: "charge", Status: StatusRunning, Attempts: 1},
+			{Name: "ship", Status: StatusPending},
+		},
+	}
+	b, _ := json.Marshal(st)
+	if err := s.Write(&store.Record{Key: "1", Value: b}, store.WriteTo("", "saga")); err != nil {
+		t.Fatal(err)
+	}
+
+	co := New(Client(c), Store(s))
+	defer co.Close()
+	if err := co.Register(order()); err != nil {
+		t.Fatal(err)
+	}
+	if err := co.Resume(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(time.Second * 5)
+	for {
+		st, err := co.Get("1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st.Status.Done() {
+			if st.Status != StatusCompleted {
+				t.Fatalf("expect completed, got %s", st.Status)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("saga isn't resumed")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	if calls := strings.Join(c.Calls(), ","); calls != "Payment.Charge,Shipping.Ship" {
+		t.Fatalf("unexpected calls %s", calls)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	c := newClient()
+	c.handle("Stock.Reserve", ok)
+	c.handle("Payment.Charge", ok)
+	c.handle("Shipping.Ship", ok)
+
+	co := New(Client(c), Store(smemory.NewStore()))
+	if err := co.Register(order()); err != nil {
+		t.Fatal(err)
+	}
+	st, err := co.Execute(context.TODO(), "order", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewHandler(co)
+	rsp := &pb.GetResponse{}
+	if err := h.Get(context.TODO(), &pb.GetRequest{Id: st.Id}, rsp); err != nil {
+		t.Fatal(err)
+	}
+	if rsp.State.Status != string(StatusCompleted) || len(rsp.State.Steps) != 3 {
+		t.Fatalf("unexpected state %v", rsp.State)
+	}
+
+	err = h.Get(context.TODO(), &pb.GetRequest{Id: "missing"}, &pb.GetResponse{})
+	if e := errors.FromErr(err); e.Code != 404 {
+		t.Fatalf("expect not found, got %v", err)
+	}
+
+	list := &pb.ListResponse{}
+	if err := h.List(context.TODO(), &pb.ListRequest{Status: string(StatusRunning)}, list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.States) != 0 {
+		t.Fatalf("expect no running sagas, got %d", len(list.States))
+	}
+	if err := h.List(context.TODO(), &pb.ListRequest{Name: "order"}, list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.States) != 1 {
+		t.Fatalf("expect 1 saga, got %d", len(list.States))
+	}
+}
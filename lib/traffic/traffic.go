@@ -0,0 +1,228 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package traffic provides declarative traffic policies, the weighted
+// version splits and header based routes of services
+package traffic
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"sync"
+
+	"github.com/lack-io/vine/lib/config"
+	"github.com/lack-io/vine/lib/config/reader"
+	log "github.com/lack-io/vine/lib/logger"
+	regpb "github.com/lack-io/vine/proto/apis/registry"
+	"github.com/lack-io/vine/util/context/metadata"
+)
+
+var (
+	// DefaultPath is the path of the policies in the config
+	DefaultPath = []string{"traffic"}
+	// VersionHeader records the version chosen for the request
+	VersionHeader = "Vine-Version"
+	// Any matches every value of a header which is present
+	Any = "*"
+)
+
+// Policy is the traffic policy of a service. The routes are matched in
+// order and the first match wins, otherwise the request is split by weight.
+type Policy struct {
+	Routes []*Route `json:"routes"`
+	Splits []*Split `json:"splits"`
+	// Sticky is the header whose value pins a request to a split,
+	// e.g. a user id, requests without it are split randomly
+	Sticky string `json:"sticky"`
+}
+
+// Route sends the requests which have all the headers to a version
+type Route struct {
+	Match   map[string]string `json:"match"`
+	Version string            `json:"version"`
+}
+
+// Split is the relative weight of a version
+type Split struct {
+	Version string `json:"version"`
+	Weight  int    `json:"weight"`
+}
+
+// Traffic holds the policies keyed by service name
+type Traffic struct {
+	sync.RWMutex
+	policies map[string]*Policy
+
+	exit chan bool
+	once sync.Once
+}
+
+func (r *Route) match(md metadata.Metadata) bool {
+	for k, v := range r.Match {
+		val, ok := md.Get(k)
+		if !ok || (v != Any && v != val) {
+			return false
+		}
+	}
+	return true
+}
+
+func has(services []*regpb.Service, version string) bool {
+	for _, s := range services {
+		if s.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// version chooses the version of the request among the given services
+func (p *Policy) version(md metadata.Metadata, services []*regpb.Service) string {
+	for _, r := range p.Routes {
+		if r.match(md) && has(services, r.Version) {
+			return r.Version
+		}
+	}
+
+	// only split to the versions which are running
+	var splits []*Split
+	var total int
+	for _, s := range p.Splits {
+		if s.Weight > 0 && has(services, s.Version) {
+			splits = append(splits, s)
+			total += s.Weight
+		}
+	}
+	if total == 0 {
+		return ""
+	}
+
+	var n int
+	if val, ok := md.Get(p.Sticky); ok && len(p.Sticky) > 0 {
+		h := fnv.New32a()
+		h.Write([]byte(val))
+		n = int(h.Sum32() % uint32(total))
+	} else {
+		n = rand.Intn(total)
+	}
+	for _, s := range splits {
+		if n < s.Weight {
+			return s.Version
+		}
+		n -= s.Weight
+	}
+	return ""
+}
+
+// Update replaces the policies
+func (t *Traffic) Update(policies map[string]*Policy) {
+	t.Lock()
+	t.policies = policies
+	t.Unlock()
+}
+
+// Policy returns the policy of the service
+func (t *Traffic) Policy(service string) (*Policy, bool) {
+	t.RLock()
+	defer t.RUnlock()
+	p, ok := t.policies[service]
+	return p, ok
+}
+
+// Select chooses the version of the request by the metadata and returns the
+// services of that version. The services are returned as is when there is no
+// policy or none of the versions of the policy are running.
+func (t *Traffic) Select(service string, md metadata.Metadata, services []*regpb.Service) (string, []*regpb.Service) {
+	p, ok := t.Policy(service)
+	if !ok || len(services) == 0 {
+		return "", services
+	}
+
+	if md == nil {
+		md = metadata.Metadata{}
+	}
+	version := p.version(md, services)
+	if len(version) == 0 {
+		return "", services
+	}
+
+	var selected []*regpb.Service
+	for _, s := range services {
+		if s.Version == version {
+			selected = append(selected, s)
+		}
+	}
+	return version, selected
+}
+
+// Stop stops watching the config
+func (t *Traffic) Stop() {
+	t.once.Do(func() {
+		close(t.exit)
+	})
+}
+
+func (t *Traffic) load(c config.Config, path []string) error {
+	policies := map[string]*Policy{}
+	if err := c.Get(path...).Scan(&policies); err != nil {
+		return err
+	}
+	t.Update(policies)
+	return nil
+}
+
+func (t *Traffic) watch(c config.Config, path []string) {
+	config.WatchFunc(c, t.exit, func(v reader.Value) {
+		policies := map[string]*Policy{}
+		if err := v.Scan(&policies); err != nil {
+			log.Errorf("traffic policy error: %v", err)
+			return
+		}
+		t.Update(policies)
+		log.Debugf("traffic policies updated")
+	}, path...)
+}
+
+// New returns the traffic with fixed policies
+func New(policies map[string]*Policy) *Traffic {
+	if policies == nil {
+		policies = map[string]*Policy{}
+	}
+	return &Traffic{
+		policies: policies,
+		exit:     make(chan bool),
+	}
+}
+
+// Load reads the policies at the path of the config and reloads them on
+// every change until the traffic is stopped, DefaultPath is used by default
+func Load(c config.Config, path ...string) (*Traffic, error) {
+	if len(path) == 0 {
+		path = DefaultPath
+	}
+	t := New(nil)
+	if err := t.load(c, path); err != nil {
+		return nil, err
+	}
+	go t.watch(c, path)
+	return t, nil
+}
@@ -0,0 +1,194 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package traffic
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lack-io/vine/lib/config"
+	"github.com/lack-io/vine/lib/config/reader"
+	regpb "github.com/lack-io/vine/proto/apis/registry"
+	"github.com/lack-io/vine/util/context/metadata"
+)
+
+func testServices(versions ...string) []*regpb.Service {
+	var services []*regpb.Service
+	for _, v := range versions {
+		services = append(services, &regpb.Service{Name: "foo", Version: v})
+	}
+	return services
+}
+
+func TestSelectRoute(t *testing.T) {
+	tr := New(map[string]*Policy{
+		"foo": {
+			Routes: []*Route{
+				{Match: map[string]string{"X-Canary": "true"}, Version: "v2"},
+				{Match: map[string]string{"X-Beta": Any}, Version: "v3"},
+			},
+			Splits: []*Split{{Version: "v1", Weight: 100}},
+		},
+	})
+	services := testServices("v1", "v2", "v3")
+
+	testData := []struct {
+		md      metadata.Metadata
+		version string
+	}{
+		{metadata.Metadata{"x-canary": "true"}, "v2"},
+		{metadata.Metadata{"x-canary": "false"}, "v1"},
+		{metadata.Metadata{"x-beta": "anything"}, "v3"},
+		{nil, "v1"},
+	}
+
+	for _, d := range testData {
+		version, selected := tr.Select("foo", d.md, services)
+		if version != d.version {
+			t.Fatalf("expected version %s got %s", d.version, version)
+		}
+		if len(selected) != 1 || selected[0].Version != d.version {
+			t.Fatalf("expected the services of %s got %v", d.version, selected)
+		}
+	}
+
+	// no policy
+	version, selected := tr.Select("bar", nil, services)
+	if len(version) > 0 || len(selected) != len(services) {
+		t.Fatalf("expected all services without a policy, got %s %v", version, selected)
+	}
+
+	// the routed version isn't running
+	version, _ = tr.Select("foo", metadata.Metadata{"x-canary": "true"}, testServices("v1"))
+	if version != "v1" {
+		t.Fatalf("expected v1 when v2 isn't running, got %s", version)
+	}
+}
+
+func TestSelectSplit(t *testing.T) {
+	tr := New(map[string]*Policy{
+		"foo": {
+			Splits: []*Split{{Version: "v1", Weight: 90}, {Version: "v2", Weight: 10}},
+			Sticky: "X-User",
+		},
+	})
+	services := testServices("v1", "v2")
+
+	counts := map[string]int{}
+	for i := 0; i < 10000; i++ {
+		version, _ := tr.Select("foo", nil, services)
+		counts[version]++
+	}
+	if counts["v2"] < 700 || counts["v2"] > 1300 {
+		t.Fatalf("expected about 10%% of the traffic to v2, got %v", counts)
+	}
+
+	// sticky requests always get the same version
+	md := metadata.Metadata{"x-user": "alice"}
+	first, _ := tr.Select("foo", md, services)
+	for i := 0; i < 100; i++ {
+		if version, _ := tr.Select("foo", md, services); version != first {
+			t.Fatalf("expected sticky version %s got %s", first, version)
+		}
+	}
+
+	// only running versions get traffic
+	for i := 0; i < 100; i++ {
+		if version, _ := tr.Select("foo", nil, testServices("v2")); version != "v2" {
+			t.Fatalf("expected v2 got %s", version)
+		}
+	}
+}
+
+type testValue struct {
+	reader.Value
+	data string
+}
+
+func (v *testValue) Scan(val interface{}) error {
+	return json.Unmarshal([]byte(v.data), val)
+}
+
+type testWatcher struct {
+	updates chan reader.Value
+	exit    chan bool
+}
+
+func (w *testWatcher) Next() (reader.Value, error) {
+	select {
+	case v := <-w.updates:
+		return v, nil
+	case <-w.exit:
+		return nil, errors.New("watcher stopped")
+	}
+}
+
+func (w *testWatcher) Stop() error {
+	close(w.exit)
+	return nil
+}
+
+type testConfig struct {
+	config.Config
+	value   reader.Value
+	updates chan reader.Value
+}
+
+func (c *testConfig) Get(path ...string) reader.Value {
+	return c.value
+}
+
+func (c *testConfig) Watch(path ...string) (config.Watcher, error) {
+	return &testWatcher{updates: c.updates, exit: make(chan bool)}, nil
+}
+
+func TestLoad(t *testing.T) {
+	c := &testConfig{
+		value:   &testValue{data: `{"foo": {"splits": [{"version": "v1", "weight": 1}]}}`},
+		updates: make(chan reader.Value),
+	}
+
+	tr, err := Load(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Stop()
+
+	services := testServices("v1", "v2")
+	if version, _ := tr.Select("foo", nil, services); version != "v1" {
+		t.Fatalf("expected v1 got %s", version)
+	}
+
+	c.updates <- &testValue{data: `{"foo": {"splits": [{"version": "v2", "weight": 1}]}}`}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if version, _ := tr.Select("foo", nil, services); version == "v2" {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("expected the policies to be reloaded")
+}
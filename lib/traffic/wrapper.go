@@ -0,0 +1,63 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package traffic
+
+import (
+	"context"
+
+	"github.com/lack-io/vine/core/client"
+	"github.com/lack-io/vine/core/client/selector"
+	regpb "github.com/lack-io/vine/proto/apis/registry"
+	"github.com/lack-io/vine/util/context/metadata"
+)
+
+type trafficWrapper struct {
+	client.Client
+
+	t *Traffic
+}
+
+func (w *trafficWrapper) option(ctx context.Context, req client.Request) client.CallOption {
+	md, _ := metadata.FromContext(ctx)
+	service := req.Service()
+	return client.WithSelectOption(selector.WithFilter(func(services []*regpb.Service) []*regpb.Service {
+		_, services = w.t.Select(service, md, services)
+		return services
+	}))
+}
+
+func (w *trafficWrapper) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	return w.Client.Call(ctx, req, rsp, append(opts, w.option(ctx, req))...)
+}
+
+func (w *trafficWrapper) Stream(ctx context.Context, req client.Request, opts ...client.CallOption) (client.Stream, error) {
+	return w.Client.Stream(ctx, req, append(opts, w.option(ctx, req))...)
+}
+
+// NewClientWrapper returns a client wrapper which selects the nodes of the
+// version chosen by the traffic policies for every request
+func NewClientWrapper(t *Traffic) client.Wrapper {
+	return func(c client.Client) client.Client {
+		return &trafficWrapper{Client: c, t: t}
+	}
+}
@@ -37,6 +37,7 @@ import (
 	"github.com/lack-io/vine/lib/cmd"
 	"github.com/lack-io/vine/lib/config"
 	"github.com/lack-io/vine/lib/dao"
+	"github.com/lack-io/vine/lib/job"
 	"github.com/lack-io/vine/lib/trace"
 )
 
@@ -51,6 +52,19 @@ type Options struct {
 	Dialect   dao.Dialect
 	Registry  registry.Registry
 	Scheduler gscheduler.Scheduler
+	// Jobs schedules the persisted jobs across the nodes of the service,
+	// they are managed by the job handler registered on the server
+	Jobs *job.Scheduler
+
+	// Debug registers the debug handler on the server, it's disabled by
+	// default. The handler serves the profiles and the logs of the process
+	// to any caller of the service
+	Debug bool
+
+	// DebugAddress is the address of the http debug endpoints,
+	// they are disabled when it's empty. The endpoints aren't
+	// authenticated, bind it to localhost or a private interface only
+	DebugAddress string
 
 	// Before and After funcs
 	BeforeStart []func() error
@@ -181,6 +195,41 @@ func Address(addr string) Option {
 	}
 }
 
+// Debug toggles the debug handler on the server which is queried by the
+// vine debug command. Like DebugAddress the handler isn't authenticated,
+// enable it only when the callers of the service are trusted
+func Debug(b bool) Option {
+	return func(o *Options) {
+		o.Debug = b
+	}
+}
+
+// DebugAddress serves the debug endpoints over http on the address, e.g.
+// "127.0.0.1:6060". The endpoints expose the profiles and the logs of the
+// process without authentication so never bind it to a public interface
+func DebugAddress(addr string) Option {
+	return func(o *Options) {
+		o.DebugAddress = addr
+	}
+}
+
+// Jobs sets the job scheduler of the service, it's started with the service
+// and shares the jobs with the other nodes of the service
+func Jobs(s *job.Scheduler) Option {
+	return func(o *Options) {
+		o.Jobs = s
+	}
+}
+
+// Drain sets the lame duck phase of the shutdown, the service advertises
+// the draining and waits delay before refusing the new requests, then the
+// in-flight requests and messages are waited for at most timeout
+func Drain(delay, timeout time.Duration) Option {
+	return func(o *Options) {
+		_ = o.Server.Init(server.Drain(delay, timeout))
+	}
+}
+
 // Name of the service
 func Name(n string) Option {
 	return func(o *Options) {
@@ -135,6 +135,16 @@ func Conflict(id, format string, a ...interface{}) *Error {
 	return New(id, fmt.Sprintf(format, a...), 409)
 }
 
+// Canceled generates a 499 error, the request is cancelled by the client.
+func Canceled(id, format string, a ...interface{}) *Error {
+	return &Error{
+		Id:     id,
+		Code:   499,
+		Detail: fmt.Sprintf(format, a...),
+		Status: "Client Closed Request",
+	}
+}
+
 // InternalServerError generates a 500 error.
 func InternalServerError(id, format string, a ...interface{}) *Error {
 	return New(id, fmt.Sprintf(format, a...), 500)
@@ -0,0 +1,191 @@
+// Code generated by proto-gen-vine. DO NOT EDIT.
+// source: github.com/lack-io/vine/proto/services/debug/debug.proto
+
+package debug
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/gogo/protobuf/proto"
+	client "github.com/lack-io/vine/core/client"
+	server "github.com/lack-io/vine/core/server"
+	apipb "github.com/lack-io/vine/proto/apis/api"
+	_ "github.com/lack-io/vine/proto/apis/registry"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.GoGoProtoPackageIsVersion3 // please upgrade the proto package
+
+// API Endpoints for Debug service
+func NewDebugEndpoints() []*apipb.Endpoint {
+	return []*apipb.Endpoint{}
+}
+
+// Client API for Debug service
+type DebugService interface {
+	Logs(ctx context.Context, in *LogRequest, opts ...client.CallOption) (Debug_LogsService, error)
+	Info(ctx context.Context, in *InfoRequest, opts ...client.CallOption) (*InfoResponse, error)
+	Profile(ctx context.Context, in *ProfileRequest, opts ...client.CallOption) (*ProfileResponse, error)
+}
+
+type debugService struct {
+	c    client.Client
+	name string
+}
+
+func NewDebugService(name string, c client.Client) DebugService {
+	return &debugService{
+		c:    c,
+		name: name,
+	}
+}
+
+func (c *debugService) Logs(ctx context.Context, in *LogRequest, opts ...client.CallOption) (Debug_LogsService, error) {
+	req := c.c.NewRequest(c.name, "Debug.Logs", &LogRequest{})
+	stream, err := c.c.Stream(ctx, req, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.Send(in); err != nil {
+		return nil, err
+	}
+	return &debugServiceLogs{stream}, nil
+}
+
+type Debug_LogsService interface {
+	Context() context.Context
+	SendMsg(interface{}) error
+	RecvMsg(interface{}) error
+	Close() error
+	Recv() (*Record, error)
+}
+
+type debugServiceLogs struct {
+	stream client.Stream
+}
+
+func (x *debugServiceLogs) Close() error {
+	return x.stream.Close()
+}
+
+func (x *debugServiceLogs) Context() context.Context {
+	return x.stream.Context()
+}
+
+func (x *debugServiceLogs) SendMsg(m interface{}) error {
+	return x.stream.Send(m)
+}
+
+func (x *debugServiceLogs) RecvMsg(m interface{}) error {
+	return x.stream.Recv(m)
+}
+
+func (x *debugServiceLogs) Recv() (*Record, error) {
+	m := new(Record)
+	err := x.stream.Recv(m)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *debugService) Info(ctx context.Context, in *InfoRequest, opts ...client.CallOption) (*InfoResponse, error) {
+	req := c.c.NewRequest(c.name, "Debug.Info", in)
+	out := new(InfoResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *debugService) Profile(ctx context.Context, in *ProfileRequest, opts ...client.CallOption) (*ProfileResponse, error) {
+	req := c.c.NewRequest(c.name, "Debug.Profile", in)
+	out := new(ProfileResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for Debug service
+type DebugHandler interface {
+	Logs(context.Context, *LogRequest, Debug_LogsStream) error
+	Info(context.Context, *InfoRequest, *InfoResponse) error
+	Profile(context.Context, *ProfileRequest, *ProfileResponse) error
+}
+
+func RegisterDebugHandler(s server.Server, hdlr DebugHandler, opts ...server.HandlerOption) error {
+	type debugImpl interface {
+		Logs(ctx context.Context, stream server.Stream) error
+		Info(ctx context.Context, in *InfoRequest, out *InfoResponse) error
+		Profile(ctx context.Context, in *ProfileRequest, out *ProfileResponse) error
+	}
+	type Debug struct {
+		debugImpl
+	}
+	h := &debugHandler{hdlr}
+	return s.Handle(s.NewHandler(&Debug{h}, opts...))
+}
+
+type debugHandler struct {
+	DebugHandler
+}
+
+func (h *debugHandler) Logs(ctx context.Context, stream server.Stream) error {
+	m := new(LogRequest)
+	if err := stream.Recv(m); err != nil {
+		return err
+	}
+	return h.DebugHandler.Logs(ctx, m, &debugLogsStream{stream})
+}
+
+type Debug_LogsStream interface {
+	Context() context.Context
+	SendMsg(interface{}) error
+	RecvMsg(interface{}) error
+	Close() error
+	Send(*Record) error
+}
+
+type debugLogsStream struct {
+	stream server.Stream
+}
+
+func (x *debugLogsStream) Close() error {
+	return x.stream.Close()
+}
+
+func (x *debugLogsStream) Context() context.Context {
+	return x.stream.Context()
+}
+
+func (x *debugLogsStream) SendMsg(m interface{}) error {
+	return x.stream.Send(m)
+}
+
+func (x *debugLogsStream) RecvMsg(m interface{}) error {
+	return x.stream.Recv(m)
+}
+
+func (x *debugLogsStream) Send(m *Record) error {
+	return x.stream.Send(m)
+}
+
+func (h *debugHandler) Info(ctx context.Context, in *InfoRequest, out *InfoResponse) error {
+	return h.DebugHandler.Info(ctx, in, out)
+}
+
+func (h *debugHandler) Profile(ctx context.Context, in *ProfileRequest, out *ProfileResponse) error {
+	return h.DebugHandler.Profile(ctx, in, out)
+}
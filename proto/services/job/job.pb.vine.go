@@ -0,0 +1,183 @@
+// Code generated by proto-gen-vine. DO NOT EDIT.
+// source: github.com/lack-io/vine/proto/services/job/job.proto
+
+package job
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/gogo/protobuf/proto"
+	client "github.com/lack-io/vine/core/client"
+	server "github.com/lack-io/vine/core/server"
+	apipb "github.com/lack-io/vine/proto/apis/api"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.GoGoProtoPackageIsVersion3 // please upgrade the proto package
+
+// API Endpoints for Job service
+func NewJobEndpoints() []*apipb.Endpoint {
+	return []*apipb.Endpoint{}
+}
+
+// Client API for Job service
+type JobService interface {
+	List(ctx context.Context, in *ListRequest, opts ...client.CallOption) (*ListResponse, error)
+	Add(ctx context.Context, in *AddRequest, opts ...client.CallOption) (*AddResponse, error)
+	Remove(ctx context.Context, in *RemoveRequest, opts ...client.CallOption) (*RemoveResponse, error)
+	Pause(ctx context.Context, in *PauseRequest, opts ...client.CallOption) (*PauseResponse, error)
+	Resume(ctx context.Context, in *ResumeRequest, opts ...client.CallOption) (*ResumeResponse, error)
+	Trigger(ctx context.Context, in *TriggerRequest, opts ...client.CallOption) (*TriggerResponse, error)
+	History(ctx context.Context, in *HistoryRequest, opts ...client.CallOption) (*HistoryResponse, error)
+}
+
+type jobService struct {
+	c    client.Client
+	name string
+}
+
+func NewJobService(name string, c client.Client) JobService {
+	return &jobService{
+		c:    c,
+		name: name,
+	}
+}
+
+func (c *jobService) List(ctx context.Context, in *ListRequest, opts ...client.CallOption) (*ListResponse, error) {
+	req := c.c.NewRequest(c.name, "Job.List", in)
+	out := new(ListResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobService) Add(ctx context.Context, in *AddRequest, opts ...client.CallOption) (*AddResponse, error) {
+	req := c.c.NewRequest(c.name, "Job.Add", in)
+	out := new(AddResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobService) Remove(ctx context.Context, in *RemoveRequest, opts ...client.CallOption) (*RemoveResponse, error) {
+	req := c.c.NewRequest(c.name, "Job.Remove", in)
+	out := new(RemoveResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobService) Pause(ctx context.Context, in *PauseRequest, opts ...client.CallOption) (*PauseResponse, error) {
+	req := c.c.NewRequest(c.name, "Job.Pause", in)
+	out := new(PauseResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobService) Resume(ctx context.Context, in *ResumeRequest, opts ...client.CallOption) (*ResumeResponse, error) {
+	req := c.c.NewRequest(c.name, "Job.Resume", in)
+	out := new(ResumeResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobService) Trigger(ctx context.Context, in *TriggerRequest, opts ...client.CallOption) (*TriggerResponse, error) {
+	req := c.c.NewRequest(c.name, "Job.Trigger", in)
+	out := new(TriggerResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobService) History(ctx context.Context, in *HistoryRequest, opts ...client.CallOption) (*HistoryResponse, error) {
+	req := c.c.NewRequest(c.name, "Job.History", in)
+	out := new(HistoryResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for Job service
+type JobHandler interface {
+	List(context.Context, *ListRequest, *ListResponse) error
+	Add(context.Context, *AddRequest, *AddResponse) error
+	Remove(context.Context, *RemoveRequest, *RemoveResponse) error
+	Pause(context.Context, *PauseRequest, *PauseResponse) error
+	Resume(context.Context, *ResumeRequest, *ResumeResponse) error
+	Trigger(context.Context, *TriggerRequest, *TriggerResponse) error
+	History(context.Context, *HistoryRequest, *HistoryResponse) error
+}
+
+func RegisterJobHandler(s server.Server, hdlr JobHandler, opts ...server.HandlerOption) error {
+	type jobImpl interface {
+		List(ctx context.Context, in *ListRequest, out *ListResponse) error
+		Add(ctx context.Context, in *AddRequest, out *AddResponse) error
+		Remove(ctx context.Context, in *RemoveRequest, out *RemoveResponse) error
+		Pause(ctx context.Context, in *PauseRequest, out *PauseResponse) error
+		Resume(ctx context.Context, in *ResumeRequest, out *ResumeResponse) error
+		Trigger(ctx context.Context, in *TriggerRequest, out *TriggerResponse) error
+		History(ctx context.Context, in *HistoryRequest, out *HistoryResponse) error
+	}
+	type Job struct {
+		jobImpl
+	}
+	h := &jobHandler{hdlr}
+	return s.Handle(s.NewHandler(&Job{h}, opts...))
+}
+
+type jobHandler struct {
+	JobHandler
+}
+
+func (h *jobHandler) List(ctx context.Context, in *ListRequest, out *ListResponse) error {
+	return h.JobHandler.List(ctx, in, out)
+}
+
+func (h *jobHandler) Add(ctx context.Context, in *AddRequest, out *AddResponse) error {
+	return h.JobHandler.Add(ctx, in, out)
+}
+
+func (h *jobHandler) Remove(ctx context.Context, in *RemoveRequest, out *RemoveResponse) error {
+	return h.JobHandler.Remove(ctx, in, out)
+}
+
+func (h *jobHandler) Pause(ctx context.Context, in *PauseRequest, out *PauseResponse) error {
+	return h.JobHandler.Pause(ctx, in, out)
+}
+
+func (h *jobHandler) Resume(ctx context.Context, in *ResumeRequest, out *ResumeResponse) error {
+	return h.JobHandler.Resume(ctx, in, out)
+}
+
+func (h *jobHandler) Trigger(ctx context.Context, in *TriggerRequest, out *TriggerResponse) error {
+	return h.JobHandler.Trigger(ctx, in, out)
+}
+
+func (h *jobHandler) History(ctx context.Context, in *HistoryRequest, out *HistoryResponse) error {
+	return h.JobHandler.History(ctx, in, out)
+}
@@ -0,0 +1,98 @@
+// Code generated by proto-gen-vine. DO NOT EDIT.
+// source: github.com/lack-io/vine/proto/services/saga/saga.proto
+
+package saga
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/gogo/protobuf/proto"
+	client "github.com/lack-io/vine/core/client"
+	server "github.com/lack-io/vine/core/server"
+	apipb "github.com/lack-io/vine/proto/apis/api"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.GoGoProtoPackageIsVersion3 // please upgrade the proto package
+
+// API Endpoints for Saga service
+func NewSagaEndpoints() []*apipb.Endpoint {
+	return []*apipb.Endpoint{}
+}
+
+// Client API for Saga service
+type SagaService interface {
+	Get(ctx context.Context, in *GetRequest, opts ...client.CallOption) (*GetResponse, error)
+	List(ctx context.Context, in *ListRequest, opts ...client.CallOption) (*ListResponse, error)
+}
+
+type sagaService struct {
+	c    client.Client
+	name string
+}
+
+func NewSagaService(name string, c client.Client) SagaService {
+	return &sagaService{
+		c:    c,
+		name: name,
+	}
+}
+
+func (c *sagaService) Get(ctx context.Context, in *GetRequest, opts ...client.CallOption) (*GetResponse, error) {
+	req := c.c.NewRequest(c.name, "Saga.Get", in)
+	out := new(GetResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sagaService) List(ctx context.Context, in *ListRequest, opts ...client.CallOption) (*ListResponse, error) {
+	req := c.c.NewRequest(c.name, "Saga.List", in)
+	out := new(ListResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for Saga service
+type SagaHandler interface {
+	Get(context.Context, *GetRequest, *GetResponse) error
+	List(context.Context, *ListRequest, *ListResponse) error
+}
+
+func RegisterSagaHandler(s server.Server, hdlr SagaHandler, opts ...server.HandlerOption) error {
+	type sagaImpl interface {
+		Get(ctx context.Context, in *GetRequest, out *GetResponse) error
+		List(ctx context.Context, in *ListRequest, out *ListResponse) error
+	}
+	type Saga struct {
+		sagaImpl
+	}
+	h := &sagaHandler{hdlr}
+	return s.Handle(s.NewHandler(&Saga{h}, opts...))
+}
+
+type sagaHandler struct {
+	SagaHandler
+}
+
+func (h *sagaHandler) Get(ctx context.Context, in *GetRequest, out *GetResponse) error {
+	return h.SagaHandler.Get(ctx, in, out)
+}
+
+func (h *sagaHandler) List(ctx context.Context, in *ListRequest, out *ListResponse) error {
+	return h.SagaHandler.List(ctx, in, out)
+}
@@ -25,6 +25,9 @@ package vine
 
 import "github.com/lack-io/gscheduler"
 
+// defaultScheduler fires the jobs in process, every node of a service runs
+// them and they are lost on restart. See lib/job and the Jobs option for the
+// jobs persisted and shared by the nodes.
 var defaultScheduler gscheduler.Scheduler
 
 func init() {
@@ -23,6 +23,9 @@
 package vine
 
 import (
+	"context"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"sync"
@@ -30,8 +33,12 @@ import (
 	"github.com/lack-io/vine/core/client"
 	"github.com/lack-io/vine/core/server"
 	"github.com/lack-io/vine/lib/cmd"
+	"github.com/lack-io/vine/lib/debug/handler"
+	"github.com/lack-io/vine/lib/job"
 	"github.com/lack-io/vine/lib/logger"
 	"github.com/lack-io/vine/lib/trace"
+	debugpb "github.com/lack-io/vine/proto/services/debug"
+	jobpb "github.com/lack-io/vine/proto/services/job"
 	signalutil "github.com/lack-io/vine/util/signal"
 	"github.com/lack-io/vine/util/wrapper"
 )
@@ -52,10 +59,12 @@ func newService(opts ...Option) Service {
 	// wrap client to inject From-Service header on any calls
 	options.Client = wrapper.FromService(serviceName, options.Client)
 	options.Client = wrapper.TraceCall(serviceName, trace.DefaultTracer, options.Client)
+	options.Client = wrapper.LogCall(options.Client)
 
 	// wrap the server to provided handler stats
 	_ = options.Server.Init(
 		server.WrapHandler(wrapper.TraceHandler(trace.DefaultTracer)),
+		server.WrapHandler(wrapper.LogHandler()),
 	)
 
 	// set opts
@@ -164,10 +173,53 @@ func (s *service) Run() error {
 	logger.Infof("Starting [service] %s", s.Name())
 	logger.Infof("service [version] %s", s.Options().Server.Options().Version)
 
+	// register the debug handler
+	dh := handler.NewHandler(handler.Server(s.opts.Server), handler.Client(s.opts.Client))
+	if s.opts.Debug {
+		if err := debugpb.RegisterDebugHandler(s.opts.Server, dh, server.InternalHandler(true)); err != nil {
+			return err
+		}
+	}
+
+	// register the job handler
+	if s.opts.Jobs != nil {
+		opts := []job.Option{job.Node(s.opts.Server.Options().Id)}
+		if len(s.opts.Jobs.Options().Name) == 0 {
+			opts = append(opts, job.Name(s.Name()))
+		}
+		if err := s.opts.Jobs.Init(opts...); err != nil {
+			return err
+		}
+		if err := jobpb.RegisterJobHandler(s.opts.Server, job.NewHandler(s.opts.Jobs), server.InternalHandler(true)); err != nil {
+			return err
+		}
+	}
+
 	if err := s.Start(); err != nil {
 		return err
 	}
 
+	if s.opts.Jobs != nil {
+		if err := s.opts.Jobs.Start(); err != nil {
+			return err
+		}
+	}
+
+	if len(s.opts.DebugAddress) > 0 {
+		l, err := net.Listen("tcp", s.opts.DebugAddress)
+		if err != nil {
+			return err
+		}
+		logger.Infof("Debug [http] Listening on %s", l.Addr().String())
+		hs := &http.Server{Handler: dh.HTTPHandler()}
+		go func() {
+			if err := hs.Serve(l); err != nil && err != http.ErrServerClosed {
+				logger.Errorf("debug http server: %v", err)
+			}
+		}()
+		defer hs.Shutdown(context.Background())
+	}
+
 	ch := make(chan os.Signal, 1)
 	if s.opts.Signal {
 		signal.Notify(ch, signalutil.Shutdown()...)
@@ -180,6 +232,11 @@ func (s *service) Run() error {
 	case <-s.opts.Context.Done():
 	}
 
+	// stop firing the jobs before the service stops
+	if s.opts.Jobs != nil {
+		_ = s.opts.Jobs.Stop()
+	}
+
 	return s.Stop()
 }
 
@@ -0,0 +1,241 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package compress provides the compression algorithms negotiated by rpc and broker
+package compress
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	Gzip   = "gzip"
+	Zstd   = "zstd"
+	Snappy = "snappy"
+)
+
+// Compressor compresses and decompresses streams
+type Compressor interface {
+	// Writer returns a writer compressing data written to w,
+	// the writer must be closed to flush the data
+	Writer(w io.Writer) (io.WriteCloser, error)
+	// Reader returns a reader decompressing data read from r
+	Reader(r io.Reader) (io.Reader, error)
+	// Name of the algorithm
+	Name() string
+}
+
+// maxZstdMemory caps the memory of the zstd decoder which decodes the whole
+// frame before the limit of Decompress applies
+const maxZstdMemory = 1 << 30
+
+var (
+	// DefaultMaxSize is the limit of the decompressed data of Decompress,
+	// it's the default max message size of the servers
+	DefaultMaxSize = 1024 * 1024 * 100
+
+	// ErrTooLarge is returned when the decompressed data exceeds the limit
+	ErrTooLarge = errors.New("decompressed data exceeds the limit")
+)
+
+var (
+	mu          sync.RWMutex
+	compressors = map[string]Compressor{}
+)
+
+func init() {
+	Register(gzipCompressor{})
+	Register(newZstdCompressor())
+	Register(snappyCompressor{})
+}
+
+// Register registers the compressor, replaces the one has the same name
+func Register(c Compressor) {
+	mu.Lock()
+	compressors[c.Name()] = c
+	mu.Unlock()
+}
+
+// Get returns the compressor by name
+func Get(name string) (Compressor, bool) {
+	mu.RLock()
+	c, ok := compressors[name]
+	mu.RUnlock()
+	return c, ok
+}
+
+// Names returns the sorted names of registered compressors
+func Names() []string {
+	mu.RLock()
+	names := make([]string, 0, len(compressors))
+	for name := range compressors {
+		names = append(names, name)
+	}
+	mu.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+// Supports reports whether the comma separated list contains the name
+func Supports(list, name string) bool {
+	for _, item := range strings.Split(list, ",") {
+		if strings.TrimSpace(item) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Compress compresses data by the named algorithm
+func Compress(name string, data []byte) ([]byte, error) {
+	c, ok := Get(name)
+	if !ok {
+		return nil, fmt.Errorf("unsupported compressor: %s", name)
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, len(data)/2))
+	w, err := c.Writer(buf)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(data); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decompress decompresses data by the named algorithm, the decompressed
+// data is limited to DefaultMaxSize
+func Decompress(name string, data []byte) ([]byte, error) {
+	return DecompressLimit(name, data, DefaultMaxSize)
+}
+
+// DecompressLimit decompresses data by the named algorithm, ErrTooLarge is
+// returned when the decompressed data exceeds limit bytes
+func DecompressLimit(name string, data []byte, limit int) ([]byte, error) {
+	c, ok := Get(name)
+	if !ok {
+		return nil, fmt.Errorf("unsupported compressor: %s", name)
+	}
+	r, err := c.Reader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	// read one more byte to find out the data over the limit
+	b, err := ioutil.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > limit {
+		return nil, ErrTooLarge
+	}
+	return b, nil
+}
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) Writer(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriter(w), nil
+}
+
+func (gzipCompressor) Reader(r io.Reader) (io.Reader, error) {
+	return gzip.NewReader(r)
+}
+
+func (gzipCompressor) Name() string {
+	return Gzip
+}
+
+type snappyCompressor struct{}
+
+func (snappyCompressor) Writer(w io.Writer) (io.WriteCloser, error) {
+	return snappy.NewBufferedWriter(w), nil
+}
+
+func (snappyCompressor) Reader(r io.Reader) (io.Reader, error) {
+	return snappy.NewReader(r), nil
+}
+
+func (snappyCompressor) Name() string {
+	return Snappy
+}
+
+// zstdCompressor shares a single encoder and decoder, both are
+// safe for concurrent use by EncodeAll and DecodeAll.
+type zstdCompressor struct {
+	enc *zstd.Encoder
+	dec *zstd.Decoder
+}
+
+func newZstdCompressor() *zstdCompressor {
+	enc, _ := zstd.NewWriter(nil)
+	dec, _ := zstd.NewReader(nil, zstd.WithDecoderMaxMemory(maxZstdMemory))
+	return &zstdCompressor{enc: enc, dec: dec}
+}
+
+func (z *zstdCompressor) Writer(w io.Writer) (io.WriteCloser, error) {
+	return &zstdWriter{enc: z.enc, w: w}, nil
+}
+
+func (z *zstdCompressor) Reader(r io.Reader) (io.Reader, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	out, err := z.dec.DecodeAll(b, nil)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(out), nil
+}
+
+func (z *zstdCompressor) Name() string {
+	return Zstd
+}
+
+type zstdWriter struct {
+	enc *zstd.Encoder
+	w   io.Writer
+	buf bytes.Buffer
+}
+
+func (z *zstdWriter) Write(p []byte) (int, error) {
+	return z.buf.Write(p)
+}
+
+func (z *zstdWriter) Close() error {
+	_, err := z.w.Write(z.enc.EncodeAll(z.buf.Bytes(), nil))
+	return err
+}
@@ -0,0 +1,79 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package compress
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+	data := bytes.Repeat([]byte("vine compress "), 1024)
+
+	for _, name := range Names() {
+		b, err := Compress(name, data)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(b) >= len(data) {
+			t.Fatalf("%s: expect compressed data, got %d bytes", name, len(b))
+		}
+		out, err := Decompress(name, b)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.Equal(out, data) {
+			t.Fatalf("%s: data mismatch", name)
+		}
+	}
+
+	if _, err := Compress("unknown", data); err == nil {
+		t.Fatal("expect unsupported compressor error")
+	}
+}
+
+func TestDecompressLimit(t *testing.T) {
+	data := bytes.Repeat([]byte("vine compress "), 1024)
+
+	for _, name := range Names() {
+		b, err := Compress(name, data)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if _, err := DecompressLimit(name, b, len(data)); err != nil {
+			t.Fatalf("%s: expect the data within the limit, got %v", name, err)
+		}
+		if _, err := DecompressLimit(name, b, len(data)-1); err != ErrTooLarge {
+			t.Fatalf("%s: expect ErrTooLarge, got %v", name, err)
+		}
+	}
+}
+
+func TestSupports(t *testing.T) {
+	if !Supports("gzip, snappy,zstd", "snappy") {
+		t.Fatal("expect snappy supported")
+	}
+	if Supports("gzip", "zstd") {
+		t.Fatal("expect zstd unsupported")
+	}
+}
@@ -0,0 +1,87 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package locality describes the region and zone of nodes
+package locality
+
+import "os"
+
+const (
+	// RegionKey is the node metadata key of region
+	RegionKey = "region"
+	// ZoneKey is the node metadata key of zone
+	ZoneKey = "zone"
+
+	// RegionEnv is the environment variable of the region of process
+	RegionEnv = "VINE_REGION"
+	// ZoneEnv is the environment variable of the zone of process
+	ZoneEnv = "VINE_ZONE"
+)
+
+// Distance is the locality distance between nodes
+type Distance int
+
+const (
+	// SameZone the nodes are in the same zone
+	SameZone Distance = iota
+	// SameRegion the nodes are in the different zones of the same region
+	SameRegion
+	// Remote the nodes are in the different regions, or the locality is unknown
+	Remote
+)
+
+// Region returns the region of process
+func Region() string {
+	return os.Getenv(RegionEnv)
+}
+
+// Zone returns the zone of process
+func Zone() string {
+	return os.Getenv(ZoneEnv)
+}
+
+// Populate sets the region and zone of process to the metadata unless
+// they are set already
+func Populate(md map[string]string) {
+	if _, ok := md[RegionKey]; !ok {
+		if region := Region(); len(region) > 0 {
+			md[RegionKey] = region
+		}
+	}
+	if _, ok := md[ZoneKey]; !ok {
+		if zone := Zone(); len(zone) > 0 {
+			md[ZoneKey] = zone
+		}
+	}
+}
+
+// Between returns the distance between the locality and the node metadata
+func Between(region, zone string, md map[string]string) Distance {
+	r, z := md[RegionKey], md[ZoneKey]
+	if len(zone) > 0 && zone == z && (len(region) == 0 || len(r) == 0 || region == r) {
+		return SameZone
+	}
+	if len(region) > 0 && region == r {
+		return SameRegion
+	}
+	return Remote
+}
@@ -0,0 +1,62 @@
+// MIT License
+//
+// Copyright (c) 2020 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package locality
+
+import (
+	"os"
+	"testing"
+)
+
+func TestBetween(t *testing.T) {
+	testData := []struct {
+		region string
+		zone   string
+		md     map[string]string
+		d      Distance
+	}{
+		{"r1", "z1", map[string]string{RegionKey: "r1", ZoneKey: "z1"}, SameZone},
+		{"", "z1", map[string]string{ZoneKey: "z1"}, SameZone},
+		{"r1", "z1", map[string]string{RegionKey: "r1", ZoneKey: "z2"}, SameRegion},
+		{"r1", "z1", map[string]string{RegionKey: "r2", ZoneKey: "z1"}, Remote},
+		{"r1", "z1", nil, Remote},
+	}
+
+	for _, d := range testData {
+		if v := Between(d.region, d.zone, d.md); v != d.d {
+			t.Fatalf("%s/%s %v: expect %d, got %d", d.region, d.zone, d.md, d.d, v)
+		}
+	}
+}
+
+func TestPopulate(t *testing.T) {
+	os.Setenv(RegionEnv, "r1")
+	os.Setenv(ZoneEnv, "z1")
+	defer os.Unsetenv(RegionEnv)
+	defer os.Unsetenv(ZoneEnv)
+
+	md := map[string]string{ZoneKey: "z2"}
+	Populate(md)
+	if md[RegionKey] != "r1" || md[ZoneKey] != "z2" {
+		t.Fatalf("unexpected metadata %v", md)
+	}
+}
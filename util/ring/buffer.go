@@ -37,12 +37,17 @@ type Buffer struct {
 	sync.RWMutex
 	vals    []*Entry
 	streams map[string]*Stream
+	// seq is the sequence of the last entry
+	seq uint64
 }
 
 // Entry is ring buffer data entry
 type Entry struct {
 	Value     interface{}
 	Timestamp time.Time
+	// Sequence is the position of the entry in the buffer, it increases
+	// by one with every entry put
+	Sequence uint64
 }
 
 // Stream is used to stream the buffer
@@ -53,6 +58,19 @@ type Stream struct {
 	Entries chan *Entry
 	// Stop channel
 	Stop chan bool
+	// Drop the entries rather than block the writer when the stream is slow
+	Drop bool
+}
+
+// StreamOption sets the options of a stream
+type StreamOption func(*Stream)
+
+// Drop makes Put drop the entries of the stream when its channel is full
+// rather than block until they are read
+func Drop() StreamOption {
+	return func(s *Stream) {
+		s.Drop = true
+	}
 }
 
 // Put adds a new value to ring buffer
@@ -61,9 +79,11 @@ func (b *Buffer) Put(v interface{}) {
 	defer b.Unlock()
 
 	// append to values
+	b.seq++
 	entry := &Entry{
 		Value:     v,
 		Timestamp: time.Now(),
+		Sequence:  b.seq,
 	}
 	b.vals = append(b.vals, entry)
 
@@ -74,6 +94,17 @@ func (b *Buffer) Put(v interface{}) {
 
 	// send to every stream
 	for _, stream := range b.streams {
+		if stream.Drop {
+			select {
+			case <-stream.Stop:
+				delete(b.streams, stream.Id)
+				close(stream.Entries)
+			case stream.Entries <- entry:
+			default:
+			}
+			continue
+		}
+
 		select {
 		case <-stream.Stop:
 			delete(b.streams, stream.Id)
@@ -130,7 +161,7 @@ func (b *Buffer) Since(t time.Time) []*Entry {
 
 // Stream logs from the buffer
 // Close the channel when you want to stop
-func (b *Buffer) Stream() (<-chan *Entry, chan bool) {
+func (b *Buffer) Stream(opts ...StreamOption) (<-chan *Entry, chan bool) {
 	b.Lock()
 	defer b.Unlock()
 
@@ -138,11 +169,15 @@ func (b *Buffer) Stream() (<-chan *Entry, chan bool) {
 	id := uuid.New().String()
 	stop := make(chan bool)
 
-	b.streams[id] = &Stream{
+	stream := &Stream{
 		Id:      id,
 		Entries: entries,
 		Stop:    stop,
 	}
+	for _, o := range opts {
+		o(stream)
+	}
+	b.streams[id] = stream
 
 	return entries, stop
 }
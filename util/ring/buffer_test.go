@@ -99,3 +99,54 @@ func TestBuffer(t *testing.T) {
 		t.Fatalf("expected value 100 got %v", v[0])
 	}
 }
+
+func TestStreamDrop(t *testing.T) {
+	b := New(10)
+
+	entries, stop := b.Stream(Drop())
+	defer close(stop)
+
+	// the writer must not block on the full stream
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 200; i++ {
+			b.Put(i)
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("put blocked on a slow stream")
+	}
+
+	if n := len(entries); n != 128 {
+		t.Fatalf("expected 128 buffered entries got %d", n)
+	}
+}
+
+func TestStreamBlock(t *testing.T) {
+	b := New(10)
+
+	entries, stop := b.Stream()
+	defer close(stop)
+
+	go func() {
+		for i := 0; i < 200; i++ {
+			b.Put(i)
+		}
+	}()
+
+	// every entry is delivered to the stream
+	for i := 0; i < 200; i++ {
+		select {
+		case e := <-entries:
+			if v := e.Value.(int); v != i {
+				t.Fatalf("expected %d got %d", i, v)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("missing entry %d", i)
+		}
+	}
+}
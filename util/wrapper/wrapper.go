@@ -28,6 +28,7 @@ import (
 
 	"github.com/lack-io/vine/core/client"
 	"github.com/lack-io/vine/core/server"
+	"github.com/lack-io/vine/lib/logger"
 	"github.com/lack-io/vine/lib/trace"
 	"github.com/lack-io/vine/util/context/metadata"
 )
@@ -131,6 +132,57 @@ func TraceHandler(t trace.Tracer) server.HandlerWrapper {
 	}
 }
 
+type logWrapper struct {
+	client.Client
+}
+
+func (l *logWrapper) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	ctx = logger.ContextWithFields(ctx, map[string]interface{}{
+		"call": req.Service() + "." + req.Endpoint(),
+	})
+	err := l.Client.Call(ctx, req, rsp, opts...)
+	if err != nil {
+		logger.HelperFromContext(ctx).Debugf("call failed: %v", err)
+	}
+	return err
+}
+
+func (l *logWrapper) Stream(ctx context.Context, req client.Request, opts ...client.CallOption) (client.Stream, error) {
+	ctx = logger.ContextWithFields(ctx, map[string]interface{}{
+		"call": req.Service() + "." + req.Endpoint(),
+	})
+	return l.Client.Stream(ctx, req, opts...)
+}
+
+// LogCall wraps a client to carry the service and endpoint of the calls in
+// the context logger, the failed calls are logged at debug level
+func LogCall(c client.Client) client.Client {
+	return &logWrapper{Client: c}
+}
+
+// LogHandler wraps a server handler to carry the request fields in the
+// context logger, the records of logger.HelperFromContext then have the service,
+// endpoint, caller and remote address of the request
+func LogHandler() server.HandlerWrapper {
+	return func(h server.HandlerFunc) server.HandlerFunc {
+		return func(ctx context.Context, req server.Request, rsp interface{}) error {
+			fields := map[string]interface{}{
+				logger.ServiceKey:  req.Service(),
+				logger.EndpointKey: req.Endpoint(),
+			}
+			if md, ok := metadata.FromContext(ctx); ok {
+				if caller, ok := md.Get(HeaderPrefix + "From-Service"); ok {
+					fields[logger.CallerKey] = caller
+				}
+				if remote, ok := md.Get("Remote"); ok {
+					fields[logger.RemoteKey] = remote
+				}
+			}
+			return h(logger.ContextWithFields(ctx, fields), req, rsp)
+		}
+	}
+}
+
 type staticClient struct {
 	address string
 	client.Client